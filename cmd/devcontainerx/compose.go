@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/devcontainers"
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/output"
+)
+
+func createComposeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compose",
+		Short: "work with compose-based dev containers",
+		Long:  "Work with dev containers that use dockerComposeFile in devcontainer.json",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Usage()
+		},
+	}
+	cmd.AddCommand(createComposeServicesCommand())
+	cmd.AddCommand(createComposeUpCommand())
+	cmd.AddCommand(createComposeDownCommand())
+	return cmd
+}
+
+// getComposeProjectFromArgs returns the compose project for the path in args (defaulting to the current directory)
+// The commands using this limit args with cobra.MaximumNArgs(1)
+func getComposeProjectFromArgs(args []string) (*devcontainers.ComposeProject, error) {
+	path := "." // default to current directory
+	if len(args) == 1 {
+		path = args[0]
+	}
+	return devcontainers.GetComposeProjectForFolder(path)
+}
+
+// composeServiceColumns are the table columns for ComposeService output
+var composeServiceColumns = []output.Column{
+	{Header: "SERVICE", Value: func(item interface{}) string {
+		service := item.(devcontainers.ComposeService)
+		if service.Primary {
+			return service.Name + " (dev container)"
+		}
+		return service.Name
+	}},
+	{Header: "CONTAINER NAME", Value: func(item interface{}) string { return item.(devcontainers.ComposeService).ContainerName }},
+	{Header: "STATUS", Value: func(item interface{}) string { return item.(devcontainers.ComposeService).Status }},
+	{Header: "CONTAINER ID", Wide: true, Value: func(item interface{}) string { return item.(devcontainers.ComposeService).ContainerID }},
+}
+
+func createComposeServicesCommand() *cobra.Command {
+	var servicesOutput outputFlags
+	cmd := &cobra.Command{
+		Use:   "services [<path>]",
+		Args:  cobra.MaximumNArgs(1),
+		Short: "list services in the compose project",
+		Long:  "List the services in the compose project for the dev container in the specified path (defaults to the current directory) along with their status",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			project, err := getComposeProjectFromArgs(args)
+			if err != nil {
+				return err
+			}
+			services, err := devcontainers.ListComposeServices(project)
+			if err != nil {
+				return err
+			}
+			// default to table output
+			return output.Write(os.Stdout, servicesOutput.options(), services, composeServiceColumns)
+		},
+	}
+	servicesOutput.addFlags(cmd)
+	return cmd
+}
+
+func createComposeUpCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "up [<path>]",
+		Args:  cobra.MaximumNArgs(1),
+		Short: "start the compose project",
+		Long:  "Start the compose project for the dev container in the specified path (defaults to the current directory)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			project, err := getComposeProjectFromArgs(args)
+			if err != nil {
+				return err
+			}
+			containerID, err := devcontainers.ComposeUp(project, nil, os.Stdout)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Dev container running: %s\n", containerID)
+			return nil
+		},
+	}
+	return cmd
+}
+
+func createComposeDownCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "down [<path>]",
+		Args:  cobra.MaximumNArgs(1),
+		Short: "stop and remove the compose project",
+		Long:  "Stop and remove the containers in the compose project for the dev container in the specified path (defaults to the current directory)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			project, err := getComposeProjectFromArgs(args)
+			if err != nil {
+				return err
+			}
+			return devcontainers.ComposeDown(project, os.Stdout)
+		},
+	}
+	return cmd
+}
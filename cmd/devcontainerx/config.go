@@ -3,9 +3,12 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
 
 	"github.com/spf13/cobra"
 	"github.com/stuartleeks/devcontainer-cli/internal/pkg/config"
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/output"
 )
 
 func createConfigCommand() *cobra.Command {
@@ -16,13 +19,37 @@ func createConfigCommand() *cobra.Command {
 	cmd.AddCommand(createConfigWriteCommand())
 	return cmd
 }
+
+// configSetting is a single config value for table output
+type configSetting struct {
+	Key   string
+	Value interface{}
+}
+
+var configColumns = []output.Column{
+	{Header: "KEY", Value: func(item interface{}) string { return item.(configSetting).Key }},
+	{Header: "VALUE", Value: func(item interface{}) string { return fmt.Sprintf("%v", item.(configSetting).Value) }},
+}
+
 func createConfigShowCommand() *cobra.Command {
+	var showOutput outputFlags
 	cmd := &cobra.Command{
 		Use:   "show",
 		Short: "show the current config",
 		Long:  "load the current config and print it out",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			c := config.GetAll()
+			outputOptions := showOutput.options()
+			if !outputOptions.IsDefault() {
+				if format, err := outputOptions.Resolve(); err == nil && (format == output.FormatTable || format == output.FormatWide) {
+					settings := []configSetting{}
+					for _, key := range sortedConfigKeys(c) {
+						settings = append(settings, configSetting{Key: key, Value: c[key]})
+					}
+					return output.Write(os.Stdout, outputOptions, settings, configColumns)
+				}
+				return output.Write(os.Stdout, outputOptions, c, configColumns)
+			}
 			jsonConfig, err := json.MarshalIndent(c, "", "  ")
 			if err != nil {
 				return fmt.Errorf("Error converting to JSON: %s\n", err)
@@ -31,8 +58,18 @@ func createConfigShowCommand() *cobra.Command {
 			return nil
 		},
 	}
+	showOutput.addFlags(cmd)
 	return cmd
 }
+
+func sortedConfigKeys(c map[string]interface{}) []string {
+	keys := []string{}
+	for key := range c {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
 func createConfigWriteCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "write",
@@ -5,46 +5,68 @@ import (
 	"fmt"
 	"os"
 	"sort"
-	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 	"github.com/stuartleeks/devcontainer-cli/internal/pkg/devcontainers"
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/output"
 )
 
+// devcontainerColumns are the table columns for DevcontainerInfo output
+var devcontainerColumns = []output.Column{
+	{Header: "DEVCONTAINER NAME", Value: func(item interface{}) string { return item.(devcontainers.DevcontainerInfo).DevcontainerName }},
+	{Header: "CONTAINER NAME", Value: func(item interface{}) string { return item.(devcontainers.DevcontainerInfo).ContainerName }},
+	{Header: "STATUS", Value: func(item interface{}) string { return item.(devcontainers.DevcontainerInfo).Status }},
+	{Header: "CREATED", Wide: true, Value: func(item interface{}) string {
+		created := item.(devcontainers.DevcontainerInfo).Created
+		if created.IsZero() {
+			return ""
+		}
+		return created.Local().Format("2006-01-02 15:04:05")
+	}},
+	{Header: "IMAGE", Wide: true, Value: func(item interface{}) string { return item.(devcontainers.DevcontainerInfo).Image }},
+	{Header: "COMPOSE PROJECT/SERVICE", Wide: true, Value: func(item interface{}) string {
+		devcontainer := item.(devcontainers.DevcontainerInfo)
+		if devcontainer.ComposeProject == "" {
+			return ""
+		}
+		return devcontainer.ComposeProject + "/" + devcontainer.ComposeService
+	}},
+}
+
 func createListCommand() *cobra.Command {
 	var listIncludeContainerNames bool
 	var listVerbose bool
+	var listAll bool
+	var listOutput outputFlags
 	cmdList := &cobra.Command{
 		Use:   "list",
 		Short: "List devcontainers",
-		Long:  "Lists running devcontainers",
+		Long:  "Lists running devcontainers (use --all to include stopped devcontainers)",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if listIncludeContainerNames && listVerbose {
-				fmt.Println("Can't use both verbose and include-container-names")
+			outputOptions := listOutput.options()
+			if countBooleans(listIncludeContainerNames, listVerbose, !outputOptions.IsDefault()) > 1 {
+				fmt.Println("Can only use one of verbose, include-container-names and output/format")
 				os.Exit(1)
 			}
-			devcontainers, err := devcontainers.ListDevcontainers()
+			listFunc := devcontainers.ListDevcontainers
+			if listAll {
+				listFunc = devcontainers.ListAllDevcontainers
+			}
+			devcontainerList, err := listFunc()
 			if err != nil {
 				return err
 			}
 			if listVerbose {
-				sort.Slice(devcontainers, func(i, j int) bool { return devcontainers[i].DevcontainerName < devcontainers[j].DevcontainerName })
-
-				w := new(tabwriter.Writer)
-				// minwidth, tabwidth, padding, padchar, flags
-				w.Init(os.Stdout, 8, 8, 0, '\t', 0)
-				defer w.Flush()
-
-				fmt.Fprintf(w, "%s\t%s\n", "DEVCONTAINER NAME", "CONTAINER NAME")
-				fmt.Fprintf(w, "%s\t%s\n", "-----------------", "--------------")
-
-				for _, devcontainer := range devcontainers {
-					fmt.Fprintf(w, "%s\t%s\n", devcontainer.DevcontainerName, devcontainer.ContainerName)
-				}
-				return nil
+				outputOptions.Format = output.FormatWide
+			}
+			if !outputOptions.IsDefault() || listVerbose {
+				sort.Slice(devcontainerList, func(i, j int) bool {
+					return devcontainerList[i].DevcontainerName < devcontainerList[j].DevcontainerName
+				})
+				return output.Write(os.Stdout, outputOptions, devcontainerList, devcontainerColumns)
 			}
 			names := []string{}
-			for _, devcontainer := range devcontainers {
+			for _, devcontainer := range devcontainerList {
 				names = append(names, devcontainer.DevcontainerName)
 				if listIncludeContainerNames {
 					names = append(names, devcontainer.ContainerName)
@@ -58,12 +80,15 @@ func createListCommand() *cobra.Command {
 		},
 	}
 	cmdList.Flags().BoolVar(&listIncludeContainerNames, "include-container-names", false, "Also include container names in the list")
-	cmdList.Flags().BoolVarP(&listVerbose, "verbose", "v", false, "Verbose output")
+	cmdList.Flags().BoolVarP(&listVerbose, "verbose", "v", false, "Verbose output (same as --output wide)")
+	cmdList.Flags().BoolVarP(&listAll, "all", "a", false, "Include stopped dev containers")
+	listOutput.addFlags(cmdList)
 	return cmdList
 }
 
 func createShowCommand() *cobra.Command {
 	var argDevcontainerName string
+	var showOutput outputFlags
 	cmd := &cobra.Command{
 		Use:   "show --name <name>",
 		Short: "Show devcontainer info",
@@ -80,11 +105,14 @@ func createShowCommand() *cobra.Command {
 				if devcontainer.ContainerName == containerIDOrName ||
 					devcontainer.DevcontainerName == containerIDOrName ||
 					devcontainer.ContainerID == containerIDOrName {
-					output, err := json.MarshalIndent(devcontainer, "", "\t")
+					if outputOptions := showOutput.options(); !outputOptions.IsDefault() {
+						return output.Write(os.Stdout, outputOptions, devcontainer, devcontainerColumns)
+					}
+					jsonOutput, err := json.MarshalIndent(devcontainer, "", "\t")
 					if err != nil {
 						return fmt.Errorf("Failed to serialise devcontainer info: %s", err)
 					}
-					fmt.Printf("%s\n", output)
+					fmt.Printf("%s\n", jsonOutput)
 					return nil
 				}
 			}
@@ -93,6 +121,7 @@ func createShowCommand() *cobra.Command {
 		},
 	}
 	cmd.Flags().StringVarP(&argDevcontainerName, "name", "n", "", "name of dev container to exec into")
+	showOutput.addFlags(cmd)
 
 	_ = cmd.RegisterFlagCompletionFunc("name", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		devcontainers, err := devcontainers.ListDevcontainers()
@@ -121,13 +150,13 @@ func countBooleans(values ...bool) int {
 }
 
 func createExecCommand() *cobra.Command {
-	var argDevcontainerName string
-	var argDevcontainerPath string
-	var argPromptForDevcontainer bool
+	selector := devcontainerSelector{}
 	var argWorkDir string
+	var argService string
+	var argInteractive bool
 
 	cmd := &cobra.Command{
-		Use:   "exec [--name <name>| --path <path> | --prompt ] [--work-dir <work-dir>] [<command> [<args...>]] (command will default to /bin/bash if none provided)",
+		Use:   "exec [--name <name>| --path <path> | --prompt ] [--work-dir <work-dir>] [--service <service>] [--interactive] [<command> [<args...>]] (command will default to /bin/bash if none provided)",
 		Short: "Execute a command in a devcontainer",
 		Long:  "Execute a command in a devcontainer, similar to `docker exec`",
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -136,75 +165,48 @@ func createExecCommand() *cobra.Command {
 				args = []string{"/bin/bash"}
 			}
 
-			sourceCount := countBooleans(
-				argDevcontainerName != "",
-				argDevcontainerPath != "",
-				argPromptForDevcontainer,
-			)
-			if sourceCount > 1 {
-				fmt.Println("Can specify at most one of --name/--path/--prompt")
+			if !selector.validate() {
 				return cmd.Usage()
 			}
 
-			// workDir default:
-			// - devcontainer mount path if name or prompt specified (ExecInDevContainer defaults to this if workDir is "")
-			// - path if path set
-			// - current directory if path == "" and neither name or prompt set
-			workDir := argWorkDir
-
-			containerID := ""
 			devcontainerList, err := devcontainers.ListDevcontainers()
 			if err != nil {
 				return err
 			}
-			if argDevcontainerName != "" {
-				containerIDOrName := argDevcontainerName
+			devcontainer, err := selector.selectDevcontainer(devcontainerList)
+			if err != nil {
+				return err
+			}
 
-				// Get container ID
-				for _, devcontainer := range devcontainerList {
-					if devcontainer.ContainerName == containerIDOrName ||
-						devcontainer.DevcontainerName == containerIDOrName ||
-						devcontainer.ContainerID == containerIDOrName {
-						containerID = devcontainer.ContainerID
-						break
-					}
+			// workDir default:
+			// - devcontainer mount path if name or prompt specified (ExecInDevContainer defaults to this if workDir is "")
+			// - path if path set
+			// - current directory if path == "" and neither name or prompt set
+			workDir := argWorkDir
+			if workDir == "" && selector.name == "" && !selector.prompt {
+				if selector.path == "" {
+					workDir = "."
+				} else {
+					workDir = selector.path
 				}
+			}
 
-				if containerID == "" {
-					return fmt.Errorf("Failed to find a matching (running) dev container for %q", containerIDOrName)
-				}
-			} else if argPromptForDevcontainer {
-				// prompt user
-				fmt.Println("Specify the devcontainer to use:")
-				for index, devcontainer := range devcontainerList {
-					fmt.Printf("%4d: %s (%s)\n", index, devcontainer.DevcontainerName, devcontainer.ContainerName)
-				}
-				selection := -1
-				_, _ = fmt.Scanf("%d", &selection)
-				if selection < 0 || selection >= len(devcontainerList) {
-					return fmt.Errorf("Invalid option")
-				}
-				containerID = devcontainerList[selection].ContainerID
+			if argService != "" {
+				// the local path doesn't map into sidecar containers so only pass an explicit work-dir
+				err = devcontainers.ExecInComposeService(devcontainer.ContainerID, argService, argWorkDir, args, argInteractive)
 			} else {
-				devcontainerPath := argDevcontainerPath
-				// TODO - update to check for devcontainers in the path ancestry
-				// Can't just check up the path for a .devcontainer folder as the container might
-				// have been created via repository containers (https://github.com/microsoft/vscode-dev-containers/tree/main/repository-containers)
-				devcontainer, err := devcontainers.GetClosestPathMatchForPath(devcontainerList, devcontainerPath)
-				if err != nil {
-					return err
-				}
-				containerID = devcontainer.ContainerID
-				if workDir == "" {
-					if devcontainerPath == "" {
-						workDir = "."
-					} else {
-						workDir = devcontainerPath
-					}
+				err = devcontainers.ExecInDevContainer(devcontainer.ContainerID, workDir, args, argInteractive)
+			}
+			if execErr, ok := err.(*devcontainers.ExecError); ok {
+				// exit with the exit code of the command run in the container
+				exitCode := execErr.ExitCode
+				if exitCode < 0 {
+					// e.g. terminated by a signal
+					exitCode = 1
 				}
+				os.Exit(exitCode)
 			}
-
-			return devcontainers.ExecInDevContainer(containerID, workDir, args)
+			return err
 		},
 		Args:                  cobra.ArbitraryArgs,
 		DisableFlagsInUseLine: true,
@@ -212,23 +214,9 @@ func createExecCommand() *cobra.Command {
 			return nil, cobra.ShellCompDirectiveNoFileComp
 		},
 	}
-	cmd.Flags().StringVarP(&argDevcontainerName, "name", "n", "", "name of dev container to exec into")
-	cmd.Flags().StringVarP(&argDevcontainerPath, "path", "", "", "path containing the dev container to exec into")
-	cmd.Flags().BoolVarP(&argPromptForDevcontainer, "prompt", "", false, "prompt for the dev container to exec into")
+	selector.addFlags(cmd, "exec into", devcontainers.ListDevcontainers)
 	cmd.Flags().StringVarP(&argWorkDir, "work-dir", "", "", "working directory to use in the dev container")
-
-	_ = cmd.RegisterFlagCompletionFunc("name", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		devcontainers, err := devcontainers.ListDevcontainers()
-		if err != nil {
-			os.Exit(1)
-		}
-		names := []string{}
-		for _, devcontainer := range devcontainers {
-			names = append(names, devcontainer.DevcontainerName)
-		}
-		sort.Strings(names)
-		return names, cobra.ShellCompDirectiveNoFileComp
-
-	})
+	cmd.Flags().StringVarP(&argService, "service", "", "", "compose service to exec into (for compose-based dev containers, defaults to the dev container service)")
+	cmd.Flags().BoolVarP(&argInteractive, "interactive", "i", false, "attach stdin to the command even when it isn't a terminal (e.g. to pipe input to the command)")
 	return cmd
 }
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/devcontainers"
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/output"
+)
+
+func createFeatureCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "feature",
+		Short: "work with dev container features",
+		Long:  "Use subcommands to work with the features in devcontainer.json",
+	}
+	cmd.AddCommand(createFeatureListCommand())
+	cmd.AddCommand(createFeatureAddCommand())
+	cmd.AddCommand(createFeatureRemoveCommand())
+	return cmd
+}
+
+func getFeatureVersion(feature *devcontainers.DevcontainerFeature) string {
+	if feature == nil || feature.Version == "" {
+		return "-"
+	}
+	return feature.Version
+}
+
+func formatFeatureOptions(options map[string]interface{}) string {
+	values := []string{}
+	for name, value := range options {
+		values = append(values, fmt.Sprintf("%s=%v", name, value))
+	}
+	sort.Strings(values)
+	return strings.Join(values, ",")
+}
+
+// configuredFeatureColumns are the table columns for ConfiguredFeature output
+var configuredFeatureColumns = []output.Column{
+	{Header: "FEATURE", Value: func(item interface{}) string { return item.(devcontainers.ConfiguredFeature).Ref }},
+	{Header: "VERSION", Value: func(item interface{}) string {
+		return getFeatureVersion(item.(devcontainers.ConfiguredFeature).Feature)
+	}},
+	{Header: "OPTIONS", Value: func(item interface{}) string {
+		return formatFeatureOptions(item.(devcontainers.ConfiguredFeature).Options)
+	}},
+	{Header: "SOURCE", Wide: true, Value: func(item interface{}) string {
+		if feature := item.(devcontainers.ConfiguredFeature).Feature; feature != nil {
+			return feature.Path
+		}
+		return "not found"
+	}},
+}
+
+// availableFeatureColumns are the table columns for DevcontainerFeature output
+var availableFeatureColumns = []output.Column{
+	{Header: "FEATURE ID", Value: func(item interface{}) string { return item.(devcontainers.DevcontainerFeature).ID }},
+	{Header: "VERSION", Value: func(item interface{}) string {
+		feature := item.(devcontainers.DevcontainerFeature)
+		return getFeatureVersion(&feature)
+	}},
+	{Header: "NAME", Value: func(item interface{}) string { return item.(devcontainers.DevcontainerFeature).Name }},
+	{Header: "PATH", Wide: true, Value: func(item interface{}) string { return item.(devcontainers.DevcontainerFeature).Path }},
+}
+
+func createFeatureListCommand() *cobra.Command {
+	var listAvailable bool
+	var listOutput outputFlags
+	cmd := &cobra.Command{
+		Use:   "list [<path>]",
+		Short: "list features",
+		Long:  "List the features in devcontainer.json for the specified path (defaults to the current directory) in install order. Use --available to list the features in the configured featurePaths",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 1 {
+				return cmd.Usage()
+			}
+			outputOptions := listOutput.options()
+
+			if listAvailable {
+				features, err := devcontainers.GetAvailableFeatures()
+				if err != nil {
+					return err
+				}
+				if !outputOptions.IsDefault() {
+					return output.Write(os.Stdout, outputOptions, features, availableFeatureColumns)
+				}
+				for _, feature := range features {
+					fmt.Println(feature.ID)
+				}
+				return nil
+			}
+
+			path := "." // default to current directory
+			if len(args) == 1 {
+				path = args[0]
+			}
+			features, err := devcontainers.GetDevcontainerFeatures(path)
+			if err != nil {
+				return err
+			}
+			if !outputOptions.IsDefault() {
+				return output.Write(os.Stdout, outputOptions, features, configuredFeatureColumns)
+			}
+			for _, feature := range features {
+				fmt.Println(feature.Ref)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&listAvailable, "available", false, "List the features available in the configured featurePaths")
+	listOutput.addFlags(cmd)
+	return cmd
+}
+
+// parseOptionValues parses name=value pairs from --option flags
+func parseOptionValues(optionValues []string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, optionValue := range optionValues {
+		parts := strings.SplitN(optionValue, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid option %q (expected name=value)", optionValue)
+		}
+		values[parts[0]] = parts[1]
+	}
+	return values, nil
+}
+
+func createFeatureAddCommand() *cobra.Command {
+	var path string
+	var optionValues []string
+	cmd := &cobra.Command{
+		Use:   "add FEATURE",
+		Short: "add a feature to devcontainer.json",
+		Long:  "Add a feature (e.g. ghcr.io/devcontainers/features/go:1 or ./local-feature) to devcontainer.json, or update its options if it is already present. Options are validated if the feature can be found locally",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return cmd.Usage()
+			}
+			ref := args[0]
+
+			values, err := parseOptionValues(optionValues)
+			if err != nil {
+				return err
+			}
+			feature, err := devcontainers.FindFeature(path, ref)
+			if err != nil {
+				return err
+			}
+			if feature == nil {
+				fmt.Fprintf(os.Stderr, "Warning: feature %q not found locally, options will not be validated\n", ref)
+			}
+			options, err := devcontainers.ParseFeatureOptionValues(feature, values)
+			if err != nil {
+				return err
+			}
+			return devcontainers.AddFeatureToDevcontainer(path, ref, feature, options)
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			features, err := devcontainers.GetAvailableFeatures()
+			if err != nil {
+				os.Exit(1)
+			}
+			ids := []string{}
+			for _, feature := range features {
+				ids = append(ids, feature.ID)
+			}
+			sort.Strings(ids)
+			return ids, cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+	cmd.Flags().StringVar(&path, "path", ".", "Path to the folder containing the dev container definition")
+	cmd.Flags().StringArrayVar(&optionValues, "option", []string{}, "Feature option to set (name=value, can be repeated)")
+	return cmd
+}
+
+func createFeatureRemoveCommand() *cobra.Command {
+	var path string
+	cmd := &cobra.Command{
+		Use:   "remove FEATURE",
+		Short: "remove a feature from devcontainer.json",
+		Long:  "Remove a feature from devcontainer.json. The feature can be specified with or without its version",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return cmd.Usage()
+			}
+			return devcontainers.RemoveFeatureFromDevcontainer(path, args[0])
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			features, err := devcontainers.GetDevcontainerFeatures(path)
+			if err != nil {
+				os.Exit(1)
+			}
+			refs := []string{}
+			for _, feature := range features {
+				refs = append(refs, feature.Ref)
+			}
+			return refs, cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+	cmd.Flags().StringVar(&path, "path", ".", "Path to the folder containing the dev container definition")
+	return cmd
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/devcontainers"
+)
+
+// devcontainerSelector holds the --name/--path/--prompt flags used to pick a dev container
+type devcontainerSelector struct {
+	name   string
+	path   string
+	prompt bool
+	// includeStopped is set when the dev container list includes stopped dev containers
+	includeStopped bool
+}
+
+func (s *devcontainerSelector) addFlags(cmd *cobra.Command, action string, listFunc func() ([]devcontainers.DevcontainerInfo, error)) {
+	cmd.Flags().StringVarP(&s.name, "name", "n", "", "name of dev container to "+action)
+	cmd.Flags().StringVarP(&s.path, "path", "", "", "path containing the dev container to "+action)
+	cmd.Flags().BoolVarP(&s.prompt, "prompt", "", false, "prompt for the dev container to "+action)
+
+	_ = cmd.RegisterFlagCompletionFunc("name", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		devcontainers, err := listFunc()
+		if err != nil {
+			os.Exit(1)
+		}
+		names := []string{}
+		for _, devcontainer := range devcontainers {
+			names = append(names, devcontainer.DevcontainerName)
+		}
+		sort.Strings(names)
+		return names, cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+// validate checks that at most one of --name/--path/--prompt is set
+func (s *devcontainerSelector) validate() bool {
+	sourceCount := countBooleans(
+		s.name != "",
+		s.path != "",
+		s.prompt,
+	)
+	if sourceCount > 1 {
+		fmt.Println("Can specify at most one of --name/--path/--prompt")
+		return false
+	}
+	return true
+}
+
+// selectDevcontainer picks the dev container from the list based on the flags
+// If no flags are set then the dev container for the current directory is used
+func (s *devcontainerSelector) selectDevcontainer(devcontainerList []devcontainers.DevcontainerInfo) (devcontainers.DevcontainerInfo, error) {
+	if s.name != "" {
+		containerIDOrName := s.name
+		for _, devcontainer := range devcontainerList {
+			if devcontainer.ContainerName == containerIDOrName ||
+				devcontainer.DevcontainerName == containerIDOrName ||
+				devcontainer.ContainerID == containerIDOrName {
+				return devcontainer, nil
+			}
+		}
+		return devcontainers.DevcontainerInfo{}, fmt.Errorf("Failed to find a matching dev container for %q", containerIDOrName)
+	}
+
+	if s.prompt {
+		fmt.Println("Specify the devcontainer to use:")
+		for index, devcontainer := range devcontainerList {
+			fmt.Printf("%4d: %s (%s)\n", index, devcontainer.DevcontainerName, devcontainer.ContainerName)
+		}
+		selection := -1
+		_, _ = fmt.Scanf("%d", &selection)
+		if selection < 0 || selection >= len(devcontainerList) {
+			return devcontainers.DevcontainerInfo{}, fmt.Errorf("Invalid option")
+		}
+		return devcontainerList[selection], nil
+	}
+
+	// TODO - update to check for devcontainers in the path ancestry
+	// Can't just check up the path for a .devcontainer folder as the container might
+	// have been created via repository containers (https://github.com/microsoft/vscode-dev-containers/tree/main/repository-containers)
+	devcontainer, err := devcontainers.GetClosestPathMatchForPath(devcontainerList, s.path)
+	if notFoundErr, ok := err.(*devcontainers.DevcontainerPathNotFoundError); ok && s.includeStopped {
+		return devcontainers.DevcontainerInfo{}, fmt.Errorf("Could not find a dev container (running or stopped) for path %q", notFoundErr.Path)
+	}
+	return devcontainer, err
+}
+
+// createLifecycleCommand creates a command that selects a dev container (including stopped dev containers) and runs action against it
+func createLifecycleCommand(use string, short string, long string, action func(devcontainer devcontainers.DevcontainerInfo, output io.Writer) error) *cobra.Command {
+	selector := devcontainerSelector{includeStopped: true}
+	cmd := &cobra.Command{
+		Use:   use + " [--name <name>| --path <path> | --prompt ]",
+		Short: short,
+		Long:  long,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 || !selector.validate() {
+				return cmd.Usage()
+			}
+			devcontainerList, err := devcontainers.ListAllDevcontainers()
+			if err != nil {
+				return err
+			}
+			devcontainer, err := selector.selectDevcontainer(devcontainerList)
+			if err != nil {
+				return err
+			}
+			return action(devcontainer, os.Stdout)
+		},
+	}
+	selector.addFlags(cmd, use, devcontainers.ListAllDevcontainers)
+	return cmd
+}
+
+func createStartCommand() *cobra.Command {
+	return createLifecycleCommand(
+		"start",
+		"start a dev container",
+		"Start a stopped dev container. For compose-based dev containers the compose project is started",
+		devcontainers.StartDevcontainer)
+}
+
+func createStopCommand() *cobra.Command {
+	return createLifecycleCommand(
+		"stop",
+		"stop a dev container",
+		"Stop a running dev container. For compose-based dev containers all services in the compose project are stopped",
+		devcontainers.StopDevcontainer)
+}
+
+func createRestartCommand() *cobra.Command {
+	return createLifecycleCommand(
+		"restart",
+		"restart a dev container",
+		"Stop and start a dev container. For compose-based dev containers the whole compose project is restarted",
+		devcontainers.RestartDevcontainer)
+}
+
+func createRmCommand() *cobra.Command {
+	return createLifecycleCommand(
+		"rm",
+		"remove a dev container",
+		"Remove a dev container (stopping it if it is running). For compose-based dev containers the compose project is taken down",
+		devcontainers.RemoveDevcontainer)
+}
+
+func createRebuildCommand() *cobra.Command {
+	return createLifecycleCommand(
+		"rebuild",
+		"rebuild a dev container",
+		"Remove a dev container and the image built from .devcontainer and then recreate it with the original labels",
+		func(devcontainer devcontainers.DevcontainerInfo, output io.Writer) error {
+			containerID, err := devcontainers.RebuildDevcontainer(devcontainer, output)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Dev container running: %s\n", containerID)
+			return nil
+		})
+}
@@ -24,11 +24,20 @@ func main() {
 	}
 
 	rootCmd.AddCommand(createCompleteCommand(rootCmd))
+	rootCmd.AddCommand(createComposeCommand())
 	rootCmd.AddCommand(createConfigCommand())
 	rootCmd.AddCommand(createExecCommand())
+	rootCmd.AddCommand(createFeatureCommand())
 	rootCmd.AddCommand(createListCommand())
 	rootCmd.AddCommand(createShowCommand())
+	rootCmd.AddCommand(createStartCommand())
+	rootCmd.AddCommand(createStopCommand())
+	rootCmd.AddCommand(createRestartCommand())
+	rootCmd.AddCommand(createRmCommand())
+	rootCmd.AddCommand(createRebuildCommand())
 	rootCmd.AddCommand(createTemplateCommand())
+	rootCmd.AddCommand(createUpCommand())
+	rootCmd.AddCommand(createValidateCommand())
 	if config.GetExperimentalFeaturesEnabled() {
 		rootCmd.AddCommand(createSnippetCommand())
 	}
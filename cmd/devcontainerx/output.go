@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/output"
+)
+
+// outputFlags holds the --output/--format flags for a command
+type outputFlags struct {
+	format   string
+	template string
+}
+
+func (f *outputFlags) addFlags(cmd *cobra.Command) {
+	formats := []string{}
+	for _, format := range output.Formats {
+		formats = append(formats, string(format))
+	}
+	cmd.Flags().StringVarP(&f.format, "output", "o", "", "output format ("+strings.Join(formats, "|")+")")
+	cmd.Flags().StringVarP(&f.template, "format", "", "", "Go template to apply to each item (e.g. '{{.Name}}'), implies --output template")
+
+	_ = cmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return formats, cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+func (f *outputFlags) options() output.Options {
+	return output.Options{Format: output.Format(f.format), Template: f.template}
+}
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"sort"
-	"text/tabwriter"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/stuartleeks/devcontainer-cli/internal/pkg/devcontainers"
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/output"
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/terminal"
 )
 
 func createSnippetCommand() *cobra.Command {
@@ -18,35 +23,72 @@ func createSnippetCommand() *cobra.Command {
 	}
 	cmd.AddCommand(createSnippetListCommand())
 	cmd.AddCommand(createSnippetAddCommand())
+	cmd.AddCommand(createSnippetRemoveCommand())
+	cmd.AddCommand(createSnippetTestCommand())
 	return cmd
 }
 
+// snippetColumns are the table columns for DevcontainerSnippet output
+var snippetColumns = []output.Column{
+	{Header: "SNIPPET NAME", Value: func(item interface{}) string { return item.(devcontainers.DevcontainerSnippet).Name }},
+	{Header: "PATH", Value: func(item interface{}) string { return item.(devcontainers.DevcontainerSnippet).Path }},
+	{Header: "TYPE", Wide: true, Value: func(item interface{}) string { return string(item.(devcontainers.DevcontainerSnippet).Type) }},
+}
+
+// installedSnippetColumns are the table columns for InstalledSnippet output
+var installedSnippetColumns = []output.Column{
+	{Header: "SNIPPET NAME", Value: func(item interface{}) string { return item.(devcontainers.InstalledSnippet).Name }},
+	{Header: "PATH", Value: func(item interface{}) string { return item.(devcontainers.InstalledSnippet).Path }},
+	{Header: "TYPE", Wide: true, Value: func(item interface{}) string { return string(item.(devcontainers.InstalledSnippet).Type) }},
+	{Header: "ACTIONS", Wide: true, Value: func(item interface{}) string {
+		actionTypes := []string{}
+		for _, action := range item.(devcontainers.InstalledSnippet).Actions {
+			actionTypes = append(actionTypes, string(action.Type))
+		}
+		return strings.Join(actionTypes, ",")
+	}},
+}
+
 func createSnippetListCommand() *cobra.Command {
 	var listVerbose bool
+	var listInstalled bool
+	var listOutput outputFlags
 	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "list snippets",
-		Long:  "List devcontainer snippets",
+		Long:  "List devcontainer snippets. Use --installed to list the snippets added to the devcontainer for the current folder",
 		RunE: func(cmd *cobra.Command, args []string) error {
 
+			outputOptions := listOutput.options()
+			if listVerbose {
+				outputOptions.Format = output.FormatTable
+			}
+
+			if listInstalled {
+				currentDirectory, err := os.Getwd()
+				if err != nil {
+					return fmt.Errorf("Error reading current directory: %s\n", err)
+				}
+				installedSnippets, err := devcontainers.GetInstalledSnippets(currentDirectory)
+				if err != nil {
+					return err
+				}
+				if !outputOptions.IsDefault() {
+					return output.Write(os.Stdout, outputOptions, installedSnippets, installedSnippetColumns)
+				}
+				for _, snippet := range installedSnippets {
+					fmt.Println(snippet.Name)
+				}
+				return nil
+			}
+
 			snippets, err := devcontainers.GetSnippets()
 			if err != nil {
 				return err
 			}
 
-			if listVerbose {
-				w := new(tabwriter.Writer)
-				// minwidth, tabwidth, padding, padchar, flags
-				w.Init(os.Stdout, 8, 8, 0, '\t', 0)
-				defer w.Flush()
-
-				fmt.Fprintf(w, "%s\t%s\n", "SNIPPET NAME", "PATH")
-				fmt.Fprintf(w, "%s\t%s\n", "-------------", "----")
-
-				for _, snippet := range snippets {
-					fmt.Fprintf(w, "%s\t%s\n", snippet.Name, snippet.Path)
-				}
-				return nil
+			if !outputOptions.IsDefault() {
+				return output.Write(os.Stdout, outputOptions, snippets, snippetColumns)
 			}
 
 			for _, snippet := range snippets {
@@ -55,16 +97,20 @@ func createSnippetListCommand() *cobra.Command {
 			return nil
 		},
 	}
-	cmd.Flags().BoolVarP(&listVerbose, "verbose", "v", false, "Verbose output")
+	cmd.Flags().BoolVarP(&listVerbose, "verbose", "v", false, "Verbose output (same as --output table)")
+	cmd.Flags().BoolVar(&listInstalled, "installed", false, "List the snippets added to the devcontainer for the current folder")
+	listOutput.addFlags(cmd)
 	return cmd
 }
 
 func createSnippetAddCommand() *cobra.Command {
 	var devcontainerName string
+	var dryRun bool
+	var parameterValues []string
 	cmd := &cobra.Command{
 		Use:   "add SNIPPET_NAME",
 		Short: "add snippet to devcontainer",
-		Long:  "Add a snippet to the devcontainer definition for the current folder",
+		Long:  "Add a snippet (and the snippets it depends on) to the devcontainer definition for the current folder. The files are only changed if all of the snippet actions succeed. Snippet parameters not set with --set are prompted for when run in a terminal, otherwise the parameter defaults are used. Use --set DEPENDENCY.name=value to set the parameters of a dependency",
 		RunE: func(cmd *cobra.Command, args []string) error {
 
 			if len(args) != 1 {
@@ -76,8 +122,51 @@ func createSnippetAddCommand() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("Error reading current directory: %s\n", err)
 			}
+			installedSnippets, err := devcontainers.GetInstalledSnippets(currentDirectory)
+			if err != nil {
+				return err
+			}
+			for _, installedSnippet := range installedSnippets {
+				if installedSnippet.Name == name {
+					fmt.Printf("Snippet %q is already installed\n", name)
+					return nil
+				}
+			}
 
-			err = devcontainers.AddSnippetToDevcontainer(currentDirectory, name)
+			snippetsToAdd, err := devcontainers.GetSnippetsToAdd(currentDirectory, name)
+			if err != nil {
+				return err
+			}
+			values, err := parseOptionValues(parameterValues)
+			if err != nil {
+				return err
+			}
+			var prompt func(string, devcontainers.TemplateOption) (string, error)
+			if terminal.IsStdinTTY() {
+				prompt = promptForTemplateOption(bufio.NewReader(os.Stdin))
+			}
+			parameters, err := resolveSnippetParameterValues(name, snippetsToAdd, splitSnippetParameterValues(name, values), prompt)
+			if err != nil {
+				return err
+			}
+
+			if dryRun {
+				preview, err := devcontainers.PreviewSnippetAdd(currentDirectory, name, parameters)
+				if err != nil {
+					return err
+				}
+				if preview == "" {
+					fmt.Println("No changes")
+				}
+				fmt.Print(preview)
+				return nil
+			}
+			for _, snippetToAdd := range snippetsToAdd {
+				if snippetToAdd.Name != name {
+					fmt.Printf("Adding dependency %q\n", snippetToAdd.Name)
+				}
+			}
+			err = devcontainers.AddSnippetToDevcontainer(currentDirectory, name, parameters)
 			if err != nil {
 				return fmt.Errorf("Error setting devcontainer name: %s", err)
 			}
@@ -102,5 +191,234 @@ func createSnippetAddCommand() *cobra.Command {
 		},
 	}
 	cmd.Flags().StringVar(&devcontainerName, "devcontainer-name", "", "Value to set the devcontainer.json name property to (default is folder name)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show a diff of the changes without changing any files")
+	cmd.Flags().StringArrayVar(&parameterValues, "set", []string{}, "Snippet parameter to set (name=value or DEPENDENCY.name=value, can be repeated)")
+	return cmd
+}
+
+// splitSnippetParameterValues splits --set values into the values for each snippet
+// Names of the form DEPENDENCY.name are for the parameters of a dependency, other names are for snippetName
+func splitSnippetParameterValues(snippetName string, values map[string]string) devcontainers.SnippetParameterValues {
+	result := devcontainers.SnippetParameterValues{}
+	for name, value := range values {
+		target := snippetName
+		if i := strings.Index(name, "."); i > 0 {
+			target, name = name[:i], name[i+1:]
+		}
+		if result[target] == nil {
+			result[target] = map[string]string{}
+		}
+		result[target][name] = value
+	}
+	return result
+}
+
+// resolveSnippetParameterValues resolves the parameter values for each of the snippets being added
+// Dependency parameters are prompted for as DEPENDENCY.name
+func resolveSnippetParameterValues(snippetName string, snippetsToAdd []devcontainers.DevcontainerSnippet, values devcontainers.SnippetParameterValues, prompt func(string, devcontainers.TemplateOption) (string, error)) (devcontainers.SnippetParameterValues, error) {
+	result := devcontainers.SnippetParameterValues{}
+	for i := range snippetsToAdd {
+		snippet := &snippetsToAdd[i]
+		snippetPrompt := prompt
+		if prompt != nil && snippet.Name != snippetName {
+			snippetPrompt = func(name string, option devcontainers.TemplateOption) (string, error) {
+				return prompt(snippet.Name+"."+name, option)
+			}
+		}
+		resolved, err := snippet.ResolveParameterValues(values[snippet.Name], snippetPrompt)
+		if err != nil {
+			return nil, err
+		}
+		result[snippet.Name] = resolved
+	}
+	for name := range values {
+		if _, ok := result[name]; !ok {
+			return nil, fmt.Errorf("--set value for snippet %q, which isn't being added", name)
+		}
+	}
+	return result, nil
+}
+
+func createSnippetRemoveCommand() *cobra.Command {
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "remove SNIPPET_NAME",
+		Short: "remove snippet from devcontainer",
+		Long:  "Remove a snippet from the devcontainer definition for the current folder by reversing the changes made when it was added",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			if len(args) != 1 {
+				return cmd.Usage()
+			}
+			name := args[0]
+
+			currentDirectory, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("Error reading current directory: %s\n", err)
+			}
+
+			if dryRun {
+				preview, err := devcontainers.PreviewSnippetRemove(currentDirectory, name)
+				if err != nil {
+					return err
+				}
+				fmt.Print(preview)
+				return nil
+			}
+			return devcontainers.RemoveSnippetFromDevcontainer(currentDirectory, name)
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			// only completing the first arg (snippet name)
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			currentDirectory, err := os.Getwd()
+			if err != nil {
+				os.Exit(1)
+			}
+			installedSnippets, err := devcontainers.GetInstalledSnippets(currentDirectory)
+			if err != nil {
+				os.Exit(1)
+			}
+			names := []string{}
+			for _, snippet := range installedSnippets {
+				names = append(names, snippet.Name)
+			}
+			sort.Strings(names)
+			return names, cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show a diff of the changes without changing any files")
+	return cmd
+}
+
+// snippetTestResultColumns are the table columns for SnippetTestResult output
+var snippetTestResultColumns = []output.Column{
+	{Header: "SNIPPET NAME", Value: func(item interface{}) string { return item.(devcontainers.SnippetTestResult).Snippet }},
+	{Header: "TEMPLATE NAME", Value: func(item interface{}) string { return item.(devcontainers.SnippetTestResult).Template }},
+	{Header: "RESULT", Value: func(item interface{}) string {
+		if item.(devcontainers.SnippetTestResult).Passed {
+			return "PASS"
+		}
+		return "FAIL"
+	}},
+	{Header: "STAGE", Value: func(item interface{}) string { return string(item.(devcontainers.SnippetTestResult).Stage) }},
+	{Header: "ERROR", Wide: true, Value: func(item interface{}) string { return item.(devcontainers.SnippetTestResult).Error }},
+}
+
+func createSnippetTestCommand() *cobra.Command {
+	var templateNames []string
+	var parameterValues []string
+	var testAll bool
+	var verbose bool
+	var testOutput outputFlags
+	cmd := &cobra.Command{
+		Use:   "test [SNIPPET_NAME]",
+		Short: "test a snippet with a template",
+		Long:  "Test a snippet by adding it to a temporary copy of a template, building the dev container image and running the verify script from snippet.json (if set) in the dev container. Use --all to test all snippets (with all templates if --template isn't set)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			snippetNames := args
+			if testAll {
+				if len(args) != 0 {
+					return cmd.Usage()
+				}
+				if len(parameterValues) > 0 {
+					return fmt.Errorf("--set can't be used with --all")
+				}
+				snippets, err := devcontainers.GetSnippets()
+				if err != nil {
+					return err
+				}
+				snippetNames = []string{}
+				for _, snippet := range snippets {
+					snippetNames = append(snippetNames, snippet.Name)
+				}
+			} else if len(args) != 1 {
+				return cmd.Usage()
+			}
+
+			if len(templateNames) == 0 {
+				if !testAll {
+					return fmt.Errorf("--template must be set")
+				}
+				templates, err := devcontainers.GetTemplates()
+				if err != nil {
+					return err
+				}
+				for _, template := range templates {
+					templateNames = append(templateNames, template.Name)
+				}
+			}
+
+			values, err := parseOptionValues(parameterValues)
+			if err != nil {
+				return err
+			}
+			parameters := devcontainers.SnippetParameterValues{}
+			if len(snippetNames) == 1 {
+				parameters = splitSnippetParameterValues(snippetNames[0], values)
+			}
+			var progressOutput io.Writer = ioutil.Discard
+			if verbose {
+				progressOutput = os.Stderr
+			}
+			results := devcontainers.RunSnippetTests(snippetNames, templateNames, devcontainers.SnippetTestOptions{
+				Parameters: parameters,
+				Output:     progressOutput,
+			})
+
+			outputOptions := testOutput.options()
+			if outputOptions.IsDefault() {
+				outputOptions.Format = output.FormatTable
+			}
+			if err = output.Write(os.Stdout, outputOptions, results, snippetTestResultColumns); err != nil {
+				return err
+			}
+			failedCount := 0
+			for _, result := range results {
+				if !result.Passed {
+					failedCount++
+				}
+			}
+			if failedCount > 0 {
+				fmt.Fprintf(os.Stderr, "%d of %d snippet test(s) failed\n", failedCount, len(results))
+				os.Exit(1)
+			}
+			return nil
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			// only completing the first arg (snippet name)
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			snippets, err := devcontainers.GetSnippets()
+			if err != nil {
+				os.Exit(1)
+			}
+			names := []string{}
+			for _, snippet := range snippets {
+				names = append(names, snippet.Name)
+			}
+			sort.Strings(names)
+			return names, cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+	cmd.Flags().StringArrayVar(&templateNames, "template", []string{}, "Template to test the snippet with (can be repeated)")
+	cmd.Flags().StringArrayVar(&parameterValues, "set", []string{}, "Snippet parameter to set (name=value or DEPENDENCY.name=value, can be repeated)")
+	cmd.Flags().BoolVar(&testAll, "all", false, "Test all snippets")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Show the build and verify script output")
+	testOutput.addFlags(cmd)
+	_ = cmd.RegisterFlagCompletionFunc("template", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		templates, err := devcontainers.GetTemplates()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		names := []string{}
+		for _, template := range templates {
+			names = append(names, template.Name)
+		}
+		sort.Strings(names)
+		return names, cobra.ShellCompDirectiveNoFileComp
+	})
 	return cmd
 }
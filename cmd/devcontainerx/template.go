@@ -1,15 +1,19 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
-	"text/tabwriter"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/stuartleeks/devcontainer-cli/internal/pkg/devcontainers"
 	ioutil2 "github.com/stuartleeks/devcontainer-cli/internal/pkg/ioutil"
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/output"
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/terminal"
 )
 
 func createTemplateCommand() *cobra.Command {
@@ -21,35 +25,62 @@ func createTemplateCommand() *cobra.Command {
 	cmd.AddCommand(createTemplateListCommand())
 	cmd.AddCommand(createTemplateAddCommand())
 	cmd.AddCommand(createTemplateAddLinkCommand())
+	cmd.AddCommand(createTemplateCreateCommand())
+	cmd.AddCommand(createTemplateUpdateCommand())
+	cmd.AddCommand(createTemplateDiffCommand())
+	cmd.AddCommand(createTemplateSyncCommand())
 	return cmd
 }
 
+// templateColumns are the table columns for DevcontainerTemplate output
+var templateColumns = []output.Column{
+	{Header: "TEMPLATE NAME", Value: func(item interface{}) string { return item.(devcontainers.DevcontainerTemplate).Name }},
+	{Header: "PATH", Value: func(item interface{}) string { return item.(devcontainers.DevcontainerTemplate).Path }},
+	{Header: "OPTIONS", Wide: true, Value: func(item interface{}) string {
+		template := item.(devcontainers.DevcontainerTemplate)
+		return strings.Join(template.GetOptionNames(), ",")
+	}},
+	{Header: "SOURCE", Wide: true, Value: func(item interface{}) string {
+		template := item.(devcontainers.DevcontainerTemplate)
+		if template.Source == "" {
+			return ""
+		}
+		return fmt.Sprintf("%s@%s", template.Source, shortCommit(template.Commit))
+	}},
+}
+
+// shortCommit returns the abbreviated form of a git commit hash
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
 func createTemplateListCommand() *cobra.Command {
 	var listVerbose bool
+	var listOutput outputFlags
 	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "list templates",
 		Long:  "List devcontainer templates",
 		RunE: func(cmd *cobra.Command, args []string) error {
 
-			templates, err := devcontainers.GetTemplates()
+			currentDirectory, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("Error reading current directory: %s\n", err)
+			}
+			templates, err := devcontainers.GetTemplatesForProject(currentDirectory)
 			if err != nil {
 				return err
 			}
 
+			outputOptions := listOutput.options()
 			if listVerbose {
-				w := new(tabwriter.Writer)
-				// minwidth, tabwidth, padding, padchar, flags
-				w.Init(os.Stdout, 8, 8, 0, '\t', 0)
-				defer w.Flush()
-
-				fmt.Fprintf(w, "%s\t%s\n", "TEMPLATE NAME", "PATH")
-				fmt.Fprintf(w, "%s\t%s\n", "-------------", "----")
-
-				for _, template := range templates {
-					fmt.Fprintf(w, "%s\t%s\n", template.Name, template.Path)
-				}
-				return nil
+				outputOptions.Format = output.FormatTable
+			}
+			if !outputOptions.IsDefault() {
+				return output.Write(os.Stdout, outputOptions, templates, templateColumns)
 			}
 
 			for _, template := range templates {
@@ -58,16 +89,20 @@ func createTemplateListCommand() *cobra.Command {
 			return nil
 		},
 	}
-	cmd.Flags().BoolVarP(&listVerbose, "verbose", "v", false, "Verbose output")
+	cmd.Flags().BoolVarP(&listVerbose, "verbose", "v", false, "Verbose output (same as --output table)")
+	listOutput.addFlags(cmd)
 	return cmd
 }
 
 func createTemplateAddCommand() *cobra.Command {
 	var devcontainerName string
+	var optionValues []string
+	var omitPaths []string
+	var dryRun bool
 	cmd := &cobra.Command{
 		Use:   "add TEMPLATE_NAME",
 		Short: "add devcontainer from template",
-		Long:  "Add a devcontainer definition to the current folder using the specified template",
+		Long:  "Add a devcontainer definition to the current folder using the specified template. Template options not set with --option are prompted for when run in a terminal, otherwise the option defaults are used",
 		RunE: func(cmd *cobra.Command, args []string) error {
 
 			if len(args) != 1 {
@@ -75,30 +110,61 @@ func createTemplateAddCommand() *cobra.Command {
 			}
 			name := args[0]
 
-			template, err := devcontainers.GetTemplateByName(name)
+			currentDirectory, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("Error reading current directory: %s\n", err)
+			}
+			template, err := devcontainers.GetTemplateByName(name, currentDirectory)
 			if err != nil {
 				return err
 			}
 			if template == nil {
-				fmt.Printf("Template '%s' not found\n", name)
+				return fmt.Errorf("Template '%s' not found\n", name)
 			}
 
 			info, err := os.Stat("./.devcontainer")
 			if info != nil && err == nil {
 				return fmt.Errorf("Current folder already contains a .devcontainer folder - exiting")
 			}
+			info, err = os.Stat("./.devcontainer.json")
+			if info != nil && err == nil {
+				return fmt.Errorf("Current folder already contains a .devcontainer.json file - exiting")
+			}
 
-			currentDirectory, err := os.Getwd()
+			values, err := parseOptionValues(optionValues)
 			if err != nil {
-				return fmt.Errorf("Error reading current directory: %s\n", err)
+				return err
+			}
+			var prompt func(string, devcontainers.TemplateOption) (string, error)
+			var reader *bufio.Reader
+			if terminal.IsStdinTTY() {
+				reader = bufio.NewReader(os.Stdin)
+				prompt = promptForTemplateOption(reader)
+			}
+			options, err := template.ResolveOptionValues(values, prompt)
+			if err != nil {
+				return err
+			}
+			if reader != nil && !cmd.Flags().Changed("omit-path") {
+				if omitPaths, err = promptForOmitPaths(reader, template.OptionalPaths); err != nil {
+					return err
+				}
 			}
 
-			err = devcontainers.CopyTemplateToFolder(template.Path, currentDirectory, devcontainerName)
+			if dryRun {
+				preview, err := devcontainers.PreviewTemplateCopy(template, currentDirectory, devcontainerName, options, omitPaths)
+				if err != nil {
+					return err
+				}
+				fmt.Print(preview)
+				return nil
+			}
+			err = devcontainers.CopyTemplateToFolder(template, currentDirectory, devcontainerName, options, omitPaths)
 			if err != nil {
 				return err
 			}
 
-			return nil
+			return pinTemplateSource(currentDirectory, template)
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			// only completing the first arg  (template name)
@@ -118,9 +184,67 @@ func createTemplateAddCommand() *cobra.Command {
 		},
 	}
 	cmd.Flags().StringVar(&devcontainerName, "devcontainer-name", "", "Value to set the devcontainer.json name property to (default is folder name)")
+	cmd.Flags().StringArrayVar(&optionValues, "option", []string{}, "Template option to set (name=value, can be repeated)")
+	cmd.Flags().StringArrayVar(&omitPaths, "omit-path", []string{}, "Optional template path to leave out (can be repeated)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show a diff of the changes without changing any files")
 	return cmd
 }
 
+// promptForTemplateOption returns a function that prompts for template option values, repeating
+// the prompt until a valid value is entered. An empty value selects the default
+func promptForTemplateOption(reader *bufio.Reader) func(string, devcontainers.TemplateOption) (string, error) {
+	return func(name string, option devcontainers.TemplateOption) (string, error) {
+		prompt := name
+		if option.Description != "" {
+			prompt = fmt.Sprintf("%s (%s)", option.Description, name)
+		}
+		if len(option.Enum) > 0 {
+			prompt += fmt.Sprintf(" {%s}", strings.Join(option.Enum, ", "))
+		} else if len(option.Proposals) > 0 {
+			prompt += fmt.Sprintf(" (e.g. %s)", strings.Join(option.Proposals, ", "))
+		}
+		if option.Default != nil {
+			prompt += fmt.Sprintf(" [%s]", option.DefaultValue())
+		}
+		for {
+			fmt.Fprintf(os.Stderr, "%s: ", prompt)
+			input, err := reader.ReadString('\n')
+			if err != nil {
+				return "", fmt.Errorf("Error reading value for option %q: %s", name, err)
+			}
+			value := strings.TrimSpace(input)
+			if value == "" {
+				if option.Default != nil {
+					return "", nil
+				}
+				fmt.Fprintln(os.Stderr, "A value is required")
+				continue
+			}
+			if err = option.ValidateValue(value); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid value: %s\n", err)
+				continue
+			}
+			return value, nil
+		}
+	}
+}
+
+// promptForOmitPaths prompts whether to include each of the optional paths and returns the paths to omit
+func promptForOmitPaths(reader *bufio.Reader, optionalPaths []string) ([]string, error) {
+	omitPaths := []string{}
+	for _, optionalPath := range optionalPaths {
+		fmt.Fprintf(os.Stderr, "Include %s? (y/n) [y]: ", optionalPath)
+		input, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("Error reading response for %q: %s", optionalPath, err)
+		}
+		if answer := strings.ToLower(strings.TrimSpace(input)); answer == "n" || answer == "no" {
+			omitPaths = append(omitPaths, optionalPath)
+		}
+	}
+	return omitPaths, nil
+}
+
 func createTemplateAddLinkCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "add-link TEMPLATE_NAME",
@@ -133,7 +257,11 @@ func createTemplateAddLinkCommand() *cobra.Command {
 			}
 			name := args[0]
 
-			template, err := devcontainers.GetTemplateByName(name)
+			currentDirectory, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("Error reading current directory: %s\n", err)
+			}
+			template, err := devcontainers.GetTemplateByName(name, currentDirectory)
 			if err != nil {
 				return err
 			}
@@ -146,11 +274,15 @@ func createTemplateAddLinkCommand() *cobra.Command {
 				return fmt.Errorf("Current folder already contains a .devcontainer folder - exiting")
 			}
 
-			currentDirectory, err := os.Getwd()
-			if err != nil {
-				return fmt.Errorf("Error reading current directory: %s\n", err)
+			templateDevcontainerFolder := template.Path
+			if template.Format == devcontainers.TemplateFormatSpec {
+				// only the .devcontainer folder is linked for spec templates
+				templateDevcontainerFolder = filepath.Join(template.Path, ".devcontainer")
+				if info, err := os.Stat(templateDevcontainerFolder); err != nil || !info.IsDir() {
+					return fmt.Errorf("Template '%s' doesn't have a .devcontainer folder to link\n", name)
+				}
 			}
-			if err = ioutil2.LinkFolder(template.Path, currentDirectory+"/.devcontainer"); err != nil {
+			if err = ioutil2.LinkFolder(templateDevcontainerFolder, currentDirectory+"/.devcontainer"); err != nil {
 				return fmt.Errorf("Error linking folder: %s\n", err)
 			}
 
@@ -158,7 +290,7 @@ func createTemplateAddLinkCommand() *cobra.Command {
 			if err := ioutil.WriteFile(currentDirectory+"/.devcontainer/.gitignore", content, 0644); err != nil { // -rw-r--r--
 				return fmt.Errorf("Error writing .gitignore: %s\n", err)
 			}
-			return err
+			return pinTemplateSource(currentDirectory, template)
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			// only completing the first arg  (template name)
@@ -179,3 +311,173 @@ func createTemplateAddLinkCommand() *cobra.Command {
 	}
 	return cmd
 }
+
+// pinTemplateSource records the commit used for templates from git repos so that
+// other users of the project get the same template content
+func pinTemplateSource(projectFolder string, template *devcontainers.DevcontainerTemplate) error {
+	if template.Source == "" {
+		return nil
+	}
+	if err := devcontainers.PinTemplateSource(projectFolder, template.Source, template.Commit); err != nil {
+		return fmt.Errorf("Error pinning template source: %s", err)
+	}
+	return nil
+}
+
+func createTemplateUpdateCommand() *cobra.Command {
+	var pin bool
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "update templates from git repos",
+		Long:  "Fetch the latest commits for templatePaths entries that are git repos. Use --pin to also update the commits pinned for the current project",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			currentDirectory, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("Error reading current directory: %s\n", err)
+			}
+			pins, err := devcontainers.GetTemplatePins(currentDirectory)
+			if err != nil {
+				return err
+			}
+
+			updates, err := devcontainers.UpdateTemplateSources()
+			if err != nil {
+				return err
+			}
+			if len(updates) == 0 {
+				fmt.Println("No templatePaths entries are git repos")
+				return nil
+			}
+			for _, update := range updates {
+				switch update.OldCommit {
+				case "":
+					fmt.Printf("%s: cloned at %s\n", update.Source, shortCommit(update.NewCommit))
+				case update.NewCommit:
+					fmt.Printf("%s: up to date at %s\n", update.Source, shortCommit(update.NewCommit))
+				default:
+					fmt.Printf("%s: updated %s -> %s\n", update.Source, shortCommit(update.OldCommit), shortCommit(update.NewCommit))
+				}
+
+				pinnedCommit, isPinned := pins[update.Source]
+				if !isPinned || pinnedCommit == update.NewCommit {
+					continue
+				}
+				if pin {
+					if err = devcontainers.PinTemplateSource(currentDirectory, update.Source, update.NewCommit); err != nil {
+						return fmt.Errorf("Error pinning template source: %s", err)
+					}
+					fmt.Printf("  pinned %s (was %s)\n", shortCommit(update.NewCommit), shortCommit(pinnedCommit))
+				} else {
+					fmt.Printf("  current project is pinned to %s (use --pin to update)\n", shortCommit(pinnedCommit))
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&pin, "pin", false, "Update the commits pinned for the current project")
+	return cmd
+}
+
+func createTemplateDiffCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "show template changes for the current project",
+		Long:  "Show the changes to the template that the current project was created from (using `template add`) alongside the files that have been modified locally",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			currentDirectory, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("Error reading current directory: %s\n", err)
+			}
+			changes, err := devcontainers.DiffTemplate(currentDirectory)
+			if err != nil {
+				return err
+			}
+			if len(changes) == 0 {
+				fmt.Println("No changes")
+				return nil
+			}
+			for _, change := range changes {
+				fmt.Printf("%s: %s\n", change.Status, change.Path)
+			}
+			for _, change := range changes {
+				if change.Diff != "" {
+					fmt.Printf("\n%s", change.Diff)
+				}
+			}
+			return nil
+		},
+	}
+	return cmd
+}
+
+func createTemplateSyncCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "apply template changes to the current project",
+		Long:  "Apply the changes to the template that the current project was created from (using `template add`). Local changes are merged with the template changes and conflicting changes are written with conflict markers",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			currentDirectory, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("Error reading current directory: %s\n", err)
+			}
+			changes, err := devcontainers.SyncTemplate(currentDirectory)
+			if err != nil {
+				return err
+			}
+			conflictCount := 0
+			for _, change := range changes {
+				switch {
+				case change.Conflict:
+					conflictCount++
+					fmt.Printf("CONFLICT %s (%s)\n", change.Path, change.Status)
+				case change.Action != "":
+					fmt.Printf("%s %s\n", change.Action, change.Path)
+				}
+			}
+			if conflictCount > 0 {
+				fmt.Fprintf(os.Stderr, "Template sync completed with %d conflict(s) - resolve the conflicts and re-run `template diff` to check\n", conflictCount)
+				os.Exit(1)
+			}
+			fmt.Println("Template sync completed")
+			return nil
+		},
+	}
+	return cmd
+}
+
+func createTemplateCreateCommand() *cobra.Command {
+	var fromFolder string
+	var force bool
+	cmd := &cobra.Command{
+		Use:   "create TEMPLATE_NAME",
+		Short: "create a template from a project",
+		Long:  "Create a template from the .devcontainer folder of a project. The template is created in the first templatePaths folder that isn't a git repo, and the project's name, remoteUser and home folder values are replaced with placeholders",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			if len(args) != 1 {
+				return cmd.Usage()
+			}
+			name := args[0]
+
+			if fromFolder == "" {
+				currentDirectory, err := os.Getwd()
+				if err != nil {
+					return fmt.Errorf("Error reading current directory: %s\n", err)
+				}
+				fromFolder = currentDirectory
+			}
+			templateFolder, err := devcontainers.CreateTemplateFromFolder(fromFolder, name, force)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Created template %q in %s\n", name, templateFolder)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&fromFolder, "from", "", "Project folder containing the .devcontainer folder to create the template from (default is the current folder)")
+	cmd.Flags().BoolVar(&force, "force", false, "Replace the template if it already exists")
+	return cmd
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/devcontainers"
+)
+
+func createUpCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "up [<path>]",
+		Short: "build and start a dev container",
+		Long:  "Build and start the dev container for the specified path (defaults to the current directory) using its devcontainer.json, without needing VS Code",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 1 {
+				return cmd.Usage()
+			}
+			path := "." // default to current directory
+			if len(args) == 1 {
+				path = args[0]
+			}
+
+			containerID, err := devcontainers.UpDevcontainer(path, devcontainers.UpOptions{Output: os.Stdout})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Dev container running: %s\n", containerID)
+			return nil
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/devcontainers"
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/output"
+)
+
+// validationIssueColumns are the table columns for ValidationIssue output
+var validationIssueColumns = []output.Column{
+	{Header: "FILE", Value: func(item interface{}) string { return item.(devcontainers.ValidationIssue).File }},
+	{Header: "LINE", Value: func(item interface{}) string { return strconv.Itoa(item.(devcontainers.ValidationIssue).Line) }},
+	{Header: "COLUMN", Value: func(item interface{}) string { return strconv.Itoa(item.(devcontainers.ValidationIssue).Column) }},
+	{Header: "LEVEL", Value: func(item interface{}) string { return string(item.(devcontainers.ValidationIssue).Severity) }},
+	{Header: "MESSAGE", Value: func(item interface{}) string { return item.(devcontainers.ValidationIssue).Message }},
+}
+
+func createValidateCommand() *cobra.Command {
+	var strict bool
+	var validateOutput outputFlags
+	cmd := &cobra.Command{
+		Use:   "validate [<path>]",
+		Short: "validate a dev container definition",
+		Long:  "Validate the devcontainer.json (and referenced files such as the Dockerfile) for the specified path (defaults to the current directory). Exits with a non-zero exit code if errors are found",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 1 {
+				return cmd.Usage()
+			}
+			path := "." // default to current directory
+			if len(args) == 1 {
+				path = args[0]
+			}
+
+			issues, err := devcontainers.ValidateDevcontainer(path)
+			if err != nil {
+				return err
+			}
+
+			outputOptions := validateOutput.options()
+			if !outputOptions.IsDefault() {
+				if err = output.Write(os.Stdout, outputOptions, issues, validationIssueColumns); err != nil {
+					return err
+				}
+			} else {
+				for _, issue := range issues {
+					fmt.Println(issue)
+				}
+			}
+
+			errorCount := 0
+			warningCount := 0
+			for _, issue := range issues {
+				if issue.Severity == devcontainers.ValidationError {
+					errorCount++
+				} else {
+					warningCount++
+				}
+			}
+			if outputOptions.IsDefault() && len(issues) == 0 {
+				fmt.Println("No issues found")
+			}
+			if errorCount > 0 || (strict && warningCount > 0) {
+				fmt.Fprintf(os.Stderr, "Validation failed: %d error(s), %d warning(s)\n", errorCount, warningCount)
+				os.Exit(1)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&strict, "strict", false, "Exit with a non-zero exit code if there are warnings")
+	validateOutput.addFlags(cmd)
+	return cmd
+}
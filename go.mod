@@ -12,7 +12,7 @@ require (
 	github.com/spf13/viper v1.4.0
 	github.com/stretchr/testify v1.8.2
 	golang.org/x/sys v0.10.0 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 replace github.com/bradford-hamilton/dora v0.1.1 => github.com/stuartleeks/dora v0.1.5
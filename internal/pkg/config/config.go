@@ -21,7 +21,9 @@ func EnsureInitialised() {
 
 		viper.SetDefault("templatePaths", []string{})
 		viper.SetDefault("settingPaths", []string{})
+		viper.SetDefault("featurePaths", []string{})
 		viper.SetDefault("experimental", false)
+		viper.SetDefault("containerRuntime", "auto")
 
 		// TODO - allow env var for config
 		if err := viper.ReadInConfig(); err != nil {
@@ -53,14 +55,35 @@ func GetTemplateFolders() []string {
 	EnsureInitialised()
 	return viper.GetStringSlice("templatePaths")
 }
+
+// GetTemplateCacheFolder returns the folder used to cache templates cloned from git repositories
+func GetTemplateCacheFolder() string {
+	return filepath.Join(getConfigPath(), "template-cache")
+}
 func GetSnippetFolders() []string {
 	EnsureInitialised()
 	return viper.GetStringSlice("snippetPaths")
 }
+
+// GetFeatureFolders returns the folders to search for dev container features (feature folders and tarballs)
+func GetFeatureFolders() []string {
+	EnsureInitialised()
+	return viper.GetStringSlice("featurePaths")
+}
 func GetExperimentalFeaturesEnabled() bool {
 	EnsureInitialised()
 	return viper.GetBool("experimental")
 }
+
+// GetContainerRuntime returns the container runtime to use (docker, podman or auto)
+// The DEVCONTAINERX_CONTAINER_RUNTIME env var takes precedence over the config file
+func GetContainerRuntime() string {
+	if value := os.Getenv("DEVCONTAINERX_CONTAINER_RUNTIME"); value != "" {
+		return value
+	}
+	EnsureInitialised()
+	return viper.GetString("containerRuntime")
+}
 func GetAll() map[string]interface{} {
 	EnsureInitialised()
 	return viper.AllSettings()
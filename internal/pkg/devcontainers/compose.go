@@ -0,0 +1,423 @@
+package devcontainers
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/terminal"
+	"gopkg.in/yaml.v2"
+)
+
+// ComposeProject describes the docker compose project for a compose-based dev container
+type ComposeProject struct {
+	Name string
+	// Files are the compose files from dockerComposeFile (resolved to absolute paths)
+	Files []string
+	// Service is the primary service, i.e. the dev container
+	Service     string
+	RunServices []string
+	LocalFolder string
+	ConfigFile  string
+	config      *DevcontainerConfig
+}
+
+// ComposeService holds the status of a service in a compose project
+type ComposeService struct {
+	Name          string `json:"name"`
+	Primary       bool   `json:"primary"`
+	ContainerID   string `json:"containerID"`
+	ContainerName string `json:"containerName"`
+	State         string `json:"state"`
+	Status        string `json:"status"`
+}
+
+// GetComposeProjectForFolder returns the compose project for the dev container in the specified folder
+func GetComposeProjectForFolder(folderPath string) (*ComposeProject, error) {
+	absPath, err := filepath.Abs(folderPath)
+	if err != nil {
+		return nil, fmt.Errorf("Error handling path %q: %s", folderPath, err)
+	}
+	devcontainerJSONPath, err := getDevContainerJsonPath(absPath)
+	if err != nil {
+		return nil, err
+	}
+	config, err := LoadDevcontainerConfig(devcontainerJSONPath)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading devcontainer.json: %s", err)
+	}
+	return getComposeProject(absPath, devcontainerJSONPath, config)
+}
+
+func getComposeProject(absPath string, devcontainerJSONPath string, config *DevcontainerConfig) (*ComposeProject, error) {
+	if len(config.DockerComposeFile) == 0 {
+		return nil, fmt.Errorf("dev container for %q is not compose-based (no dockerComposeFile set)", absPath)
+	}
+	if config.Service == "" {
+		return nil, fmt.Errorf("service must be set for compose-based dev containers")
+	}
+	configFolder := filepath.Dir(devcontainerJSONPath)
+	files := []string{}
+	for _, file := range config.DockerComposeFile {
+		files = append(files, filepath.Join(configFolder, file))
+	}
+
+	// Prefer the project name from an existing container (e.g. started by VS Code)
+	name := ""
+	runtime, err := GetContainerRuntime()
+	if err != nil {
+		return nil, err
+	}
+	containers, err := runtime.ListContainers(true)
+	if err != nil {
+		return nil, err
+	}
+	for _, container := range containers {
+		if container.Labels[labelLocalFolder] == absPath {
+			if name, _ = getComposeLabels(container.Labels); name != "" {
+				break
+			}
+		}
+	}
+	if name == "" {
+		name, err = getDefaultComposeProjectName(absPath, files)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &ComposeProject{
+		Name:        name,
+		Files:       files,
+		Service:     config.Service,
+		RunServices: config.RunServices,
+		LocalFolder: absPath,
+		ConfigFile:  devcontainerJSONPath,
+		config:      config,
+	}, nil
+}
+
+var composeProjectNameInvalidChars = regexp.MustCompile("[^a-z0-9_-]")
+
+// getDefaultComposeProjectName returns the project name that VS Code (and compose) would use
+func getDefaultComposeProjectName(absPath string, files []string) (string, error) {
+	if name := os.Getenv("COMPOSE_PROJECT_NAME"); name != "" {
+		return name, nil
+	}
+	for _, file := range files {
+		composeFile, err := loadComposeFile(file)
+		if err != nil {
+			return "", err
+		}
+		if composeFile.Name != "" {
+			return composeFile.Name, nil
+		}
+	}
+
+	// compose defaults to the folder containing the first file
+	// VS Code uses <folder>_devcontainer when the files are in .devcontainer
+	name := filepath.Base(filepath.Dir(files[0]))
+	if name == ".devcontainer" {
+		name = filepath.Base(absPath) + "_devcontainer"
+	}
+	return composeProjectNameInvalidChars.ReplaceAllString(strings.ToLower(name), ""), nil
+}
+
+type composeFile struct {
+	Name     string                 `yaml:"name"`
+	Services map[string]interface{} `yaml:"services"`
+}
+
+func loadComposeFile(path string) (*composeFile, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading compose file: %s", err)
+	}
+	var result composeFile
+	if err = yaml.Unmarshal(buf, &result); err != nil {
+		return nil, fmt.Errorf("error parsing compose file %q: %s", path, err)
+	}
+	return &result, nil
+}
+
+// ListComposeServices returns the services declared in the compose files along with their container status
+func ListComposeServices(project *ComposeProject) ([]ComposeService, error) {
+	servicesByName := map[string]*ComposeService{}
+	for _, file := range project.Files {
+		composeFile, err := loadComposeFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for name := range composeFile.Services {
+			servicesByName[name] = &ComposeService{Name: name, State: "not created", Status: "not created"}
+		}
+	}
+
+	runtime, err := GetContainerRuntime()
+	if err != nil {
+		return nil, err
+	}
+	containers, err := runtime.ListContainers(true)
+	if err != nil {
+		return nil, err
+	}
+	for _, container := range containers {
+		containerProject, containerService := getComposeLabels(container.Labels)
+		if containerProject != project.Name || containerService == "" {
+			continue
+		}
+		servicesByName[containerService] = &ComposeService{
+			Name:          containerService,
+			ContainerID:   container.ID,
+			ContainerName: container.Name,
+			State:         container.State,
+			Status:        container.Status,
+		}
+	}
+
+	services := []ComposeService{}
+	for _, service := range servicesByName {
+		service.Primary = service.Name == project.Service
+		services = append(services, *service)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Primary != services[j].Primary {
+			return services[i].Primary
+		}
+		return services[i].Name < services[j].Name
+	})
+	return services, nil
+}
+
+// getComposeCommand returns the compose command for the runtime, e.g. `docker compose` or `docker-compose`
+func getComposeCommand(runtimeName string) ([]string, error) {
+	candidates := [][]string{{runtimeName, "compose"}, {runtimeName + "-compose"}}
+	for _, candidate := range candidates {
+		if _, err := exec.LookPath(candidate[0]); err != nil {
+			continue
+		}
+		if len(candidate) > 1 {
+			if err := exec.Command(candidate[0], append(candidate[1:], "version")...).Run(); err != nil {
+				continue
+			}
+		}
+		return candidate, nil
+	}
+	return nil, fmt.Errorf("unable to find compose command for %s (tried `%s compose` and `%s-compose`)", runtimeName, runtimeName, runtimeName)
+}
+
+func runCompose(project *ComposeProject, extraFiles []string, output io.Writer, args ...string) error {
+	runtime, err := GetContainerRuntime()
+	if err != nil {
+		return err
+	}
+	composeCommand, err := getComposeCommand(runtime.Name())
+	if err != nil {
+		return err
+	}
+	composeArgs := append(composeCommand[1:], "--project-name", project.Name)
+	for _, file := range append(project.Files, extraFiles...) {
+		composeArgs = append(composeArgs, "--file", file)
+	}
+	composeArgs = append(composeArgs, args...)
+
+	cmd := exec.Command(composeCommand[0], composeArgs...)
+	cmd.Dir = filepath.Dir(project.Files[0])
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s failed: %s", strings.Join(composeCommand, " "), strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// writeComposeOverrideFile writes a compose file that adds the dev container labels (and command override)
+// to the primary service, returning the path to the file. The caller is responsible for removing the file
+func writeComposeOverrideFile(project *ComposeProject, extraLabels map[string]string) (string, error) {
+	labels := map[string]string{}
+	for name, value := range extraLabels {
+		labels[name] = value
+	}
+	labels[labelLocalFolder] = project.LocalFolder
+	labels[labelConfigFile] = project.ConfigFile
+	if project.config.RemoteUser != "" {
+		labels[labelMetadata] = fmt.Sprintf(`[{"remoteUser":%q}]`, project.config.RemoteUser)
+	}
+
+	service := map[string]interface{}{
+		"labels": labels,
+	}
+	// overrideCommand defaults to false for compose-based dev containers
+	if project.config.OverrideCommand != nil && *project.config.OverrideCommand {
+		service["entrypoint"] = []string{"/bin/sh", "-c", "echo Container started; trap \"exit 0\" 15; while sleep 1 & wait $$!; do :; done"}
+	}
+	override := map[string]interface{}{
+		"services": map[string]interface{}{
+			project.Service: service,
+		},
+	}
+	buf, err := yaml.Marshal(override)
+	if err != nil {
+		return "", err
+	}
+
+	// each invocation gets its own file so that concurrent runs (or other users) can't replace it
+	file, err := ioutil.TempFile("", "devcontainerx-"+project.Name+"-*.override.yml")
+	if err != nil {
+		return "", err
+	}
+	_, err = file.Write(buf)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// ComposeUp starts the compose project for the dev container, returning the ID of the primary service container
+func ComposeUp(project *ComposeProject, labels map[string]string, output io.Writer) (string, error) {
+	if output == nil {
+		output = ioutil.Discard
+	}
+	services, err := ListComposeServices(project)
+	if err != nil {
+		return "", err
+	}
+	created := false
+	for _, service := range services {
+		if service.Primary && service.ContainerID != "" {
+			created = true
+		}
+	}
+
+	overridePath, err := writeComposeOverrideFile(project, labels)
+	if err != nil {
+		return "", fmt.Errorf("error writing compose override file: %s", err)
+	}
+	defer os.Remove(overridePath)
+	args := []string{"up", "--detach"}
+	if len(project.RunServices) > 0 {
+		args = append(args, project.RunServices...)
+		if !containsString(project.RunServices, project.Service) {
+			args = append(args, project.Service)
+		}
+	}
+	if err = runCompose(project, []string{overridePath}, output, args...); err != nil {
+		return "", err
+	}
+
+	containerID, err := getComposeServiceContainerID(project.Name, project.Service)
+	if err != nil {
+		return "", err
+	}
+
+	workspaceFolder := project.config.WorkspaceFolder
+	if workspaceFolder == "" {
+		workspaceFolder = "/"
+	}
+	lifecycleCommands := []LifecycleCommand{project.config.PostStartCommand}
+	if !created {
+		lifecycleCommands = []LifecycleCommand{project.config.OnCreateCommand, project.config.PostCreateCommand, project.config.PostStartCommand}
+	}
+	runtime, err := GetContainerRuntime()
+	if err != nil {
+		return containerID, err
+	}
+	if err = runLifecycleCommands(runtime, containerID, project.config, workspaceFolder, output, lifecycleCommands...); err != nil {
+		return containerID, err
+	}
+	return containerID, nil
+}
+
+// ComposeDown stops and removes the containers for the compose project
+func ComposeDown(project *ComposeProject, output io.Writer) error {
+	if output == nil {
+		output = ioutil.Discard
+	}
+	return runCompose(project, nil, output, "down")
+}
+
+func upComposeDevcontainer(absPath string, devcontainerJSONPath string, config *DevcontainerConfig, options UpOptions) (string, error) {
+	project, err := getComposeProject(absPath, devcontainerJSONPath, config)
+	if err != nil {
+		return "", err
+	}
+	return ComposeUp(project, options.Labels, options.Output)
+}
+
+// getComposeServiceContainerID returns the ID of the running container for a service in a compose project
+func getComposeServiceContainerID(projectName string, serviceName string) (string, error) {
+	runtime, err := GetContainerRuntime()
+	if err != nil {
+		return "", err
+	}
+	containers, err := runtime.ListContainers(false)
+	if err != nil {
+		return "", err
+	}
+	for _, container := range containers {
+		containerProject, containerService := getComposeLabels(container.Labels)
+		if containerProject == projectName && containerService == serviceName {
+			return container.ID, nil
+		}
+	}
+	return "", fmt.Errorf("no running container found for service %q in compose project %q", serviceName, projectName)
+}
+
+// ExecInComposeService runs a command in a service from the same compose project as the dev container
+// If the service is the dev container itself then this is the same as ExecInDevContainer
+func ExecInComposeService(devcontainerID string, serviceName string, workDir string, args []string, interactive bool) error {
+	runtime, err := GetContainerRuntime()
+	if err != nil {
+		return err
+	}
+	container, err := runtime.InspectContainer(devcontainerID)
+	if err != nil {
+		return err
+	}
+	projectName, primaryService := getComposeLabels(container.Labels)
+	if projectName == "" {
+		return fmt.Errorf("dev container %q is not part of a compose project", devcontainerID)
+	}
+	if serviceName == primaryService {
+		return ExecInDevContainer(devcontainerID, workDir, args, interactive)
+	}
+
+	serviceContainerID, err := getComposeServiceContainerID(projectName, serviceName)
+	if err != nil {
+		return err
+	}
+	err = execWithSignalForwarding(runtime, serviceContainerID, ExecOptions{
+		Cmd:     args,
+		WorkDir: workDir,
+		TTY:     terminal.IsStdinTTY() && terminal.IsTTY(),
+		Stdin:   getExecStdin(interactive),
+		Stdout:  os.Stdout,
+		Stderr:  os.Stderr,
+	})
+	if err != nil {
+		if _, ok := err.(*ExecError); ok {
+			return err
+		}
+		return fmt.Errorf("Exec: %s", err)
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package devcontainers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func writeTestComposeFile(t *testing.T, folder string, content string) string {
+	devcontainerFolder := filepath.Join(folder, ".devcontainer")
+	if err := os.MkdirAll(devcontainerFolder, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(devcontainerFolder, "docker-compose.yml")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGetDefaultComposeProjectName_UsesDevcontainerFolderNaming(t *testing.T) {
+	folder, err := ioutil.TempDir("", "devcontainer-cli-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	projectFolder := filepath.Join(folder, "My.Project")
+	composeFile := writeTestComposeFile(t, projectFolder, "services:\n  app:\n    image: alpine\n")
+
+	name, err := getDefaultComposeProjectName(projectFolder, []string{composeFile})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "myproject_devcontainer", name)
+	}
+}
+
+func TestGetDefaultComposeProjectName_UsesNameFromComposeFile(t *testing.T) {
+	folder, err := ioutil.TempDir("", "devcontainer-cli-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	composeFile := writeTestComposeFile(t, folder, "name: custom\nservices:\n  app:\n    image: alpine\n")
+
+	name, err := getDefaultComposeProjectName(folder, []string{composeFile})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "custom", name)
+	}
+}
+
+func TestListComposeServices_IncludesServicesWithoutContainers(t *testing.T) {
+	folder, err := ioutil.TempDir("", "devcontainer-cli-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	composeFile := writeTestComposeFile(t, folder, "services:\n  app:\n    image: alpine\n  db:\n    image: postgres\n  cache:\n    image: redis\n")
+
+	useFakeRuntime(t, &fakeRuntime{
+		containers: []ContainerDetails{
+			{Container: Container{ID: "abc", Name: "proj_app_1", Status: "Up 2 minutes", State: "running", Labels: map[string]string{labelComposeProject: "proj", labelComposeService: "app"}}},
+			{Container: Container{ID: "def", Name: "proj_db_1", Status: "Exited (0)", State: "exited", Labels: map[string]string{labelPodmanComposeProject: "proj", labelPodmanComposeService: "db"}}},
+			{Container: Container{ID: "ghi", Name: "other_db_1", Labels: map[string]string{labelComposeProject: "other", labelComposeService: "db"}}},
+		},
+	})
+
+	project := &ComposeProject{Name: "proj", Files: []string{composeFile}, Service: "app"}
+	services, err := ListComposeServices(project)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []ComposeService{
+		{Name: "app", Primary: true, ContainerID: "abc", ContainerName: "proj_app_1", State: "running", Status: "Up 2 minutes"},
+		{Name: "cache", State: "not created", Status: "not created"},
+		{Name: "db", ContainerID: "def", ContainerName: "proj_db_1", State: "exited", Status: "Exited (0)"},
+	}, services)
+}
+
+func TestWriteComposeOverrideFile_AddsLabelsToPrimaryService(t *testing.T) {
+	overrideCommand := true
+	project := &ComposeProject{
+		Name:        "devcontainer-cli-test-override",
+		Service:     "app",
+		LocalFolder: "/home/me/source/project1",
+		ConfigFile:  "/home/me/source/project1/.devcontainer/devcontainer.json",
+		config:      &DevcontainerConfig{RemoteUser: "vscode", OverrideCommand: &overrideCommand},
+	}
+	path, err := writeComposeOverrideFile(project, map[string]string{"extra": "value"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.Remove(path)
+	// each call writes a separate file
+	otherPath, err := writeComposeOverrideFile(project, nil)
+	if assert.NoError(t, err) {
+		defer os.Remove(otherPath)
+		assert.NotEqual(t, path, otherPath)
+	}
+	if info, err := os.Stat(path); assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var override struct {
+		Services map[string]struct {
+			Labels     map[string]string `yaml:"labels"`
+			Entrypoint []string          `yaml:"entrypoint"`
+		} `yaml:"services"`
+	}
+	if !assert.NoError(t, yaml.Unmarshal(buf, &override)) {
+		return
+	}
+	service := override.Services["app"]
+	assert.Equal(t, map[string]string{
+		"extra":          "value",
+		labelLocalFolder: "/home/me/source/project1",
+		labelConfigFile:  "/home/me/source/project1/.devcontainer/devcontainer.json",
+		labelMetadata:    `[{"remoteUser":"vscode"}]`,
+	}, service.Labels)
+	assert.Equal(t, "/bin/sh", service.Entrypoint[0])
+}
@@ -0,0 +1,237 @@
+package devcontainers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/config"
+)
+
+// ContainerRuntime is the client used to query and interact with containers
+// The implementations talk to the Docker Engine API (also served by Podman) and fall back to the docker/podman CLI
+type ContainerRuntime interface {
+	// Name returns the name of the runtime (docker or podman)
+	Name() string
+	// ListContainers returns the containers known to the runtime. If all is false only running containers are returned
+	ListContainers(all bool) ([]Container, error)
+	// InspectContainer returns the details for the specified container
+	InspectContainer(containerIDOrName string) (*ContainerDetails, error)
+	// Exec runs a command in the specified container. A non-zero exit code is returned as an *ExecError
+	Exec(containerIDOrName string, options ExecOptions) error
+	// BuildImage builds an image from a Dockerfile
+	BuildImage(options BuildOptions) error
+	// RunContainer creates and starts a (detached) container, returning the container ID
+	RunContainer(options RunOptions) (string, error)
+	// StartContainer starts a stopped container
+	StartContainer(containerIDOrName string) error
+	// StopContainer stops a running container
+	StopContainer(containerIDOrName string) error
+	// RemoveContainer removes a container. If force is true then a running container is killed and removed
+	RemoveContainer(containerIDOrName string, force bool) error
+	// RemoveImage removes an image
+	RemoveImage(image string) error
+}
+
+// Container holds the summary information for a container
+type Container struct {
+	ID     string
+	Name   string
+	Image  string
+	Labels map[string]string
+	// State is the container state (e.g. running, exited)
+	State string
+	// Status is a human-readable status (e.g. "Up 2 hours"). Falls back to State if the runtime doesn't provide it
+	Status  string
+	Created time.Time
+}
+
+// ContainerDetails holds the information returned from inspecting a container
+type ContainerDetails struct {
+	Container
+	Env    []string
+	Mounts []DockerMount
+	// UsernsMode is the user namespace mode (e.g. keep-id for rootless Podman)
+	UsernsMode string
+	// StartedAt is when the container was last started
+	StartedAt time.Time
+}
+
+// ExecOptions controls how a command is run in a container
+type ExecOptions struct {
+	Cmd     []string
+	User    string
+	WorkDir string
+	Env     []string
+	// TTY allocates a pseudo-TTY for the command
+	TTY bool
+	// Stdin, Stdout and Stderr are attached to the command if set
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// BuildOptions controls how an image is built
+type BuildOptions struct {
+	ContextPath string
+	Dockerfile  string
+	Tag         string
+	Args        map[string]string
+	Target      string
+	// Output receives the build progress output
+	Output io.Writer
+}
+
+// RunOptions controls how a container is created
+type RunOptions struct {
+	Image      string
+	Labels     map[string]string
+	Mounts     []string
+	Env        []string
+	Ports      []string
+	User       string
+	Entrypoint string
+	// ExtraArgs are passed through to the runtime CLI (e.g. runArgs from devcontainer.json)
+	ExtraArgs []string
+	Cmd       []string
+}
+
+// ExecError is returned when a command run in a container exits with a non-zero exit code
+type ExecError struct {
+	ContainerID string
+	Cmd         []string
+	ExitCode    int
+	Output      string
+}
+
+func (e *ExecError) Error() string {
+	message := fmt.Sprintf("exec %q in container %s exited with code %d", strings.Join(e.Cmd, " "), e.ContainerID, e.ExitCode)
+	if e.Output != "" {
+		message += fmt.Sprintf(" (%s)", strings.TrimSpace(e.Output))
+	}
+	return message
+}
+
+// RuntimeError is returned when the container runtime fails to handle a request
+type RuntimeError struct {
+	Operation  string
+	StatusCode int
+	Message    string
+}
+
+func (e *RuntimeError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s failed (status %d): %s", e.Operation, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s failed: %s", e.Operation, e.Message)
+}
+
+var containerRuntime ContainerRuntime
+
+// GetContainerRuntime returns the container runtime to use, creating it on first use
+// An error is returned if the containerRuntime config value isn't supported
+func GetContainerRuntime() (ContainerRuntime, error) {
+	if containerRuntime == nil {
+		runtime, err := newContainerRuntime()
+		if err != nil {
+			return nil, err
+		}
+		containerRuntime = runtime
+	}
+	return containerRuntime, nil
+}
+
+const (
+	containerRuntimeAuto   = "auto"
+	containerRuntimeDocker = "docker"
+	containerRuntimePodman = "podman"
+)
+
+func newContainerRuntime() (ContainerRuntime, error) {
+	runtimeName := strings.ToLower(config.GetContainerRuntime())
+	switch runtimeName {
+	case containerRuntimeAuto, "":
+		return detectContainerRuntime(), nil
+	case containerRuntimeDocker:
+		return newRuntimeForBinary(containerRuntimeDocker, getDockerSocketPath()), nil
+	case containerRuntimePodman:
+		return newRuntimeForBinary(containerRuntimePodman, getPodmanSocketPath()), nil
+	default:
+		return nil, fmt.Errorf("unsupported containerRuntime value %q (expected auto, docker or podman)", runtimeName)
+	}
+}
+
+// newRuntimeForBinary returns an API runtime if socketPath is set, otherwise a CLI runtime for the binary
+func newRuntimeForBinary(binary string, socketPath string) ContainerRuntime {
+	cli := &cliRuntime{binary: binary}
+	if socketPath != "" {
+		return newDockerAPIRuntime(socketPath, cli)
+	}
+	return cli
+}
+
+// detectContainerRuntime prefers an available API socket (Docker, then Podman) and then a CLI on the PATH
+func detectContainerRuntime() ContainerRuntime {
+	if socketPath := getDockerSocketPath(); socketPath != "" {
+		return newRuntimeForBinary(containerRuntimeDocker, socketPath)
+	}
+	if socketPath := getPodmanSocketPath(); socketPath != "" {
+		return newRuntimeForBinary(containerRuntimePodman, socketPath)
+	}
+	if _, err := exec.LookPath(containerRuntimeDocker); err == nil {
+		return newRuntimeForBinary(containerRuntimeDocker, "")
+	}
+	if _, err := exec.LookPath(containerRuntimePodman); err == nil {
+		return newRuntimeForBinary(containerRuntimePodman, "")
+	}
+	// default to docker and let the error surface on first use
+	return newRuntimeForBinary(containerRuntimeDocker, "")
+}
+
+// getDockerSocketPath returns the path to the Docker Engine API socket or empty string
+// if the API can't be used directly (in which case the CLI is used)
+func getDockerSocketPath() string {
+	if os.Getenv("DOCKER_CONTEXT") != "" {
+		// contexts are resolved by the CLI
+		return ""
+	}
+	socketPath := "/var/run/docker.sock"
+	if dockerHost := os.Getenv("DOCKER_HOST"); dockerHost != "" {
+		if !strings.HasPrefix(dockerHost, "unix://") {
+			// tcp/ssh hosts are handled by the CLI
+			return ""
+		}
+		socketPath = strings.TrimPrefix(dockerHost, "unix://")
+	}
+	if !isSocket(socketPath) {
+		return ""
+	}
+	return socketPath
+}
+
+func isSocket(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&os.ModeSocket != 0
+}
+
+// execOutput runs a command in the container and returns the combined stdout/stderr output
+func execOutput(runtime ContainerRuntime, containerID string, userName string, cmd ...string) (string, error) {
+	var buf bytes.Buffer
+	err := runtime.Exec(containerID, ExecOptions{
+		Cmd:    cmd,
+		User:   userName,
+		Stdout: &buf,
+		Stderr: &buf,
+	})
+	if err != nil {
+		if execErr, ok := err.(*ExecError); ok && execErr.Output == "" {
+			execErr.Output = buf.String()
+		}
+		return "", err
+	}
+	return buf.String(), nil
+}
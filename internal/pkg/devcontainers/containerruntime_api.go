@@ -0,0 +1,279 @@
+package devcontainers
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var _ ContainerRuntime = &dockerAPIRuntime{}
+
+// dockerAPIRuntime implements ContainerRuntime using the Docker Engine API over a unix socket
+// Operations that need a local terminal (interactive exec) are handled by the CLI runtime
+type dockerAPIRuntime struct {
+	client  *http.Client
+	baseURL string
+	cli     *cliRuntime
+}
+
+func newDockerAPIRuntime(socketPath string, cli *cliRuntime) *dockerAPIRuntime {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	return &dockerAPIRuntime{
+		client:  &http.Client{Transport: transport},
+		baseURL: "http://docker",
+		cli:     cli,
+	}
+}
+
+func (r *dockerAPIRuntime) Name() string {
+	return r.cli.Name()
+}
+
+// do sends a request to the API and decodes the JSON response into result (if non-nil)
+func (r *dockerAPIRuntime) do(operation string, method string, path string, body interface{}, result interface{}) error {
+	resp, err := r.send(operation, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if result == nil {
+		return nil
+	}
+	if err = json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return &RuntimeError{Operation: operation, Message: fmt.Sprintf("failed to parse response: %s", err)}
+	}
+	return nil
+}
+
+// send sends a request to the API, returning an error for non-success status codes
+// The caller is responsible for closing the response body
+func (r *dockerAPIRuntime) send(operation string, method string, path string, body interface{}) (*http.Response, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		bodyReader = bytes.NewReader(buf)
+	}
+	req, err := http.NewRequest(method, r.baseURL+path, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, &RuntimeError{Operation: operation, Message: err.Error()}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, &RuntimeError{Operation: operation, StatusCode: resp.StatusCode, Message: readAPIErrorMessage(resp.Body)}
+	}
+	return resp, nil
+}
+
+func readAPIErrorMessage(body io.Reader) string {
+	buf, _ := ioutil.ReadAll(body)
+	var apiError struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(buf, &apiError); err == nil && apiError.Message != "" {
+		return apiError.Message
+	}
+	return strings.TrimSpace(string(buf))
+}
+
+func (r *dockerAPIRuntime) ListContainers(all bool) ([]Container, error) {
+	path := "/containers/json"
+	if all {
+		path += "?all=1"
+	}
+	var results []struct {
+		ID      string            `json:"Id"`
+		Names   []string          `json:"Names"`
+		Image   string            `json:"Image"`
+		Labels  map[string]string `json:"Labels"`
+		State   string            `json:"State"`
+		Status  string            `json:"Status"`
+		Created int64             `json:"Created"`
+	}
+	if err := r.do("list containers", http.MethodGet, path, nil, &results); err != nil {
+		return []Container{}, err
+	}
+
+	containers := []Container{}
+	for _, result := range results {
+		name := ""
+		if len(result.Names) > 0 {
+			name = strings.TrimPrefix(result.Names[0], "/")
+		}
+		labels := result.Labels
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		containers = append(containers, Container{
+			ID:      result.ID,
+			Name:    name,
+			Image:   result.Image,
+			Labels:  labels,
+			State:   result.State,
+			Status:  result.Status,
+			Created: time.Unix(result.Created, 0),
+		})
+	}
+	return containers, nil
+}
+
+func (r *dockerAPIRuntime) InspectContainer(containerIDOrName string) (*ContainerDetails, error) {
+	var result containerInspectResult
+	if err := r.do("inspect container", http.MethodGet, "/containers/"+url.PathEscape(containerIDOrName)+"/json", nil, &result); err != nil {
+		return nil, err
+	}
+	return result.toContainerDetails(), nil
+}
+
+func (r *dockerAPIRuntime) Exec(containerIDOrName string, options ExecOptions) error {
+	if options.Stdin != nil || options.TTY {
+		// Interactive sessions need the local terminal handling that the CLI provides
+		return r.cli.Exec(containerIDOrName, options)
+	}
+
+	createRequest := map[string]interface{}{
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Cmd":          options.Cmd,
+		"User":         options.User,
+		"WorkingDir":   options.WorkDir,
+		"Env":          options.Env,
+	}
+	var createResponse struct {
+		ID string `json:"Id"`
+	}
+	if err := r.do("exec create", http.MethodPost, "/containers/"+url.PathEscape(containerIDOrName)+"/exec", createRequest, &createResponse); err != nil {
+		return err
+	}
+
+	resp, err := r.send("exec start", http.MethodPost, "/exec/"+createResponse.ID+"/start", map[string]interface{}{"Detach": false, "Tty": false})
+	if err != nil {
+		return err
+	}
+	err = demuxExecStream(resp.Body, options.Stdout, options.Stderr)
+	resp.Body.Close()
+	if err != nil {
+		return &RuntimeError{Operation: "exec start", Message: err.Error()}
+	}
+
+	var inspectResponse struct {
+		ExitCode int `json:"ExitCode"`
+	}
+	if err = r.do("exec inspect", http.MethodGet, "/exec/"+createResponse.ID+"/json", nil, &inspectResponse); err != nil {
+		return err
+	}
+	if inspectResponse.ExitCode != 0 {
+		return &ExecError{
+			ContainerID: containerIDOrName,
+			Cmd:         options.Cmd,
+			ExitCode:    inspectResponse.ExitCode,
+		}
+	}
+	return nil
+}
+
+func (r *dockerAPIRuntime) BuildImage(options BuildOptions) error {
+	// The CLI handles sending the build context (and BuildKit)
+	return r.cli.BuildImage(options)
+}
+
+func (r *dockerAPIRuntime) RunContainer(options RunOptions) (string, error) {
+	// ExtraArgs are CLI flags (e.g. runArgs from devcontainer.json) so creation goes via the CLI
+	return r.cli.RunContainer(options)
+}
+
+func (r *dockerAPIRuntime) StartContainer(containerIDOrName string) error {
+	resp, err := r.send("start container", http.MethodPost, "/containers/"+url.PathEscape(containerIDOrName)+"/start", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (r *dockerAPIRuntime) StopContainer(containerIDOrName string) error {
+	resp, err := r.send("stop container", http.MethodPost, "/containers/"+url.PathEscape(containerIDOrName)+"/stop", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (r *dockerAPIRuntime) RemoveContainer(containerIDOrName string, force bool) error {
+	path := "/containers/" + url.PathEscape(containerIDOrName)
+	if force {
+		path += "?force=1"
+	}
+	resp, err := r.send("remove container", http.MethodDelete, path, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (r *dockerAPIRuntime) RemoveImage(image string) error {
+	resp, err := r.send("remove image", http.MethodDelete, "/images/"+url.PathEscape(image), nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// demuxExecStream splits the multiplexed stdout/stderr stream returned by the API for non-TTY execs
+// Each frame has an 8 byte header: [stream, 0, 0, 0, size (4 bytes, big endian)]
+func demuxExecStream(stream io.Reader, stdout io.Writer, stderr io.Writer) error {
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(stream, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		var target io.Writer
+		switch header[0] {
+		case 0, 1:
+			target = stdout
+		case 2:
+			target = stderr
+		default:
+			return fmt.Errorf("unexpected stream id %d in exec output", header[0])
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(target, stream, size); err != nil {
+			return err
+		}
+	}
+}
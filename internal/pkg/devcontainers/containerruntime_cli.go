@@ -0,0 +1,268 @@
+package devcontainers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+var _ ContainerRuntime = &cliRuntime{}
+
+// cliRuntime implements ContainerRuntime by running the docker (or podman) CLI
+type cliRuntime struct {
+	binary string
+}
+
+// containerInspectResult maps the subset of the `inspect` output that we use
+// The Engine API (and `podman container inspect`) return the same shape as `docker container inspect`
+type containerInspectResult struct {
+	ID      string    `json:"Id"`
+	Name    string    `json:"Name"`
+	Created time.Time `json:"Created"`
+	Config  struct {
+		Image  string            `json:"Image"`
+		Labels map[string]string `json:"Labels"`
+		Env    []string          `json:"Env"`
+	} `json:"Config"`
+	State struct {
+		Status    string    `json:"Status"`
+		ExitCode  int       `json:"ExitCode"`
+		StartedAt time.Time `json:"StartedAt"`
+	} `json:"State"`
+	HostConfig struct {
+		UsernsMode string `json:"UsernsMode"`
+	} `json:"HostConfig"`
+	Mounts []DockerMount `json:"Mounts"`
+}
+
+func (r containerInspectResult) toContainerDetails() *ContainerDetails {
+	labels := r.Config.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	return &ContainerDetails{
+		Container: Container{
+			ID:      r.ID,
+			Name:    strings.TrimPrefix(r.Name, "/"),
+			Image:   r.Config.Image,
+			Labels:  labels,
+			State:   r.State.Status,
+			Status:  r.getStatus(),
+			Created: r.Created,
+		},
+		Env:        r.Config.Env,
+		Mounts:     r.Mounts,
+		UsernsMode: r.HostConfig.UsernsMode,
+		StartedAt:  r.State.StartedAt,
+	}
+}
+
+// getStatus returns a status in the style of `docker ps` (without the durations)
+func (r containerInspectResult) getStatus() string {
+	switch r.State.Status {
+	case "running":
+		return "Up"
+	case "exited":
+		return fmt.Sprintf("Exited (%d)", r.State.ExitCode)
+	default:
+		return r.State.Status
+	}
+}
+
+func (r *cliRuntime) Name() string {
+	return r.binary
+}
+
+func (r *cliRuntime) run(operation string, args ...string) ([]byte, error) {
+	cmd := exec.Command(r.binary, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return nil, &RuntimeError{Operation: operation, Message: message}
+	}
+	return output, nil
+}
+
+func (r *cliRuntime) ListContainers(all bool) ([]Container, error) {
+	args := []string{"ps", "--quiet", "--no-trunc"}
+	if all {
+		args = append(args, "--all")
+	}
+	output, err := r.run("list containers", args...)
+	if err != nil {
+		return []Container{}, err
+	}
+	ids := strings.Fields(string(output))
+	if len(ids) == 0 {
+		return []Container{}, nil
+	}
+
+	details, err := r.inspect(ids...)
+	if err != nil {
+		return []Container{}, err
+	}
+	containers := []Container{}
+	for _, detail := range details {
+		containers = append(containers, detail.Container)
+	}
+	return containers, nil
+}
+
+func (r *cliRuntime) InspectContainer(containerIDOrName string) (*ContainerDetails, error) {
+	details, err := r.inspect(containerIDOrName)
+	if err != nil {
+		return nil, err
+	}
+	if len(details) != 1 {
+		return nil, &RuntimeError{Operation: "inspect container", Message: fmt.Sprintf("expected 1 result for %q, got %d", containerIDOrName, len(details))}
+	}
+	return details[0], nil
+}
+
+func (r *cliRuntime) inspect(containerIDsOrNames ...string) ([]*ContainerDetails, error) {
+	args := append([]string{"container", "inspect"}, containerIDsOrNames...)
+	output, err := r.run("inspect container", args...)
+	if err != nil {
+		return nil, err
+	}
+	var results []containerInspectResult
+	if err = json.Unmarshal(output, &results); err != nil {
+		return nil, &RuntimeError{Operation: "inspect container", Message: fmt.Sprintf("failed to parse output: %s", err)}
+	}
+	details := []*ContainerDetails{}
+	for _, result := range results {
+		details = append(details, result.toContainerDetails())
+	}
+	return details, nil
+}
+
+func (r *cliRuntime) Exec(containerIDOrName string, options ExecOptions) error {
+	args := []string{"exec"}
+	if options.Stdin != nil {
+		args = append(args, "--interactive")
+	}
+	if options.TTY {
+		args = append(args, "--tty")
+	}
+	if options.WorkDir != "" {
+		args = append(args, "--workdir", options.WorkDir)
+	}
+	if options.User != "" {
+		args = append(args, "--user", options.User)
+	}
+	for _, env := range options.Env {
+		args = append(args, "--env", env)
+	}
+	args = append(args, containerIDOrName)
+	args = append(args, options.Cmd...)
+
+	cmd := exec.Command(r.binary, args...)
+	cmd.Stdin = options.Stdin
+	cmd.Stdout = options.Stdout
+	cmd.Stderr = options.Stderr
+
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return &ExecError{
+				ContainerID: containerIDOrName,
+				Cmd:         options.Cmd,
+				ExitCode:    exitErr.ExitCode(),
+			}
+		}
+		return &RuntimeError{Operation: "exec", Message: err.Error()}
+	}
+	return nil
+}
+
+func (r *cliRuntime) BuildImage(options BuildOptions) error {
+	args := []string{"build", "--file", options.Dockerfile, "--tag", options.Tag}
+	if options.Target != "" {
+		args = append(args, "--target", options.Target)
+	}
+	argNames := []string{}
+	for name := range options.Args {
+		argNames = append(argNames, name)
+	}
+	sort.Strings(argNames)
+	for _, name := range argNames {
+		args = append(args, "--build-arg", name+"="+options.Args[name])
+	}
+	args = append(args, options.ContextPath)
+
+	cmd := exec.Command(r.binary, args...)
+	cmd.Stdout = options.Output
+	cmd.Stderr = options.Output
+	if err := cmd.Run(); err != nil {
+		return &RuntimeError{Operation: "build image", Message: err.Error()}
+	}
+	return nil
+}
+
+func (r *cliRuntime) RunContainer(options RunOptions) (string, error) {
+	args := []string{"run", "--detach"}
+	labelNames := []string{}
+	for name := range options.Labels {
+		labelNames = append(labelNames, name)
+	}
+	sort.Strings(labelNames)
+	for _, name := range labelNames {
+		args = append(args, "--label", name+"="+options.Labels[name])
+	}
+	for _, mount := range options.Mounts {
+		args = append(args, "--mount", mount)
+	}
+	for _, env := range options.Env {
+		args = append(args, "--env", env)
+	}
+	for _, port := range options.Ports {
+		args = append(args, "--publish", port)
+	}
+	if options.User != "" {
+		args = append(args, "--user", options.User)
+	}
+	if options.Entrypoint != "" {
+		args = append(args, "--entrypoint", options.Entrypoint)
+	}
+	args = append(args, options.ExtraArgs...)
+	args = append(args, options.Image)
+	args = append(args, options.Cmd...)
+
+	output, err := r.run("run container", args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (r *cliRuntime) StartContainer(containerIDOrName string) error {
+	_, err := r.run("start container", "start", containerIDOrName)
+	return err
+}
+
+func (r *cliRuntime) StopContainer(containerIDOrName string) error {
+	_, err := r.run("stop container", "stop", containerIDOrName)
+	return err
+}
+
+func (r *cliRuntime) RemoveContainer(containerIDOrName string, force bool) error {
+	args := []string{"rm"}
+	if force {
+		args = append(args, "--force")
+	}
+	_, err := r.run("remove container", append(args, containerIDOrName)...)
+	return err
+}
+
+func (r *cliRuntime) RemoveImage(image string) error {
+	_, err := r.run("remove image", "rmi", image)
+	return err
+}
@@ -0,0 +1,59 @@
+package devcontainers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// getPodmanSocketPath returns the path to the Podman API socket (rootless first, then rootful)
+// or empty string if no socket is found
+func getPodmanSocketPath() string {
+	candidates := []string{}
+	if containerHost := os.Getenv("CONTAINER_HOST"); containerHost != "" {
+		if !strings.HasPrefix(containerHost, "unix://") {
+			// remote connections are handled by the CLI
+			return ""
+		}
+		candidates = append(candidates, strings.TrimPrefix(containerHost, "unix://"))
+	}
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		candidates = append(candidates, filepath.Join(runtimeDir, "podman", "podman.sock"))
+	}
+	candidates = append(candidates,
+		fmt.Sprintf("/run/user/%d/podman/podman.sock", os.Getuid()),
+		"/run/podman/podman.sock",
+	)
+	for _, candidate := range candidates {
+		if isSocket(candidate) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// getRootlessExecUser returns the user to pass to `exec --user` when no user is configured for the dev container
+// With rootless Podman and --userns=keep-id the host user is mapped into the container, so exec'ing as that
+// UID/GID (rather than the image default, typically root) keeps file ownership in the workspace mount consistent
+func getRootlessExecUser(runtimeName string, container *ContainerDetails) string {
+	if runtimeName != containerRuntimePodman || os.Getuid() <= 0 {
+		return ""
+	}
+	if container.UsernsMode != "keep-id" && !strings.HasPrefix(container.UsernsMode, "keep-id:") {
+		return ""
+	}
+
+	uid := fmt.Sprintf("%d", os.Getuid())
+	gid := fmt.Sprintf("%d", os.Getgid())
+	// keep-id can override the container IDs, e.g. keep-id:uid=1000,gid=1000
+	options := strings.TrimPrefix(strings.TrimPrefix(container.UsernsMode, "keep-id"), ":")
+	for _, option := range strings.Split(options, ",") {
+		if strings.HasPrefix(option, "uid=") {
+			uid = strings.TrimPrefix(option, "uid=")
+		} else if strings.HasPrefix(option, "gid=") {
+			gid = strings.TrimPrefix(option, "gid=")
+		}
+	}
+	return uid + ":" + gid
+}
@@ -0,0 +1,49 @@
+package devcontainers
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRootlessExecUser_KeepIDUsesHostUser(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("test requires a non-root user")
+	}
+	container := &ContainerDetails{UsernsMode: "keep-id"}
+
+	user := getRootlessExecUser(containerRuntimePodman, container)
+
+	assert.Equal(t, fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()), user)
+}
+
+func TestGetRootlessExecUser_KeepIDWithOptions(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("test requires a non-root user")
+	}
+	container := &ContainerDetails{UsernsMode: "keep-id:uid=1000,gid=1001"}
+
+	user := getRootlessExecUser(containerRuntimePodman, container)
+
+	assert.Equal(t, "1000:1001", user)
+}
+
+func TestGetRootlessExecUser_IgnoresDocker(t *testing.T) {
+	container := &ContainerDetails{UsernsMode: "keep-id"}
+
+	user := getRootlessExecUser(containerRuntimeDocker, container)
+
+	assert.Equal(t, "", user)
+}
+
+func TestGetComposeLabels_UsesPodmanComposeLabels(t *testing.T) {
+	project, service := getComposeLabels(map[string]string{
+		labelPodmanComposeProject: "myproject",
+		labelPodmanComposeService: "web",
+	})
+
+	assert.Equal(t, "myproject", project)
+	assert.Equal(t, "web", service)
+}
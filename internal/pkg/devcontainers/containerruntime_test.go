@@ -0,0 +1,345 @@
+package devcontainers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRuntime is a ContainerRuntime for testing that returns canned responses
+type fakeRuntime struct {
+	containers []ContainerDetails
+	// execHandler is called for Exec. If nil, Exec succeeds with no output
+	execHandler  func(containerID string, options ExecOptions) error
+	execCalls    []ExecOptions
+	builds       []BuildOptions
+	runs         []RunOptions
+	starts       []string
+	stops        []string
+	removes      []string
+	imageRemoves []string
+	// removeImageErr is returned from RemoveImage if set
+	removeImageErr error
+}
+
+var _ ContainerRuntime = &fakeRuntime{}
+
+func (r *fakeRuntime) Name() string {
+	return "fake"
+}
+func (r *fakeRuntime) ListContainers(all bool) ([]Container, error) {
+	containers := []Container{}
+	for _, container := range r.containers {
+		if !all && container.State != "" && container.State != "running" {
+			continue
+		}
+		containers = append(containers, container.Container)
+	}
+	return containers, nil
+}
+func (r *fakeRuntime) InspectContainer(containerIDOrName string) (*ContainerDetails, error) {
+	for _, container := range r.containers {
+		if container.ID == containerIDOrName || container.Name == containerIDOrName {
+			result := container
+			return &result, nil
+		}
+	}
+	return nil, &RuntimeError{Operation: "inspect container", StatusCode: 404, Message: "No such container: " + containerIDOrName}
+}
+func (r *fakeRuntime) Exec(containerIDOrName string, options ExecOptions) error {
+	r.execCalls = append(r.execCalls, options)
+	if r.execHandler == nil {
+		return nil
+	}
+	return r.execHandler(containerIDOrName, options)
+}
+
+func (r *fakeRuntime) BuildImage(options BuildOptions) error {
+	r.builds = append(r.builds, options)
+	return nil
+}
+func (r *fakeRuntime) RunContainer(options RunOptions) (string, error) {
+	r.runs = append(r.runs, options)
+	id := fmt.Sprintf("container%d", len(r.runs))
+	r.containers = append(r.containers, ContainerDetails{Container: Container{ID: id, Image: options.Image, Labels: options.Labels}})
+	return id, nil
+}
+func (r *fakeRuntime) StartContainer(containerIDOrName string) error {
+	r.starts = append(r.starts, containerIDOrName)
+	return nil
+}
+
+func (r *fakeRuntime) StopContainer(containerIDOrName string) error {
+	r.stops = append(r.stops, containerIDOrName)
+	return nil
+}
+func (r *fakeRuntime) RemoveContainer(containerIDOrName string, force bool) error {
+	r.removes = append(r.removes, containerIDOrName)
+	containers := []ContainerDetails{}
+	for _, container := range r.containers {
+		if container.ID != containerIDOrName && container.Name != containerIDOrName {
+			containers = append(containers, container)
+		}
+	}
+	r.containers = containers
+	return nil
+}
+func (r *fakeRuntime) RemoveImage(image string) error {
+	r.imageRemoves = append(r.imageRemoves, image)
+	return r.removeImageErr
+}
+
+// useFakeRuntime sets the package container runtime for the duration of a test
+func useFakeRuntime(t *testing.T, runtime *fakeRuntime) {
+	previous := containerRuntime
+	containerRuntime = runtime
+	t.Cleanup(func() { containerRuntime = previous })
+}
+
+func TestListDevcontainers_ReturnsOnlyDevcontainers(t *testing.T) {
+	useFakeRuntime(t, &fakeRuntime{
+		containers: []ContainerDetails{
+			{Container: Container{ID: "abc", Name: "festive_saha", Labels: map[string]string{labelLocalFolder: "/home/me/source/project1"}}},
+			{Container: Container{ID: "def", Name: "not_a_devcontainer", Labels: map[string]string{}}},
+		},
+	})
+
+	devcontainers, err := ListDevcontainers()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []DevcontainerInfo{
+		{
+			ContainerID:      "abc",
+			ContainerName:    "festive_saha",
+			DevcontainerName: "project1",
+			LocalFolderPath:  "/home/me/source/project1",
+		},
+	}, devcontainers)
+}
+
+func TestListAllDevcontainers_IncludesStoppedDevcontainers(t *testing.T) {
+	created := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	useFakeRuntime(t, &fakeRuntime{
+		containers: []ContainerDetails{
+			{Container: Container{ID: "abc", Name: "festive_saha", State: "running", Status: "Up 2 hours", Labels: map[string]string{labelLocalFolder: "/home/me/source/project1"}}},
+			{Container: Container{ID: "def", Name: "sleepy_hopper", Image: "vsc-project2", State: "exited", Status: "Exited (0) 3 days ago", Created: created, Labels: map[string]string{
+				labelLocalFolder:    "/home/me/source/project2",
+				labelComposeProject: "project2_devcontainer",
+				labelComposeService: "app",
+			}}},
+		},
+	})
+
+	devcontainers, err := ListDevcontainers()
+	if assert.NoError(t, err) {
+		assert.Len(t, devcontainers, 1)
+	}
+
+	devcontainers, err = ListAllDevcontainers()
+	if !assert.NoError(t, err) || !assert.Len(t, devcontainers, 2) {
+		return
+	}
+	assert.Equal(t, DevcontainerInfo{
+		ContainerID:      "def",
+		ContainerName:    "sleepy_hopper",
+		DevcontainerName: "project2",
+		LocalFolderPath:  "/home/me/source/project2",
+		State:            "exited",
+		Status:           "Exited (0) 3 days ago",
+		Created:          created,
+		Image:            "vsc-project2",
+		ComposeProject:   "project2_devcontainer",
+		ComposeService:   "app",
+	}, devcontainers[1])
+}
+
+func TestGetContainerRuntime_ReturnsErrorForUnsupportedValue(t *testing.T) {
+	previousRuntime := containerRuntime
+	containerRuntime = nil
+	previous, hadPrevious := os.LookupEnv("DEVCONTAINERX_CONTAINER_RUNTIME")
+	os.Setenv("DEVCONTAINERX_CONTAINER_RUNTIME", "rkt")
+	t.Cleanup(func() {
+		containerRuntime = previousRuntime
+		if hadPrevious {
+			os.Setenv("DEVCONTAINERX_CONTAINER_RUNTIME", previous)
+		} else {
+			os.Unsetenv("DEVCONTAINERX_CONTAINER_RUNTIME")
+		}
+	})
+
+	runtime, err := GetContainerRuntime()
+	assert.Nil(t, runtime)
+	assert.EqualError(t, err, `unsupported containerRuntime value "rkt" (expected auto, docker or podman)`)
+	_, err = ListDevcontainers()
+	assert.Error(t, err)
+}
+
+func TestContainerInspectResult_StatusIncludesExitCode(t *testing.T) {
+	var result containerInspectResult
+	err := json.Unmarshal([]byte(`{"Id":"abc","Name":"/festive_saha","Created":"2020-10-01T12:00:00.123456789Z","State":{"Status":"exited","ExitCode":137}}`), &result)
+	if !assert.NoError(t, err) {
+		return
+	}
+	details := result.toContainerDetails()
+	assert.Equal(t, "exited", details.State)
+	assert.Equal(t, "Exited (137)", details.Status)
+	assert.Equal(t, 2020, details.Created.Year())
+}
+
+func TestGetSourceInfoFromDevContainer_ReturnsMatchingMount(t *testing.T) {
+	useFakeRuntime(t, &fakeRuntime{
+		containers: []ContainerDetails{
+			{
+				Container: Container{ID: "abc", Labels: map[string]string{labelLocalFolder: "/not/a/repo/project1"}},
+				Mounts: []DockerMount{
+					{Source: "/var/run/docker.sock", Destination: "/var/run/docker.sock"},
+					{Source: "/not/a/repo/project1", Destination: "/workspaces/project1"},
+				},
+			},
+		},
+	})
+
+	sourceInfo, err := GetSourceInfoFromDevContainer("abc")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "/not/a/repo/project1", sourceInfo.DevcontainerFolder)
+	assert.Equal(t, "/workspaces/project1", sourceInfo.DockerMount.Destination)
+}
+
+func TestTestContainerPathExists_UsesExitCode(t *testing.T) {
+	useFakeRuntime(t, &fakeRuntime{
+		execHandler: func(containerID string, options ExecOptions) error {
+			if options.Cmd[2] == "[[ -d /workspaces/project1 ]]" {
+				return nil
+			}
+			return &ExecError{ContainerID: containerID, Cmd: options.Cmd, ExitCode: 1}
+		},
+	})
+
+	exists, err := testContainerPathExists("abc", "/workspaces/project1")
+	if assert.NoError(t, err) {
+		assert.True(t, exists)
+	}
+	exists, err = testContainerPathExists("abc", "/workspaces/other")
+	if assert.NoError(t, err) {
+		assert.False(t, exists)
+	}
+}
+
+func TestGetUserNameFromRunningContainer_ReadsMetadataLabel(t *testing.T) {
+	useFakeRuntime(t, &fakeRuntime{
+		containers: []ContainerDetails{
+			{Container: Container{ID: "abc", Labels: map[string]string{labelMetadata: `[{"id":"feature"},{"remoteUser":"vscode"}]`}}},
+		},
+	})
+
+	userName, err := getUserNameFromRunningContainer("abc")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "vscode", userName)
+	}
+}
+
+func writeExecFrame(buf *bytes.Buffer, stream byte, content string) {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(content)))
+	buf.Write(header)
+	buf.WriteString(content)
+}
+
+func TestDemuxExecStream(t *testing.T) {
+	var stream bytes.Buffer
+	writeExecFrame(&stream, 1, "hello ")
+	writeExecFrame(&stream, 2, "oops")
+	writeExecFrame(&stream, 1, "world")
+
+	var stdout, stderr bytes.Buffer
+	err := demuxExecStream(&stream, &stdout, &stderr)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "hello world", stdout.String())
+		assert.Equal(t, "oops", stderr.String())
+	}
+}
+
+func TestDockerAPIRuntime_ExecReturnsExitCode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/abc/exec":
+			fmt.Fprint(w, `{"Id":"exec1"}`)
+		case "/exec/exec1/start":
+			var buf bytes.Buffer
+			writeExecFrame(&buf, 2, "not found")
+			_, _ = w.Write(buf.Bytes())
+		case "/exec/exec1/json":
+			fmt.Fprint(w, `{"ExitCode":2}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"unexpected path"}`)
+		}
+	}))
+	defer server.Close()
+
+	runtime := &dockerAPIRuntime{client: server.Client(), baseURL: server.URL}
+	var output bytes.Buffer
+	err := runtime.Exec("abc", ExecOptions{Cmd: []string{"ls", "/missing"}, Stdout: &output, Stderr: &output})
+
+	execErr, ok := err.(*ExecError)
+	if assert.True(t, ok, "expected ExecError, got %v", err) {
+		assert.Equal(t, 2, execErr.ExitCode)
+	}
+	assert.Equal(t, "not found", output.String())
+}
+
+func TestDockerAPIRuntime_InspectReturnsRuntimeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message":"No such container: missing"}`)
+	}))
+	defer server.Close()
+
+	runtime := &dockerAPIRuntime{client: server.Client(), baseURL: server.URL}
+	_, err := runtime.InspectContainer("missing")
+
+	runtimeErr, ok := err.(*RuntimeError)
+	if assert.True(t, ok, "expected RuntimeError, got %v", err) {
+		assert.Equal(t, http.StatusNotFound, runtimeErr.StatusCode)
+		assert.Equal(t, "No such container: missing", runtimeErr.Message)
+	}
+}
+
+func TestCLIRuntime_ExecPassesStdioAndReturnsExitCode(t *testing.T) {
+	folder, err := ioutil.TempDir("", "devcontainer-cli-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	// fake CLI that echoes its args to stdout, writes to stderr and exits with code 3
+	binary := filepath.Join(folder, "fake-docker")
+	script := "#!/bin/sh\necho \"$@\"\necho oops >&2\nexit 3\n"
+	if err = ioutil.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	runtime := &cliRuntime{binary: binary}
+	var stdout, stderr bytes.Buffer
+	err = runtime.Exec("abc", ExecOptions{Cmd: []string{"make", "test"}, Stdin: &bytes.Buffer{}, Stdout: &stdout, Stderr: &stderr})
+
+	execErr, ok := err.(*ExecError)
+	if assert.True(t, ok, "expected ExecError, got %v", err) {
+		assert.Equal(t, 3, execErr.ExitCode)
+	}
+	assert.Equal(t, "exec --interactive abc make test\n", stdout.String())
+	assert.Equal(t, "oops\n", stderr.String())
+}
@@ -5,6 +5,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	ioutil2 "github.com/stuartleeks/devcontainer-cli/internal/pkg/ioutil"
 )
 
 func getDevContainerJsonPath(folderPath string) (string, error) {
@@ -20,3 +22,24 @@ func getDevContainerJsonPath(folderPath string) (string, error) {
 
 	return "", fmt.Errorf("devcontainer.json not found. Looked for %s", strings.Join(pathsToTest, ","))
 }
+
+// getProjectDevcontainerJSONPath returns the path of the devcontainer.json for projectFolder in fs:
+// .devcontainer/devcontainer.json, or .devcontainer.json if the project only has that file
+func getProjectDevcontainerJSONPath(fs *ioutil2.OverlayFS, projectFolder string) string {
+	devcontainerJSONPath := filepath.Join(projectFolder, ".devcontainer", "devcontainer.json")
+	if !fs.Exists(devcontainerJSONPath) && fs.Exists(filepath.Join(projectFolder, ".devcontainer.json")) {
+		return filepath.Join(projectFolder, ".devcontainer.json")
+	}
+	return devcontainerJSONPath
+}
+
+// getDevcontainerRecordPath returns the path for a file that records changes made to the dev container for
+// projectFolder (e.g. the template lock). The file is kept next to devcontainer.json: in the .devcontainer folder
+// or, for projects using a .devcontainer.json file, as a hidden file in projectFolder
+func getDevcontainerRecordPath(fs *ioutil2.OverlayFS, projectFolder string, filename string) string {
+	devcontainerJSONPath := getProjectDevcontainerJSONPath(fs, projectFolder)
+	if filepath.Base(devcontainerJSONPath) == ".devcontainer.json" {
+		return filepath.Join(projectFolder, "."+filename)
+	}
+	return filepath.Join(filepath.Dir(devcontainerJSONPath), filename)
+}
@@ -0,0 +1,241 @@
+package devcontainers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+)
+
+// DevcontainerConfig holds the properties from devcontainer.json that the CLI uses
+type DevcontainerConfig struct {
+	Name string `json:"name"`
+
+	Image      string             `json:"image"`
+	DockerFile string             `json:"dockerFile"`
+	Context    string             `json:"context"`
+	Build      *DevcontainerBuild `json:"build"`
+
+	DockerComposeFile StringList `json:"dockerComposeFile"`
+	Service           string     `json:"service"`
+	RunServices       []string   `json:"runServices"`
+
+	RunArgs         []string            `json:"runArgs"`
+	Mounts          []DevcontainerMount `json:"mounts"`
+	ContainerEnv    map[string]string   `json:"containerEnv"`
+	ForwardPorts    []interface{}       `json:"forwardPorts"`
+	AppPort         interface{}         `json:"appPort"`
+	OverrideCommand *bool               `json:"overrideCommand"`
+
+	RemoteUser      string `json:"remoteUser"`
+	ContainerUser   string `json:"containerUser"`
+	WorkspaceFolder string `json:"workspaceFolder"`
+	WorkspaceMount  string `json:"workspaceMount"`
+
+	// RemoteEnv values can be null to unset a variable
+	RemoteEnv    map[string]*string `json:"remoteEnv"`
+	UserEnvProbe string             `json:"userEnvProbe"`
+
+	// Features values are the feature options (or a version string)
+	Features                    map[string]interface{} `json:"features"`
+	OverrideFeatureInstallOrder []string               `json:"overrideFeatureInstallOrder"`
+
+	OnCreateCommand   LifecycleCommand `json:"onCreateCommand"`
+	PostCreateCommand LifecycleCommand `json:"postCreateCommand"`
+	PostStartCommand  LifecycleCommand `json:"postStartCommand"`
+}
+
+// DevcontainerBuild holds the `build` properties from devcontainer.json
+type DevcontainerBuild struct {
+	Dockerfile string            `json:"dockerfile"`
+	Context    string            `json:"context"`
+	Args       map[string]string `json:"args"`
+	Target     string            `json:"target"`
+}
+
+// StringList handles properties that can be either a string or an array of strings
+type StringList []string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*l = StringList{value}
+		return nil
+	}
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("expected string or array of strings: %s", err)
+	}
+	*l = StringList(values)
+	return nil
+}
+
+// DevcontainerMount is a mount from devcontainer.json. Mounts can be specified as
+// a string in `docker run --mount` format or as an object with type/source/target
+type DevcontainerMount string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (m *DevcontainerMount) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*m = DevcontainerMount(value)
+		return nil
+	}
+	var mount struct {
+		Type   string `json:"type"`
+		Source string `json:"source"`
+		Target string `json:"target"`
+	}
+	if err := json.Unmarshal(data, &mount); err != nil {
+		return fmt.Errorf("expected mount string or object: %s", err)
+	}
+	value = fmt.Sprintf("type=%s,target=%s", mount.Type, mount.Target)
+	if mount.Source != "" {
+		value = fmt.Sprintf("type=%s,source=%s,target=%s", mount.Type, mount.Source, mount.Target)
+	}
+	*m = DevcontainerMount(value)
+	return nil
+}
+
+// LifecycleCommand holds a lifecycle command (e.g. postCreateCommand) from devcontainer.json
+// Each entry is a command to run. A string command is run via /bin/sh, an array is run directly
+// and an object provides a set of named commands (which are run in name order)
+type LifecycleCommand [][]string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (c *LifecycleCommand) UnmarshalJSON(data []byte) error {
+	parseCommand := func(data []byte) ([]string, error) {
+		var value string
+		if err := json.Unmarshal(data, &value); err == nil {
+			if value == "" {
+				return nil, nil
+			}
+			return []string{"/bin/sh", "-c", value}, nil
+		}
+		var values []string
+		if err := json.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("expected string or array of strings: %s", err)
+		}
+		return values, nil
+	}
+
+	if command, err := parseCommand(data); err == nil {
+		if command == nil {
+			*c = LifecycleCommand{}
+		} else {
+			*c = LifecycleCommand{command}
+		}
+		return nil
+	}
+
+	var namedCommands map[string]json.RawMessage
+	if err := json.Unmarshal(data, &namedCommands); err != nil {
+		return fmt.Errorf("expected string, array or object: %s", err)
+	}
+	names := []string{}
+	for name := range namedCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	commands := LifecycleCommand{}
+	for _, name := range names {
+		command, err := parseCommand(namedCommands[name])
+		if err != nil {
+			return fmt.Errorf("command %q: %s", name, err)
+		}
+		if command != nil {
+			commands = append(commands, command)
+		}
+	}
+	*c = commands
+	return nil
+}
+
+// LoadDevcontainerConfig reads the devcontainer.json file at the specified path
+// Variables (e.g. ${localWorkspaceFolder}) are resolved, except for ${containerEnv:VAR}
+// which is resolved when the container environment is available (e.g. for remoteEnv)
+func LoadDevcontainerConfig(devcontainerJSONPath string) (*DevcontainerConfig, error) {
+	buf, err := ioutil.ReadFile(devcontainerJSONPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %q: %s", devcontainerJSONPath, err)
+	}
+	return loadDevcontainerConfigContent(devcontainerJSONPath, buf)
+}
+
+// loadDevcontainerConfigContent parses the devcontainer.json content for devcontainerJSONPath and resolves variables
+func loadDevcontainerConfigContent(devcontainerJSONPath string, buf []byte) (*DevcontainerConfig, error) {
+	document, err := parseJSONC(buf)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing file %q: %s", devcontainerJSONPath, err)
+	}
+	var workspaceFolder string
+	if node := document.Find("workspaceFolder"); node != nil && node.Kind == jsoncString {
+		_ = json.Unmarshal(document.standardized[node.Start:node.End], &workspaceFolder)
+	}
+	variables, err := getDevcontainerVariables(devcontainerJSONPath, workspaceFolder)
+	if err != nil {
+		return nil, err
+	}
+	config, err := loadDevcontainerConfigFromDocument(document, variables)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing file %q: %s", devcontainerJSONPath, err)
+	}
+	return config, nil
+}
+
+// parseDevcontainerConfig parses devcontainer.json content without resolving variables
+func parseDevcontainerConfig(buf []byte) (*DevcontainerConfig, error) {
+	document, err := parseJSONC(buf)
+	if err != nil {
+		return nil, err
+	}
+	return loadDevcontainerConfigFromDocument(document, nil)
+}
+
+func loadDevcontainerConfigFromDocument(document *jsoncDocument, variables *devcontainerVariables) (*DevcontainerConfig, error) {
+	var config DevcontainerConfig
+	if variables == nil {
+		if err := document.Unmarshal(&config); err != nil {
+			return nil, err
+		}
+		return &config, nil
+	}
+
+	var values interface{}
+	if err := document.Unmarshal(&values); err != nil {
+		return nil, err
+	}
+	buf, err := json.Marshal(variables.substituteJSONValue(values))
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(buf, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// getDockerfilePath returns the path to the Dockerfile (or empty string if the dev container is image-based)
+// along with the build context. Paths are relative to the folder containing devcontainer.json
+func (c *DevcontainerConfig) getDockerfilePath(devcontainerJSONPath string) (string, string) {
+	configFolder := filepath.Dir(devcontainerJSONPath)
+	dockerfile := c.DockerFile
+	context := c.Context
+	if c.Build != nil {
+		if c.Build.Dockerfile != "" {
+			dockerfile = c.Build.Dockerfile
+		}
+		if c.Build.Context != "" {
+			context = c.Build.Context
+		}
+	}
+	if dockerfile == "" {
+		return "", ""
+	}
+	if context == "" {
+		context = "."
+	}
+	return filepath.Join(configFolder, dockerfile), filepath.Join(configFolder, context)
+}
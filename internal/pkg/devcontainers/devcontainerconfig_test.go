@@ -0,0 +1,55 @@
+package devcontainers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDevcontainerConfig_LifecycleCommandForms(t *testing.T) {
+	config, err := parseDevcontainerConfig([]byte(`{
+		"onCreateCommand": ["npm", "install"],
+		"postCreateCommand": "echo hello",
+		"postStartCommand": {
+			"server": "npm start",
+			"db": ["./start-db.sh"]
+		}
+	}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, LifecycleCommand{{"npm", "install"}}, config.OnCreateCommand)
+	assert.Equal(t, LifecycleCommand{{"/bin/sh", "-c", "echo hello"}}, config.PostCreateCommand)
+	assert.Equal(t, LifecycleCommand{{"./start-db.sh"}, {"/bin/sh", "-c", "npm start"}}, config.PostStartCommand)
+}
+
+func TestParseDevcontainerConfig_MountsAndComposeFiles(t *testing.T) {
+	config, err := parseDevcontainerConfig([]byte(`{
+		// comments are allowed
+		"dockerComposeFile": "docker-compose.yml",
+		"mounts": [
+			"source=/var/run/docker.sock,target=/var/run/docker.sock,type=bind",
+			{ "type": "volume", "source": "cache", "target": "/cache" },
+		],
+	}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, StringList{"docker-compose.yml"}, config.DockerComposeFile)
+	assert.Equal(t, []DevcontainerMount{
+		"source=/var/run/docker.sock,target=/var/run/docker.sock,type=bind",
+		"type=volume,source=cache,target=/cache",
+	}, config.Mounts)
+}
+
+func TestGetDockerfilePath_UsesBuildProperties(t *testing.T) {
+	config, err := parseDevcontainerConfig([]byte(`{
+		"build": { "dockerfile": "Dockerfile", "context": ".." }
+	}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	dockerfile, context := config.getDockerfilePath("/src/project/.devcontainer/devcontainer.json")
+	assert.Equal(t, "/src/project/.devcontainer/Dockerfile", dockerfile)
+	assert.Equal(t, "/src/project", context)
+}
@@ -0,0 +1,109 @@
+package devcontainers
+
+// devcontainerSchemaJSON is the JSON Schema used to validate devcontainer.json
+// It is based on the devcontainer spec schema (https://containers.dev/implementors/json_schema/) and
+// is included in the binary so that validation works offline. Only the schema keywords supported
+// by jsonSchema are used
+const devcontainerSchemaJSON = `{
+	"type": "object",
+	"properties": {
+		"$schema": { "type": "string" },
+		"name": { "type": "string" },
+
+		"image": { "type": "string" },
+		"dockerFile": { "type": "string" },
+		"context": { "type": "string" },
+		"build": {
+			"type": "object",
+			"properties": {
+				"dockerfile": { "type": "string" },
+				"context": { "type": "string" },
+				"args": { "type": "object", "additionalProperties": { "type": "string" } },
+				"target": { "type": "string" },
+				"cacheFrom": { "type": ["string", "array"], "items": { "type": "string" } },
+				"options": { "type": "array", "items": { "type": "string" } }
+			},
+			"additionalProperties": false
+		},
+		"appPort": {
+			"type": ["integer", "string", "array"],
+			"items": { "type": ["integer", "string"] }
+		},
+		"runArgs": { "type": "array", "items": { "type": "string" } },
+		"workspaceMount": { "type": "string" },
+		"workspaceFolder": { "type": "string" },
+		"overrideCommand": { "type": "boolean" },
+		"shutdownAction": { "type": "string", "enum": ["none", "stopContainer", "stopCompose"] },
+
+		"dockerComposeFile": { "type": ["string", "array"], "items": { "type": "string" } },
+		"service": { "type": "string" },
+		"runServices": { "type": "array", "items": { "type": "string" } },
+
+		"forwardPorts": { "type": "array", "items": { "type": ["integer", "string"] } },
+		"portsAttributes": { "type": "object" },
+		"otherPortsAttributes": { "type": "object" },
+		"containerEnv": { "type": "object", "additionalProperties": { "type": "string" } },
+		"remoteEnv": { "type": "object", "additionalProperties": { "type": ["string", "null"] } },
+		"containerUser": { "type": "string" },
+		"remoteUser": { "type": "string" },
+		"updateRemoteUserUID": { "type": "boolean" },
+		"userEnvProbe": { "type": "string", "enum": ["none", "loginShell", "interactiveShell", "loginInteractiveShell"] },
+		"mounts": {
+			"type": "array",
+			"items": {
+				"type": ["string", "object"],
+				"properties": {
+					"type": { "type": "string", "enum": ["bind", "volume"] },
+					"source": { "type": "string" },
+					"target": { "type": "string" }
+				},
+				"required": ["type", "target"],
+				"additionalProperties": false
+			}
+		},
+		"init": { "type": "boolean" },
+		"privileged": { "type": "boolean" },
+		"capAdd": { "type": "array", "items": { "type": "string" } },
+		"securityOpt": { "type": "array", "items": { "type": "string" } },
+
+		"initializeCommand": { "$ref": "#/definitions/lifecycleCommand" },
+		"onCreateCommand": { "$ref": "#/definitions/lifecycleCommand" },
+		"updateContentCommand": { "$ref": "#/definitions/lifecycleCommand" },
+		"postCreateCommand": { "$ref": "#/definitions/lifecycleCommand" },
+		"postStartCommand": { "$ref": "#/definitions/lifecycleCommand" },
+		"postAttachCommand": { "$ref": "#/definitions/lifecycleCommand" },
+		"waitFor": { "type": "string", "enum": ["initializeCommand", "onCreateCommand", "updateContentCommand", "postCreateCommand", "postStartCommand"] },
+
+		"features": {
+			"type": "object",
+			"additionalProperties": { "type": ["object", "string", "boolean"] }
+		},
+		"overrideFeatureInstallOrder": { "type": "array", "items": { "type": "string" } },
+		"hostRequirements": {
+			"type": "object",
+			"properties": {
+				"cpus": { "type": "integer" },
+				"memory": { "type": "string" },
+				"storage": { "type": "string" },
+				"gpu": { "type": ["boolean", "string", "object"] }
+			},
+			"additionalProperties": false
+		},
+		"customizations": { "type": "object" },
+
+		"settings": { "type": "object" },
+		"extensions": { "type": "array", "items": { "type": "string" } },
+		"devPort": { "type": "integer" }
+	},
+	"additionalProperties": false,
+	"definitions": {
+		"lifecycleCommand": {
+			"type": ["string", "array", "object"],
+			"items": { "type": "string" },
+			"additionalProperties": {
+				"type": ["string", "array"],
+				"items": { "type": "string" }
+			}
+		}
+	}
+}`
@@ -1,16 +1,16 @@
 package devcontainers
 
 import (
-	"bufio"
-	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/stuartleeks/devcontainer-cli/internal/pkg/terminal"
 	"github.com/stuartleeks/devcontainer-cli/internal/pkg/wsl"
@@ -24,42 +24,64 @@ import (
 
 // DevcontainerInfo holds details about a devcontainer
 type DevcontainerInfo struct {
-	ContainerID      string `json:"containerID"`
-	ContainerName    string `json:"containerName"`
-	DevcontainerName string `json:"devcontainerName"`
-	LocalFolderPath  string `json:"localFolderPath"`
+	ContainerID      string    `json:"containerID"`
+	ContainerName    string    `json:"containerName"`
+	DevcontainerName string    `json:"devcontainerName"`
+	LocalFolderPath  string    `json:"localFolderPath"`
+	State            string    `json:"state"`
+	Status           string    `json:"status"`
+	Created          time.Time `json:"created"`
+	Image            string    `json:"image"`
+	ComposeProject   string    `json:"composeProject,omitempty"`
+	ComposeService   string    `json:"composeService,omitempty"`
 }
 
 const (
-	listPartID                     int = 0
-	listPartLocalFolder            int = 1
-	listPartComposeProject         int = 2
-	listPartComposeService         int = 3
-	listPartComposeContainerNumber int = 4
-	listPartContainerName          int = 5
+	labelLocalFolder    = "devcontainer.local_folder"
+	labelMetadata       = "devcontainer.metadata"
+	labelComposeProject = "com.docker.compose.project"
+	labelComposeService = "com.docker.compose.service"
+	// podman-compose uses its own labels
+	labelPodmanComposeProject = "io.podman.compose.project"
+	labelPodmanComposeService = "io.podman.compose.service"
 )
 
-var _ = listPartComposeContainerNumber
+// getComposeLabels returns the compose project and service for a container from either the docker or podman compose labels
+func getComposeLabels(labels map[string]string) (string, string) {
+	project := labels[labelComposeProject]
+	if project == "" {
+		project = labels[labelPodmanComposeProject]
+	}
+	service := labels[labelComposeService]
+	if service == "" {
+		service = labels[labelPodmanComposeService]
+	}
+	return project, service
+}
 
-// ListDevcontainers returns a list of devcontainers
+// ListDevcontainers returns a list of running devcontainers
 func ListDevcontainers() ([]DevcontainerInfo, error) {
-	cmd := exec.Command("docker", "ps", "--format", "{{.ID}}|{{.Label \"devcontainer.local_folder\"}}|{{.Label \"com.docker.compose.project\"}}|{{.Label \"com.docker.compose.service\"}}|{{.Label \"com.docker.compose.container-number\"}}|{{.Names}}")
+	return listDevcontainers(false)
+}
 
-	output, err := cmd.Output()
+// ListAllDevcontainers returns a list of devcontainers including stopped/exited containers
+func ListAllDevcontainers() ([]DevcontainerInfo, error) {
+	return listDevcontainers(true)
+}
+
+func listDevcontainers(all bool) ([]DevcontainerInfo, error) {
+	runtime, err := GetContainerRuntime()
 	if err != nil {
-		return []DevcontainerInfo{}, fmt.Errorf("Failed to read docker stdout: %v", err)
+		return []DevcontainerInfo{}, err
 	}
-
-	reader := bytes.NewReader(output)
-	scanner := bufio.NewScanner(reader)
-	if scanner == nil {
-		return []DevcontainerInfo{}, fmt.Errorf("Failed to parse stdout")
+	containers, err := runtime.ListContainers(all)
+	if err != nil {
+		return []DevcontainerInfo{}, fmt.Errorf("Failed to list containers: %v", err)
 	}
+
 	devcontainers := []DevcontainerInfo{}
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.Split(line, "|")
-		localPath := parts[listPartLocalFolder]
+	for _, container := range containers {
+		localPath := container.Labels[labelLocalFolder]
 		if localPath == "" {
 			// not a dev container
 			continue
@@ -70,10 +92,11 @@ func ListDevcontainers() ([]DevcontainerInfo, error) {
 				return []DevcontainerInfo{}, fmt.Errorf("error converting path: %s", err)
 			}
 		}
-		name := parts[listPartLocalFolder]
+		composeProject, composeService := getComposeLabels(container.Labels)
+		name := container.Labels[labelLocalFolder]
 		if name == "" {
 			// No local folder => use dockercompose parts
-			name = fmt.Sprintf("%s/%s", parts[listPartComposeProject], parts[listPartComposeService])
+			name = fmt.Sprintf("%s/%s", composeProject, composeService)
 		} else {
 			// get the last path segment for the name
 			if index := strings.LastIndexAny(name, "/\\"); index >= 0 {
@@ -81,10 +104,16 @@ func ListDevcontainers() ([]DevcontainerInfo, error) {
 			}
 		}
 		devcontainer := DevcontainerInfo{
-			ContainerID:      parts[listPartID],
-			ContainerName:    parts[listPartContainerName],
+			ContainerID:      container.ID,
+			ContainerName:    container.Name,
 			LocalFolderPath:  localPath,
 			DevcontainerName: name,
+			State:            container.State,
+			Status:           container.Status,
+			Created:          container.Created,
+			Image:            container.Image,
+			ComposeProject:   composeProject,
+			ComposeService:   composeService,
 		}
 		devcontainers = append(devcontainers, devcontainer)
 	}
@@ -93,15 +122,16 @@ func ListDevcontainers() ([]DevcontainerInfo, error) {
 
 // GetLocalFolderFromDevContainer looks up the local (host) folder name from the container labels
 func GetLocalFolderFromDevContainer(containerIDOrName string) (string, error) {
-
-	cmd := exec.Command("docker", "inspect", containerIDOrName, "--format", "{{ index .Config.Labels \"devcontainer.local_folder\" }}")
-
-	output, err := cmd.Output()
+	runtime, err := GetContainerRuntime()
 	if err != nil {
-		return "", fmt.Errorf("Failed to read docker stdout: %v", err)
+		return "", err
+	}
+	container, err := runtime.InspectContainer(containerIDOrName)
+	if err != nil {
+		return "", fmt.Errorf("Failed to inspect container: %v", err)
 	}
 
-	return strings.TrimSpace(string(output)), nil
+	return strings.TrimSpace(container.Labels[labelLocalFolder]), nil
 }
 
 // DockerMount represents mount info from Docker output
@@ -158,22 +188,29 @@ func GetSourceInfoFromDevContainer(containerIDOrName string) (SourceInfo, error)
 		return SourceInfo{}, fmt.Errorf("search for mount folder failed: %s", err)
 	}
 
-	cmd := exec.Command("docker", "inspect", containerIDOrName, "--format", fmt.Sprintf("{{ range .Mounts }}{{if eq .Source \"%s\"}}{{json .}}{{end}}{{end}}", mountFolder))
-
-	output, err := cmd.Output()
+	runtime, err := GetContainerRuntime()
 	if err != nil {
-		return SourceInfo{}, fmt.Errorf("Failed to read docker stdout: %v", err)
+		return SourceInfo{}, err
 	}
-
-	var mount DockerMount
-	err = json.Unmarshal(output, &mount)
+	container, err := runtime.InspectContainer(containerIDOrName)
 	if err != nil {
-		return SourceInfo{}, fmt.Errorf("failed to parse JSON getting mount folder for container %q (path=%q): %s", containerIDOrName, mountFolder, err)
+		return SourceInfo{}, fmt.Errorf("Failed to inspect container: %v", err)
+	}
+
+	var mount *DockerMount
+	for i := range container.Mounts {
+		if container.Mounts[i].Source == mountFolder {
+			mount = &container.Mounts[i]
+			break
+		}
+	}
+	if mount == nil {
+		return SourceInfo{}, fmt.Errorf("failed to find mount for container %q (path=%q)", containerIDOrName, mountFolder)
 	}
 
 	return SourceInfo{
 		DevcontainerFolder: localPath,
-		DockerMount:        mount,
+		DockerMount:        *mount,
 	}, nil
 }
 
@@ -189,6 +226,15 @@ func (s byLocalPathLength) Less(i, j int) bool {
 	return len(s[i].LocalFolderPath) < len(s[j].LocalFolderPath)
 }
 
+// DevcontainerPathNotFoundError is returned by GetClosestPathMatchForPath when no dev container matches the path
+type DevcontainerPathNotFoundError struct {
+	Path string
+}
+
+func (e *DevcontainerPathNotFoundError) Error() string {
+	return fmt.Sprintf("Could not find running container for path %q", e.Path)
+}
+
 // GetClosestPathMatchForPath returns the dev container with the closes match to the specified path
 func GetClosestPathMatchForPath(devContainers []DevcontainerInfo, devcontainerPath string) (DevcontainerInfo, error) {
 	if devcontainerPath == "" {
@@ -215,7 +261,7 @@ func GetClosestPathMatchForPath(devContainers []DevcontainerInfo, devcontainerPa
 		}
 	}
 	if len(matchingPaths) == 0 {
-		return DevcontainerInfo{}, fmt.Errorf("Could not find running container for path %q", devcontainerPath)
+		return DevcontainerInfo{}, &DevcontainerPathNotFoundError{Path: devcontainerPath}
 	}
 
 	// return longest prefix match
@@ -223,9 +269,26 @@ func GetClosestPathMatchForPath(devContainers []DevcontainerInfo, devcontainerPa
 	return matchingPaths[len(matchingPaths)-1], nil
 }
 
-func ExecInDevContainer(containerID string, workDir string, args []string) error {
+// getExecStdin returns the stdin to attach to an exec session. stdin is only attached if it is a terminal or
+// interactive is set, so that commands don't consume input meant for the caller (e.g. in a `while read` loop)
+func getExecStdin(interactive bool) io.Reader {
+	if interactive || terminal.IsStdinTTY() {
+		return os.Stdin
+	}
+	return nil
+}
 
-	statusWriter := &terminal.UpdatingStatusWriter{}
+// ExecInDevContainer runs a command in the dev container with the same environment as the VS Code terminal.
+// A TTY is only allocated when stdin and stdout are terminals so that the command can be used in pipes/scripts.
+// interactive attaches stdin when it isn't a terminal (e.g. to pipe input to the command).
+// If the command exits with a non-zero exit code then an *ExecError is returned
+func ExecInDevContainer(containerID string, workDir string, args []string, interactive bool) error {
+
+	// Status and warning messages go to stderr so that stdout only contains the command output
+	statusWriter := &terminal.UpdatingStatusWriter{Writer: os.Stderr}
+	if !terminal.IsFileTTY(os.Stderr) {
+		statusWriter.Writer = ioutil.Discard
+	}
 
 	sourceInfo, err := GetSourceInfoFromDevContainer(containerID)
 	if err != nil {
@@ -238,83 +301,30 @@ func ExecInDevContainer(containerID string, workDir string, args []string) error
 	if err != nil {
 		return err
 	}
-	userName, err := GetDevContainerUserName(devcontainerJSONPath)
+	// remoteUser/remoteEnv/userEnvProbe are optional so continue without them if devcontainer.json can't be loaded
+	config, err := LoadDevcontainerConfig(devcontainerJSONPath)
 	if err != nil {
-		return err
+		fmt.Fprintf(os.Stderr, "Warning: Failed to load devcontainer.json: %s\n", err)
+		fmt.Fprintln(os.Stderr, "Continuing without remoteUser, remoteEnv and userEnvProbe...")
+		config = &DevcontainerConfig{UserEnvProbe: userEnvProbeNone}
 	}
+	userName := config.RemoteUser
 	if userName == "" {
 		userName, err = getUserNameFromRunningContainer(containerID)
 		if err != nil {
 			return err
 		}
 	}
-
-	statusWriter.Printf("Checking for SSH_AUTH_SOCK")
-	sshAuthSockValue, err := getSshAuthSockValue(containerID)
+	runtime, err := GetContainerRuntime()
 	if err != nil {
-		// output error and continue without SSH_AUTH_SOCK value
-		sshAuthSockValue = ""
-		fmt.Printf("Warning: Failed to get SSH_AUTH_SOCK value: %s\n", err)
-		fmt.Println("Continuing without setting SSH_AUTH_SOCK...")
-	}
-
-	statusWriter.Printf("Getting container PATH")
-	containerPath, err := getContainerEnvVar(containerID, "PATH")
-	vscodeServerPath := ""
-	if err == nil {
-		// Got the PATH
-		statusWriter.Printf("Getting code server path")
-		vscodeServerPath, err = getVscodeServerPath(containerID, userName)
-		if err == nil {
-			// Got the VS Code server location - add bin subfolder to PATH
-			containerPath = strings.TrimSpace(containerPath)
-			containerPath = fmt.Sprintf("%s/bin:%s", vscodeServerPath, containerPath)
-		} else {
-			// output error and continue without adding to PATH value
-			fmt.Printf("Warning: Failed to get VS Code server location: %s\n", err)
-			fmt.Println("Continuing without adding VS Code Server to PATH...")
-		}
-	} else {
-		// output error and continue without adding to PATH value
-		containerPath = ""
-		fmt.Printf("Warning: Failed to get PATH value for container: %s\n", err)
-		fmt.Println("Continuing without overriding PATH...")
-	}
-
-	browser := ""
-	if vscodeServerPath == "" {
-		fmt.Printf("Warning: VS Code Server location not found. Continuing without setting BROWSER...")
-	} else {
-		browser = fmt.Sprintf("%s/helpers/browser.sh", vscodeServerPath)
+		return err
 	}
-
-	statusWriter.Printf("Getting VSCODE_IPC_HOOK_CLI")
-	vscodeIpcSock, err := getVscodeIpcSock(containerID)
+	container, err := runtime.InspectContainer(containerID)
 	if err != nil {
-		vscodeIpcSock = ""
-		fmt.Printf("Warning; Failed to get VSCODE_IPC_HOOK_CLI: %s\n", err)
-		fmt.Println("Continuing without setting VSCODE_IPC_HOOK_CLI...")
+		return err
 	}
-	statusWriter.Printf("Getting REMOTE_CONTAINERS_IPC")
-	remoteContainersIpcSock, err := getRemoteContainersIpcSock(containerID)
-	if err != nil {
-		remoteContainersIpcSock = ""
-		fmt.Printf("Warning; Failed to get REMOTE_CONTAINERS_IPC: %s\n", err)
-		fmt.Println("Continuing without setting REMOTE_CONTAINERS_IPC...")
-	}
-	statusWriter.Printf("Getting container User ID")
-	vscodeGitIpcSock := ""
-	userID, err := getContainerUserID(containerID, userName)
-	if err == nil {
-		statusWriter.Printf("Getting VSCODE_GIT_IPC_HANDLE")
-		vscodeGitIpcSock, err = getGitIpcSock(containerID, userID)
-		if err != nil {
-			fmt.Printf("Warning; Failed to get VSCODE_GIT_IPC_HANDLE: %s\n", err)
-			fmt.Println("Continuing without setting VSCODE_GIT_IPC_HANDLE...")
-		}
-	} else {
-		fmt.Printf("Warning; Failed to get container User ID: %s\n", err)
-		fmt.Println("Continuing without setting VSCODE_GIT_IPC_HANDLE...")
+	if userName == "" {
+		userName = getRootlessExecUser(runtime.Name(), container)
 	}
 
 	mountPath := sourceInfo.DockerMount.Destination
@@ -331,12 +341,52 @@ func ExecInDevContainer(containerID string, workDir string, args []string) error
 		}
 	}
 
-	statusWriter.Printf("Test container path")
-	containerPathExists, err := testContainerPathExists(containerID, workDir)
+	userEnvProbe := config.UserEnvProbe
+	if userEnvProbe == "" {
+		userEnvProbe = userEnvProbeDefault
+	}
+
+	statusWriter.Printf("Getting container environment")
+	execEnv, err := getExecEnvironment(container, userName, workDir, userEnvProbe)
 	if err != nil {
-		return fmt.Errorf("error checking container path: %s", err)
+		return err
+	}
+	containerEnv := envListToMap(container.Env)
+	remoteEnv := getRemoteEnv(config, containerEnv, execEnv.UserEnv)
+	args = getRemoteEnvUnsetCommand(config, containerEnv, args)
+
+	sshAuthSockValue := ""
+	if os.Getenv("SSH_AUTH_SOCK") != "" {
+		// If the host has SSH_AUTH_SOCK set then VS Code spins up forwarding for key requests
+		// inside the dev container to the SSH agent on the host.
+		sshAuthSockValue = execEnv.SSHAuthSock
+		if sshAuthSockValue == "" {
+			fmt.Fprintln(os.Stderr, "Warning: Failed to get SSH_AUTH_SOCK value")
+			fmt.Fprintln(os.Stderr, "Continuing without setting SSH_AUTH_SOCK...")
+		}
+	}
+
+	containerPath := execEnv.Path
+	if remotePath, ok := remoteEnv["PATH"]; ok {
+		containerPath = remotePath
 	}
-	if !containerPathExists {
+	vscodeServerPath := execEnv.VscodeServerPath
+	browser := ""
+	if vscodeServerPath == "" {
+		fmt.Fprintf(os.Stderr, "Warning: VS Code Server location not found. Continuing without setting BROWSER...\n")
+	} else {
+		// Got the VS Code server location - add bin subfolder to PATH
+		if containerPath != "" {
+			containerPath = fmt.Sprintf("%s/bin:%s", vscodeServerPath, containerPath)
+		}
+		browser = fmt.Sprintf("%s/helpers/browser.sh", vscodeServerPath)
+	}
+
+	vscodeIpcSock := execEnv.VscodeIpcSock
+	remoteContainersIpcSock := execEnv.RemoteContainersIpcSock
+	vscodeGitIpcSock := execEnv.GitIpcSock
+
+	if !execEnv.PathExists[workDir] {
 		// path not found - try converting from local path
 		// ? Should we check here that the workDir has mountPath as a prefix?
 		devContainerRelativePath, err := filepath.Rel(sourceInfo.DockerMount.Source, workDir)
@@ -347,165 +397,78 @@ func ExecInDevContainer(containerID string, workDir string, args []string) error
 	}
 
 	statusWriter.Printf("Starting exec session\n") // newline to put container shell at start of line
-	dockerArgs := []string{"exec", "-it", "--workdir", workDir}
-	if userName != "" {
-		dockerArgs = append(dockerArgs, "--user", userName)
-	}
+	tty := terminal.IsStdinTTY() && terminal.IsTTY()
+	delete(remoteEnv, "PATH")
+	env := envMapToList(remoteEnv)
 	if sshAuthSockValue != "" {
-		dockerArgs = append(dockerArgs, "--env", "SSH_AUTH_SOCK="+sshAuthSockValue)
+		env = append(env, "SSH_AUTH_SOCK="+sshAuthSockValue)
 	}
 	if containerPath != "" {
-		dockerArgs = append(dockerArgs, "--env", "PATH="+containerPath)
+		env = append(env, "PATH="+containerPath)
 	}
 	if vscodeIpcSock != "" {
-		dockerArgs = append(dockerArgs, "--env", "VSCODE_IPC_HOOK_CLI="+vscodeIpcSock)
+		env = append(env, "VSCODE_IPC_HOOK_CLI="+vscodeIpcSock)
 	}
 	if remoteContainersIpcSock != "" {
-		dockerArgs = append(dockerArgs, "--env", "REMOTE_CONTAINERS_IPC="+remoteContainersIpcSock)
+		env = append(env, "REMOTE_CONTAINERS_IPC="+remoteContainersIpcSock)
 	}
 	if vscodeGitIpcSock != "" {
-		dockerArgs = append(dockerArgs, "--env", "VSCODE_GIT_IPC_HANDLE="+vscodeGitIpcSock)
+		env = append(env, "VSCODE_GIT_IPC_HANDLE="+vscodeGitIpcSock)
 	}
 	if browser != "" {
-		dockerArgs = append(dockerArgs, "--env", "BROWSER="+browser)
-	}
-	dockerArgs = append(dockerArgs, containerID)
-	dockerArgs = append(dockerArgs, args...)
-
-	dockerCmd := exec.Command("docker", dockerArgs...)
-	dockerCmd.Stdin = os.Stdin
-	dockerCmd.Stdout = os.Stdout
-
-	err = dockerCmd.Start()
-	if err != nil {
-		return fmt.Errorf("Exec: start error: %s", err)
-	}
-	err = dockerCmd.Wait()
+		env = append(env, "BROWSER="+browser)
+	}
+
+	err = execWithSignalForwarding(runtime, containerID, ExecOptions{
+		Cmd:     args,
+		User:    userName,
+		WorkDir: workDir,
+		Env:     env,
+		TTY:     tty,
+		Stdin:   getExecStdin(interactive),
+		Stdout:  os.Stdout,
+		Stderr:  os.Stderr,
+	})
 	if err != nil {
-		return fmt.Errorf("Exec: wait error: %s", err)
+		if _, ok := err.(*ExecError); ok {
+			// return as-is so that callers can propagate the exit code
+			return err
+		}
+		return fmt.Errorf("Exec: %s", err)
 	}
 	return nil
 }
 
-// getSshAuthSockValue returns the value to use for the SSH_AUTH_SOCK env var when exec'ing into the container, or empty string if no value is found
-func getSshAuthSockValue(containerID string) (string, error) {
-
-	// If the host has SSH_AUTH_SOCK set then VS Code spins up forwarding for key requests
-	// inside the dev container to the SSH agent on the host.
-
-	hostSshAuthSockValue := os.Getenv("SSH_AUTH_SOCK")
-	if hostSshAuthSockValue == "" {
-		// Nothing to see, move along
-		return "", nil
-	}
-
-	// Host has SSH_AUTH_SOCK set, so expecting the dev container to have forwarding set up
-	// Find the latest /tmp/vscode-ssh-auth-<...>.sock
-
-	return getLatestFileMatch(containerID, "", "\"${TMPDIR:-/tmp}\"/vscode-ssh-auth-*")
-}
-
-func getVscodeServerPath(containerID string, userName string) (string, error) {
-	path, err := getLatestFileMatch(containerID, userName, "${HOME}/.vscode-server/bin/*")
-	if err == nil {
-		return path, err
-	}
-	path, err = getLatestFileMatch(containerID, "", "/vscode/vscode-server/bin/linux-x64/*")
-	if err == nil {
-		return path, err
-	}
-	return getLatestFileMatch(containerID, "", "/vscode/vscode-server/bin/x64/*")
-}
-func getVscodeIpcSock(containerID string) (string, error) {
-	return getLatestFileMatch(containerID, "", "\"${TMPDIR:-/tmp}\"/vscode-ipc-*")
-}
-func getRemoteContainersIpcSock(containerID string) (string, error) {
-	return getLatestFileMatch(containerID, "", "\"${TMPDIR:-/tmp}\"/vscode-remote-containers-ipc-*")
-}
-func getGitIpcSock(containerID string, userID string) (string, error) {
-	return getLatestFileMatch(containerID, "", fmt.Sprintf("\"${TMPDIR:-/tmp}\"/user/%s/vscode-git-*", userID))
-}
-
-// getLatestFileMatch lists files matching `pattern` in the container and returns the latest filename
-func getLatestFileMatch(containerID string, userName string, pattern string) (string, error) {
-
-	dockerArgs := []string{"exec"}
-	if userName != "" {
-		dockerArgs = append(dockerArgs, "--user", userName)
-	}
-	dockerArgs = append(dockerArgs, containerID, "bash", "-c", fmt.Sprintf("ls -t -d -1 %s", pattern))
-	dockerCmd := exec.Command("docker", dockerArgs...)
-	buf, err := dockerCmd.CombinedOutput()
-	if err != nil {
-		errMessage := string(buf)
-		return "", fmt.Errorf("Docker exec error: %s (%s)", err, strings.TrimSpace(errMessage))
-	}
-
-	output := string(buf)
-	lines := strings.Split(output, "\n")
-	if len(lines) <= 0 {
-		return "", nil
-	}
-	return strings.TrimSpace(lines[0]), nil
-}
-
-func getContainerEnvVar(containerID string, varName string) (string, error) {
-
-	// could inspect the docker container as an alternative approach
-	dockerArgs := []string{"exec", containerID, "bash", "-c", fmt.Sprintf("echo $%s", varName)}
-	dockerCmd := exec.Command("docker", dockerArgs...)
-	buf, err := dockerCmd.CombinedOutput()
+func testContainerPathExists(containerID string, path string) (bool, error) {
+	runtime, err := GetContainerRuntime()
 	if err != nil {
-		errMessage := string(buf)
-		return "", fmt.Errorf("Docker exec error: %s (%s)", err, strings.TrimSpace(errMessage))
+		return false, err
 	}
-
-	return string(buf), nil
-}
-
-// getContainerUserID gets the UID of the specified user in the container
-func getContainerUserID(containerID string, userName string) (string, error) {
-
-	dockerArgs := []string{"exec", containerID, "bash", "-c", fmt.Sprintf("id -u %s", userName)}
-	dockerCmd := exec.Command("docker", dockerArgs...)
-	buf, err := dockerCmd.CombinedOutput()
+	err = runtime.Exec(containerID, ExecOptions{
+		Cmd: []string{"bash", "-c", fmt.Sprintf("[[ -d %s ]]", path)},
+	})
 	if err != nil {
-		errMessage := string(buf)
-		return "", fmt.Errorf("Docker exec error: %s (%s)", err, strings.TrimSpace(errMessage))
-	}
-
-	output := string(buf)
-	lines := strings.Split(output, "\n")
-	if len(lines) <= 0 {
-		return "", nil
+		if _, ok := err.(*ExecError); ok {
+			// non-zero exit code => path doesn't exist
+			return false, nil
+		}
+		return false, err
 	}
-	return strings.TrimSpace(lines[0]), nil
+	return true, nil
 }
 
-func testContainerPathExists(containerID string, path string) (bool, error) {
-	dockerArgs := []string{"exec", containerID, "bash", "-c", fmt.Sprintf("[[ -d %s ]]; echo $?", path)}
-	dockerCmd := exec.Command("docker", dockerArgs...)
-	buf, err := dockerCmd.CombinedOutput()
+func getUserNameFromRunningContainer(containerID string) (string, error) {
+	runtime, err := GetContainerRuntime()
 	if err != nil {
-		errMessage := string(buf)
-		return false, fmt.Errorf("Docker exec error: %s (%s)", err, strings.TrimSpace(errMessage))
+		return "", err
 	}
-
-	response := strings.TrimSpace(string(buf))
-	return response == "0", nil
-}
-
-func getUserNameFromRunningContainer(containerID string) (string, error) {
-	dockerArgs := []string{"inspect", containerID, "--format", "{{index .Config.Labels \"devcontainer.metadata\" }}"}
-	dockerCmd := exec.Command("docker", dockerArgs...)
-	buf, err := dockerCmd.CombinedOutput()
+	container, err := runtime.InspectContainer(containerID)
 	if err != nil {
-		errMessage := string(buf)
-		return "", fmt.Errorf("Docker exec error: %s (%s)", err, strings.TrimSpace(errMessage))
+		return "", err
 	}
 
 	var metadata []interface{}
-	err = json.Unmarshal(buf, &metadata)
+	err = json.Unmarshal([]byte(container.Labels[labelMetadata]), &metadata)
 	if err != nil {
 		return "", nil
 	}
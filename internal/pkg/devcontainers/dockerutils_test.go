@@ -47,3 +47,14 @@ func TestGetClosestPathMatchForPath_ReturnsLongestMatchForChildFolder(t *testing
 		assert.Equal(t, "/path/to/project", actual.LocalFolderPath)
 	}
 }
+
+func TestGetClosestPathMatchForPath_ReturnsNotFoundError(t *testing.T) {
+	inputs := []DevcontainerInfo{
+		{LocalFolderPath: "/path/to/project"},
+	}
+
+	_, err := GetClosestPathMatchForPath(inputs, "/path/to/other")
+	if assert.IsType(t, &DevcontainerPathNotFoundError{}, err) {
+		assert.Equal(t, "/path/to/other", err.(*DevcontainerPathNotFoundError).Path)
+	}
+}
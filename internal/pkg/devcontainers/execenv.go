@@ -0,0 +1,229 @@
+package devcontainers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/status"
+)
+
+// execEnvironment holds the values probed from a container to set up the environment for exec sessions
+// It is cached per container (keyed by container ID and start time) to avoid probing the container on each exec
+type execEnvironment struct {
+	ContainerID string    `json:"containerID"`
+	StartedAt   time.Time `json:"startedAt"`
+	UserName    string    `json:"userName"`
+
+	Path                    string `json:"path"`
+	UserID                  string `json:"userID"`
+	SSHAuthSock             string `json:"sshAuthSock"`
+	VscodeServerPath        string `json:"vscodeServerPath"`
+	VscodeIpcSock           string `json:"vscodeIpcSock"`
+	RemoteContainersIpcSock string `json:"remoteContainersIpcSock"`
+	GitIpcSock              string `json:"gitIpcSock"`
+
+	// PathExists caches whether (container) paths exist, used when mapping the local work dir
+	// Paths that didn't exist are checked again as they can be created later (e.g. in the workspace bind mount)
+	PathExists map[string]bool `json:"pathExists"`
+
+	// UserEnv is the environment captured from the user's shell using the UserEnvProbe mode
+	UserEnvProbe string            `json:"userEnvProbe"`
+	UserEnv      map[string]string `json:"userEnv"`
+}
+
+// execEnvCacheMaxAge is the age after which unused cache files are removed
+const execEnvCacheMaxAge = 7 * 24 * time.Hour
+
+// execEnvProbeScript prints the values for execEnvironment as KEY=value lines
+// It is run as the exec user with the work dir to test as $1
+const execEnvProbeScript = `workdir="$1"
+latest() { ls -t -d -1 $1 2>/dev/null | head -n 1; }
+user_id=$(id -u)
+server=$(latest "$HOME/.vscode-server/bin/*")
+[ -z "$server" ] && server=$(latest "/vscode/vscode-server/bin/linux-x64/*")
+[ -z "$server" ] && server=$(latest "/vscode/vscode-server/bin/x64/*")
+echo "PATH=$PATH"
+echo "USER_ID=$user_id"
+echo "SSH_AUTH_SOCK=$(latest "${TMPDIR:-/tmp}/vscode-ssh-auth-*")"
+echo "VSCODE_SERVER_PATH=$server"
+echo "VSCODE_IPC_HOOK_CLI=$(latest "${TMPDIR:-/tmp}/vscode-ipc-*")"
+echo "REMOTE_CONTAINERS_IPC=$(latest "${TMPDIR:-/tmp}/vscode-remote-containers-ipc-*")"
+echo "VSCODE_GIT_IPC_HANDLE=$(latest "${TMPDIR:-/tmp}/user/$user_id/vscode-git-*")"
+[ -n "$workdir" ] && [ -d "$workdir" ] && echo "WORKDIR_EXISTS=1"
+exit 0
+`
+
+// execEnvValidateScript prints any of the socket paths passed as args that no longer exist
+const execEnvValidateScript = `for s in "$@"; do [ -S "$s" ] || echo "$s"; done; exit 0`
+
+// getExecEnvironment returns the exec environment for the container, using the cached values if they are still valid
+// workDir is the container path to check for existence (the result is added to PathExists)
+// userEnvProbe is the userEnvProbe mode from devcontainer.json used to capture UserEnv
+func getExecEnvironment(container *ContainerDetails, userName string, workDir string, userEnvProbe string) (*execEnvironment, error) {
+	cachePath := getExecEnvCachePath(container.ID)
+	env := loadExecEnvironment(cachePath)
+	if env != nil && (!env.StartedAt.Equal(container.StartedAt) || env.UserName != userName) {
+		env = nil
+	}
+	if env != nil {
+		valid, err := validateExecEnvironment(container.ID, env)
+		if err != nil {
+			return nil, err
+		}
+		if !valid {
+			env = nil
+		}
+	}
+
+	if env == nil {
+		var err error
+		env, err = probeExecEnvironment(container, userName, workDir)
+		if err != nil {
+			return nil, err
+		}
+	} else if !env.PathExists[workDir] && workDir != "" {
+		exists, err := testContainerPathExists(container.ID, workDir)
+		if err != nil {
+			return nil, fmt.Errorf("error checking container path: %s", err)
+		}
+		env.PathExists[workDir] = exists
+	}
+
+	if env.UserEnvProbe != userEnvProbe || env.UserEnv == nil {
+		userEnv, err := probeUserEnv(container.ID, userName, userEnvProbe)
+		if err != nil {
+			// continue without the user env (and cache this to avoid re-probing on every exec)
+			fmt.Fprintf(os.Stderr, "Warning: Failed to probe user environment (userEnvProbe: %s): %s\n", userEnvProbe, err)
+		}
+		if userEnv == nil {
+			userEnv = map[string]string{}
+		}
+		env.UserEnvProbe = userEnvProbe
+		env.UserEnv = userEnv
+	}
+
+	// failing to save the cache only affects performance so isn't treated as an error
+	_ = saveExecEnvironment(cachePath, env)
+	return env, nil
+}
+
+// probeExecEnvironment gets the exec environment values from the container in a single exec
+func probeExecEnvironment(container *ContainerDetails, userName string, workDir string) (*execEnvironment, error) {
+	runtime, err := GetContainerRuntime()
+	if err != nil {
+		return nil, err
+	}
+	output, err := execOutput(runtime, container.ID, userName, "/bin/sh", "-c", execEnvProbeScript, "probe", workDir)
+	if err != nil {
+		return nil, fmt.Errorf("error probing container environment: %s", err)
+	}
+	values := parseKeyValueLines(output)
+	env := &execEnvironment{
+		ContainerID:             container.ID,
+		StartedAt:               container.StartedAt,
+		UserName:                userName,
+		Path:                    values["PATH"],
+		UserID:                  values["USER_ID"],
+		SSHAuthSock:             values["SSH_AUTH_SOCK"],
+		VscodeServerPath:        values["VSCODE_SERVER_PATH"],
+		VscodeIpcSock:           values["VSCODE_IPC_HOOK_CLI"],
+		RemoteContainersIpcSock: values["REMOTE_CONTAINERS_IPC"],
+		GitIpcSock:              values["VSCODE_GIT_IPC_HANDLE"],
+		PathExists:              map[string]bool{},
+	}
+	if workDir != "" {
+		env.PathExists[workDir] = values["WORKDIR_EXISTS"] == "1"
+	}
+	return env, nil
+}
+
+// validateExecEnvironment checks that the sockets in the cached environment still exist
+// (e.g. VS Code creates new sockets when it reconnects to the container)
+func validateExecEnvironment(containerID string, env *execEnvironment) (bool, error) {
+	sockets := []string{}
+	for _, socket := range []string{env.SSHAuthSock, env.VscodeIpcSock, env.RemoteContainersIpcSock, env.GitIpcSock} {
+		if socket != "" {
+			sockets = append(sockets, socket)
+		}
+	}
+	if len(sockets) == 0 {
+		return true, nil
+	}
+	args := append([]string{"/bin/sh", "-c", execEnvValidateScript, "validate"}, sockets...)
+	runtime, err := GetContainerRuntime()
+	if err != nil {
+		return false, err
+	}
+	output, err := execOutput(runtime, containerID, "", args...)
+	if err != nil {
+		return false, fmt.Errorf("error validating container environment: %s", err)
+	}
+	return strings.TrimSpace(output) == "", nil
+}
+
+func parseKeyValueLines(output string) map[string]string {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if index := strings.Index(line, "="); index > 0 {
+			values[line[:index]] = strings.TrimSpace(line[index+1:])
+		}
+	}
+	return values
+}
+
+func getExecEnvCacheFolder() string {
+	return filepath.Join(status.GetStatusFolder(), "exec-cache")
+}
+
+func getExecEnvCachePath(containerID string) string {
+	return filepath.Join(getExecEnvCacheFolder(), containerID+".json")
+}
+
+func loadExecEnvironment(path string) *execEnvironment {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var env execEnvironment
+	if err = json.Unmarshal(buf, &env); err != nil {
+		return nil
+	}
+	if env.PathExists == nil {
+		env.PathExists = map[string]bool{}
+	}
+	return &env
+}
+
+func saveExecEnvironment(path string, env *execEnvironment) error {
+	folder := filepath.Dir(path)
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return err
+	}
+	pruneExecEnvCache(folder)
+	buf, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf, 0644)
+}
+
+// pruneExecEnvCache removes cache files that haven't been updated recently (e.g. for removed containers)
+func pruneExecEnvCache(folder string) {
+	files, err := ioutil.ReadDir(folder)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		if time.Since(file.ModTime()) > execEnvCacheMaxAge {
+			_ = os.Remove(filepath.Join(folder, file.Name()))
+		}
+	}
+}
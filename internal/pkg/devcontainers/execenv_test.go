@@ -0,0 +1,157 @@
+package devcontainers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// useTempStatusFolder points the status folder (and so the exec environment cache) at a temp folder for the test
+func useTempStatusFolder(t *testing.T) {
+	folder, err := ioutil.TempDir("", "devcontainer-cli-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, hadPrevious := os.LookupEnv("DEVCONTAINERX_STATUS_PATH")
+	os.Setenv("DEVCONTAINERX_STATUS_PATH", folder)
+	t.Cleanup(func() {
+		if hadPrevious {
+			os.Setenv("DEVCONTAINERX_STATUS_PATH", previous)
+		} else {
+			os.Unsetenv("DEVCONTAINERX_STATUS_PATH")
+		}
+		os.RemoveAll(folder)
+	})
+}
+
+// execEnvTestRuntime returns a fake runtime that answers the probe/validate scripts
+// missingSockets is the output for the validate script
+func execEnvTestRuntime(missingSockets *string) *fakeRuntime {
+	return &fakeRuntime{
+		execHandler: func(containerID string, options ExecOptions) error {
+			switch options.Cmd[2] {
+			case execEnvProbeScript:
+				fmt.Fprint(options.Stdout, "PATH=/usr/bin:/bin\nUSER_ID=1000\nSSH_AUTH_SOCK=\nVSCODE_SERVER_PATH=/home/vscode/.vscode-server/bin/abc\nVSCODE_IPC_HOOK_CLI=/tmp/vscode-ipc-1.sock\nREMOTE_CONTAINERS_IPC=\nVSCODE_GIT_IPC_HANDLE=/tmp/user/1000/vscode-git-1.sock\nWORKDIR_EXISTS=1\n")
+			case execEnvValidateScript:
+				fmt.Fprint(options.Stdout, *missingSockets)
+			}
+			return nil
+		},
+	}
+}
+
+func countExecs(runtime *fakeRuntime, script string) int {
+	count := 0
+	for _, call := range runtime.execCalls {
+		if len(call.Cmd) > 2 && call.Cmd[2] == script {
+			count++
+		}
+	}
+	return count
+}
+
+func TestGetExecEnvironment_ProbesOnceAndUsesCache(t *testing.T) {
+	useTempStatusFolder(t)
+	missingSockets := ""
+	runtime := execEnvTestRuntime(&missingSockets)
+	useFakeRuntime(t, runtime)
+
+	container := &ContainerDetails{Container: Container{ID: "abc"}, StartedAt: time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)}
+	env, err := getExecEnvironment(container, "vscode", "/workspaces/project1", userEnvProbeNone)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "/usr/bin:/bin", env.Path)
+	assert.Equal(t, "1000", env.UserID)
+	assert.Equal(t, "/home/vscode/.vscode-server/bin/abc", env.VscodeServerPath)
+	assert.Equal(t, "/tmp/vscode-ipc-1.sock", env.VscodeIpcSock)
+	assert.Equal(t, "/tmp/user/1000/vscode-git-1.sock", env.GitIpcSock)
+	assert.True(t, env.PathExists["/workspaces/project1"])
+	if assert.Len(t, runtime.execCalls, 1) {
+		assert.Equal(t, "vscode", runtime.execCalls[0].User)
+	}
+
+	// second call uses the cache (only validating the sockets)
+	env, err = getExecEnvironment(container, "vscode", "/workspaces/project1", userEnvProbeNone)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "/usr/bin:/bin", env.Path)
+	}
+	assert.Equal(t, 1, countExecs(runtime, execEnvProbeScript))
+	assert.Equal(t, 1, countExecs(runtime, execEnvValidateScript))
+}
+
+func TestGetExecEnvironment_ReprobesWhenContainerRestarted(t *testing.T) {
+	useTempStatusFolder(t)
+	missingSockets := ""
+	runtime := execEnvTestRuntime(&missingSockets)
+	useFakeRuntime(t, runtime)
+
+	container := &ContainerDetails{Container: Container{ID: "abc"}, StartedAt: time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)}
+	_, err := getExecEnvironment(container, "vscode", "", userEnvProbeNone)
+	if !assert.NoError(t, err) {
+		return
+	}
+	container.StartedAt = container.StartedAt.Add(time.Hour)
+	_, err = getExecEnvironment(container, "vscode", "", userEnvProbeNone)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, countExecs(runtime, execEnvProbeScript))
+		assert.Equal(t, 0, countExecs(runtime, execEnvValidateScript))
+	}
+}
+
+func TestGetExecEnvironment_ReprobesWhenSocketsDisappear(t *testing.T) {
+	useTempStatusFolder(t)
+	missingSockets := ""
+	runtime := execEnvTestRuntime(&missingSockets)
+	useFakeRuntime(t, runtime)
+
+	container := &ContainerDetails{Container: Container{ID: "abc"}}
+	_, err := getExecEnvironment(container, "vscode", "", userEnvProbeNone)
+	if !assert.NoError(t, err) {
+		return
+	}
+	missingSockets = "/tmp/vscode-ipc-1.sock\n"
+	_, err = getExecEnvironment(container, "vscode", "", userEnvProbeNone)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, countExecs(runtime, execEnvProbeScript))
+		assert.Equal(t, 1, countExecs(runtime, execEnvValidateScript))
+	}
+}
+
+func TestGetExecEnvironment_RechecksMissingPaths(t *testing.T) {
+	useTempStatusFolder(t)
+	workDirExists := false
+	runtime := &fakeRuntime{
+		execHandler: func(containerID string, options ExecOptions) error {
+			switch options.Cmd[2] {
+			case execEnvProbeScript:
+				fmt.Fprint(options.Stdout, "PATH=/usr/bin:/bin\nWORKDIR_EXISTS=0\n")
+			case "[[ -d /workspaces/project1/new ]]":
+				if !workDirExists {
+					return &ExecError{ContainerID: containerID, Cmd: options.Cmd, ExitCode: 1}
+				}
+			}
+			return nil
+		},
+	}
+	useFakeRuntime(t, runtime)
+
+	container := &ContainerDetails{Container: Container{ID: "abc"}}
+	env, err := getExecEnvironment(container, "vscode", "/workspaces/project1/new", userEnvProbeNone)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.False(t, env.PathExists["/workspaces/project1/new"])
+
+	// the folder is created (e.g. on the host in the workspace bind mount) so the cached value is checked again
+	workDirExists = true
+	env, err = getExecEnvironment(container, "vscode", "/workspaces/project1/new", userEnvProbeNone)
+	if assert.NoError(t, err) {
+		assert.True(t, env.PathExists["/workspaces/project1/new"])
+		assert.Equal(t, 1, countExecs(runtime, execEnvProbeScript))
+	}
+}
@@ -0,0 +1,76 @@
+package devcontainers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// execIDEnvName is the env var that identifies the processes started by an exec so that signals can be sent to them
+const execIDEnvName = "DEVCONTAINERX_EXEC_ID"
+
+// execForwardedSignals maps the signals that are forwarded to the command in the container to their kill names
+// SIGINT and SIGQUIT aren't forwarded: they are sent from the terminal to the whole process group (or, with a TTY,
+// passed to the container's terminal) and `docker exec` doesn't pass signals on to the process in the container
+var execForwardedSignals = map[os.Signal]string{
+	syscall.SIGTERM: "TERM",
+	syscall.SIGHUP:  "HUP",
+}
+
+// signalExecScript sends a signal to the processes whose environment contains the exec ID ($1 is the
+// exec ID env var assignment, $2 is the signal name)
+const signalExecScript = `for p in /proc/[0-9]*; do
+	if tr '\0' '\n' 2>/dev/null < "$p/environ" | grep -qx "$1"; then
+		kill -"$2" "${p#/proc/}" 2>/dev/null
+	fi
+done`
+
+// execWithSignalForwarding runs the exec and forwards SIGTERM and SIGHUP received by this process to the command
+// in the container by running kill in the container
+func execWithSignalForwarding(runtime ContainerRuntime, containerID string, options ExecOptions) error {
+	execID, err := newExecID()
+	if err != nil {
+		return err
+	}
+	options.Env = append(append([]string{}, options.Env...), execIDEnvName+"="+execID)
+
+	signals := make(chan os.Signal, 1)
+	forwardedSignals := []os.Signal{}
+	for sig := range execForwardedSignals {
+		forwardedSignals = append(forwardedSignals, sig)
+	}
+	signal.Notify(signals, forwardedSignals...)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				_ = signalExec(runtime, containerID, options.User, execID, sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+	err = runtime.Exec(containerID, options)
+	signal.Stop(signals)
+	close(done)
+	return err
+}
+
+// signalExec sends sig to the processes in the container started by the exec with execID
+func signalExec(runtime ContainerRuntime, containerID string, userName string, execID string, sig os.Signal) error {
+	return runtime.Exec(containerID, ExecOptions{
+		Cmd:  []string{"/bin/sh", "-c", signalExecScript, "signal", execIDEnvName + "=" + execID, execForwardedSignals[sig]},
+		User: userName,
+	})
+}
+
+func newExecID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,31 @@
+package devcontainers
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecWithSignalForwarding_SetsExecIDAndSignalsMatchingProcesses(t *testing.T) {
+	runtime := &fakeRuntime{}
+
+	err := execWithSignalForwarding(runtime, "abc", ExecOptions{Cmd: []string{"make", "test"}, User: "vscode", Env: []string{"FOO=bar"}})
+	if !assert.NoError(t, err) || !assert.Len(t, runtime.execCalls, 1) {
+		return
+	}
+	env := runtime.execCalls[0].Env
+	if !assert.Len(t, env, 2) {
+		return
+	}
+	assert.Equal(t, "FOO=bar", env[0])
+	assert.True(t, strings.HasPrefix(env[1], execIDEnvName+"="))
+	execID := strings.TrimPrefix(env[1], execIDEnvName+"=")
+
+	assert.NoError(t, signalExec(runtime, "abc", "vscode", execID, syscall.SIGTERM))
+	if assert.Len(t, runtime.execCalls, 2) {
+		assert.Equal(t, []string{"/bin/sh", "-c", signalExecScript, "signal", env[1], "TERM"}, runtime.execCalls[1].Cmd)
+		assert.Equal(t, "vscode", runtime.execCalls[1].User)
+	}
+}
@@ -0,0 +1,570 @@
+package devcontainers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/config"
+)
+
+const featureMetadataFilename = "devcontainer-feature.json"
+
+// DevcontainerFeature is the metadata for a dev container feature (from devcontainer-feature.json)
+// See https://containers.dev/implementors/features/
+type DevcontainerFeature struct {
+	ID            string                   `json:"id"`
+	Version       string                   `json:"version"`
+	Name          string                   `json:"name"`
+	Description   string                   `json:"description"`
+	Options       map[string]FeatureOption `json:"options"`
+	InstallsAfter []string                 `json:"installsAfter"`
+	// Path is the folder or tarball that the feature was loaded from
+	Path string `json:"path"`
+}
+
+// FeatureOption is an option for a feature (from devcontainer-feature.json)
+type FeatureOption struct {
+	Type        string      `json:"type"`
+	Default     interface{} `json:"default"`
+	Enum        []string    `json:"enum"`
+	Proposals   []string    `json:"proposals"`
+	Description string      `json:"description"`
+}
+
+// ConfiguredFeature is a feature referenced in the "features" property of devcontainer.json
+type ConfiguredFeature struct {
+	// Ref is the feature reference used in devcontainer.json (e.g. ghcr.io/devcontainers/features/go:1 or ./local-feature)
+	Ref     string                 `json:"ref"`
+	Options map[string]interface{} `json:"options"`
+	// Feature is the resolved feature metadata (nil if the feature wasn't found)
+	Feature *DevcontainerFeature `json:"feature,omitempty"`
+}
+
+// GetAvailableFeatures returns the features from the configured featurePaths
+func GetAvailableFeatures() ([]DevcontainerFeature, error) {
+	return getFeaturesFromFolders(configFeatureFolders())
+}
+
+func getFeaturesFromFolders(folders []string) ([]DevcontainerFeature, error) {
+	features := []DevcontainerFeature{}
+	for _, folder := range folders {
+		folder = os.ExpandEnv(folder)
+		items, err := ioutil.ReadDir(folder)
+		if err != nil {
+			return nil, fmt.Errorf("error reading feature folder %q: %s", folder, err)
+		}
+		for _, item := range items {
+			itemPath := filepath.Join(folder, item.Name())
+			var feature *DevcontainerFeature
+			switch {
+			case item.IsDir():
+				if _, err := os.Stat(filepath.Join(itemPath, featureMetadataFilename)); err != nil {
+					continue
+				}
+				feature, err = loadFeatureFromFolder(itemPath)
+			case isFeatureTarball(item.Name()):
+				feature, err = loadFeatureFromTarball(itemPath)
+			default:
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			features = append(features, *feature)
+		}
+	}
+	return features, nil
+}
+
+func isFeatureTarball(name string) bool {
+	return strings.HasSuffix(name, ".tgz") || strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tar")
+}
+
+func loadFeatureFromFolder(folder string) (*DevcontainerFeature, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(folder, featureMetadataFilename))
+	if err != nil {
+		return nil, fmt.Errorf("error reading feature metadata: %s", err)
+	}
+	return parseFeatureMetadata(buf, folder)
+}
+
+func parseFeatureMetadata(buf []byte, featurePath string) (*DevcontainerFeature, error) {
+	document, err := parseJSONC(buf)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s from %q: %s", featureMetadataFilename, featurePath, err)
+	}
+	var feature DevcontainerFeature
+	if err = document.Unmarshal(&feature); err != nil {
+		return nil, fmt.Errorf("error parsing %s from %q: %s", featureMetadataFilename, featurePath, err)
+	}
+	feature.Path = featurePath
+	return &feature, nil
+}
+
+// loadFeatureFromTarball loads the feature metadata from a tarball. This can either be a feature
+// tarball (e.g. devcontainer-feature-go.tgz) or an OCI image layout tarball for a feature (e.g. from `oras pull` or `skopeo copy`)
+func loadFeatureFromTarball(tarballPath string) (*DevcontainerFeature, error) {
+	buf, err := ioutil.ReadFile(tarballPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading feature tarball: %s", err)
+	}
+	files, err := readTarFiles(buf)
+	if err != nil {
+		return nil, fmt.Errorf("error reading feature tarball %q: %s", tarballPath, err)
+	}
+	if metadata, ok := files[featureMetadataFilename]; ok {
+		return parseFeatureMetadata(metadata, tarballPath)
+	}
+	if _, ok := files["oci-layout"]; ok {
+		layer, err := getOCIFeatureLayer(files)
+		if err != nil {
+			return nil, fmt.Errorf("error reading OCI layout from %q: %s", tarballPath, err)
+		}
+		layerFiles, err := readTarFiles(layer)
+		if err != nil {
+			return nil, fmt.Errorf("error reading feature layer from %q: %s", tarballPath, err)
+		}
+		if metadata, ok := layerFiles[featureMetadataFilename]; ok {
+			return parseFeatureMetadata(metadata, tarballPath)
+		}
+	}
+	return nil, fmt.Errorf("%s not found in %q", featureMetadataFilename, tarballPath)
+}
+
+// readTarFiles returns the contents of the files in a (optionally gzipped) tarball keyed by name
+func readTarFiles(buf []byte) (map[string][]byte, error) {
+	var reader io.Reader = bytes.NewReader(buf)
+	if len(buf) > 2 && buf[0] == 0x1f && buf[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+	files := map[string][]byte{}
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			return nil, err
+		}
+		files[path.Clean(strings.TrimPrefix(header.Name, "./"))] = content
+	}
+	return files, nil
+}
+
+// getOCIFeatureLayer returns the feature layer (a tarball) from the files in an OCI image layout
+func getOCIFeatureLayer(files map[string][]byte) ([]byte, error) {
+	type descriptor struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+	}
+	getBlob := func(digest string) ([]byte, error) {
+		blob, ok := files[path.Join("blobs", strings.Replace(digest, ":", "/", 1))]
+		if !ok {
+			return nil, fmt.Errorf("blob %q not found", digest)
+		}
+		return blob, nil
+	}
+
+	var index struct {
+		Manifests []descriptor `json:"manifests"`
+	}
+	if err := json.Unmarshal(files["index.json"], &index); err != nil {
+		return nil, fmt.Errorf("error parsing index.json: %s", err)
+	}
+	if len(index.Manifests) == 0 {
+		return nil, fmt.Errorf("no manifests found in index.json")
+	}
+	manifestJSON, err := getBlob(index.Manifests[0].Digest)
+	if err != nil {
+		return nil, err
+	}
+	var manifest struct {
+		Layers []descriptor `json:"layers"`
+	}
+	if err = json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return nil, fmt.Errorf("error parsing manifest: %s", err)
+	}
+	for _, layer := range manifest.Layers {
+		if layer.MediaType == "application/vnd.devcontainers.layer.v1+tar" {
+			return getBlob(layer.Digest)
+		}
+	}
+	return nil, fmt.Errorf("no dev container feature layer found in manifest")
+}
+
+// getFeatureRefID returns the ID for a feature reference without the version/digest
+// e.g. ghcr.io/devcontainers/features/go:1 => ghcr.io/devcontainers/features/go
+func getFeatureRefID(ref string) string {
+	if index := strings.Index(ref, "@"); index >= 0 {
+		ref = ref[:index]
+	}
+	if index := strings.LastIndex(ref, ":"); index > strings.LastIndex(ref, "/") {
+		ref = ref[:index]
+	}
+	return ref
+}
+
+// getFeatureRefVersion returns the version (tag) from a feature reference (or empty string if not set)
+func getFeatureRefVersion(ref string) string {
+	id := getFeatureRefID(ref)
+	if len(ref) > len(id) && ref[len(id)] == ':' {
+		return ref[len(id)+1:]
+	}
+	return ""
+}
+
+func isLocalFeatureRef(ref string) bool {
+	return strings.HasPrefix(ref, "./") || strings.HasPrefix(ref, "../")
+}
+
+// getFeaturesForRefs loads the features from the feature folders once so that they can be used to resolve refs
+// The feature folders are only read if there are refs that aren't local features
+func getFeaturesForRefs(refs []string, featureFolders []string) ([]DevcontainerFeature, error) {
+	for _, ref := range refs {
+		if !isLocalFeatureRef(ref) && !filepath.IsAbs(ref) {
+			return getFeaturesFromFolders(featureFolders)
+		}
+	}
+	return nil, nil
+}
+
+// resolveFeature finds the metadata for a feature reference. Local features (e.g. ./my-feature)
+// are resolved relative to configFolder and other references are looked up in availableFeatures
+// (see getFeaturesForRefs). Returns nil if the feature isn't found
+func resolveFeature(ref string, configFolder string, availableFeatures []DevcontainerFeature) (*DevcontainerFeature, error) {
+	if isLocalFeatureRef(ref) || filepath.IsAbs(ref) {
+		featurePath := ref
+		if !filepath.IsAbs(featurePath) {
+			featurePath = filepath.Join(configFolder, ref)
+		}
+		info, err := os.Stat(featurePath)
+		if err != nil {
+			return nil, nil
+		}
+		if info.IsDir() {
+			return loadFeatureFromFolder(featurePath)
+		}
+		return loadFeatureFromTarball(featurePath)
+	}
+
+	id := path.Base(getFeatureRefID(ref))
+	version := getFeatureRefVersion(ref)
+	for i := range availableFeatures {
+		// take the first match as feature folders are in priority order
+		if availableFeatures[i].ID == id && featureVersionMatches(availableFeatures[i].Version, version) {
+			feature := availableFeatures[i]
+			return &feature, nil
+		}
+	}
+	return nil, nil
+}
+
+// findFeature resolves a single feature reference using the feature folders
+func findFeature(ref string, configFolder string, featureFolders []string) (*DevcontainerFeature, error) {
+	availableFeatures, err := getFeaturesForRefs([]string{ref}, featureFolders)
+	if err != nil {
+		return nil, err
+	}
+	return resolveFeature(ref, configFolder, availableFeatures)
+}
+
+// featureVersionMatches checks whether version matches a reference version (e.g. 1.2.3 matches 1, 1.2 and 1.2.3)
+func featureVersionMatches(version string, refVersion string) bool {
+	if refVersion == "" || refVersion == "latest" || version == "" {
+		return true
+	}
+	return version == refVersion || strings.HasPrefix(version, refVersion+".")
+}
+
+// getFeatureOptions converts a value from the "features" property to a map of options
+// A string value is shorthand for the "version" option. If the feature is known and doesn't
+// declare a "version" option then the shorthand value is ignored
+func getFeatureOptions(value interface{}, feature *DevcontainerFeature) (map[string]interface{}, error) {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		return typedValue, nil
+	case string:
+		if feature != nil {
+			if _, ok := feature.Options["version"]; !ok {
+				return map[string]interface{}{}, nil
+			}
+		}
+		return map[string]interface{}{"version": typedValue}, nil
+	case bool, nil:
+		return map[string]interface{}{}, nil
+	}
+	return nil, fmt.Errorf("expected an object of options")
+}
+
+// ValidateOptions checks the option values against the options defined by the feature
+func (f *DevcontainerFeature) ValidateOptions(options map[string]interface{}) []string {
+	problems := []string{}
+	for _, name := range sortedInterfaceKeys(options) {
+		option, ok := f.Options[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("option %q is not defined by feature %q", name, f.ID))
+			continue
+		}
+		value := options[name]
+		switch option.Type {
+		case "boolean":
+			if _, ok := value.(bool); !ok {
+				problems = append(problems, fmt.Sprintf("option %q must be a boolean", name))
+			}
+		case "string":
+			stringValue, ok := value.(string)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("option %q must be a string", name))
+			} else if len(option.Enum) > 0 && !containsString(option.Enum, stringValue) {
+				problems = append(problems, fmt.Sprintf("option %q must be one of %s", name, strings.Join(option.Enum, ", ")))
+			}
+		}
+	}
+	return problems
+}
+
+// ParseFeatureOptionValues converts option values from the command line (e.g. from --option name=value) to the types
+// defined by the feature. If feature is nil, "true" and "false" are treated as booleans
+func ParseFeatureOptionValues(feature *DevcontainerFeature, values map[string]string) (map[string]interface{}, error) {
+	options := map[string]interface{}{}
+	for name, value := range values {
+		optionType := ""
+		if feature != nil {
+			optionType = feature.Options[name].Type
+		}
+		switch {
+		case optionType == "boolean" || (optionType == "" && (value == "true" || value == "false")):
+			boolValue, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("option %q must be a boolean: %s", name, err)
+			}
+			options[name] = boolValue
+		default:
+			options[name] = value
+		}
+	}
+	return options, nil
+}
+
+func sortedInterfaceKeys(values map[string]interface{}) []string {
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GetDevcontainerFeatures returns the features configured in devcontainer.json for folderPath, in install order
+func GetDevcontainerFeatures(folderPath string) ([]ConfiguredFeature, error) {
+	devcontainerJSONPath, err := getDevContainerJsonPath(folderPath)
+	if err != nil {
+		return nil, err
+	}
+	config, err := LoadDevcontainerConfig(devcontainerJSONPath)
+	if err != nil {
+		return nil, err
+	}
+	return resolveConfiguredFeatures(config, filepath.Dir(devcontainerJSONPath), configFeatureFolders())
+}
+
+// configFeatureFolders is the source of the feature folders used when resolving features (replaced in tests)
+var configFeatureFolders = config.GetFeatureFolders
+
+func resolveConfiguredFeatures(devcontainerConfig *DevcontainerConfig, configFolder string, featureFolders []string) ([]ConfiguredFeature, error) {
+	refs := sortedInterfaceKeys(devcontainerConfig.Features)
+	availableFeatures, err := getFeaturesForRefs(refs, featureFolders)
+	if err != nil {
+		return nil, err
+	}
+	features := []ConfiguredFeature{}
+	for _, ref := range refs {
+		feature, err := resolveFeature(ref, configFolder, availableFeatures)
+		if err != nil {
+			return nil, err
+		}
+		options, err := getFeatureOptions(devcontainerConfig.Features[ref], feature)
+		if err != nil {
+			return nil, fmt.Errorf("feature %q: %s", ref, err)
+		}
+		features = append(features, ConfiguredFeature{Ref: ref, Options: options, Feature: feature})
+	}
+	return orderFeatures(features, devcontainerConfig.OverrideFeatureInstallOrder)
+}
+
+// featureMatchesID checks whether the feature matches an ID from installsAfter or overrideFeatureInstallOrder
+func featureMatchesID(feature ConfiguredFeature, id string) bool {
+	id = getFeatureRefID(id)
+	if getFeatureRefID(feature.Ref) == id {
+		return true
+	}
+	return feature.Feature != nil && feature.Feature.ID == id
+}
+
+// orderFeatures sorts features into install order. Features are installed after the features in their
+// installsAfter list (if present). Features that can be installed at the same point are ordered by their
+// position in overrideFeatureInstallOrder and then by reference
+func orderFeatures(features []ConfiguredFeature, overrideOrder []string) ([]ConfiguredFeature, error) {
+	priority := func(feature ConfiguredFeature) int {
+		for i, id := range overrideOrder {
+			if featureMatchesID(feature, id) {
+				return i
+			}
+		}
+		return len(overrideOrder)
+	}
+
+	// dependencies[i] holds the indexes of the features that feature i installs after
+	dependencies := make([]map[int]bool, len(features))
+	for i, feature := range features {
+		dependencies[i] = map[int]bool{}
+		if feature.Feature == nil {
+			continue
+		}
+		for _, id := range feature.Feature.InstallsAfter {
+			for j, other := range features {
+				if i != j && featureMatchesID(other, id) {
+					dependencies[i][j] = true
+				}
+			}
+		}
+	}
+
+	ordered := []ConfiguredFeature{}
+	installed := map[int]bool{}
+	for len(installed) < len(features) {
+		ready := []int{}
+		for i := range features {
+			if installed[i] {
+				continue
+			}
+			isReady := true
+			for j := range dependencies[i] {
+				isReady = isReady && installed[j]
+			}
+			if isReady {
+				ready = append(ready, i)
+			}
+		}
+		if len(ready) == 0 {
+			remaining := []string{}
+			for i, feature := range features {
+				if !installed[i] {
+					remaining = append(remaining, feature.Ref)
+				}
+			}
+			return nil, fmt.Errorf("features have circular installsAfter dependencies: %s", strings.Join(remaining, ", "))
+		}
+		sort.SliceStable(ready, func(a, b int) bool {
+			priorityA, priorityB := priority(features[ready[a]]), priority(features[ready[b]])
+			if priorityA != priorityB {
+				return priorityA < priorityB
+			}
+			return features[ready[a]].Ref < features[ready[b]].Ref
+		})
+		for _, i := range ready {
+			installed[i] = true
+			ordered = append(ordered, features[i])
+		}
+	}
+	return ordered, nil
+}
+
+// FindFeature resolves a feature reference for the dev container in folderPath (local references
+// are relative to the folder containing devcontainer.json). Returns nil if the feature isn't found
+func FindFeature(folderPath string, ref string) (*DevcontainerFeature, error) {
+	devcontainerJSONPath, err := getDevContainerJsonPath(folderPath)
+	if err != nil {
+		return nil, err
+	}
+	return findFeature(ref, filepath.Dir(devcontainerJSONPath), configFeatureFolders())
+}
+
+// AddFeatureToDevcontainer adds (or updates) a feature in the devcontainer.json for folderPath
+// feature is the metadata for ref (see FindFeature) used to validate the options, or nil if it wasn't found
+// If the feature is already present with a different version then that entry is updated to ref
+func AddFeatureToDevcontainer(folderPath string, ref string, feature *DevcontainerFeature, options map[string]interface{}) error {
+	devcontainerJSONPath, err := getDevContainerJsonPath(folderPath)
+	if err != nil {
+		return err
+	}
+	if feature != nil {
+		if problems := feature.ValidateOptions(options); len(problems) > 0 {
+			return fmt.Errorf("invalid options for feature %q: %s", ref, strings.Join(problems, "; "))
+		}
+	}
+
+	return updateDevcontainerJSON(devcontainerJSONPath, func(document *jsoncDocument) error {
+		if featuresNode := document.Find("features"); featuresNode != nil {
+			for _, property := range featuresNode.Properties {
+				if property.Name != ref && getFeatureRefID(property.Name) == getFeatureRefID(ref) {
+					return document.RenameValue([]string{"features", property.Name}, ref, options)
+				}
+			}
+		}
+		return document.SetValue([]string{"features", ref}, options)
+	})
+}
+
+// RemoveFeatureFromDevcontainer removes a feature from the devcontainer.json for folderPath
+// ref can be the reference used in devcontainer.json or the reference without the version
+func RemoveFeatureFromDevcontainer(folderPath string, ref string) error {
+	devcontainerJSONPath, err := getDevContainerJsonPath(folderPath)
+	if err != nil {
+		return err
+	}
+	return updateDevcontainerJSON(devcontainerJSONPath, func(document *jsoncDocument) error {
+		featuresNode := document.Find("features")
+		if featuresNode != nil {
+			for _, property := range featuresNode.Properties {
+				if property.Name == ref || getFeatureRefID(property.Name) == ref {
+					return document.RemoveValue([]string{"features", property.Name})
+				}
+			}
+		}
+		return fmt.Errorf("feature %q not found in %q", ref, devcontainerJSONPath)
+	})
+}
+
+// updateDevcontainerJSON loads the devcontainer.json document, applies update and saves the result
+func updateDevcontainerJSON(devcontainerJSONPath string, update func(document *jsoncDocument) error) error {
+	buf, err := ioutil.ReadFile(devcontainerJSONPath)
+	if err != nil {
+		return fmt.Errorf("error reading file %q: %s", devcontainerJSONPath, err)
+	}
+	document, err := parseJSONC(buf)
+	if err != nil {
+		return fmt.Errorf("error parsing file %q: %s", devcontainerJSONPath, err)
+	}
+	if err = update(document); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(devcontainerJSONPath, document.Bytes(), 0644); err != nil {
+		return fmt.Errorf("error writing file %q: %s", devcontainerJSONPath, err)
+	}
+	return nil
+}
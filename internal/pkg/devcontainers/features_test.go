@@ -0,0 +1,329 @@
+package devcontainers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// useFeatureFolders sets the feature folders used to resolve features for the test
+func useFeatureFolders(t *testing.T, folders ...string) {
+	previous := configFeatureFolders
+	configFeatureFolders = func() []string { return folders }
+	t.Cleanup(func() { configFeatureFolders = previous })
+}
+
+func createTarball(t *testing.T, files map[string][]byte, gzipped bool) []byte {
+	var buf bytes.Buffer
+	tarWriter := tar.NewWriter(&buf)
+	for name, content := range files {
+		if err := tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !gzipped {
+		return buf.Bytes()
+	}
+	var gzipBuf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipBuf)
+	_, _ = gzipWriter.Write(buf.Bytes())
+	_ = gzipWriter.Close()
+	return gzipBuf.Bytes()
+}
+
+// createOCILayoutTarball creates an OCI image layout tarball for a feature
+func createOCILayoutTarball(t *testing.T, featureFiles map[string][]byte) []byte {
+	files := map[string][]byte{"oci-layout": []byte(`{"imageLayoutVersion": "1.0.0"}`)}
+	addBlob := func(content []byte) string {
+		hash := sha256.Sum256(content)
+		digest := hex.EncodeToString(hash[:])
+		files["blobs/sha256/"+digest] = content
+		return "sha256:" + digest
+	}
+	layerDigest := addBlob(createTarball(t, featureFiles, false))
+	manifest, _ := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"layers": []map[string]string{
+			{"mediaType": "application/vnd.devcontainers.layer.v1+tar", "digest": layerDigest},
+		},
+	})
+	manifestDigest := addBlob(manifest)
+	index, _ := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"manifests":     []map[string]string{{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": manifestDigest}},
+	})
+	files["index.json"] = index
+	return createTarball(t, files, false)
+}
+
+func TestGetFeaturesFromFolders_LoadsFoldersAndTarballs(t *testing.T) {
+	root := createValidateTestFolder(t, map[string]string{
+		"features/go/devcontainer-feature.json": `{
+	// comment
+	"id": "go", "version": "1.2.0", "name": "Go",
+	"options": { "version": { "type": "string", "default": "latest" } }
+}`,
+		"features/not-a-feature/readme.md": "",
+	})
+	featureFolder := filepath.Join(root, "features")
+	_ = ioutil.WriteFile(filepath.Join(featureFolder, "devcontainer-feature-node.tgz"), createTarball(t, map[string][]byte{
+		"./devcontainer-feature.json": []byte(`{ "id": "node", "version": "1.0.0" }`),
+		"./install.sh":                []byte("#!/bin/sh\n"),
+	}, true), 0644)
+	_ = ioutil.WriteFile(filepath.Join(featureFolder, "python.tar"), createOCILayoutTarball(t, map[string][]byte{
+		"devcontainer-feature.json": []byte(`{ "id": "python", "version": "2.1.0" }`),
+	}), 0644)
+
+	features, err := getFeaturesFromFolders([]string{featureFolder})
+	if !assert.NoError(t, err) {
+		return
+	}
+	versions := map[string]string{}
+	for _, feature := range features {
+		versions[feature.ID] = feature.Version
+	}
+	assert.Equal(t, map[string]string{"go": "1.2.0", "node": "1.0.0", "python": "2.1.0"}, versions)
+}
+
+func TestResolveFeature(t *testing.T) {
+	root := createValidateTestFolder(t, map[string]string{
+		"features/go/devcontainer-feature.json":                 `{ "id": "go", "version": "1.2.0" }`,
+		"project/.devcontainer/local/devcontainer-feature.json": `{ "id": "local", "version": "0.1.0" }`,
+	})
+	availableFeatures, err := getFeaturesFromFolders([]string{filepath.Join(root, "features")})
+	if !assert.NoError(t, err) {
+		return
+	}
+	configFolder := filepath.Join(root, "project", ".devcontainer")
+
+	feature, err := resolveFeature("ghcr.io/devcontainers/features/go:1", configFolder, availableFeatures)
+	if assert.NoError(t, err) && assert.NotNil(t, feature) {
+		assert.Equal(t, "go", feature.ID)
+	}
+	feature, err = resolveFeature("ghcr.io/devcontainers/features/go:2", configFolder, availableFeatures)
+	if assert.NoError(t, err) {
+		assert.Nil(t, feature)
+	}
+	feature, err = resolveFeature("./local", configFolder, availableFeatures)
+	if assert.NoError(t, err) && assert.NotNil(t, feature) {
+		assert.Equal(t, "local", feature.ID)
+	}
+	feature, err = resolveFeature("./missing", configFolder, availableFeatures)
+	if assert.NoError(t, err) {
+		assert.Nil(t, feature)
+	}
+}
+
+func TestGetFeatureRefID(t *testing.T) {
+	assert.Equal(t, "ghcr.io/devcontainers/features/go", getFeatureRefID("ghcr.io/devcontainers/features/go:1"))
+	assert.Equal(t, "ghcr.io/devcontainers/features/go", getFeatureRefID("ghcr.io/devcontainers/features/go@sha256:abc"))
+	assert.Equal(t, "localhost:5000/features/go", getFeatureRefID("localhost:5000/features/go"))
+	assert.Equal(t, "1", getFeatureRefVersion("ghcr.io/devcontainers/features/go:1"))
+	assert.Equal(t, "", getFeatureRefVersion("localhost:5000/features/go"))
+}
+
+func TestOrderFeatures(t *testing.T) {
+	features := []ConfiguredFeature{
+		{Ref: "ghcr.io/devcontainers/features/python:1", Feature: &DevcontainerFeature{ID: "python", InstallsAfter: []string{"ghcr.io/devcontainers/features/common-utils"}}},
+		{Ref: "ghcr.io/devcontainers/features/common-utils:2", Feature: &DevcontainerFeature{ID: "common-utils"}},
+		{Ref: "ghcr.io/devcontainers/features/go:1"},
+		{Ref: "ghcr.io/devcontainers/features/azure-cli:1"},
+	}
+
+	getRefs := func(features []ConfiguredFeature) []string {
+		refs := []string{}
+		for _, feature := range features {
+			refs = append(refs, feature.Ref)
+		}
+		return refs
+	}
+
+	ordered, err := orderFeatures(features, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			"ghcr.io/devcontainers/features/azure-cli:1",
+			"ghcr.io/devcontainers/features/common-utils:2",
+			"ghcr.io/devcontainers/features/go:1",
+			"ghcr.io/devcontainers/features/python:1",
+		}, getRefs(ordered))
+	}
+
+	ordered, err = orderFeatures(features, []string{"ghcr.io/devcontainers/features/go"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			"ghcr.io/devcontainers/features/go:1",
+			"ghcr.io/devcontainers/features/azure-cli:1",
+			"ghcr.io/devcontainers/features/common-utils:2",
+			"ghcr.io/devcontainers/features/python:1",
+		}, getRefs(ordered))
+	}
+
+	features[1].Feature.InstallsAfter = []string{"ghcr.io/devcontainers/features/python"}
+	_, err = orderFeatures(features, nil)
+	assert.Error(t, err)
+}
+
+func TestGetFeatureOptions_VersionShorthand(t *testing.T) {
+	withVersion := &DevcontainerFeature{ID: "go", Options: map[string]FeatureOption{"version": {Type: "string"}}}
+	withoutVersion := &DevcontainerFeature{ID: "docker-in-docker", Options: map[string]FeatureOption{"moby": {Type: "boolean"}}}
+
+	options, err := getFeatureOptions("1.19", withVersion)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]interface{}{"version": "1.19"}, options)
+		assert.Empty(t, withVersion.ValidateOptions(options))
+	}
+	options, err = getFeatureOptions("latest", withoutVersion)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]interface{}{}, options)
+		assert.Empty(t, withoutVersion.ValidateOptions(options))
+	}
+	options, err = getFeatureOptions("latest", nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]interface{}{"version": "latest"}, options)
+	}
+}
+
+func TestValidateFeatureOptions(t *testing.T) {
+	feature := &DevcontainerFeature{
+		ID: "test",
+		Options: map[string]FeatureOption{
+			"version": {Type: "string"},
+			"flavor":  {Type: "string", Enum: []string{"a", "b"}},
+			"enabled": {Type: "boolean"},
+		},
+	}
+	assert.Empty(t, feature.ValidateOptions(map[string]interface{}{"version": "1.0", "flavor": "a", "enabled": true}))
+	assert.Equal(t, []string{
+		`option "enabled" must be a boolean`,
+		`option "flavor" must be one of a, b`,
+		`option "unknown" is not defined by feature "test"`,
+	}, feature.ValidateOptions(map[string]interface{}{"flavor": "c", "enabled": "yes", "unknown": "value"}))
+
+	options, err := ParseFeatureOptionValues(feature, map[string]string{"enabled": "true", "version": "true"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]interface{}{"enabled": true, "version": "true"}, options)
+	}
+	_, err = ParseFeatureOptionValues(feature, map[string]string{"enabled": "maybe"})
+	assert.Error(t, err)
+}
+
+func TestAddAndRemoveFeature_KeepsComments(t *testing.T) {
+	root := createValidateTestFolder(t, map[string]string{
+		"features/go/devcontainer-feature.json": `{ "id": "go", "version": "1.2.0", "options": { "version": { "type": "string" } } }`,
+		"project/.devcontainer/devcontainer.json": `{
+	// the name
+	"name": "test",
+	"image": "ubuntu" // the image
+}`,
+	})
+	useFeatureFolders(t, filepath.Join(root, "features"))
+	projectFolder := filepath.Join(root, "project")
+	devcontainerJSONPath := filepath.Join(projectFolder, ".devcontainer", "devcontainer.json")
+
+	goFeature, err := FindFeature(projectFolder, "ghcr.io/devcontainers/features/go:1")
+	if !assert.NoError(t, err) || !assert.NotNil(t, goFeature) {
+		return
+	}
+	err = AddFeatureToDevcontainer(projectFolder, "ghcr.io/devcontainers/features/go:1", goFeature, map[string]interface{}{"version": "1.20"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	err = AddFeatureToDevcontainer(projectFolder, "ghcr.io/devcontainers/features/node:1", nil, map[string]interface{}{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	buf, _ := ioutil.ReadFile(devcontainerJSONPath)
+	assert.Equal(t, `{
+	// the name
+	"name": "test",
+	"image": "ubuntu", // the image
+	"features": {
+		"ghcr.io/devcontainers/features/go:1": {
+			"version": "1.20"
+		},
+		"ghcr.io/devcontainers/features/node:1": {}
+	}
+}`, string(buf))
+
+	features, err := GetDevcontainerFeatures(projectFolder)
+	if assert.NoError(t, err) && assert.Len(t, features, 2) {
+		assert.NotNil(t, features[0].Feature)
+		assert.Nil(t, features[1].Feature)
+	}
+
+	err = AddFeatureToDevcontainer(projectFolder, "ghcr.io/devcontainers/features/go:1", goFeature, map[string]interface{}{"unknown": "value"})
+	assert.Error(t, err)
+
+	// adding a different version updates the existing entry
+	err = AddFeatureToDevcontainer(projectFolder, "ghcr.io/devcontainers/features/go:2", goFeature, map[string]interface{}{"version": "1.21"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	buf, _ = ioutil.ReadFile(devcontainerJSONPath)
+	assert.Equal(t, `{
+	// the name
+	"name": "test",
+	"image": "ubuntu", // the image
+	"features": {
+		"ghcr.io/devcontainers/features/go:2": {
+			"version": "1.21"
+		},
+		"ghcr.io/devcontainers/features/node:1": {}
+	}
+}`, string(buf))
+
+	if !assert.NoError(t, RemoveFeatureFromDevcontainer(projectFolder, "ghcr.io/devcontainers/features/go")) {
+		return
+	}
+	buf, _ = ioutil.ReadFile(devcontainerJSONPath)
+	assert.Equal(t, `{
+	// the name
+	"name": "test",
+	"image": "ubuntu", // the image
+	"features": {
+		"ghcr.io/devcontainers/features/node:1": {}
+	}
+}`, string(buf))
+
+	assert.Error(t, RemoveFeatureFromDevcontainer(projectFolder, "ghcr.io/devcontainers/features/missing"))
+}
+
+func TestValidateDevcontainer_ValidatesFeatureOptions(t *testing.T) {
+	root := createValidateTestFolder(t, map[string]string{
+		"features/go/devcontainer-feature.json":   `{ "id": "go", "options": { "version": { "type": "string" } } }`,
+		"features/sshd/devcontainer-feature.json": `{ "id": "sshd", "options": { "port": { "type": "string" } } }`,
+		".devcontainer/devcontainer.json": `{
+	"image": "ubuntu",
+	"features": {
+		"ghcr.io/devcontainers/features/go:1": { "version": 1 },
+		"ghcr.io/devcontainers/features/node:1": "lts",
+		"ghcr.io/devcontainers/features/sshd:1": "latest",
+		"./missing": {}
+	}
+}`,
+	})
+	useFeatureFolders(t, filepath.Join(root, "features"))
+	jsonPath := filepath.Join(root, ".devcontainer", "devcontainer.json")
+
+	issues, err := ValidateDevcontainer(root)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			jsonPath + `:4:42: error: features.ghcr.io/devcontainers/features/go:1: option "version" must be a string`,
+			jsonPath + `:7:3: error: features: local feature "./missing" not found`,
+		}, getIssueMessages(issues))
+	}
+}
@@ -0,0 +1,638 @@
+package devcontainers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jsoncSyntaxError is returned when a JSONC document can't be parsed
+type jsoncSyntaxError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *jsoncSyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+func newJSONCSyntaxError(input []byte, offset int, message string) *jsoncSyntaxError {
+	line, column := getLineColumn(input, offset)
+	return &jsoncSyntaxError{Line: line, Column: column, Message: message}
+}
+
+// getLineColumn converts an offset in input to a (1-based) line and column
+func getLineColumn(input []byte, offset int) (int, int) {
+	line := bytes.Count(input[:offset], []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(input[:offset], '\n')
+	return line, column
+}
+
+// utf8BOM is the byte order mark that some editors write at the start of UTF-8 files
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// standardizeJSON converts JSON with comments (as permitted in devcontainer.json) to standard JSON
+// by blanking out a leading byte order mark, line/block comments and trailing commas. Offsets in the output match the input
+func standardizeJSON(input []byte) ([]byte, error) {
+	output := make([]byte, len(input))
+	copy(output, input)
+
+	blank := func(start int, end int) {
+		for i := start; i < end; i++ {
+			if output[i] != '\n' && output[i] != '\r' {
+				output[i] = ' '
+			}
+		}
+	}
+	if bytes.HasPrefix(input, utf8BOM) {
+		blank(0, len(utf8BOM))
+	}
+
+	// lastComma tracks the position of a comma that may turn out to be a trailing comma
+	lastComma := -1
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		switch {
+		case c == '"':
+			lastComma = -1
+			start := i
+			i++
+			for ; i < len(input) && input[i] != '"'; i++ {
+				if input[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(input) {
+				return nil, newJSONCSyntaxError(input, start, "unterminated string")
+			}
+		case c == '/' && i+1 < len(input) && input[i+1] == '/':
+			end := bytes.IndexByte(input[i:], '\n')
+			if end < 0 {
+				end = len(input)
+			} else {
+				end += i
+			}
+			blank(i, end)
+			i = end - 1
+		case c == '/' && i+1 < len(input) && input[i+1] == '*':
+			end := bytes.Index(input[i+2:], []byte("*/"))
+			if end < 0 {
+				return nil, newJSONCSyntaxError(input, i, "unterminated block comment")
+			}
+			end += i + 4
+			blank(i, end)
+			i = end - 1
+		case c == ',':
+			lastComma = i
+		case c == '}' || c == ']':
+			if lastComma >= 0 {
+				output[lastComma] = ' '
+			}
+			lastComma = -1
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			// whitespace doesn't affect whether a comma is trailing
+		default:
+			lastComma = -1
+		}
+	}
+	return output, nil
+}
+
+type jsoncKind int
+
+const (
+	jsoncObject jsoncKind = iota
+	jsoncArray
+	jsoncString
+	jsoncLiteral // number, true, false or null
+)
+
+// jsoncNode is a value in a JSONC document along with its location in the source
+type jsoncNode struct {
+	Kind       jsoncKind
+	Start      int // offset of the first byte of the value
+	End        int // offset after the last byte of the value
+	Properties []*jsoncProperty
+	Items      []*jsoncNode
+}
+
+// jsoncProperty is a property of a JSONC object. Start is the offset of the property name
+type jsoncProperty struct {
+	Name  string
+	Start int
+	Value *jsoncNode
+}
+
+// jsoncDocument is a parsed JSON with comments document (e.g. devcontainer.json)
+// It supports reading values into typed structs and editing values in place so
+// that comments and formatting in the rest of the document are preserved
+type jsoncDocument struct {
+	source []byte
+	// standardized is source with comments and trailing commas blanked out (offsets match source)
+	standardized []byte
+	root         *jsoncNode
+}
+
+func parseJSONC(input []byte) (*jsoncDocument, error) {
+	standardized, err := standardizeJSON(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &jsoncParser{input: standardized}
+	p.skipWhitespace()
+	root, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+	if p.pos < len(p.input) {
+		return nil, p.errorf("unexpected content after end of document")
+	}
+	return &jsoncDocument{source: input, standardized: standardized, root: root}, nil
+}
+
+// Bytes returns the (possibly edited) document source
+func (d *jsoncDocument) Bytes() []byte {
+	return d.source
+}
+
+// Unmarshal decodes the document into v (using encoding/json)
+func (d *jsoncDocument) Unmarshal(v interface{}) error {
+	return json.Unmarshal(d.standardized, v)
+}
+
+// Find returns the node at the property path (or nil if not found)
+func (d *jsoncDocument) Find(path ...string) *jsoncNode {
+	node := d.root
+	for _, name := range path {
+		property := node.findProperty(name)
+		if property == nil {
+			return nil
+		}
+		node = property.Value
+	}
+	return node
+}
+
+// SetValue sets the value at the property path, adding properties (and parent objects) that don't exist
+// Only the text for the value (or new property) is changed, so comments and formatting elsewhere are kept
+func (d *jsoncDocument) SetValue(path []string, value interface{}) error {
+	if len(path) == 0 {
+		return fmt.Errorf("path must not be empty")
+	}
+	node := d.root
+	for i, name := range path {
+		if node.Kind != jsoncObject {
+			return fmt.Errorf("cannot set %q: %q is not an object", strings.Join(path, "."), strings.Join(path[:i], "."))
+		}
+		property := node.findProperty(name)
+		if property == nil {
+			// build the missing part of the path as nested objects
+			for j := len(path) - 1; j > i; j-- {
+				value = map[string]interface{}{path[j]: value}
+			}
+			return d.addProperty(node, name, value)
+		}
+		node = property.Value
+	}
+	formatted, err := d.formatValue(value, d.getLineIndent(node.Start))
+	if err != nil {
+		return err
+	}
+	return d.replace(node.Start, node.End, formatted)
+}
+
+// RenameValue replaces the property at the path with a property called name that has value, keeping its position
+func (d *jsoncDocument) RenameValue(path []string, name string, value interface{}) error {
+	if len(path) == 0 {
+		return fmt.Errorf("path must not be empty")
+	}
+	parent := d.Find(path[:len(path)-1]...)
+	if parent == nil {
+		return fmt.Errorf("cannot rename %q: property not found", strings.Join(path, "."))
+	}
+	property := parent.findProperty(path[len(path)-1])
+	if property == nil {
+		return fmt.Errorf("cannot rename %q: property not found", strings.Join(path, "."))
+	}
+	formatted, err := d.formatProperty(name, value, d.getLineIndent(property.Start))
+	if err != nil {
+		return err
+	}
+	return d.replace(property.Start, property.Value.End, formatted)
+}
+
+// RemoveValue removes the property at the path (if it exists) along with the line it is on if nothing else is on that line
+func (d *jsoncDocument) RemoveValue(path []string) error {
+	if len(path) == 0 {
+		return fmt.Errorf("path must not be empty")
+	}
+	parent := d.Find(path[:len(path)-1]...)
+	if parent == nil || parent.Kind != jsoncObject {
+		return nil
+	}
+	index := -1
+	for i, property := range parent.Properties {
+		if property.Name == path[len(path)-1] {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil
+	}
+	property := parent.Properties[index]
+	previousEnd := -1
+	if index > 0 {
+		previousEnd = parent.Properties[index-1].Value.End
+	}
+	return d.removeEntry(parent, property.Start, property.Value.End, previousEnd)
+}
+
+// RemoveItem removes the item at index from the array at the property path
+// along with the line it is on if nothing else is on that line
+func (d *jsoncDocument) RemoveItem(path []string, index int) error {
+	array := d.Find(path...)
+	if array == nil || array.Kind != jsoncArray {
+		return fmt.Errorf("cannot remove from %q: value is not an array", strings.Join(path, "."))
+	}
+	if index < 0 || index >= len(array.Items) {
+		return fmt.Errorf("cannot remove item %d from %q: index out of range", index, strings.Join(path, "."))
+	}
+	previousEnd := -1
+	if index > 0 {
+		previousEnd = array.Items[index-1].End
+	}
+	return d.removeEntry(array, array.Items[index].Start, array.Items[index].End, previousEnd)
+}
+
+// removeEntry removes the object property or array item at source[start:end] from container along with its
+// separating comma: the following comma, or the preceding comma if this is the last entry
+// previousEnd is the end of the previous entry (or -1 if this is the first entry)
+func (d *jsoncDocument) removeEntry(container *jsoncNode, start int, end int, previousEnd int) error {
+	precedingComma := -1
+	afterValue := d.skipWhitespaceAndComments(end, container.End-1)
+	if d.source[afterValue] == ',' {
+		end = afterValue + 1
+	} else if previousEnd >= 0 {
+		if comma := d.skipWhitespaceAndComments(previousEnd, start); d.source[comma] == ',' {
+			if len(bytes.TrimSpace(d.source[comma+1:start])) == 0 {
+				start = comma
+			} else {
+				// keep the comment after the comma
+				precedingComma = comma
+			}
+		}
+	}
+	return d.removeRange(start, end, precedingComma)
+}
+
+// removeRange removes source[start:end] along with the line it is on if nothing else is on that line
+// If comma is not -1 then the comma at that offset (before start) is also removed, keeping anything else
+// between it and start (e.g. a comment after the comma)
+func (d *jsoncDocument) removeRange(start int, end int, comma int) error {
+	lineStart := bytes.LastIndexByte(d.source[:start], '\n') + 1
+	lineEnd := bytes.IndexByte(d.source[end:], '\n')
+	if lineEnd >= 0 && len(bytes.TrimSpace(d.source[lineStart:start])) == 0 && len(bytes.TrimSpace(d.source[end:end+lineEnd])) == 0 {
+		start = lineStart
+		end = end + lineEnd + 1
+	}
+	if comma < 0 {
+		return d.replace(start, end, "")
+	}
+	// the comma is before start so its offset isn't changed by the first replace
+	// (and the document stays valid as the comma is a trailing comma until it is removed)
+	if err := d.replace(start, end, ""); err != nil {
+		return err
+	}
+	return d.replace(comma, comma+1, "")
+}
+
+// addProperty inserts a new property at the end of the object
+func (d *jsoncDocument) addProperty(object *jsoncNode, name string, value interface{}) error {
+	if len(object.Properties) == 0 {
+		indent := d.getLineIndent(object.Start)
+		formatted, err := d.formatProperty(name, value, indent+d.getIndentUnit())
+		if err != nil {
+			return err
+		}
+		return d.replace(object.Start, object.End, "{\n"+indent+d.getIndentUnit()+formatted+"\n"+indent+"}")
+	}
+
+	last := object.Properties[len(object.Properties)-1]
+	indent := d.getLineIndent(last.Start)
+	formatted, err := d.formatProperty(name, value, indent)
+	if err != nil {
+		return err
+	}
+	return d.insertAfter(object, last.Value.End, indent, formatted)
+}
+
+// AppendValue appends value to the array at the property path, adding the array (and parent objects) if it doesn't exist
+// Only the text for the new item is added, so comments and formatting elsewhere are kept
+func (d *jsoncDocument) AppendValue(path []string, value interface{}) error {
+	array := d.Find(path...)
+	if array == nil {
+		return d.SetValue(path, []interface{}{value})
+	}
+	if array.Kind != jsoncArray {
+		return fmt.Errorf("cannot append to %q: value is not an array", strings.Join(path, "."))
+	}
+	if len(array.Items) == 0 {
+		formatted, err := d.formatValue([]interface{}{value}, d.getLineIndent(array.Start))
+		if err != nil {
+			return err
+		}
+		return d.replace(array.Start, array.End, formatted)
+	}
+
+	last := array.Items[len(array.Items)-1]
+	indent := d.getLineIndent(last.Start)
+	formatted, err := d.formatValue(value, indent)
+	if err != nil {
+		return err
+	}
+	return d.insertAfter(array, last.End, indent, formatted)
+}
+
+// UnmarshalValue decodes the value of node into v (using encoding/json)
+func (d *jsoncDocument) UnmarshalValue(node *jsoncNode, v interface{}) error {
+	return json.Unmarshal(d.standardized[node.Start:node.End], v)
+}
+
+// insertAfter inserts formatted as a new entry in container after the entry ending at lastEnd
+func (d *jsoncDocument) insertAfter(container *jsoncNode, lastEnd int, indent string, formatted string) error {
+	afterValue := d.skipWhitespaceAndComments(lastEnd, container.End-1)
+	hasTrailingComma := d.source[afterValue] == ','
+	// with a trailing comma the entry is added after the line with the comma (which may follow a comment)
+	lineStart := lastEnd
+	if hasTrailingComma {
+		lineStart = afterValue
+	}
+
+	lineEnd := bytes.IndexByte(d.source[lineStart:], '\n')
+	if lineEnd < 0 || lineStart+lineEnd > container.End-1 {
+		// container closes on the same line as the last value - add the entry inline
+		return d.replace(lastEnd, lastEnd, ", "+formatted)
+	}
+	lineEnd += lineStart
+	if lineEnd > 0 && d.source[lineEnd-1] == '\r' {
+		lineEnd--
+	}
+	// insert after any comment on the line of the last value, keeping the trailing comma style
+	if hasTrailingComma {
+		return d.replace(lineEnd, lineEnd, "\n"+indent+formatted+",")
+	}
+	restOfLine := string(d.source[lastEnd:lineEnd])
+	return d.replace(lastEnd, lineEnd, ","+restOfLine+"\n"+indent+formatted)
+}
+
+// skipWhitespaceAndComments returns the offset of the first character in source at or after offset (and before
+// limit) that isn't whitespace or part of a comment. Unlike scanning standardized, trailing commas aren't skipped
+func (d *jsoncDocument) skipWhitespaceAndComments(offset int, limit int) int {
+	for offset < limit {
+		switch {
+		case isJSONWhitespace(d.source[offset]):
+			offset++
+		case d.standardized[offset] == ' ' && bytes.HasPrefix(d.source[offset:limit], []byte("//")):
+			end := bytes.IndexByte(d.source[offset:limit], '\n')
+			if end < 0 {
+				return limit
+			}
+			offset += end
+		case d.standardized[offset] == ' ' && bytes.HasPrefix(d.source[offset:limit], []byte("/*")):
+			end := bytes.Index(d.source[offset+2:limit], []byte("*/"))
+			if end < 0 {
+				return limit
+			}
+			offset += end + 4
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+// replace replaces source[start:end] with value and re-parses the document
+func (d *jsoncDocument) replace(start int, end int, value string) error {
+	source := make([]byte, 0, len(d.source)-(end-start)+len(value))
+	source = append(source, d.source[:start]...)
+	source = append(source, value...)
+	source = append(source, d.source[end:]...)
+	updated, err := parseJSONC(source)
+	if err != nil {
+		return fmt.Errorf("error updating document: %s", err)
+	}
+	*d = *updated
+	return nil
+}
+
+func (d *jsoncDocument) formatProperty(name string, value interface{}, indent string) (string, error) {
+	formattedName, err := d.formatValue(name, indent)
+	if err != nil {
+		return "", err
+	}
+	formattedValue, err := d.formatValue(value, indent)
+	if err != nil {
+		return "", err
+	}
+	return formattedName + ": " + formattedValue, nil
+}
+
+// formatValue returns the JSON for value, with nested lines indented to match the document
+func (d *jsoncDocument) formatValue(value interface{}, indent string) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent(indent, d.getIndentUnit())
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(buf.String(), "\n"), nil
+}
+
+// getLineIndent returns the whitespace at the start of the line containing offset
+func (d *jsoncDocument) getLineIndent(offset int) string {
+	lineStart := bytes.LastIndexByte(d.source[:offset], '\n') + 1
+	end := lineStart
+	for end < offset && (d.source[end] == ' ' || d.source[end] == '\t') {
+		end++
+	}
+	return string(d.source[lineStart:end])
+}
+
+// getIndentUnit returns the indentation used for the properties of the root object (defaulting to a tab)
+func (d *jsoncDocument) getIndentUnit() string {
+	if d.root.Kind == jsoncObject && len(d.root.Properties) > 0 {
+		indent := d.getLineIndent(d.root.Properties[0].Start)
+		rootIndent := d.getLineIndent(d.root.Start)
+		if len(indent) > len(rootIndent) && strings.HasPrefix(indent, rootIndent) {
+			return indent[len(rootIndent):]
+		}
+	}
+	return "\t"
+}
+
+func (n *jsoncNode) findProperty(name string) *jsoncProperty {
+	if n.Kind != jsoncObject {
+		return nil
+	}
+	// use the last matching property as encoding/json does for duplicate names
+	for i := len(n.Properties) - 1; i >= 0; i-- {
+		if n.Properties[i].Name == name {
+			return n.Properties[i]
+		}
+	}
+	return nil
+}
+
+func isJSONWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// jsoncParser parses standardized JSON (i.e. with comments and trailing commas blanked) into jsoncNodes
+type jsoncParser struct {
+	input []byte
+	pos   int
+}
+
+func (p *jsoncParser) errorf(format string, args ...interface{}) error {
+	return newJSONCSyntaxError(p.input, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *jsoncParser) skipWhitespace() {
+	for p.pos < len(p.input) && isJSONWhitespace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *jsoncParser) parseValue() (*jsoncNode, error) {
+	if p.pos >= len(p.input) {
+		return nil, p.errorf("unexpected end of document")
+	}
+	switch c := p.input[p.pos]; {
+	case c == '{':
+		return p.parseObject()
+	case c == '[':
+		return p.parseArray()
+	case c == '"':
+		start := p.pos
+		if _, err := p.parseString(); err != nil {
+			return nil, err
+		}
+		return &jsoncNode{Kind: jsoncString, Start: start, End: p.pos}, nil
+	default:
+		start := p.pos
+		for p.pos < len(p.input) && !isJSONWhitespace(p.input[p.pos]) && strings.IndexByte(",:{}[]\"", p.input[p.pos]) < 0 {
+			p.pos++
+		}
+		if p.pos == start || !json.Valid(p.input[start:p.pos]) {
+			p.pos = start
+			return nil, p.errorf("invalid value")
+		}
+		return &jsoncNode{Kind: jsoncLiteral, Start: start, End: p.pos}, nil
+	}
+}
+
+func (p *jsoncParser) parseString() (string, error) {
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.input) && p.input[p.pos] != '"' {
+		if p.input[p.pos] == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.input) {
+		p.pos = start
+		return "", p.errorf("unterminated string")
+	}
+	p.pos++
+	var value string
+	if err := json.Unmarshal(p.input[start:p.pos], &value); err != nil {
+		p.pos = start
+		return "", p.errorf("invalid string: %s", err)
+	}
+	return value, nil
+}
+
+func (p *jsoncParser) parseObject() (*jsoncNode, error) {
+	node := &jsoncNode{Kind: jsoncObject, Start: p.pos}
+	p.pos++
+	p.skipWhitespace()
+	if p.pos < len(p.input) && p.input[p.pos] == '}' {
+		p.pos++
+		node.End = p.pos
+		return node, nil
+	}
+	for {
+		if p.pos >= len(p.input) || p.input[p.pos] != '"' {
+			return nil, p.errorf("expected property name")
+		}
+		property := &jsoncProperty{Start: p.pos}
+		name, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		property.Name = name
+		p.skipWhitespace()
+		if p.pos >= len(p.input) || p.input[p.pos] != ':' {
+			return nil, p.errorf("expected ':' after property name")
+		}
+		p.pos++
+		p.skipWhitespace()
+		if property.Value, err = p.parseValue(); err != nil {
+			return nil, err
+		}
+		node.Properties = append(node.Properties, property)
+		p.skipWhitespace()
+		if p.pos >= len(p.input) {
+			return nil, p.errorf("unterminated object")
+		}
+		switch p.input[p.pos] {
+		case ',':
+			p.pos++
+			p.skipWhitespace()
+		case '}':
+			p.pos++
+			node.End = p.pos
+			return node, nil
+		default:
+			return nil, p.errorf("expected ',' or '}'")
+		}
+	}
+}
+
+func (p *jsoncParser) parseArray() (*jsoncNode, error) {
+	node := &jsoncNode{Kind: jsoncArray, Start: p.pos}
+	p.pos++
+	p.skipWhitespace()
+	if p.pos < len(p.input) && p.input[p.pos] == ']' {
+		p.pos++
+		node.End = p.pos
+		return node, nil
+	}
+	for {
+		item, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		node.Items = append(node.Items, item)
+		p.skipWhitespace()
+		if p.pos >= len(p.input) {
+			return nil, p.errorf("unterminated array")
+		}
+		switch p.input[p.pos] {
+		case ',':
+			p.pos++
+			p.skipWhitespace()
+		case ']':
+			p.pos++
+			node.End = p.pos
+			return node, nil
+		default:
+			return nil, p.errorf("expected ',' or ']'")
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package devcontainers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStandardizeJSON_RemovesCommentsAndTrailingCommas(t *testing.T) {
+	input := `{
+	// line comment
+	"name": "test", /* block
+	comment */
+	"url": "http://example.com//not-a-comment",
+	"escaped": "quote \" // still a string",
+	"list": [1, 2, ],
+}`
+	output, err := standardizeJSON([]byte(input))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, len(input), len(output))
+
+	var result map[string]interface{}
+	if assert.NoError(t, json.Unmarshal(output, &result)) {
+		assert.Equal(t, "test", result["name"])
+		assert.Equal(t, "http://example.com//not-a-comment", result["url"])
+		assert.Equal(t, "quote \" // still a string", result["escaped"])
+		assert.Equal(t, []interface{}{1.0, 2.0}, result["list"])
+	}
+}
+
+func TestStandardizeJSON_UnterminatedBlockComment(t *testing.T) {
+	_, err := standardizeJSON([]byte(`{ /* oops }`))
+	assert.Error(t, err)
+}
+
+func TestParseJSONC_FindsValues(t *testing.T) {
+	document, err := parseJSONC([]byte(`{
+	/* "name": "commented" */
+	"name":
+		"test \"quoted\"",
+	"build": { "args": { "name": "nested" } },
+}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	var value struct {
+		Name string `json:"name"`
+	}
+	if assert.NoError(t, document.Unmarshal(&value)) {
+		assert.Equal(t, `test "quoted"`, value.Name)
+	}
+	assert.NotNil(t, document.Find("build", "args", "name"))
+	assert.Nil(t, document.Find("build", "missing"))
+}
+
+func TestParseJSONC_ReportsErrorLocation(t *testing.T) {
+	_, err := parseJSONC([]byte("{\n\t\"name\": \"test\"\n\t\"other\": 1\n}"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "line 3, column 2")
+	}
+}
+
+func TestJSONCSetValue_ReplacesOnlyTargetValue(t *testing.T) {
+	document, err := parseJSONC([]byte(`{
+	// the name
+	"name": "initial", // trailing comment
+	"build": {
+		"args": { "name": "nested" }
+	}
+}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NoError(t, document.SetValue([]string{"name"}, `new "name"`)) {
+		return
+	}
+	assert.Equal(t, `{
+	// the name
+	"name": "new \"name\"", // trailing comment
+	"build": {
+		"args": { "name": "nested" }
+	}
+}`, string(document.Bytes()))
+}
+
+func TestJSONCSetValue_AddsProperties(t *testing.T) {
+	document, err := parseJSONC([]byte(`{
+	"name": "test" // comment
+}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NoError(t, document.SetValue([]string{"settings", "editor.tabSize"}, 4)) {
+		return
+	}
+	assert.Equal(t, `{
+	"name": "test", // comment
+	"settings": {
+		"editor.tabSize": 4
+	}
+}`, string(document.Bytes()))
+
+	if !assert.NoError(t, document.SetValue([]string{"settings", "files.eol"}, "\n")) {
+		return
+	}
+	assert.Equal(t, `{
+	"name": "test", // comment
+	"settings": {
+		"editor.tabSize": 4,
+		"files.eol": "\n"
+	}
+}`, string(document.Bytes()))
+}
+
+func TestJSONCSetValue_KeepsTrailingCommaStyle(t *testing.T) {
+	document, err := parseJSONC([]byte(`{
+  "name": "test",
+}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NoError(t, document.SetValue([]string{"remoteUser"}, "vscode")) {
+		return
+	}
+	assert.Equal(t, `{
+  "name": "test",
+  "remoteUser": "vscode",
+}`, string(document.Bytes()))
+}
+
+func TestJSONCSetValue_ErrorsForNonObjectParent(t *testing.T) {
+	document, err := parseJSONC([]byte(`{ "name": "test" }`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Error(t, document.SetValue([]string{"name", "child"}, "value"))
+}
+
+func TestJSONCAppendValue_AddsItems(t *testing.T) {
+	document, err := parseJSONC([]byte(`{
+	"forwardPorts": [
+		3000 // app
+	],
+	"mounts": [],
+	"runArgs": [ "--init" ]
+}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, document.AppendValue([]string{"forwardPorts"}, 5432))
+	assert.NoError(t, document.AppendValue([]string{"mounts"}, "source=cache,target=/cache,type=volume"))
+	assert.NoError(t, document.AppendValue([]string{"runArgs"}, "--privileged"))
+	assert.NoError(t, document.AppendValue([]string{"customizations", "vscode", "extensions"}, "golang.go"))
+	assert.Equal(t, `{
+	"forwardPorts": [
+		3000, // app
+		5432
+	],
+	"mounts": [
+		"source=cache,target=/cache,type=volume"
+	],
+	"runArgs": [ "--init", "--privileged" ],
+	"customizations": {
+		"vscode": {
+			"extensions": [
+				"golang.go"
+			]
+		}
+	}
+}`, string(document.Bytes()))
+
+	var ports []int
+	assert.NoError(t, document.UnmarshalValue(document.Find("forwardPorts"), &ports))
+	assert.Equal(t, []int{3000, 5432}, ports)
+	assert.Error(t, document.AppendValue([]string{"customizations"}, "value"))
+}
+
+func TestJSONCRemoveValue_RemovesPropertyAndLine(t *testing.T) {
+	document, err := parseJSONC([]byte(`{
+	"name": "test",
+	// features
+	"features": {
+		"ghcr.io/devcontainers/features/go:1": {},
+		"ghcr.io/devcontainers/features/node:1": { "version": "lts" }
+	},
+	"remoteUser": "vscode"
+}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NoError(t, document.RemoveValue([]string{"features", "ghcr.io/devcontainers/features/go:1"})) {
+		return
+	}
+	assert.Equal(t, `{
+	"name": "test",
+	// features
+	"features": {
+		"ghcr.io/devcontainers/features/node:1": { "version": "lts" }
+	},
+	"remoteUser": "vscode"
+}`, string(document.Bytes()))
+
+	// removing the last property also removes the preceding comma
+	if !assert.NoError(t, document.RemoveValue([]string{"remoteUser"})) {
+		return
+	}
+	assert.Equal(t, `{
+	"name": "test",
+	// features
+	"features": {
+		"ghcr.io/devcontainers/features/node:1": { "version": "lts" }
+	}
+}`, string(document.Bytes()))
+
+	// removing a missing property is a no-op
+	assert.NoError(t, document.RemoveValue([]string{"missing", "value"}))
+}
+
+func TestJSONCRemoveValue_CommentBeforeComma(t *testing.T) {
+	document, err := parseJSONC([]byte(`{"a": 1 /* c */, "b": 2}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NoError(t, document.RemoveValue([]string{"a"})) {
+		return
+	}
+	assert.Equal(t, `{ "b": 2}`, string(document.Bytes()))
+}
+
+func TestJSONCRemoveValue_KeepsCommentAfterPrecedingComma(t *testing.T) {
+	document, err := parseJSONC([]byte(`{
+	"name": "test", // the name
+	"remoteUser": "vscode"
+}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NoError(t, document.RemoveValue([]string{"remoteUser"})) {
+		return
+	}
+	assert.Equal(t, `{
+	"name": "test" // the name
+}`, string(document.Bytes()))
+
+	document, err = parseJSONC([]byte(`{"a": 1, "b": 2}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	if assert.NoError(t, document.RemoveValue([]string{"b"})) {
+		assert.Equal(t, `{"a": 1}`, string(document.Bytes()))
+	}
+}
+
+func TestJSONCRemoveItem_RemovesItemAndComma(t *testing.T) {
+	document, err := parseJSONC([]byte(`{
+	"forwardPorts": [
+		3000, // app
+		5432
+	],
+	"mounts": [ "a", "b" ]
+}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NoError(t, document.RemoveItem([]string{"forwardPorts"}, 1)) {
+		return
+	}
+	if !assert.NoError(t, document.RemoveItem([]string{"mounts"}, 1)) {
+		return
+	}
+	assert.Equal(t, `{
+	"forwardPorts": [
+		3000 // app
+	],
+	"mounts": [ "a" ]
+}`, string(document.Bytes()))
+	assert.Error(t, document.RemoveItem([]string{"mounts"}, 1))
+}
+
+func TestParseJSONC_IgnoresByteOrderMark(t *testing.T) {
+	document, err := parseJSONC([]byte("\xef\xbb\xbf{ \"name\": \"test\" }"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	var config struct{ Name string }
+	if assert.NoError(t, document.Unmarshal(&config)) {
+		assert.Equal(t, "test", config.Name)
+	}
+	// the byte order mark is kept when the document is edited
+	if assert.NoError(t, document.SetValue([]string{"name"}, "updated")) {
+		assert.Equal(t, "\xef\xbb\xbf{ \"name\": \"updated\" }", string(document.Bytes()))
+	}
+}
+
+func TestJSONCAppendValue_CommentBeforeTrailingComma(t *testing.T) {
+	document, err := parseJSONC([]byte(`{
+  "forwardPorts": [
+    3000 /* app */,
+  ]
+}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NoError(t, document.AppendValue([]string{"forwardPorts"}, 5432)) {
+		return
+	}
+	assert.Equal(t, `{
+  "forwardPorts": [
+    3000 /* app */,
+    5432,
+  ]
+}`, string(document.Bytes()))
+}
@@ -0,0 +1,176 @@
+package devcontainers
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// StartDevcontainer starts a stopped dev container (running the postStartCommand)
+// For compose-based dev containers the whole compose project is started
+func StartDevcontainer(devcontainer DevcontainerInfo, output io.Writer) error {
+	if output == nil {
+		output = ioutil.Discard
+	}
+	if devcontainer.ComposeProject != "" {
+		project, err := GetComposeProjectForFolder(devcontainer.LocalFolderPath)
+		if err != nil {
+			return err
+		}
+		_, err = ComposeUp(project, nil, output)
+		return err
+	}
+	if devcontainer.State == "running" {
+		fmt.Fprintf(output, "Dev container %s is already running\n", devcontainer.DevcontainerName)
+		return nil
+	}
+	runtime, err := GetContainerRuntime()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(output, "Starting container %s\n", devcontainer.ContainerName)
+	if err = runtime.StartContainer(devcontainer.ContainerID); err != nil {
+		return err
+	}
+	// the local folder may have been moved or its devcontainer.json changed since the container was
+	// created, in which case the container is started without running the postStartCommand
+	config, workspaceFolder, err := loadDevcontainerConfigForFolder(devcontainer.LocalFolderPath)
+	if err != nil {
+		fmt.Fprintf(output, "Not running postStartCommand: %s\n", err)
+		return nil
+	}
+	return runLifecycleCommands(runtime, devcontainer.ContainerID, config, workspaceFolder, output, config.PostStartCommand)
+}
+
+// loadDevcontainerConfigForFolder loads the devcontainer.json for folderPath and returns it
+// along with the workspace folder in the container
+func loadDevcontainerConfigForFolder(folderPath string) (*DevcontainerConfig, string, error) {
+	devcontainerJSONPath, err := getDevContainerJsonPath(folderPath)
+	if err != nil {
+		return nil, "", err
+	}
+	config, err := LoadDevcontainerConfig(devcontainerJSONPath)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error loading devcontainer.json: %s", err)
+	}
+	_, workspaceFolder, err := getWorkspaceMount(folderPath, config)
+	if err != nil {
+		return nil, "", err
+	}
+	return config, workspaceFolder, nil
+}
+
+// StopDevcontainer stops a dev container
+// For compose-based dev containers all services in the compose project are stopped
+func StopDevcontainer(devcontainer DevcontainerInfo, output io.Writer) error {
+	if output == nil {
+		output = ioutil.Discard
+	}
+	if devcontainer.ComposeProject != "" {
+		project, err := GetComposeProjectForFolder(devcontainer.LocalFolderPath)
+		if err != nil {
+			return err
+		}
+		return runCompose(project, nil, output, "stop")
+	}
+	runtime, err := GetContainerRuntime()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(output, "Stopping container %s\n", devcontainer.ContainerName)
+	return runtime.StopContainer(devcontainer.ContainerID)
+}
+
+// RestartDevcontainer stops and then starts a dev container
+func RestartDevcontainer(devcontainer DevcontainerInfo, output io.Writer) error {
+	if err := StopDevcontainer(devcontainer, output); err != nil {
+		return err
+	}
+	devcontainer.State = "exited"
+	return StartDevcontainer(devcontainer, output)
+}
+
+// RemoveDevcontainer removes a dev container (stopping it if it is running)
+// For compose-based dev containers the compose project is taken down
+func RemoveDevcontainer(devcontainer DevcontainerInfo, output io.Writer) error {
+	if output == nil {
+		output = ioutil.Discard
+	}
+	if devcontainer.ComposeProject != "" {
+		project, err := GetComposeProjectForFolder(devcontainer.LocalFolderPath)
+		if err != nil {
+			return err
+		}
+		return ComposeDown(project, output)
+	}
+	runtime, err := GetContainerRuntime()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(output, "Removing container %s\n", devcontainer.ContainerName)
+	return runtime.RemoveContainer(devcontainer.ContainerID, true)
+}
+
+// RebuildDevcontainer removes a dev container along with the image built for it from .devcontainer
+// and then recreates it with the labels from the original container. Returns the ID of the new container
+func RebuildDevcontainer(devcontainer DevcontainerInfo, output io.Writer) (string, error) {
+	if output == nil {
+		output = ioutil.Discard
+	}
+	runtime, err := GetContainerRuntime()
+	if err != nil {
+		return "", err
+	}
+	container, err := runtime.InspectContainer(devcontainer.ContainerID)
+	if err != nil {
+		return "", fmt.Errorf("Failed to inspect container: %v", err)
+	}
+
+	if devcontainer.ComposeProject != "" {
+		project, err := GetComposeProjectForFolder(devcontainer.LocalFolderPath)
+		if err != nil {
+			return "", err
+		}
+		// --rmi local removes the images built by compose (i.e. those without a custom tag)
+		if err = runCompose(project, nil, output, "down", "--rmi", "local"); err != nil {
+			return "", err
+		}
+		return ComposeUp(project, getRebuildLabels(container.Labels), output)
+	}
+
+	fmt.Fprintf(output, "Removing container %s\n", devcontainer.ContainerName)
+	if err = runtime.RemoveContainer(devcontainer.ContainerID, true); err != nil {
+		return "", err
+	}
+	if isBuiltDevcontainerImage(container.Image) {
+		// The container has already been removed so carry on to recreate it if the image can't be removed
+		// (e.g. when another container is using it). The build will then reuse the image's cached layers
+		fmt.Fprintf(output, "Removing image %s\n", container.Image)
+		if err = runtime.RemoveImage(container.Image); err != nil {
+			fmt.Fprintf(output, "Failed to remove image %s (continuing with rebuild): %v\n", container.Image, err)
+		}
+	}
+	return UpDevcontainer(devcontainer.LocalFolderPath, UpOptions{Labels: getRebuildLabels(container.Labels), Output: output})
+}
+
+// isBuiltDevcontainerImage returns true if the image was built from a Dockerfile in .devcontainer
+// (by VS Code or UpDevcontainer) rather than being an image referenced from devcontainer.json
+func isBuiltDevcontainerImage(image string) bool {
+	return strings.HasPrefix(image, "vsc-")
+}
+
+// getRebuildLabels returns the labels to apply when recreating a container
+// Only the devcontainer.* labels that identify the dev container (e.g. devcontainer.local_folder) are kept.
+// devcontainer.metadata is recreated from devcontainer.json, and the other labels come from the image
+// (or compose) so are applied to the new container by the new image
+func getRebuildLabels(labels map[string]string) map[string]string {
+	result := map[string]string{}
+	for name, value := range labels {
+		if !strings.HasPrefix(name, "devcontainer.") || name == labelMetadata {
+			continue
+		}
+		result[name] = value
+	}
+	return result
+}
@@ -0,0 +1,154 @@
+package devcontainers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRebuildDevcontainer_RemovesContainerAndImageAndKeepsLabels(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	_ = os.MkdirAll(filepath.Join(root, ".devcontainer"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(root, ".devcontainer", "devcontainer.json"), []byte(`{ "build": { "dockerfile": "Dockerfile" } }`), 0644)
+
+	image := getImageNameForFolder(root)
+	runtime := &fakeRuntime{
+		containers: []ContainerDetails{
+			{Container: Container{ID: "abc", Name: "festive_saha", Image: image, State: "running", Labels: map[string]string{
+				labelLocalFolder:     root,
+				labelConfigFile:      filepath.Join(root, ".devcontainer", "devcontainer.json"),
+				labelMetadata:        `[{"remoteUser":"vscode"}]`,
+				"devcontainer.id":    "123",
+				"org.opencontainers": "from-old-image",
+			}}},
+		},
+	}
+	useFakeRuntime(t, runtime)
+
+	devcontainer := DevcontainerInfo{ContainerID: "abc", ContainerName: "festive_saha", LocalFolderPath: root}
+	containerID, err := RebuildDevcontainer(devcontainer, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "container1", containerID)
+	assert.Equal(t, []string{"abc"}, runtime.removes)
+	assert.Equal(t, []string{image}, runtime.imageRemoves)
+	if assert.Len(t, runtime.builds, 1) && assert.Len(t, runtime.runs, 1) {
+		assert.Equal(t, image, runtime.runs[0].Image)
+		assert.Equal(t, "123", runtime.runs[0].Labels["devcontainer.id"])
+		assert.Equal(t, root, runtime.runs[0].Labels[labelLocalFolder])
+		// image labels and the metadata for the old devcontainer.json (without remoteUser) aren't kept
+		assert.NotContains(t, runtime.runs[0].Labels, "org.opencontainers")
+		assert.NotContains(t, runtime.runs[0].Labels, labelMetadata)
+	}
+}
+
+func TestRebuildDevcontainer_RecreatesContainerWhenImageRemoveFails(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	_ = os.MkdirAll(filepath.Join(root, ".devcontainer"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(root, ".devcontainer", "devcontainer.json"), []byte(`{ "build": { "dockerfile": "Dockerfile" } }`), 0644)
+
+	image := getImageNameForFolder(root)
+	runtime := &fakeRuntime{
+		containers: []ContainerDetails{
+			{Container: Container{ID: "abc", Image: image, State: "running", Labels: map[string]string{labelLocalFolder: root}}},
+		},
+		removeImageErr: &RuntimeError{Operation: "remove image", StatusCode: 409, Message: "image is being used by running container def"},
+	}
+	useFakeRuntime(t, runtime)
+
+	var output bytes.Buffer
+	containerID, err := RebuildDevcontainer(DevcontainerInfo{ContainerID: "abc", LocalFolderPath: root}, &output)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "container1", containerID)
+		assert.Equal(t, []string{"abc"}, runtime.removes)
+		assert.Equal(t, []string{image}, runtime.imageRemoves)
+		assert.Len(t, runtime.builds, 1)
+		assert.Len(t, runtime.runs, 1)
+		assert.Contains(t, output.String(), "Failed to remove image")
+	}
+}
+
+func TestRebuildDevcontainer_KeepsReferencedImage(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	_ = os.MkdirAll(filepath.Join(root, ".devcontainer"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(root, ".devcontainer", "devcontainer.json"), []byte(`{ "image": "ubuntu" }`), 0644)
+
+	runtime := &fakeRuntime{
+		containers: []ContainerDetails{
+			{Container: Container{ID: "abc", Image: "ubuntu", State: "exited", Labels: map[string]string{labelLocalFolder: root}}},
+		},
+	}
+	useFakeRuntime(t, runtime)
+
+	_, err = RebuildDevcontainer(DevcontainerInfo{ContainerID: "abc", LocalFolderPath: root}, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"abc"}, runtime.removes)
+		assert.Empty(t, runtime.imageRemoves)
+		assert.Len(t, runtime.runs, 1)
+	}
+}
+
+func TestRestartDevcontainer_StopsAndStartsContainer(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	_ = os.MkdirAll(filepath.Join(root, ".devcontainer"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(root, ".devcontainer", "devcontainer.json"), []byte(`{ "image": "ubuntu", "postStartCommand": "echo started" }`), 0644)
+
+	runtime := &fakeRuntime{
+		containers: []ContainerDetails{
+			{Container: Container{ID: "abc", Image: "ubuntu", State: "exited", Labels: map[string]string{labelLocalFolder: root}}},
+		},
+	}
+	useFakeRuntime(t, runtime)
+
+	err = RestartDevcontainer(DevcontainerInfo{ContainerID: "abc", LocalFolderPath: root, State: "running"}, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"abc"}, runtime.stops)
+		assert.Equal(t, []string{"abc"}, runtime.starts)
+		if assert.Len(t, runtime.execCalls, 1) {
+			assert.Equal(t, []string{"/bin/sh", "-c", "echo started"}, runtime.execCalls[0].Cmd)
+		}
+	}
+}
+
+func TestStartDevcontainer_StartsContainerWhenLocalFolderIsMissing(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	missingFolder := filepath.Join(root, "moved")
+
+	runtime := &fakeRuntime{
+		containers: []ContainerDetails{
+			{Container: Container{ID: "abc", Image: "ubuntu", State: "exited", Labels: map[string]string{labelLocalFolder: missingFolder}}},
+		},
+	}
+	useFakeRuntime(t, runtime)
+
+	err = StartDevcontainer(DevcontainerInfo{ContainerID: "abc", LocalFolderPath: missingFolder, State: "exited"}, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"abc"}, runtime.starts)
+		assert.Empty(t, runtime.execCalls)
+	}
+}
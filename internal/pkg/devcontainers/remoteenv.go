@@ -0,0 +1,182 @@
+package devcontainers
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// userEnvProbe modes from devcontainer.json
+const (
+	userEnvProbeNone                  = "none"
+	userEnvProbeLoginShell            = "loginShell"
+	userEnvProbeInteractiveShell      = "interactiveShell"
+	userEnvProbeLoginInteractiveShell = "loginInteractiveShell"
+	userEnvProbeDefault               = userEnvProbeLoginInteractiveShell
+)
+
+const (
+	userEnvStartMarker = "__DEVCONTAINERX_ENV_START__"
+	userEnvEndMarker   = "__DEVCONTAINERX_ENV_END__"
+)
+
+// userEnvProbeIgnoredVars are shell-specific variables that shouldn't be passed to exec sessions
+var userEnvProbeIgnoredVars = map[string]bool{
+	"_":        true,
+	"PWD":      true,
+	"OLDPWD":   true,
+	"SHLVL":    true,
+	"HOSTNAME": true,
+}
+
+// getUserEnvProbeShellArgs returns the shell flags for the userEnvProbe mode (or nil for none)
+func getUserEnvProbeShellArgs(mode string) ([]string, error) {
+	switch mode {
+	case userEnvProbeNone:
+		return nil, nil
+	case userEnvProbeLoginShell:
+		return []string{"-l", "-c"}, nil
+	case userEnvProbeInteractiveShell:
+		return []string{"-i", "-c"}, nil
+	case userEnvProbeLoginInteractiveShell, "":
+		return []string{"-l", "-i", "-c"}, nil
+	default:
+		return nil, fmt.Errorf("unsupported userEnvProbe value %q", mode)
+	}
+}
+
+// probeUserEnv captures the environment from the user's shell in the container (using the userEnvProbe mode)
+func probeUserEnv(containerID string, userName string, mode string) (map[string]string, error) {
+	shellArgs, err := getUserEnvProbeShellArgs(mode)
+	if err != nil || shellArgs == nil {
+		return nil, err
+	}
+	// Run the user's shell (from passwd) and dump its environment between markers
+	// as login/interactive shells can write other output (e.g. from profile scripts)
+	shellCommand := fmt.Sprintf("printf '\\n%s\\n'; cat /proc/self/environ; printf '\\n%s\\n'", userEnvStartMarker, userEnvEndMarker)
+	script := fmt.Sprintf(`shell=$(getent passwd "$(id -un)" 2>/dev/null | cut -d: -f7)
+[ -x "$shell" ] || shell=/bin/sh
+exec "$shell" %s %s`, strings.Join(shellArgs, " "), shellQuote(shellCommand))
+
+	runtime, err := GetContainerRuntime()
+	if err != nil {
+		return nil, err
+	}
+	output, err := execOutput(runtime, containerID, userName, "/bin/sh", "-c", script)
+	if err != nil {
+		return nil, err
+	}
+	return parseUserEnvOutput(output)
+}
+
+// shellQuote quotes value for use as a single argument in a shell command
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func parseUserEnvOutput(output string) (map[string]string, error) {
+	start := strings.Index(output, userEnvStartMarker+"\n")
+	end := strings.LastIndex(output, "\n"+userEnvEndMarker)
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("failed to find environment in shell output")
+	}
+	content := output[start+len(userEnvStartMarker)+1 : end]
+
+	env := map[string]string{}
+	for _, entry := range strings.Split(content, "\x00") {
+		index := strings.Index(entry, "=")
+		if index <= 0 {
+			continue
+		}
+		name := entry[:index]
+		if userEnvProbeIgnoredVars[name] {
+			continue
+		}
+		env[name] = entry[index+1:]
+	}
+	return env, nil
+}
+
+// envListToMap converts a list of NAME=value entries to a map
+func envListToMap(envList []string) map[string]string {
+	env := map[string]string{}
+	for _, entry := range envList {
+		if index := strings.Index(entry, "="); index > 0 {
+			env[entry[:index]] = entry[index+1:]
+		}
+	}
+	return env
+}
+
+// getRemoteEnv builds the environment for an exec session in the same way as VS Code terminals:
+// the probed user environment (or the container environment with userEnvProbe: none) with remoteEnv
+// from devcontainer.json applied on top
+// containerEnv is the environment of the container used for ${containerEnv:VAR}
+func getRemoteEnv(config *DevcontainerConfig, containerEnv map[string]string, userEnv map[string]string) map[string]string {
+	baseEnv := userEnv
+	if len(baseEnv) == 0 {
+		baseEnv = containerEnv
+	}
+	env := map[string]string{}
+	for name, value := range baseEnv {
+		env[name] = value
+	}
+
+	// ${containerEnv:VAR} resolves against the container (with the probed values taking precedence)
+	lookupEnv := map[string]string{}
+	for name, value := range config.ContainerEnv {
+		lookupEnv[name] = value
+	}
+	for name, value := range containerEnv {
+		lookupEnv[name] = value
+	}
+	for name, value := range userEnv {
+		lookupEnv[name] = value
+	}
+	variables := &devcontainerVariables{LocalEnv: os.LookupEnv, ContainerEnv: lookupEnv}
+
+	for name, value := range config.RemoteEnv {
+		if value == nil {
+			delete(env, name)
+			continue
+		}
+		env[name] = variables.Substitute(*value)
+	}
+	return env
+}
+
+// getRemoteEnvUnsetCommand wraps args to unset the variables that remoteEnv removes ("VAR": null)
+// An exec session inherits the container environment, so removing a variable from the exec env isn't
+// enough to unset it
+func getRemoteEnvUnsetCommand(config *DevcontainerConfig, containerEnv map[string]string, args []string) []string {
+	names := []string{}
+	for name, value := range config.RemoteEnv {
+		if _, ok := containerEnv[name]; value == nil && ok && name != "PATH" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 || len(args) == 0 {
+		return args
+	}
+	sort.Strings(names)
+	result := []string{"env"}
+	for _, name := range names {
+		result = append(result, "-u", name)
+	}
+	return append(result, args...)
+}
+
+// envMapToList converts an env map to a sorted list of NAME=value entries
+func envMapToList(env map[string]string) []string {
+	names := []string{}
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := []string{}
+	for _, name := range names {
+		result = append(result, name+"="+env[name])
+	}
+	return result
+}
@@ -0,0 +1,89 @@
+package devcontainers
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRemoteEnv_AppliesRemoteEnvOverUserEnv(t *testing.T) {
+	config, err := parseDevcontainerConfig([]byte(`{
+		"containerEnv": { "FROM_CONFIG": "config" },
+		"remoteEnv": {
+			"PATH": "${containerEnv:PATH}:/extra",
+			"GREETING": "${containerEnv:FROM_CONFIG}-${containerEnv:FROM_CONTAINER}",
+			"REMOVED": null
+		}
+	}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	containerEnv := map[string]string{"PATH": "/usr/bin", "FROM_CONTAINER": "container"}
+	userEnv := map[string]string{"PATH": "/home/vscode/bin:/usr/bin", "REMOVED": "value", "LANG": "C.UTF-8"}
+
+	env := getRemoteEnv(config, containerEnv, userEnv)
+	assert.Equal(t, map[string]string{
+		"PATH":     "/home/vscode/bin:/usr/bin:/extra",
+		"GREETING": "config-container",
+		"LANG":     "C.UTF-8",
+	}, env)
+}
+
+func TestGetRemoteEnv_UsesContainerEnvWithoutUserEnvProbe(t *testing.T) {
+	config, err := parseDevcontainerConfig([]byte(`{
+		"userEnvProbe": "none",
+		"remoteEnv": {
+			"GREETING": "hello",
+			"REMOVED": null
+		}
+	}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	containerEnv := map[string]string{"PATH": "/usr/bin", "REMOVED": "value"}
+
+	env := getRemoteEnv(config, containerEnv, map[string]string{})
+	assert.Equal(t, map[string]string{
+		"PATH":     "/usr/bin",
+		"GREETING": "hello",
+	}, env)
+	// the exec session inherits REMOVED from the container so the command unsets it
+	assert.Equal(t, []string{"env", "-u", "REMOVED", "bash", "-l"}, getRemoteEnvUnsetCommand(config, containerEnv, []string{"bash", "-l"}))
+	assert.Equal(t, []string{"bash", "-l"}, getRemoteEnvUnsetCommand(config, map[string]string{"PATH": "/usr/bin"}, []string{"bash", "-l"}))
+}
+
+func TestParseUserEnvOutput_IgnoresShellOutput(t *testing.T) {
+	output := "Welcome!\n\n" + userEnvStartMarker + "\nPATH=/usr/bin\x00MULTI=line1\nline2\x00PWD=/root\x00\n" + userEnvEndMarker + "\n"
+	env, err := parseUserEnvOutput(output)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{"PATH": "/usr/bin", "MULTI": "line1\nline2"}, env)
+	}
+
+	_, err = parseUserEnvOutput("no markers")
+	assert.Error(t, err)
+}
+
+func TestProbeUserEnv_RunsShellScript(t *testing.T) {
+	// run the probe script locally to check that it captures the environment
+	useFakeRuntime(t, &fakeRuntime{
+		execHandler: func(containerID string, options ExecOptions) error {
+			cmd := exec.Command(options.Cmd[0], options.Cmd[1:]...)
+			cmd.Env = []string{"PATH=/usr/bin:/bin", "PROBE_TEST=value"}
+			cmd.Stdout = options.Stdout
+			return cmd.Run()
+		},
+	})
+
+	env, err := probeUserEnv("abc", "", userEnvProbeLoginShell)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "value", env["PROBE_TEST"])
+	}
+
+	env, err = probeUserEnv("abc", "", userEnvProbeNone)
+	if assert.NoError(t, err) {
+		assert.Nil(t, env)
+	}
+	_, err = probeUserEnv("abc", "", "unknown")
+	assert.Error(t, err)
+}
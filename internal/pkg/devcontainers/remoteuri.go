@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
-	"regexp"
 
 	"github.com/stuartleeks/devcontainer-cli/internal/pkg/git"
 	"github.com/stuartleeks/devcontainer-cli/internal/pkg/wsl"
@@ -71,7 +70,11 @@ func GetWorkspaceMountPath(folderPath string) (string, error) {
 		return "", fmt.Errorf("Error parsing devcontainer definition: %s", err)
 	}
 	if workspaceMountPath != "" {
-		return workspaceMountPath, nil
+		variables, err := getDevcontainerVariables(devcontainerDefinitionPath, "")
+		if err != nil {
+			return "", fmt.Errorf("Error getting devcontainer variables: %s", err)
+		}
+		return variables.Substitute(workspaceMountPath), nil
 	}
 
 	// No `workspaceFolder` found in devcontainer.json - use default
@@ -82,21 +85,12 @@ func GetWorkspaceMountPath(folderPath string) (string, error) {
 	return fmt.Sprintf("/workspaces/%s", devcontainerPath), nil
 }
 
-// TODO: add tests (and implementation) to handle JSON parsing with comments
-// Current implementation doesn't handle
-//  - block comments
-//  - the value split on a new line from the property name
-
 func getWorkspaceMountPathFromDevcontainerDefinition(definition []byte) (string, error) {
-	r, err := regexp.Compile("(?m)^\\s*\"workspaceFolder\"\\s*:\\s*\"(.*)\"")
+	config, err := parseDevcontainerConfig(definition)
 	if err != nil {
-		return "", fmt.Errorf("Error compiling regex: %s", err)
-	}
-	matches := r.FindSubmatch(definition)
-	if len(matches) == 2 {
-		return string(matches[1]), nil
+		return "", err
 	}
-	return "", nil
+	return config.WorkspaceFolder, nil
 }
 
 func getDefaultWorkspaceFolderForPath(path string) (string, error) {
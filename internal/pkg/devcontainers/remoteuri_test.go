@@ -48,3 +48,17 @@ func TestGetWorkspaceFolder_withNoWorkspaceFolder(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "", result)
 }
+func TestGetWorkspaceFolder_withBlockCommentAndValueOnNextLine(t *testing.T) {
+
+	content := `{
+		/*
+		"workspaceFolder": "/workspace/commented",
+		*/
+		"workspaceFolder":
+			"/workspace/wibble",
+	}`
+	result, err := getWorkspaceMountPathFromDevcontainerDefinition([]byte(content))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/workspace/wibble", result)
+}
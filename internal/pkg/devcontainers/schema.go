@@ -0,0 +1,235 @@
+package devcontainers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// jsonSchema is a (subset of) JSON Schema used to validate JSONC documents
+// Supported keywords: type, properties, additionalProperties, required, items, enum and $ref (to definitions)
+type jsonSchema struct {
+	Type                 jsonSchemaTypes        `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties"`
+	Required             []string               `json:"required"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []interface{}          `json:"enum"`
+	Ref                  string                 `json:"$ref"`
+	Definitions          map[string]*jsonSchema `json:"definitions"`
+
+	// disallowed is set for `false` schemas (e.g. "additionalProperties": false)
+	disallowed bool
+}
+
+// UnmarshalJSON implements json.Unmarshaler to handle boolean schemas
+func (s *jsonSchema) UnmarshalJSON(data []byte) error {
+	switch string(bytes.TrimSpace(data)) {
+	case "true":
+		*s = jsonSchema{}
+		return nil
+	case "false":
+		*s = jsonSchema{disallowed: true}
+		return nil
+	}
+	type schema jsonSchema // avoid recursing into UnmarshalJSON
+	return json.Unmarshal(data, (*schema)(s))
+}
+
+// jsonSchemaTypes handles `type` values that can be either a string or an array of strings
+type jsonSchemaTypes []string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (t *jsonSchemaTypes) UnmarshalJSON(data []byte) error {
+	var list StringList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*t = jsonSchemaTypes(list)
+	return nil
+}
+
+// schemaViolation is a validation failure for the value at Offset in the document
+type schemaViolation struct {
+	Offset  int
+	Message string
+}
+
+func parseJSONSchema(schemaJSON string) (*jsonSchema, error) {
+	var schema jsonSchema
+	if err := json.Unmarshal([]byte(schemaJSON), &schema); err != nil {
+		return nil, fmt.Errorf("error parsing schema: %s", err)
+	}
+	return &schema, nil
+}
+
+// Validate validates the document against the schema
+func (s *jsonSchema) Validate(document *jsoncDocument) []schemaViolation {
+	v := &schemaValidator{document: document, definitions: s.Definitions}
+	v.validate(s, document.root, "")
+	return v.violations
+}
+
+type schemaValidator struct {
+	document    *jsoncDocument
+	definitions map[string]*jsonSchema
+	violations  []schemaViolation
+}
+
+func (v *schemaValidator) addViolation(offset int, path string, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if path != "" {
+		message = fmt.Sprintf("%s: %s", path, message)
+	}
+	v.violations = append(v.violations, schemaViolation{Offset: offset, Message: message})
+}
+
+func (v *schemaValidator) validate(schema *jsonSchema, node *jsoncNode, path string) {
+	if schema.Ref != "" {
+		definition, ok := v.definitions[strings.TrimPrefix(schema.Ref, "#/definitions/")]
+		if !ok {
+			v.addViolation(node.Start, path, "schema reference %q not found", schema.Ref)
+			return
+		}
+		schema = definition
+	}
+
+	nodeType := v.getNodeType(node)
+	if len(schema.Type) > 0 && !schemaTypesMatch(schema.Type, nodeType) {
+		v.addViolation(node.Start, path, "expected %s, got %s", strings.Join(schema.Type, " or "), nodeType)
+		return
+	}
+
+	if len(schema.Enum) > 0 {
+		var value interface{}
+		_ = json.Unmarshal(v.document.standardized[node.Start:node.End], &value)
+		found := false
+		allowed := []string{}
+		for _, enumValue := range schema.Enum {
+			found = found || reflect.DeepEqual(value, enumValue)
+			buf, _ := json.Marshal(enumValue)
+			allowed = append(allowed, string(buf))
+		}
+		if !found {
+			v.addViolation(node.Start, path, "value must be one of %s", strings.Join(allowed, ", "))
+		}
+	}
+
+	switch node.Kind {
+	case jsoncObject:
+		for _, name := range schema.Required {
+			if node.findProperty(name) == nil {
+				v.addViolation(node.Start, path, "missing required property %q", name)
+			}
+		}
+		for _, property := range node.Properties {
+			propertyPath := property.Name
+			if path != "" {
+				propertyPath = path + "." + property.Name
+			}
+			if propertySchema, ok := schema.Properties[property.Name]; ok {
+				v.validate(propertySchema, property.Value, propertyPath)
+			} else if schema.AdditionalProperties != nil {
+				if schema.AdditionalProperties.disallowed {
+					message := fmt.Sprintf("property %q is not allowed", property.Name)
+					if suggestion := getPropertySuggestion(property.Name, schema.Properties); suggestion != "" {
+						message += fmt.Sprintf(" (did you mean %q?)", suggestion)
+					}
+					v.addViolation(property.Start, path, "%s", message)
+				} else {
+					v.validate(schema.AdditionalProperties, property.Value, propertyPath)
+				}
+			}
+		}
+	case jsoncArray:
+		if schema.Items != nil {
+			for i, item := range node.Items {
+				v.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+}
+
+func (v *schemaValidator) getNodeType(node *jsoncNode) string {
+	switch node.Kind {
+	case jsoncObject:
+		return "object"
+	case jsoncArray:
+		return "array"
+	case jsoncString:
+		return "string"
+	}
+	value := string(v.document.standardized[node.Start:node.End])
+	switch value {
+	case "true", "false":
+		return "boolean"
+	case "null":
+		return "null"
+	}
+	if strings.ContainsAny(value, ".eE") {
+		return "number"
+	}
+	return "integer"
+}
+
+func schemaTypesMatch(types []string, nodeType string) bool {
+	for _, t := range types {
+		if t == nodeType || (t == "number" && nodeType == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// getPropertySuggestion returns the closest property name for a misspelt property (or empty string if none is close)
+func getPropertySuggestion(name string, properties map[string]*jsonSchema) string {
+	names := []string{}
+	for propertyName := range properties {
+		names = append(names, propertyName)
+	}
+	sort.Strings(names)
+
+	suggestion := ""
+	bestDistance := 3 // only suggest names within an edit distance of 2
+	for _, propertyName := range names {
+		if strings.EqualFold(name, propertyName) {
+			return propertyName
+		}
+		if distance := getEditDistance(strings.ToLower(name), strings.ToLower(propertyName)); distance < bestDistance {
+			suggestion = propertyName
+			bestDistance = distance
+		}
+	}
+	return suggestion
+}
+
+// getEditDistance returns the Levenshtein distance between a and b
+func getEditDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, minInt(current[j-1]+1, previous[j-1]+cost))
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
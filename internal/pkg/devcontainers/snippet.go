@@ -10,16 +10,19 @@ import (
 	"strings"
 
 	"github.com/stuartleeks/devcontainer-cli/internal/pkg/config"
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/diff"
 	"github.com/stuartleeks/devcontainer-cli/internal/pkg/errors"
 	ioutil2 "github.com/stuartleeks/devcontainer-cli/internal/pkg/ioutil"
 
 	dora_ast "github.com/bradford-hamilton/dora/pkg/ast"
-	"github.com/bradford-hamilton/dora/pkg/dora"
 	dora_lexer "github.com/bradford-hamilton/dora/pkg/lexer"
 	dora_merge "github.com/bradford-hamilton/dora/pkg/merge"
 	dora_parser "github.com/bradford-hamilton/dora/pkg/parser"
 )
 
+// configSnippetFolders returns the configured snippetPaths (replaced in tests)
+var configSnippetFolders = config.GetSnippetFolders
+
 type SubstitutionValues struct {
 	Name       string
 	UserName   string
@@ -36,10 +39,10 @@ const (
 // DevcontainerSnippet holds info on snippets for list/add etc
 // Snippets can be either single script files or a directory with a set of files
 type DevcontainerSnippet struct {
-	Name string
-	Type DevcontainerSnippetType
+	Name string                  `json:"name"`
+	Type DevcontainerSnippetType `json:"type"`
 	// Path is the path to either the path to the single script file or to the directory for multi-file snippets
-	Path string
+	Path string `json:"path"`
 }
 
 type FolderSnippetActionType string
@@ -48,18 +51,44 @@ const (
 	FolderSnippetActionMergeJSON         FolderSnippetActionType = "mergeJSON"         // merge JSON file from snippet with target JSON file
 	FolderSnippetActionCopyAndRun        FolderSnippetActionType = "copyAndRun"        // COPY and RUN script from snippet in the Dockerfile (as with single-file snippet)
 	FolderSnippetActionDockerfileSnippet FolderSnippetActionType = "dockerfileSnippet" // snippet to include as-is in the Dockerfile
+	FolderSnippetActionForwardPorts      FolderSnippetActionType = "forwardPorts"      // add ports to forwardPorts in devcontainer.json
+	FolderSnippetActionExtensions        FolderSnippetActionType = "extensions"        // add VS Code extensions to customizations.vscode.extensions in devcontainer.json
+	FolderSnippetActionMounts            FolderSnippetActionType = "mounts"            // add mounts to mounts in devcontainer.json
+	FolderSnippetActionContainerEnv      FolderSnippetActionType = "containerEnv"      // add environment variables to containerEnv in devcontainer.json
+	FolderSnippetActionFeatures          FolderSnippetActionType = "features"          // add features to features in devcontainer.json
+	FolderSnippetActionComposeService    FolderSnippetActionType = "composeService"    // add a service to the docker-compose file for the dev container
 )
 
 type FolderSnippetAction struct {
 	Type        FolderSnippetActionType `json:"type"`
 	SourcePath  string                  `json:"source"`      // for mergeJSON this is snippet-relative path to JSON. for copyAndRun this is the script filename
-	TargetPath  string                  `json:"target"`      // for mergeJSON this is project-relative path to JSON
+	TargetPath  string                  `json:"target"`      // for mergeJSON this is project-relative path to JSON. for composeService this is the (optional) project-relative path to the compose file
 	Content     string                  `json:"content"`     // for dockerfileSnippet this is the content to include
 	ContentPath string                  `json:"contentPath"` // for dockerfileSnippet this is the path to content to include
+	// Values are the items to add for forwardPorts, extensions and mounts
+	Values []interface{} `json:"values"`
+	// Env is the environment variables to add for containerEnv
+	Env map[string]string `json:"env"`
+	// Features maps the feature IDs to their options for features
+	Features map[string]interface{} `json:"features"`
+	// Service is the name of the service to add for composeService and Definition is the service definition
+	Service    string                 `json:"service"`
+	Definition map[string]interface{} `json:"definition"`
 }
 
 // FolderSnippet maps to the content of the snippet.json file for folder-based snippets
 type FolderSnippet struct {
+	// RenderTemplates enables rendering the snippet content as Go templates (see snippetTemplateData)
+	RenderTemplates bool `json:"renderTemplates"`
+	// Parameters are the values that can be set when adding the snippet (see ResolveParameterValues)
+	// Parameters can only be used in templates so renderTemplates must be set if there are parameters
+	Parameters map[string]TemplateOption `json:"parameters"`
+	// DependsOn are the names of snippets that are added before this snippet
+	DependsOn []string `json:"dependsOn"`
+	// ConflictsWith are the names of snippets that can't be added to the same dev container as this snippet
+	ConflictsWith []string `json:"conflictsWith"`
+	// Verify is the path to a script (relative to the snippet folder) that checks the snippet in a dev container (see RunSnippetTest)
+	Verify  string                `json:"verify"`
 	Actions []FolderSnippetAction `json:"actions"`
 }
 
@@ -81,7 +110,7 @@ func GetSnippetByName(name string) (*DevcontainerSnippet, error) {
 // GetSnippets returns a list of discovered templates
 func GetSnippets() ([]DevcontainerSnippet, error) {
 
-	folders := config.GetSnippetFolders()
+	folders := configSnippetFolders()
 	if len(folders) == 0 {
 		return []DevcontainerSnippet{}, &errors.StatusError{Message: "No snippet folders configured - see https://github.com/stuartleeks/devcontainer-cli/#working-with-devcontainer-snippets"}
 	}
@@ -153,7 +182,11 @@ func getSnippetsFromFolder(folder string) ([]DevcontainerSnippet, error) {
 	return snippets, nil
 }
 
-func AddSnippetToDevcontainer(projectFolder string, snippetName string) error {
+// AddSnippetToDevcontainer adds the snippet (and any snippets it depends on) to the dev container for projectFolder
+// The project files are only changed if all of the snippet actions succeed
+// parameters are the values for the parameters of the snippet and its dependencies (parameters that
+// aren't set use their defaults)
+func AddSnippetToDevcontainer(projectFolder string, snippetName string, parameters SnippetParameterValues) error {
 	snippet, err := GetSnippetByName(snippetName)
 	if err != nil {
 		return err
@@ -161,106 +194,253 @@ func AddSnippetToDevcontainer(projectFolder string, snippetName string) error {
 	if snippet == nil {
 		return fmt.Errorf("Snippet '%s' not found\n", snippetName)
 	}
-	return addSnippetToDevcontainer(projectFolder, snippet)
+	return addSnippetToDevcontainer(projectFolder, snippet, parameters)
 }
-func addSnippetToDevcontainer(projectFolder string, snippet *DevcontainerSnippet) error {
+
+// PreviewSnippetAdd returns a unified diff of the changes that AddSnippetToDevcontainer would make
+// (without changing the project files)
+func PreviewSnippetAdd(projectFolder string, snippetName string, parameters SnippetParameterValues) (string, error) {
+	snippet, err := GetSnippetByName(snippetName)
+	if err != nil {
+		return "", err
+	}
+	if snippet == nil {
+		return "", fmt.Errorf("Snippet '%s' not found\n", snippetName)
+	}
+	fs := ioutil2.NewOverlayFS()
+	if err = applySnippetWithDependencies(fs, projectFolder, snippet, parameters); err != nil {
+		return "", err
+	}
+	return formatOverlayChanges(fs, projectFolder), nil
+}
+
+func addSnippetToDevcontainer(projectFolder string, snippet *DevcontainerSnippet, parameters SnippetParameterValues) error {
+	fs := ioutil2.NewOverlayFS()
+	if err := applySnippetWithDependencies(fs, projectFolder, snippet, parameters); err != nil {
+		return err
+	}
+	return fs.Commit()
+}
+
+// applySnippet applies the snippet actions to the project files in fs and records the snippet in the snippet manifest
+// Snippets that are already installed are not applied again
+func applySnippet(fs *ioutil2.OverlayFS, projectFolder string, snippet *DevcontainerSnippet, parameters map[string]string) error {
+	manifest, err := loadSnippetManifest(fs, projectFolder)
+	if err != nil {
+		return err
+	}
+	if manifest.getSnippet(snippet.Name) != nil {
+		return nil
+	}
+	parameters, err = snippet.ResolveParameterValues(parameters, nil)
+	if err != nil {
+		return err
+	}
+	relations, err := getSnippetRelations(snippet.Type, snippet.Path)
+	if err != nil {
+		return err
+	}
+
+	var actions []InstalledSnippetAction
 	switch snippet.Type {
 	case DevcontainerSnippetTypeSingleFile:
-		return addSingleFileSnippetToDevContainer(projectFolder, snippet)
+		actions, err = addSingleFileSnippetToDevContainer(fs, projectFolder, snippet)
 	case DevcontainerSnippetTypeFolder:
-		return addFolderSnippetToDevContainer(projectFolder, snippet)
+		actions, err = addFolderSnippetToDevContainer(fs, projectFolder, snippet, parameters)
 	default:
 		return fmt.Errorf("Unhandled snippet type: %q", snippet.Type)
 	}
+	if err != nil {
+		return err
+	}
+
+	if len(parameters) == 0 {
+		parameters = nil
+	}
+	manifest.Snippets = append(manifest.Snippets, InstalledSnippet{
+		Name:       snippet.Name,
+		Type:       snippet.Type,
+		Path:       snippet.Path,
+		Parameters: parameters,
+		DependsOn:  relations.DependsOn,
+		Actions:    actions,
+	})
+	return manifest.save(fs, projectFolder)
 }
 
-func addSingleFileSnippetToDevContainer(projectFolder string, snippet *DevcontainerSnippet) error {
+func addSingleFileSnippetToDevContainer(fs *ioutil2.OverlayFS, projectFolder string, snippet *DevcontainerSnippet) ([]InstalledSnippetAction, error) {
 
 	if snippet.Type != DevcontainerSnippetTypeSingleFile {
-		return fmt.Errorf("Expected single file snippet")
+		return nil, fmt.Errorf("Expected single file snippet")
 	}
 	snippetBasePath, scriptFilename := filepath.Split(snippet.Path)
 
 	scriptFolderPath := filepath.Join(projectFolder, ".devcontainer", "scripts")
-	err := copyAndRunScriptFile(projectFolder, snippet, snippetBasePath, scriptFolderPath, scriptFilename)
-	return err
+	action, err := copyAndRunScriptFile(fs, projectFolder, snippet, nil, snippetBasePath, scriptFolderPath, scriptFilename)
+	if err != nil {
+		return nil, err
+	}
+	return []InstalledSnippetAction{*action}, nil
 }
 
-func addFolderSnippetToDevContainer(projectFolder string, snippet *DevcontainerSnippet) error {
+func addFolderSnippetToDevContainer(fs *ioutil2.OverlayFS, projectFolder string, snippet *DevcontainerSnippet, parameters map[string]string) ([]InstalledSnippetAction, error) {
 	if snippet.Type != DevcontainerSnippetTypeFolder {
-		return fmt.Errorf("Expected folder snippet")
+		return nil, fmt.Errorf("Expected folder snippet")
 	}
 
-	snippetJSONPath := filepath.Join(snippet.Path, "snippet.json")
-	buf, err := ioutil.ReadFile(snippetJSONPath)
+	snippetJSON, err := loadFolderSnippet(snippet.Path)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	var snippetJSON FolderSnippet
-	err = json.Unmarshal(buf, &snippetJSON)
+	data, err := newSnippetTemplateData(fs, projectFolder, snippetJSON, parameters)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	installedActions := []InstalledSnippetAction{}
 	for _, action := range snippetJSON.Actions {
+		if action, err = data.renderAction(action); err != nil {
+			return nil, fmt.Errorf("error rendering %s action for snippet %q: %s", action.Type, snippet.Name, err)
+		}
+		var installedAction *InstalledSnippetAction
 		switch action.Type {
 		case FolderSnippetActionMergeJSON:
 			if action.SourcePath == "" {
-				return fmt.Errorf("source must be set for %s actions", action.Type)
+				return nil, fmt.Errorf("source must be set for %s actions", action.Type)
 			}
 			if action.TargetPath == "" {
-				return fmt.Errorf("target must be set for %s actions", action.Type)
+				return nil, fmt.Errorf("target must be set for %s actions", action.Type)
 			}
-			err = mergeJSON(projectFolder, snippet, action.SourcePath, action.TargetPath)
+			installedAction, err = mergeJSON(fs, projectFolder, snippet, action.SourcePath, action.TargetPath)
 			if err != nil {
-				return err
+				return nil, err
 			}
 		case FolderSnippetActionCopyAndRun:
 			if action.SourcePath == "" {
-				return fmt.Errorf("source must be set for %s actions", action.Type)
+				return nil, fmt.Errorf("source must be set for %s actions", action.Type)
 			}
 			targetPath := filepath.Join(projectFolder, ".devcontainer", "scripts")
 			sourceParent, sourceFileName := filepath.Split(action.SourcePath)
 			sourceBasePath := filepath.Join(snippet.Path, sourceParent)
-			err = copyAndRunScriptFile(projectFolder, snippet, sourceBasePath, targetPath, sourceFileName)
+			installedAction, err = copyAndRunScriptFile(fs, projectFolder, snippet, data, sourceBasePath, targetPath, sourceFileName)
 			if err != nil {
-				return err
+				return nil, err
 			}
 		case FolderSnippetActionDockerfileSnippet:
 			var content string
 			if action.Content != "" {
 				if action.ContentPath != "" {
-					return fmt.Errorf("can only set one of content and contentPath")
+					return nil, fmt.Errorf("can only set one of content and contentPath")
 				}
 				content = action.Content + "\n"
 			} else if action.ContentPath != "" {
-				buf, err = ioutil.ReadFile(filepath.Join(snippet.Path, action.ContentPath))
+				buf, err := ioutil.ReadFile(filepath.Join(snippet.Path, action.ContentPath))
 				if err != nil {
-					return err
+					return nil, err
+				}
+				if content, err = data.render(action.ContentPath, string(buf)); err != nil {
+					return nil, err
 				}
-				content = string(buf)
 			} else {
-				return fmt.Errorf("one of content and contentPath must be set for %s actions", action.Type)
+				return nil, fmt.Errorf("one of content and contentPath must be set for %s actions", action.Type)
 			}
 			dockerfileFilename := filepath.Join(projectFolder, ".devcontainer", "Dockerfile")
-			err = insertDockerfileSnippet(projectFolder, dockerfileFilename, content)
+			block, line, err := insertDockerfileSnippet(fs, projectFolder, dockerfileFilename, content)
+			if err != nil {
+				return nil, err
+			}
+			installedAction = &InstalledSnippetAction{DockerfileBlock: block, DockerfileLine: line}
+		case FolderSnippetActionForwardPorts, FolderSnippetActionExtensions, FolderSnippetActionMounts:
+			if len(action.Values) == 0 {
+				return nil, fmt.Errorf("values must be set for %s actions", action.Type)
+			}
+			installedAction, err = appendDevcontainerValues(fs, projectFolder, snippetArrayPaths[action.Type], action.Values)
+			if err != nil {
+				return nil, err
+			}
+		case FolderSnippetActionContainerEnv:
+			if len(action.Env) == 0 {
+				return nil, fmt.Errorf("env must be set for %s actions", action.Type)
+			}
+			values := map[string]interface{}{}
+			for name, value := range action.Env {
+				values[name] = value
+			}
+			installedAction, err = setDevcontainerProperties(fs, projectFolder, "containerEnv", values)
+			if err != nil {
+				return nil, err
+			}
+		case FolderSnippetActionFeatures:
+			if len(action.Features) == 0 {
+				return nil, fmt.Errorf("features must be set for %s actions", action.Type)
+			}
+			installedAction, err = setDevcontainerProperties(fs, projectFolder, "features", action.Features)
 			if err != nil {
-				return err
+				return nil, err
+			}
+		case FolderSnippetActionComposeService:
+			if action.Service == "" {
+				return nil, fmt.Errorf("service must be set for %s actions", action.Type)
+			}
+			if len(action.Definition) == 0 {
+				return nil, fmt.Errorf("definition must be set for %s actions", action.Type)
+			}
+			installedAction, err = addComposeServiceToDevcontainer(fs, projectFolder, action.TargetPath, action.Service, action.Definition)
+			if err != nil {
+				return nil, err
 			}
 		default:
-			return fmt.Errorf("unhandled action type: %q", action.Type)
+			return nil, fmt.Errorf("unhandled action type: %q", action.Type)
 		}
+		installedAction.Type = action.Type
+		installedActions = append(installedActions, *installedAction)
 	}
 
-	return nil
+	return installedActions, nil
 }
 
-func copyAndRunScriptFile(projectFolder string, snippet *DevcontainerSnippet, snippetBasePath string, targetPath, scriptFilename string) error {
-	if err := os.MkdirAll(targetPath, 0755); err != nil {
-		return err
+// loadFolderSnippet loads the snippet.json file for the folder-based snippet at snippetPath
+func loadFolderSnippet(snippetPath string) (*FolderSnippet, error) {
+	snippetJSONPath := filepath.Join(snippetPath, "snippet.json")
+	buf, err := ioutil.ReadFile(snippetJSONPath)
+	if err != nil {
+		return nil, err
 	}
-	if err := ioutil2.CopyFile(filepath.Join(snippetBasePath, scriptFilename), filepath.Join(targetPath, scriptFilename), 0755); err != nil {
-		return err
+	var snippetJSON FolderSnippet
+	if err = json.Unmarshal(buf, &snippetJSON); err != nil {
+		return nil, err
+	}
+	if err = validateOptionTypes(snippetJSON.Parameters, "parameter"); err != nil {
+		return nil, fmt.Errorf("error parsing file %q: %s", snippetJSONPath, err)
+	}
+	if len(snippetJSON.Parameters) > 0 && !snippetJSON.RenderTemplates {
+		return nil, fmt.Errorf("error parsing file %q: renderTemplates must be true to use parameters", snippetJSONPath)
+	}
+	return &snippetJSON, nil
+}
+
+// copyAndRunScriptFile copies the script file and adds steps to run it to the Dockerfile
+// If data is not nil then the script is rendered as a template
+func copyAndRunScriptFile(fs *ioutil2.OverlayFS, projectFolder string, snippet *DevcontainerSnippet, data *snippetTemplateData, snippetBasePath string, targetPath, scriptFilename string) (*InstalledSnippetAction, error) {
+	targetFilename := filepath.Join(targetPath, scriptFilename)
+	if fs.Exists(targetFilename) {
+		return nil, fmt.Errorf("Target file %q already exists", targetFilename)
+	}
+	buf, err := ioutil.ReadFile(filepath.Join(snippetBasePath, scriptFilename))
+	if err != nil {
+		return nil, err
+	}
+	content, err := data.render(scriptFilename, string(buf))
+	if err != nil {
+		return nil, err
+	}
+	buf = []byte(content)
+	if err = fs.WriteFile(targetFilename, buf, 0755); err != nil {
+		return nil, err
+	}
+	relativeTargetFilename, err := filepath.Rel(projectFolder, targetFilename)
+	if err != nil {
+		return nil, err
 	}
 
 	snippetContent := fmt.Sprintf(`# %[1]s
@@ -269,36 +449,56 @@ RUN /tmp/%[2]s
 `, snippet.Name, scriptFilename)
 	dockerfileFilename := filepath.Join(projectFolder, ".devcontainer", "Dockerfile")
 
-	err := insertDockerfileSnippet(projectFolder, dockerfileFilename, snippetContent)
-	return err
+	block, line, err := insertDockerfileSnippet(fs, projectFolder, dockerfileFilename, snippetContent)
+	if err != nil {
+		return nil, err
+	}
+	return &InstalledSnippetAction{
+		Type:            FolderSnippetActionCopyAndRun,
+		Files:           map[string]string{filepath.ToSlash(relativeTargetFilename): hashContent(buf)},
+		DockerfileBlock: block,
+		DockerfileLine:  line,
+	}, nil
 }
 
-func insertDockerfileSnippet(projectFolder string, dockerfileFilename string, snippetContent string) error {
+// dockerfileSnippetInsertMarker marks the line in the Dockerfile where snippets are inserted
+// (snippets are appended to the end of the Dockerfile if it isn't present)
+const dockerfileSnippetInsertMarker = "__DEVCONTAINER_SNIPPET_INSERT__"
+
+// insertDockerfileSnippet inserts snippetContent in the Dockerfile
+// Returns the block of content that was inserted and the line number that the block starts at
+func insertDockerfileSnippet(fs *ioutil2.OverlayFS, projectFolder string, dockerfileFilename string, snippetContent string) (string, int, error) {
 
-	buf, err := ioutil.ReadFile(dockerfileFilename)
+	buf, err := fs.ReadFile(dockerfileFilename)
 	if err != nil {
-		return fmt.Errorf("Error reading Dockerfile: %s", err)
+		return "", 0, fmt.Errorf("Error reading Dockerfile: %s", err)
 	}
 
 	dockerfileContent := string(buf)
 	dockerFileLines := strings.Split(dockerfileContent, "\n")
 	addSeparator := false
 	addedSnippetContent := false
+	blockStart := -1
+	var block string
 	var newContent strings.Builder
 	for _, line := range dockerFileLines {
 		if addSeparator {
 			if _, err = newContent.WriteString("\n"); err != nil {
-				return err
+				return "", 0, err
 			}
 		}
 		addSeparator = true
 
-		if strings.Contains(line, "__DEVCONTAINER_SNIPPET_INSERT__") {
+		if strings.Contains(line, dockerfileSnippetInsertMarker) {
+			if blockStart < 0 {
+				blockStart = newContent.Len()
+				block = snippetContent + "\n"
+			}
 			if _, err = newContent.WriteString(snippetContent); err != nil {
-				return err
+				return "", 0, err
 			}
 			if _, err = newContent.WriteString("\n"); err != nil {
-				return err
+				return "", 0, err
 			}
 			line += "\n"
 			addedSnippetContent = true
@@ -306,77 +506,88 @@ func insertDockerfileSnippet(projectFolder string, dockerfileFilename string, sn
 		}
 
 		if _, err = newContent.WriteString(line); err != nil {
-			return err
+			return "", 0, err
 		}
 	}
 
 	if !addedSnippetContent {
+		blockStart = newContent.Len()
+		block = "\n" + snippetContent
 		if _, err = newContent.WriteString("\n"); err != nil {
-			return err
+			return "", 0, err
 		}
 		if _, err = newContent.WriteString(snippetContent); err != nil {
-			return err
+			return "", 0, err
 		}
 	}
 
 	content := newContent.String()
-	// TODO - decide whether to support .devcontainer.json or just remove snippet support
-	values, err := getSubstitutionValuesFromFile(filepath.Join(projectFolder, ".devcontainer/devcontainer.json"))
+	values, err := getSubstitutionValuesFromFile(fs, getProjectDevcontainerJSONPath(fs, projectFolder))
 	if err != nil {
-		return fmt.Errorf("failed to get dev container values: %s", err)
+		return "", 0, fmt.Errorf("failed to get dev container values: %s", err)
 	}
+	line := strings.Count(performSubstitutionString(values, content[:blockStart]), "\n") + 1
+	block = performSubstitutionString(values, block)
 	content = performSubstitutionString(values, content)
 
-	err = ioutil.WriteFile(dockerfileFilename, []byte(content), 0)
+	err = fs.WriteFile(dockerfileFilename, []byte(content), 0644)
 
-	return err
+	return block, line, err
 
 }
-func mergeJSON(projectFolder string, snippet *DevcontainerSnippet, relativeMergePath string, relativeBasePath string) error {
+func mergeJSON(fs *ioutil2.OverlayFS, projectFolder string, snippet *DevcontainerSnippet, relativeMergePath string, relativeBasePath string) (*InstalledSnippetAction, error) {
 	mergePath := filepath.Join(snippet.Path, relativeMergePath)
 	_, err := os.Stat(mergePath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	basePath := filepath.Join(projectFolder, relativeBasePath)
-	baseDocument, err := loadJSONDocument(basePath)
+	original, err := fs.ReadFile(basePath)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	baseDocument, err := loadJSONDocument(fs, basePath)
+	if err != nil {
+		return nil, err
 	}
 
-	mergeDocument, err := loadJSONDocument(mergePath)
+	mergeDocument, err := loadJSONDocument(fs, mergePath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	resultDocument, err := dora_merge.MergeJSON(*baseDocument, *mergeDocument)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	resultJSON, err := dora_ast.WriteJSONString(resultDocument)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// TODO - decide whether to support .devcontainer.json or just remove snippet support
-	values, err := getSubstitutionValuesFromFile(filepath.Join(projectFolder, ".devcontainer/devcontainer.json"))
+	values, err := getSubstitutionValuesFromFile(fs, getProjectDevcontainerJSONPath(fs, projectFolder))
 	if err != nil {
-		return fmt.Errorf("failed to get dev container values: %s", err)
+		return nil, fmt.Errorf("failed to get dev container values: %s", err)
 	}
 	resultJSON = performSubstitutionString(values, resultJSON)
 
-	err = ioutil.WriteFile(basePath, []byte(resultJSON), 0666)
+	err = fs.WriteFile(basePath, []byte(resultJSON), 0644)
 	if err != nil {
-		return fmt.Errorf("failed to write file: %s", err)
+		return nil, fmt.Errorf("failed to write file: %s", err)
 	}
 
-	return nil
+	return &InstalledSnippetAction{
+		Type:     FolderSnippetActionMergeJSON,
+		Target:   filepath.ToSlash(relativeBasePath),
+		Original: string(original),
+		Merged:   resultJSON,
+	}, nil
 }
 
-func loadJSONDocument(path string) (*dora_ast.RootNode, error) {
+func loadJSONDocument(fs *ioutil2.OverlayFS, path string) (*dora_ast.RootNode, error) {
 
-	buf, err := ioutil.ReadFile(path)
+	buf, err := fs.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
@@ -389,25 +600,18 @@ func loadJSONDocument(path string) (*dora_ast.RootNode, error) {
 	return &baseDocument, nil
 }
 
-func getSubstitutionValuesFromFile(devContainerJsonPath string) (*SubstitutionValues, error) {
-	// This doesn't use standard `json` pkg as devcontainer.json permits comments (and the default templates include them!)
-
-	buf, err := ioutil.ReadFile(devContainerJsonPath)
+func getSubstitutionValuesFromFile(fs *ioutil2.OverlayFS, devContainerJsonPath string) (*SubstitutionValues, error) {
+	buf, err := fs.ReadFile(devContainerJsonPath)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error reading file %q: %s", devContainerJsonPath, err)
 	}
-
-	c, err := dora.NewFromBytes(buf)
+	config, err := loadDevcontainerConfigContent(devContainerJsonPath, buf)
 	if err != nil {
 		return nil, err
 	}
 
-	name, err := c.GetString("$.name")
-	if err != nil {
-		name = ""
-	}
-	userName, err := c.GetString("$.remoteUser")
-	if err != nil {
+	userName := config.RemoteUser
+	if userName == "" {
 		userName = "root"
 	}
 	homeFolder := "/home/" + userName
@@ -416,23 +620,45 @@ func getSubstitutionValuesFromFile(devContainerJsonPath string) (*SubstitutionVa
 	}
 
 	return &SubstitutionValues{
-		Name:       name,
+		Name:       config.Name,
 		UserName:   userName,
 		HomeFolder: homeFolder,
 	}, nil
 }
 
-func performSubstitutionFile(substitutionValues *SubstitutionValues, filename string) error {
-	buf, err := ioutil.ReadFile(filename)
+func performSubstitutionFile(fs *ioutil2.OverlayFS, filename string, substitute func(content string) string) error {
+	buf, err := fs.ReadFile(filename)
 	if err != nil {
 		return err
 	}
 	content := string(buf)
-	content = performSubstitutionString(substitutionValues, content)
-	err = ioutil.WriteFile(filename, []byte(content), 0)
+	content = substitute(content)
+	err = fs.WriteFile(filename, []byte(content), 0644)
 	return err
 }
 
+// formatOverlayChanges returns a unified diff of the pending changes in fs (with paths relative to baseFolder)
+func formatOverlayChanges(fs *ioutil2.OverlayFS, baseFolder string) string {
+	var builder strings.Builder
+	for _, change := range fs.Changes() {
+		relativePath, err := filepath.Rel(baseFolder, change.Path)
+		if err != nil {
+			relativePath = change.Path
+		}
+		relativePath = filepath.ToSlash(relativePath)
+		fromName := "a/" + relativePath
+		if change.Original == nil {
+			fromName = "/dev/null"
+		}
+		toName := "b/" + relativePath
+		if change.Content == nil {
+			toName = "/dev/null"
+		}
+		builder.WriteString(diff.Unified(fromName, toName, string(change.Original), string(change.Content)))
+	}
+	return builder.String()
+}
+
 func performSubstitutionString(substitutionValues *SubstitutionValues, content string) string {
 	// replace __DEVCONTAINER_NAME__ with name etc
 	content = strings.ReplaceAll(content, "__DEVCONTAINER_NAME__", substitutionValues.Name)
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	ioutil2 "github.com/stuartleeks/devcontainer-cli/internal/pkg/ioutil"
 )
 
 func TestGetSnippets_ListsSingleFileTemplates(t *testing.T) {
@@ -168,7 +169,7 @@ RUN echo hi
 		Path: snippetFilename,
 		Type: DevcontainerSnippetTypeSingleFile,
 	}
-	err := addSingleFileSnippetToDevContainer(targetFolder, &snippet)
+	err := addSnippetToDevcontainer(targetFolder, &snippet, nil)
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -223,7 +224,7 @@ RUN echo hi2
 		Path: snippetFilename,
 		Type: DevcontainerSnippetTypeSingleFile,
 	}
-	err := addSingleFileSnippetToDevContainer(targetFolder, &snippet)
+	err := addSnippetToDevcontainer(targetFolder, &snippet, nil)
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -343,7 +344,7 @@ func TestFolderAddSnippet_MergesDevcontainerJSON(t *testing.T) {
 		Path: snippetFolder,
 		Type: DevcontainerSnippetTypeFolder,
 	}
-	err := addSnippetToDevcontainer(targetFolder, &snippet)
+	err := addSnippetToDevcontainer(targetFolder, &snippet, nil)
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -444,7 +445,7 @@ RUN echo hi2
 		Path: snippetFolder,
 		Type: DevcontainerSnippetTypeFolder,
 	}
-	err := addSnippetToDevcontainer(targetFolder, &snippet)
+	err := addSnippetToDevcontainer(targetFolder, &snippet, nil)
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -516,7 +517,7 @@ RUN echo hi2
 		Path: snippetFolder,
 		Type: DevcontainerSnippetTypeFolder,
 	}
-	err := addSnippetToDevcontainer(targetFolder, &snippet)
+	err := addSnippetToDevcontainer(targetFolder, &snippet, nil)
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -587,7 +588,7 @@ RUN echo hi2
 		Path: snippetFolder,
 		Type: DevcontainerSnippetTypeFolder,
 	}
-	err := addSnippetToDevcontainer(targetFolder, &snippet)
+	err := addSnippetToDevcontainer(targetFolder, &snippet, nil)
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -664,7 +665,7 @@ RUN echo hi2
 		Path: snippetFolder,
 		Type: DevcontainerSnippetTypeFolder,
 	}
-	err := addSnippetToDevcontainer(targetFolder, &snippet)
+	err := addSnippetToDevcontainer(targetFolder, &snippet, nil)
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -755,7 +756,7 @@ RUN echo hi2
 		Path: snippetFolder,
 		Type: DevcontainerSnippetTypeFolder,
 	}
-	err := addSnippetToDevcontainer(targetFolder, &snippet)
+	err := addSnippetToDevcontainer(targetFolder, &snippet, nil)
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -792,3 +793,108 @@ RUN echo hi2
 }`, stringContent)
 
 }
+
+func TestFolderAddSnippet_FailingActionLeavesProjectUnchanged(t *testing.T) {
+
+	root, _ := ioutil.TempDir("", "devcontainer*")
+	defer os.RemoveAll(root)
+
+	// set up snippet
+	snippetFolder := filepath.Join(root, "snippets/test1")
+	_ = os.MkdirAll(snippetFolder, 0755)
+	_ = ioutil.WriteFile(filepath.Join(snippetFolder, "script.sh"), []byte("# dummy file"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(snippetFolder, "snippet.json"), []byte(`{
+		"actions": [
+			{
+				"type": "copyAndRun",
+				"source": "script.sh"
+			},
+			{
+				"type": "dockerfileSnippet"
+			}
+		]
+	}`), 0755)
+
+	// set up devcontainer
+	targetFolder := filepath.Join(root, "target")
+	devcontainerFolder := filepath.Join(targetFolder, ".devcontainer")
+	_ = os.MkdirAll(devcontainerFolder, 0755)
+	_ = ioutil.WriteFile(filepath.Join(devcontainerFolder, "Dockerfile"), []byte("FROM foo\n"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(devcontainerFolder, "devcontainer.json"), []byte(`{ "name" : "testname" }`), 0755)
+
+	// Add snippet
+	snippet := DevcontainerSnippet{
+		Name: "test",
+		Path: snippetFolder,
+		Type: DevcontainerSnippetTypeFolder,
+	}
+	err := addSnippetToDevcontainer(targetFolder, &snippet, nil)
+	assert.Error(t, err)
+
+	buf, err := ioutil.ReadFile(filepath.Join(devcontainerFolder, "Dockerfile"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "FROM foo\n", string(buf))
+	assert.NoDirExists(t, filepath.Join(devcontainerFolder, "scripts"))
+}
+
+func TestFolderAddSnippet_PreviewShowsChanges(t *testing.T) {
+
+	root, _ := ioutil.TempDir("", "devcontainer*")
+	defer os.RemoveAll(root)
+
+	// set up snippet
+	snippetFolder := filepath.Join(root, "snippets/test1")
+	_ = os.MkdirAll(snippetFolder, 0755)
+	_ = ioutil.WriteFile(filepath.Join(snippetFolder, "script.sh"), []byte("echo hi\n"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(snippetFolder, "snippet.json"), []byte(`{
+		"actions": [
+			{
+				"type": "copyAndRun",
+				"source": "script.sh"
+			}
+		]
+	}`), 0755)
+
+	// set up devcontainer
+	targetFolder := filepath.Join(root, "target")
+	devcontainerFolder := filepath.Join(targetFolder, ".devcontainer")
+	_ = os.MkdirAll(devcontainerFolder, 0755)
+	_ = ioutil.WriteFile(filepath.Join(devcontainerFolder, "Dockerfile"), []byte("FROM foo\n"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(devcontainerFolder, "devcontainer.json"), []byte(`{ "name" : "testname" }`), 0755)
+
+	snippet := DevcontainerSnippet{
+		Name: "test",
+		Path: snippetFolder,
+		Type: DevcontainerSnippetTypeFolder,
+	}
+	fs := ioutil2.NewOverlayFS()
+	err := applySnippet(fs, targetFolder, &snippet, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	preview := formatOverlayChanges(fs, targetFolder)
+	assert.Contains(t, preview, `--- a/.devcontainer/Dockerfile
++++ b/.devcontainer/Dockerfile
+@@ -1 +1,5 @@
+ FROM foo
++
++# test
++COPY scripts/script.sh /tmp/
++RUN /tmp/script.sh
+`)
+	assert.Contains(t, preview, `--- /dev/null
++++ b/.devcontainer/scripts/script.sh
+@@ -0,0 +1 @@
++echo hi
+`)
+	assert.Contains(t, preview, "+++ b/.devcontainer/"+snippetManifestFilename)
+
+	buf, err := ioutil.ReadFile(filepath.Join(devcontainerFolder, "Dockerfile"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "FROM foo\n", string(buf))
+	assert.NoDirExists(t, filepath.Join(devcontainerFolder, "scripts"))
+}
@@ -0,0 +1,266 @@
+package devcontainers
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	ioutil2 "github.com/stuartleeks/devcontainer-cli/internal/pkg/ioutil"
+	"gopkg.in/yaml.v2"
+)
+
+// snippetArrayPaths maps the action types that add items to arrays in devcontainer.json to the path of the array
+var snippetArrayPaths = map[FolderSnippetActionType][]string{
+	FolderSnippetActionForwardPorts: {"forwardPorts"},
+	FolderSnippetActionExtensions:   {"customizations", "vscode", "extensions"},
+	FolderSnippetActionMounts:       {"mounts"},
+}
+
+// appendDevcontainerValues adds values to the array at path in devcontainer.json (skipping values that are already present)
+func appendDevcontainerValues(fs *ioutil2.OverlayFS, projectFolder string, path []string, values []interface{}) (*InstalledSnippetAction, error) {
+	return updateSnippetDevcontainerJSON(fs, projectFolder, path, func(document *jsoncDocument, substitutionValues *SubstitutionValues, action *InstalledSnippetAction) error {
+		existing := []interface{}{}
+		if node := document.Find(path...); node != nil {
+			if err := document.UnmarshalValue(node, &existing); err != nil {
+				return fmt.Errorf("%s must be an array: %s", strings.Join(path, "."), err)
+			}
+		}
+		for _, value := range values {
+			value, err := substituteValue(substitutionValues, value)
+			if err != nil {
+				return err
+			}
+			if containsValue(existing, value) {
+				continue
+			}
+			if err = document.AppendValue(path, value); err != nil {
+				return err
+			}
+			existing = append(existing, value)
+			action.AddedValues = append(action.AddedValues, value)
+		}
+		return nil
+	})
+}
+
+// setDevcontainerProperties adds values as properties of the object named property in devcontainer.json
+// Properties that are already set to the same value are skipped, and properties set to a different value are an error
+func setDevcontainerProperties(fs *ioutil2.OverlayFS, projectFolder string, property string, values map[string]interface{}) (*InstalledSnippetAction, error) {
+	return updateSnippetDevcontainerJSON(fs, projectFolder, []string{property}, func(document *jsoncDocument, substitutionValues *SubstitutionValues, action *InstalledSnippetAction) error {
+		names := []string{}
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			value, err := substituteValue(substitutionValues, values[name])
+			if err != nil {
+				return err
+			}
+			if node := document.Find(property, name); node != nil {
+				var existing interface{}
+				if err = document.UnmarshalValue(node, &existing); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(existing, value) {
+					return fmt.Errorf("%s.%s is already set to a different value in devcontainer.json", property, name)
+				}
+				continue
+			}
+			if err = document.SetValue([]string{property, name}, value); err != nil {
+				return err
+			}
+			action.AddedProperties = append(action.AddedProperties, name)
+		}
+		return nil
+	})
+}
+
+// updateSnippetDevcontainerJSON applies update to the devcontainer.json for projectFolder in fs and returns an
+// action for the array or object at path. update records the values or properties that it adds in the action
+// so that they can be removed without reversing changes that other snippets made to the same array or object
+func updateSnippetDevcontainerJSON(fs *ioutil2.OverlayFS, projectFolder string, path []string, update func(document *jsoncDocument, substitutionValues *SubstitutionValues, action *InstalledSnippetAction) error) (*InstalledSnippetAction, error) {
+	devcontainerJSONPath := getProjectDevcontainerJSONPath(fs, projectFolder)
+	buf, err := fs.ReadFile(devcontainerJSONPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %q: %s", devcontainerJSONPath, err)
+	}
+	values, err := getSubstitutionValuesFromFile(fs, devcontainerJSONPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dev container values: %s", err)
+	}
+	document, err := parseJSONC(buf)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing file %q: %s", devcontainerJSONPath, err)
+	}
+	target, err := filepath.Rel(projectFolder, devcontainerJSONPath)
+	if err != nil {
+		return nil, err
+	}
+	action := &InstalledSnippetAction{Target: filepath.ToSlash(target), JSONPath: path}
+	if err = update(document, values, action); err != nil {
+		return nil, err
+	}
+	if err = fs.WriteFile(devcontainerJSONPath, document.Bytes(), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write file: %s", err)
+	}
+	return action, nil
+}
+
+// substituteValue returns value (normalized as if decoded from JSON) with the placeholder values substituted in its strings
+func substituteValue(substitutionValues *SubstitutionValues, value interface{}) (interface{}, error) {
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	if err = json.Unmarshal([]byte(performSubstitutionString(substitutionValues, string(buf))), &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// addComposeServiceToDevcontainer adds a service to the compose file at composeFilePath (relative to projectFolder)
+// or to the first compose file in dockerComposeFile in devcontainer.json if composeFilePath is empty
+func addComposeServiceToDevcontainer(fs *ioutil2.OverlayFS, projectFolder string, composeFilePath string, service string, definition map[string]interface{}) (*InstalledSnippetAction, error) {
+	devcontainerJSONPath := getProjectDevcontainerJSONPath(fs, projectFolder)
+	buf, err := fs.ReadFile(devcontainerJSONPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %q: %s", devcontainerJSONPath, err)
+	}
+	config, err := loadDevcontainerConfigContent(devcontainerJSONPath, buf)
+	if err != nil {
+		return nil, err
+	}
+	if composeFilePath == "" {
+		if len(config.DockerComposeFile) == 0 {
+			return nil, fmt.Errorf("dev container for %q is not compose-based (no dockerComposeFile set)", projectFolder)
+		}
+		composeFilePath, err = filepath.Rel(projectFolder, filepath.Join(filepath.Dir(devcontainerJSONPath), config.DockerComposeFile[0]))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	values, err := getSubstitutionValuesFromFile(fs, devcontainerJSONPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dev container values: %s", err)
+	}
+	substitutedDefinition, err := substituteValue(values, definition)
+	if err != nil {
+		return nil, err
+	}
+
+	fullPath := filepath.Join(projectFolder, composeFilePath)
+	original, err := fs.ReadFile(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading compose file: %s", err)
+	}
+	content, err := addComposeService(string(original), service, substitutedDefinition)
+	if err != nil {
+		return nil, fmt.Errorf("error updating compose file %q: %s", composeFilePath, err)
+	}
+	if err = fs.WriteFile(fullPath, []byte(content), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write file: %s", err)
+	}
+	return &InstalledSnippetAction{
+		Target:   filepath.ToSlash(composeFilePath),
+		Original: string(original),
+		Merged:   content,
+	}, nil
+}
+
+var composeServicesLine = regexp.MustCompile(`^services:\s*(#.*)?$`)
+
+// addComposeService adds the service definition to the services in the compose file content
+// The service is inserted as text so that comments and formatting in the rest of the file are kept
+func addComposeService(content string, service string, definition interface{}) (string, error) {
+	var compose composeFile
+	if err := yaml.Unmarshal([]byte(content), &compose); err != nil {
+		return "", fmt.Errorf("error parsing compose file: %s", err)
+	}
+	if _, ok := compose.Services[service]; ok {
+		return "", fmt.Errorf("service %q already exists", service)
+	}
+	buf, err := yaml.Marshal(map[string]interface{}{service: definition})
+	if err != nil {
+		return "", err
+	}
+	serviceLines := strings.Split(strings.TrimRight(string(buf), "\n"), "\n")
+
+	lines := strings.Split(content, "\n")
+	servicesIndex := -1
+	for i, line := range lines {
+		if composeServicesLine.MatchString(strings.TrimRight(line, "\r")) {
+			servicesIndex = i
+			break
+		}
+	}
+
+	var result []string
+	if servicesIndex < 0 {
+		result = append([]string{}, lines...)
+		if result[len(result)-1] == "" {
+			result = result[:len(result)-1]
+		}
+		result = append(result, "services:")
+		result = append(result, indentLines(serviceLines, "  ")...)
+		result = append(result, "")
+	} else {
+		// find the indent used for the existing services and the end of the services block
+		indent := ""
+		insertIndex := servicesIndex + 1
+		for i := servicesIndex + 1; i < len(lines); i++ {
+			trimmed := strings.TrimSpace(lines[i])
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			lineIndent := lines[i][:len(lines[i])-len(strings.TrimLeft(lines[i], " \t"))]
+			if lineIndent == "" {
+				break
+			}
+			if indent == "" {
+				indent = lineIndent
+			}
+			insertIndex = i + 1
+		}
+		if indent == "" {
+			indent = "  "
+		}
+		result = append(result, lines[:insertIndex]...)
+		result = append(result, indentLines(serviceLines, indent)...)
+		result = append(result, lines[insertIndex:]...)
+	}
+	updated := strings.Join(result, "\n")
+
+	// check that the result is valid and has the new service
+	var updatedCompose composeFile
+	if err = yaml.Unmarshal([]byte(updated), &updatedCompose); err != nil {
+		return "", fmt.Errorf("error adding service: %s", err)
+	}
+	if _, ok := updatedCompose.Services[service]; !ok {
+		return "", fmt.Errorf("unable to add service %q", service)
+	}
+	return updated, nil
+}
+
+func indentLines(lines []string, indent string) []string {
+	result := []string{}
+	for _, line := range lines {
+		result = append(result, indent+line)
+	}
+	return result
+}
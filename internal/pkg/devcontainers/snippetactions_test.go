@@ -0,0 +1,260 @@
+package devcontainers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddSnippet_DevcontainerJSONActions(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+
+	snippetFolder := filepath.Join(root, "snippets", "test1")
+	writeTestFile(t, filepath.Join(snippetFolder, "snippet.json"), `{
+		"actions": [
+			{ "type": "forwardPorts", "values": [ 3000, 5432 ] },
+			{ "type": "extensions", "values": [ "golang.go" ] },
+			{ "type": "mounts", "values": [ "source=cache,target=__DEVCONTAINER_HOME__/.cache,type=volume" ] },
+			{ "type": "containerEnv", "env": { "CACHE_DIR": "__DEVCONTAINER_HOME__/.cache", "EXISTING": "value" } },
+			{ "type": "features", "features": { "ghcr.io/devcontainers/features/go:1": { "version": "1.20" } } }
+		]
+	}`)
+	projectFolder := filepath.Join(root, "project")
+	original := `{
+	"name": "test",
+	// app port
+	"forwardPorts": [ 3000 ],
+	"containerEnv": {
+		"EXISTING": "value"
+	},
+	"remoteUser": "vscode"
+}`
+	devcontainerJSONPath := filepath.Join(projectFolder, ".devcontainer", "devcontainer.json")
+	writeTestFile(t, devcontainerJSONPath, original)
+	snippet := &DevcontainerSnippet{Name: "test1", Path: snippetFolder, Type: DevcontainerSnippetTypeFolder}
+
+	if !assert.NoError(t, addSnippetToDevcontainer(projectFolder, snippet, nil)) {
+		return
+	}
+	assert.Equal(t, `{
+	"name": "test",
+	// app port
+	"forwardPorts": [ 3000, 5432 ],
+	"containerEnv": {
+		"EXISTING": "value",
+		"CACHE_DIR": "/home/vscode/.cache"
+	},
+	"remoteUser": "vscode",
+	"customizations": {
+		"vscode": {
+			"extensions": [
+				"golang.go"
+			]
+		}
+	},
+	"mounts": [
+		"source=cache,target=/home/vscode/.cache,type=volume"
+	],
+	"features": {
+		"ghcr.io/devcontainers/features/go:1": {
+			"version": "1.20"
+		}
+	}
+}`, readTestFile(t, devcontainerJSONPath))
+
+	if !assert.NoError(t, RemoveSnippetFromDevcontainer(projectFolder, "test1")) {
+		return
+	}
+	assert.Equal(t, original, readTestFile(t, devcontainerJSONPath))
+}
+
+func TestRemoveSnippet_KeepsOtherSnippetValuesInSameArray(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+
+	writeTestFile(t, filepath.Join(root, "snippets", "a", "snippet.json"), `{
+		"actions": [
+			{ "type": "forwardPorts", "values": [ 3000 ] },
+			{ "type": "extensions", "values": [ "golang.go" ] },
+			{ "type": "containerEnv", "env": { "A": "a" } }
+		]
+	}`)
+	writeTestFile(t, filepath.Join(root, "snippets", "b", "snippet.json"), `{
+		"actions": [
+			{ "type": "forwardPorts", "values": [ 5432 ] },
+			{ "type": "extensions", "values": [ "ms-ossdata.vscode-postgresql" ] },
+			{ "type": "containerEnv", "env": { "B": "b" } }
+		]
+	}`)
+	projectFolder := filepath.Join(root, "project")
+	devcontainerJSONPath := filepath.Join(projectFolder, ".devcontainer", "devcontainer.json")
+	original := `{
+	"name": "test"
+}`
+	writeTestFile(t, devcontainerJSONPath, original)
+	for _, name := range []string{"a", "b"} {
+		snippet := &DevcontainerSnippet{Name: name, Path: filepath.Join(root, "snippets", name), Type: DevcontainerSnippetTypeFolder}
+		if !assert.NoError(t, addSnippetToDevcontainer(projectFolder, snippet, nil)) {
+			return
+		}
+	}
+
+	if !assert.NoError(t, RemoveSnippetFromDevcontainer(projectFolder, "a")) {
+		return
+	}
+	assert.Equal(t, `{
+	"name": "test",
+	"forwardPorts": [
+		5432
+	],
+	"customizations": {
+		"vscode": {
+			"extensions": [
+				"ms-ossdata.vscode-postgresql"
+			]
+		}
+	},
+	"containerEnv": {
+		"B": "b"
+	}
+}`, readTestFile(t, devcontainerJSONPath))
+
+	if !assert.NoError(t, RemoveSnippetFromDevcontainer(projectFolder, "b")) {
+		return
+	}
+	assert.Equal(t, original, readTestFile(t, devcontainerJSONPath))
+}
+
+func TestAddSnippet_DevcontainerJSONActionsWithDotDevcontainerJSON(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+
+	snippetFolder := filepath.Join(root, "snippets", "test1")
+	writeTestFile(t, filepath.Join(snippetFolder, "snippet.json"), `{
+		"actions": [ { "type": "forwardPorts", "values": [ 5432 ] } ]
+	}`)
+	projectFolder := filepath.Join(root, "project")
+	devcontainerJSONPath := filepath.Join(projectFolder, ".devcontainer.json")
+	writeTestFile(t, devcontainerJSONPath, `{ "forwardPorts": [ 3000 ] }`)
+	snippet := &DevcontainerSnippet{Name: "test1", Path: snippetFolder, Type: DevcontainerSnippetTypeFolder}
+
+	if !assert.NoError(t, addSnippetToDevcontainer(projectFolder, snippet, nil)) {
+		return
+	}
+	assert.Equal(t, `{ "forwardPorts": [ 3000, 5432 ] }`, readTestFile(t, devcontainerJSONPath))
+	assert.FileExists(t, filepath.Join(projectFolder, "."+snippetManifestFilename))
+	if assert.NoError(t, RemoveSnippetFromDevcontainer(projectFolder, "test1")) {
+		assert.Equal(t, `{ "forwardPorts": [ 3000 ] }`, readTestFile(t, devcontainerJSONPath))
+	}
+}
+
+func TestAddSnippet_ContainerEnvConflict(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+
+	snippetFolder := filepath.Join(root, "snippets", "test1")
+	writeTestFile(t, filepath.Join(snippetFolder, "snippet.json"), `{
+		"actions": [ { "type": "containerEnv", "env": { "MODE": "debug" } } ]
+	}`)
+	projectFolder := filepath.Join(root, "project")
+	writeTestFile(t, filepath.Join(projectFolder, ".devcontainer", "devcontainer.json"), `{ "containerEnv": { "MODE": "release" } }`)
+	snippet := &DevcontainerSnippet{Name: "test1", Path: snippetFolder, Type: DevcontainerSnippetTypeFolder}
+
+	assert.Error(t, addSnippetToDevcontainer(projectFolder, snippet, nil))
+	assert.Equal(t, `{ "containerEnv": { "MODE": "release" } }`, readTestFile(t, filepath.Join(projectFolder, ".devcontainer", "devcontainer.json")))
+}
+
+func TestAddSnippet_ComposeServiceAction(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+
+	snippetFolder := filepath.Join(root, "snippets", "postgres")
+	writeTestFile(t, filepath.Join(snippetFolder, "snippet.json"), `{
+		"actions": [
+			{
+				"type": "composeService",
+				"service": "db",
+				"definition": { "image": "postgres:15", "environment": { "POSTGRES_PASSWORD": "postgres" } }
+			},
+			{ "type": "forwardPorts", "values": [ "db:5432" ] }
+		]
+	}`)
+	projectFolder := filepath.Join(root, "project")
+	writeTestFile(t, filepath.Join(projectFolder, ".devcontainer", "devcontainer.json"), `{
+	"dockerComposeFile": "docker-compose.yml",
+	"service": "app"
+}`)
+	originalCompose := `version: "3.8"
+services:
+  # the dev container
+  app:
+    build: .
+
+volumes:
+  data:
+`
+	composePath := filepath.Join(projectFolder, ".devcontainer", "docker-compose.yml")
+	writeTestFile(t, composePath, originalCompose)
+	snippet := &DevcontainerSnippet{Name: "postgres", Path: snippetFolder, Type: DevcontainerSnippetTypeFolder}
+
+	if !assert.NoError(t, addSnippetToDevcontainer(projectFolder, snippet, nil)) {
+		return
+	}
+	assert.Equal(t, `version: "3.8"
+services:
+  # the dev container
+  app:
+    build: .
+  db:
+    environment:
+      POSTGRES_PASSWORD: postgres
+    image: postgres:15
+
+volumes:
+  data:
+`, readTestFile(t, composePath))
+
+	installedSnippets, err := GetInstalledSnippets(projectFolder)
+	if assert.NoError(t, err) && assert.Len(t, installedSnippets, 1) {
+		assert.Equal(t, ".devcontainer/docker-compose.yml", installedSnippets[0].Actions[0].Target)
+	}
+
+	if !assert.NoError(t, RemoveSnippetFromDevcontainer(projectFolder, "postgres")) {
+		return
+	}
+	assert.Equal(t, originalCompose, readTestFile(t, composePath))
+}
+
+func TestAddComposeService(t *testing.T) {
+	definition := map[string]interface{}{"image": "redis"}
+
+	content, err := addComposeService("version: \"3.8\"\n", "cache", definition)
+	assert.NoError(t, err)
+	assert.Equal(t, "version: \"3.8\"\nservices:\n  cache:\n    image: redis\n", content)
+
+	content, err = addComposeService("services:\n    app:\n        image: app\n", "cache", definition)
+	assert.NoError(t, err)
+	assert.Equal(t, "services:\n    app:\n        image: app\n    cache:\n      image: redis\n", content)
+
+	_, err = addComposeService("services:\n  cache:\n    image: other\n", "cache", definition)
+	assert.Error(t, err)
+}
@@ -0,0 +1,155 @@
+package devcontainers
+
+import (
+	"fmt"
+	"strings"
+
+	ioutil2 "github.com/stuartleeks/devcontainer-cli/internal/pkg/ioutil"
+)
+
+// GetSnippetsToAdd returns the snippets that AddSnippetToDevcontainer adds for snippetName (the dependencies
+// that aren't already in the project followed by the snippet itself) in the order that they are added
+func GetSnippetsToAdd(projectFolder string, snippetName string) ([]DevcontainerSnippet, error) {
+	snippet, err := GetSnippetByName(snippetName)
+	if err != nil {
+		return nil, err
+	}
+	if snippet == nil {
+		return nil, fmt.Errorf("Snippet '%s' not found\n", snippetName)
+	}
+	manifest, err := loadSnippetManifest(ioutil2.NewOverlayFS(), projectFolder)
+	if err != nil {
+		return nil, err
+	}
+	snippets, err := resolveSnippetOrder(manifest, snippet)
+	if err != nil {
+		return nil, err
+	}
+	result := []DevcontainerSnippet{}
+	for _, s := range snippets {
+		result = append(result, *s)
+	}
+	return result, nil
+}
+
+// SnippetParameterValues maps snippet names to the values for their parameters
+type SnippetParameterValues map[string]map[string]string
+
+// applySnippetWithDependencies applies the snippet and the snippets it depends on to the project files in fs
+// parameters has the parameter values for each snippet (parameters that aren't set use their defaults)
+func applySnippetWithDependencies(fs *ioutil2.OverlayFS, projectFolder string, snippet *DevcontainerSnippet, parameters SnippetParameterValues) error {
+	manifest, err := loadSnippetManifest(fs, projectFolder)
+	if err != nil {
+		return err
+	}
+	snippets, err := resolveSnippetOrder(manifest, snippet)
+	if err != nil {
+		return err
+	}
+	for _, s := range snippets {
+		if err = applySnippet(fs, projectFolder, s, parameters[s.Name]); err != nil {
+			return fmt.Errorf("error adding snippet %q: %s", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// resolveSnippetOrder returns the snippets to add for snippet in dependency order. Dependencies are looked up
+// across all of the snippet folders, and snippets that are already installed are skipped
+// Dependencies are visited in the order they are declared so that the order is stable
+func resolveSnippetOrder(manifest *snippetManifest, snippet *DevcontainerSnippet) ([]*DevcontainerSnippet, error) {
+	order := []*DevcontainerSnippet{}
+	if manifest.getSnippet(snippet.Name) != nil {
+		return order, nil
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	conflicts := map[string][]string{}
+	var visit func(s *DevcontainerSnippet, path []string) error
+	visit = func(s *DevcontainerSnippet, path []string) error {
+		switch state[s.Name] {
+		case visiting:
+			for i, name := range path {
+				if name == s.Name {
+					path = path[i:]
+					break
+				}
+			}
+			return fmt.Errorf("snippet dependency cycle: %s -> %s", strings.Join(path, " -> "), s.Name)
+		case visited:
+			return nil
+		}
+		state[s.Name] = visiting
+		path = append(append([]string{}, path...), s.Name)
+
+		relations, err := getSnippetRelations(s.Type, s.Path)
+		if err != nil {
+			return err
+		}
+		conflicts[s.Name] = relations.ConflictsWith
+		for _, name := range relations.DependsOn {
+			if manifest.getSnippet(name) != nil {
+				continue
+			}
+			dependency, err := GetSnippetByName(name)
+			if err != nil {
+				return err
+			}
+			if dependency == nil {
+				return fmt.Errorf("snippet %q depends on %q, which was not found", s.Name, name)
+			}
+			if err = visit(dependency, path); err != nil {
+				return err
+			}
+		}
+
+		state[s.Name] = visited
+		order = append(order, s)
+		return nil
+	}
+	if err := visit(snippet, []string{}); err != nil {
+		return nil, err
+	}
+
+	// check for conflicts between the snippets being added and with the installed snippets
+	planned := map[string]bool{}
+	for _, s := range order {
+		planned[s.Name] = true
+	}
+	for _, s := range order {
+		for _, conflict := range conflicts[s.Name] {
+			if planned[conflict] {
+				return nil, fmt.Errorf("snippet %q conflicts with snippet %q", s.Name, conflict)
+			}
+			if manifest.getSnippet(conflict) != nil {
+				return nil, fmt.Errorf("snippet %q conflicts with installed snippet %q", s.Name, conflict)
+			}
+		}
+	}
+	for _, installedSnippet := range manifest.Snippets {
+		relations, err := getSnippetRelations(installedSnippet.Type, installedSnippet.Path)
+		if err != nil {
+			// the snippet may have been removed from the snippet folders since it was installed
+			continue
+		}
+		for _, conflict := range relations.ConflictsWith {
+			if planned[conflict] {
+				return nil, fmt.Errorf("installed snippet %q conflicts with snippet %q", installedSnippet.Name, conflict)
+			}
+		}
+	}
+	return order, nil
+}
+
+// getSnippetRelations returns the snippet.json content (used for dependsOn and conflictsWith) for the snippet
+// Single file snippets have no dependencies or conflicts
+func getSnippetRelations(snippetType DevcontainerSnippetType, snippetPath string) (*FolderSnippet, error) {
+	if snippetType != DevcontainerSnippetTypeFolder {
+		return &FolderSnippet{}, nil
+	}
+	return loadFolderSnippet(snippetPath)
+}
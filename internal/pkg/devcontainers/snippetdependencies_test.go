@@ -0,0 +1,185 @@
+package devcontainers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func useSnippetFolders(t *testing.T, folders ...string) {
+	previous := configSnippetFolders
+	configSnippetFolders = func() []string { return folders }
+	t.Cleanup(func() { configSnippetFolders = previous })
+}
+
+// writeTestDependencySnippet writes a folder snippet that adds a comment with its name to the Dockerfile
+func writeTestDependencySnippet(t *testing.T, folder string, name string, dependsOn []string, conflictsWith []string) {
+	buf, err := json.Marshal(FolderSnippet{
+		DependsOn:     dependsOn,
+		ConflictsWith: conflictsWith,
+		Actions:       []FolderSnippetAction{{Type: FolderSnippetActionDockerfileSnippet, Content: "# " + name}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(folder, name, "snippet.json"), string(buf))
+}
+
+func getInstalledSnippetNames(t *testing.T, projectFolder string) []string {
+	installedSnippets, err := GetInstalledSnippets(projectFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, snippet := range installedSnippets {
+		names = append(names, snippet.Name)
+	}
+	return names
+}
+
+func TestAddSnippet_AddsDependenciesInOrder(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	// dependencies are resolved across all of the snippet folders
+	folder1 := filepath.Join(root, "snippets1")
+	folder2 := filepath.Join(root, "snippets2")
+	writeTestDependencySnippet(t, folder1, "functions", []string{"dotnet", "node"}, nil)
+	writeTestDependencySnippet(t, folder2, "dotnet", []string{"base"}, nil)
+	writeTestDependencySnippet(t, folder2, "node", []string{"base"}, nil)
+	writeTestDependencySnippet(t, folder1, "base", nil, nil)
+	useSnippetFolders(t, folder1, folder2)
+	projectFolder := filepath.Join(root, "project")
+	writeTestProject(t, projectFolder, `{ "name": "test" }`, "FROM foo\n")
+
+	snippets, err := GetSnippetsToAdd(projectFolder, "functions")
+	if !assert.NoError(t, err) {
+		return
+	}
+	names := []string{}
+	for _, snippet := range snippets {
+		names = append(names, snippet.Name)
+	}
+	assert.Equal(t, []string{"base", "dotnet", "node", "functions"}, names)
+
+	if !assert.NoError(t, AddSnippetToDevcontainer(projectFolder, "functions", nil)) {
+		return
+	}
+	assert.Equal(t, "FROM foo\n\n# base\n\n# dotnet\n\n# node\n\n# functions\n", readTestFile(t, filepath.Join(projectFolder, ".devcontainer", "Dockerfile")))
+	assert.Equal(t, []string{"base", "dotnet", "node", "functions"}, getInstalledSnippetNames(t, projectFolder))
+}
+
+func TestAddSnippet_SkipsInstalledDependencies(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	folder := filepath.Join(root, "snippets")
+	writeTestDependencySnippet(t, folder, "kubectl-plugins", []string{"kubectl"}, nil)
+	writeTestDependencySnippet(t, folder, "kubectl", nil, nil)
+	useSnippetFolders(t, folder)
+	projectFolder := filepath.Join(root, "project")
+	writeTestProject(t, projectFolder, `{ "name": "test" }`, "FROM foo\n")
+
+	if !assert.NoError(t, AddSnippetToDevcontainer(projectFolder, "kubectl", nil)) {
+		return
+	}
+	if !assert.NoError(t, AddSnippetToDevcontainer(projectFolder, "kubectl-plugins", nil)) {
+		return
+	}
+	assert.Equal(t, "FROM foo\n\n# kubectl\n\n# kubectl-plugins\n", readTestFile(t, filepath.Join(projectFolder, ".devcontainer", "Dockerfile")))
+	assert.Equal(t, []string{"kubectl", "kubectl-plugins"}, getInstalledSnippetNames(t, projectFolder))
+}
+
+func TestAddSnippet_DependencyErrors(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	folder := filepath.Join(root, "snippets")
+	writeTestDependencySnippet(t, folder, "cycle1", []string{"cycle2"}, nil)
+	writeTestDependencySnippet(t, folder, "cycle2", []string{"cycle1"}, nil)
+	writeTestDependencySnippet(t, folder, "missing", []string{"not-found"}, nil)
+	writeTestDependencySnippet(t, folder, "docker-in-docker", nil, []string{"docker-from-docker"})
+	writeTestDependencySnippet(t, folder, "docker-from-docker", nil, nil)
+	writeTestDependencySnippet(t, folder, "uses-both", []string{"docker-in-docker", "docker-from-docker"}, nil)
+	useSnippetFolders(t, folder)
+	projectFolder := filepath.Join(root, "project")
+	writeTestProject(t, projectFolder, `{ "name": "test" }`, "FROM foo\n")
+
+	err = AddSnippetToDevcontainer(projectFolder, "cycle1", nil)
+	if assert.Error(t, err) {
+		assert.Equal(t, "snippet dependency cycle: cycle1 -> cycle2 -> cycle1", err.Error())
+	}
+	assert.Error(t, AddSnippetToDevcontainer(projectFolder, "missing", nil))
+	assert.Error(t, AddSnippetToDevcontainer(projectFolder, "uses-both", nil))
+	assert.Equal(t, "FROM foo\n", readTestFile(t, filepath.Join(projectFolder, ".devcontainer", "Dockerfile")))
+
+	// conflicts are checked in both directions against installed snippets
+	if !assert.NoError(t, AddSnippetToDevcontainer(projectFolder, "docker-from-docker", nil)) {
+		return
+	}
+	assert.Error(t, AddSnippetToDevcontainer(projectFolder, "docker-in-docker", nil))
+	writeTestDependencySnippet(t, folder, "docker-from-docker", nil, []string{"other"})
+	writeTestDependencySnippet(t, folder, "other", nil, nil)
+	assert.Error(t, AddSnippetToDevcontainer(projectFolder, "other", nil))
+	assert.Equal(t, []string{"docker-from-docker"}, getInstalledSnippetNames(t, projectFolder))
+}
+
+func TestAddSnippet_DependencyParameters(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	folder := filepath.Join(root, "snippets")
+	writeTestFile(t, filepath.Join(folder, "dotnet", "snippet.json"), `{
+		"renderTemplates": true,
+		"parameters": { "version": { "type": "string" } },
+		"actions": [ { "type": "dockerfileSnippet", "content": "# dotnet {{ .Parameters.version }}" } ]
+	}`)
+	writeTestDependencySnippet(t, folder, "functions", []string{"dotnet"}, nil)
+	useSnippetFolders(t, folder)
+	projectFolder := filepath.Join(root, "project")
+	writeTestProject(t, projectFolder, `{ "name": "test" }`, "FROM foo\n")
+
+	// the dependency's parameter has no default so it must be set
+	assert.Error(t, AddSnippetToDevcontainer(projectFolder, "functions", nil))
+	if !assert.NoError(t, AddSnippetToDevcontainer(projectFolder, "functions", SnippetParameterValues{"dotnet": {"version": "8.0"}})) {
+		return
+	}
+	assert.Equal(t, "FROM foo\n\n# dotnet 8.0\n\n# functions\n", readTestFile(t, filepath.Join(projectFolder, ".devcontainer", "Dockerfile")))
+}
+
+func TestRemoveSnippet_RefusesWhenRequiredByInstalledSnippet(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	folder := filepath.Join(root, "snippets")
+	writeTestDependencySnippet(t, folder, "kubectl-plugins", []string{"kubectl"}, nil)
+	writeTestDependencySnippet(t, folder, "kubectl", nil, nil)
+	useSnippetFolders(t, folder)
+	projectFolder := filepath.Join(root, "project")
+	writeTestProject(t, projectFolder, `{ "name": "test" }`, "FROM foo\n")
+
+	if !assert.NoError(t, AddSnippetToDevcontainer(projectFolder, "kubectl-plugins", nil)) {
+		return
+	}
+	err = RemoveSnippetFromDevcontainer(projectFolder, "kubectl")
+	if assert.Error(t, err) {
+		assert.Equal(t, `snippet "kubectl" is required by installed snippet "kubectl-plugins" (remove "kubectl-plugins" first)`, err.Error())
+	}
+	assert.NoError(t, RemoveSnippetFromDevcontainer(projectFolder, "kubectl-plugins"))
+	assert.NoError(t, RemoveSnippetFromDevcontainer(projectFolder, "kubectl"))
+	assert.Equal(t, "FROM foo\n", readTestFile(t, filepath.Join(projectFolder, ".devcontainer", "Dockerfile")))
+}
@@ -0,0 +1,304 @@
+package devcontainers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/diff"
+	ioutil2 "github.com/stuartleeks/devcontainer-cli/internal/pkg/ioutil"
+)
+
+// snippetManifestFilename is the name of the file that records the installed snippets
+// (see getDevcontainerRecordPath for where it is written)
+const snippetManifestFilename = "devcontainer-snippets.json"
+
+// snippetManifest records the snippets that have been added to a dev container
+type snippetManifest struct {
+	Snippets []InstalledSnippet `json:"snippets"`
+}
+
+// InstalledSnippet records a snippet that has been added to a dev container and the changes made by its actions
+type InstalledSnippet struct {
+	Name string                  `json:"name"`
+	Type DevcontainerSnippetType `json:"type"`
+	// Path is the path to the snippet that was added
+	Path string `json:"path"`
+	// Parameters are the values for the snippet parameters
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// DependsOn are the snippets that the snippet depended on when it was added
+	DependsOn []string                 `json:"dependsOn,omitempty"`
+	Actions   []InstalledSnippetAction `json:"actions"`
+}
+
+// InstalledSnippetAction records the changes made by a snippet action so that they can be reversed
+type InstalledSnippetAction struct {
+	Type FolderSnippetActionType `json:"type"`
+	// Files maps the paths of the files created by the action (relative to the project folder) to the hash of their content
+	Files map[string]string `json:"files,omitempty"`
+	// DockerfileBlock is the content inserted in the Dockerfile and DockerfileLine is the line that it was inserted at
+	DockerfileBlock string `json:"dockerfileBlock,omitempty"`
+	DockerfileLine  int    `json:"dockerfileLine,omitempty"`
+	// Target is the file updated by the action (relative to the project folder) with the content before and after the update
+	Target   string `json:"target,omitempty"`
+	Original string `json:"original,omitempty"`
+	Merged   string `json:"merged,omitempty"`
+	// JSONPath is the array or object in Target (devcontainer.json) that the action added AddedValues
+	// or AddedProperties to. These are removed individually rather than by reversing the change to the whole file
+	JSONPath        []string      `json:"jsonPath,omitempty"`
+	AddedValues     []interface{} `json:"addedValues,omitempty"`
+	AddedProperties []string      `json:"addedProperties,omitempty"`
+}
+
+func getSnippetManifestPath(fs *ioutil2.OverlayFS, projectFolder string) string {
+	return getDevcontainerRecordPath(fs, projectFolder, snippetManifestFilename)
+}
+
+// loadSnippetManifest loads the snippet manifest for projectFolder (returning an empty manifest if there isn't one)
+func loadSnippetManifest(fs *ioutil2.OverlayFS, projectFolder string) (*snippetManifest, error) {
+	manifestPath := getSnippetManifestPath(fs, projectFolder)
+	buf, err := fs.ReadFile(manifestPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &snippetManifest{Snippets: []InstalledSnippet{}}, nil
+		}
+		return nil, fmt.Errorf("error reading file %q: %s", manifestPath, err)
+	}
+	var manifest snippetManifest
+	if err = json.Unmarshal(buf, &manifest); err != nil {
+		return nil, fmt.Errorf("error parsing file %q: %s", manifestPath, err)
+	}
+	if manifest.Snippets == nil {
+		manifest.Snippets = []InstalledSnippet{}
+	}
+	return &manifest, nil
+}
+
+// getSnippet returns the installed snippet with the specified name or nil if not found
+func (m *snippetManifest) getSnippet(name string) *InstalledSnippet {
+	for i := range m.Snippets {
+		if m.Snippets[i].Name == name {
+			return &m.Snippets[i]
+		}
+	}
+	return nil
+}
+
+// save writes the manifest (or removes the manifest file if there are no installed snippets)
+func (m *snippetManifest) save(fs *ioutil2.OverlayFS, projectFolder string) error {
+	manifestPath := getSnippetManifestPath(fs, projectFolder)
+	if len(m.Snippets) == 0 {
+		if fs.Exists(manifestPath) {
+			return fs.Remove(manifestPath)
+		}
+		return nil
+	}
+	buf, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = fs.WriteFile(manifestPath, append(buf, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing file %q: %s", manifestPath, err)
+	}
+	return nil
+}
+
+// GetInstalledSnippets returns the snippets that have been added to the dev container for projectFolder
+func GetInstalledSnippets(projectFolder string) ([]InstalledSnippet, error) {
+	manifest, err := loadSnippetManifest(ioutil2.NewOverlayFS(), projectFolder)
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Snippets, nil
+}
+
+// RemoveSnippetFromDevcontainer reverses the changes made when the snippet was added to the dev container for projectFolder
+// The project files are only changed if all of the snippet actions can be reversed
+func RemoveSnippetFromDevcontainer(projectFolder string, snippetName string) error {
+	fs := ioutil2.NewOverlayFS()
+	if err := removeSnippet(fs, projectFolder, snippetName); err != nil {
+		return err
+	}
+	return fs.Commit()
+}
+
+// PreviewSnippetRemove returns a unified diff of the changes that RemoveSnippetFromDevcontainer would make
+// (without changing the project files)
+func PreviewSnippetRemove(projectFolder string, snippetName string) (string, error) {
+	fs := ioutil2.NewOverlayFS()
+	if err := removeSnippet(fs, projectFolder, snippetName); err != nil {
+		return "", err
+	}
+	return formatOverlayChanges(fs, projectFolder), nil
+}
+
+// removeSnippet reverses the snippet actions in fs (in reverse order) and removes the snippet from the snippet manifest
+func removeSnippet(fs *ioutil2.OverlayFS, projectFolder string, snippetName string) error {
+	manifest, err := loadSnippetManifest(fs, projectFolder)
+	if err != nil {
+		return err
+	}
+	snippet := manifest.getSnippet(snippetName)
+	if snippet == nil {
+		return fmt.Errorf("snippet %q is not installed", snippetName)
+	}
+	for _, installedSnippet := range manifest.Snippets {
+		for _, dependency := range installedSnippet.DependsOn {
+			if dependency == snippetName {
+				return fmt.Errorf("snippet %q is required by installed snippet %q (remove %q first)", snippetName, installedSnippet.Name, installedSnippet.Name)
+			}
+		}
+	}
+	for i := len(snippet.Actions) - 1; i >= 0; i-- {
+		if err = snippet.Actions[i].remove(fs, projectFolder); err != nil {
+			return fmt.Errorf("error removing snippet %q: %s", snippetName, err)
+		}
+	}
+
+	snippets := []InstalledSnippet{}
+	for _, installedSnippet := range manifest.Snippets {
+		if installedSnippet.Name != snippetName {
+			snippets = append(snippets, installedSnippet)
+		}
+	}
+	manifest.Snippets = snippets
+	return manifest.save(fs, projectFolder)
+}
+
+// remove reverses the changes recorded for the action
+func (a *InstalledSnippetAction) remove(fs *ioutil2.OverlayFS, projectFolder string) error {
+	if len(a.JSONPath) > 0 {
+		if err := a.removeDevcontainerJSONValues(fs, projectFolder); err != nil {
+			return err
+		}
+	} else if a.Target != "" {
+		targetPath := filepath.Join(projectFolder, filepath.FromSlash(a.Target))
+		buf, err := fs.ReadFile(targetPath)
+		if err != nil {
+			return fmt.Errorf("error reading file %q: %s", targetPath, err)
+		}
+		// merge the reversal with any changes made since the action was applied
+		content, hasConflicts := diff.Merge(a.Merged, string(buf), a.Original, "local", "original")
+		if hasConflicts {
+			return fmt.Errorf("%q has been modified and the changes can't be reversed automatically", a.Target)
+		}
+		if err = fs.WriteFile(targetPath, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	if a.DockerfileBlock != "" {
+		dockerfilePath := filepath.Join(projectFolder, ".devcontainer", "Dockerfile")
+		buf, err := fs.ReadFile(dockerfilePath)
+		if err != nil {
+			return fmt.Errorf("Error reading Dockerfile: %s", err)
+		}
+		content, removed := removeDockerfileBlock(string(buf), a.DockerfileBlock, a.DockerfileLine)
+		if !removed {
+			return fmt.Errorf("the content added to the Dockerfile has been modified - remove it manually:\n%s", a.DockerfileBlock)
+		}
+		if err = fs.WriteFile(dockerfilePath, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	for path, hash := range a.Files {
+		fullPath := filepath.Join(projectFolder, filepath.FromSlash(path))
+		buf, err := fs.ReadFile(fullPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("error reading file %q: %s", fullPath, err)
+		}
+		if hashContent(buf) != hash {
+			return fmt.Errorf("%q has been modified since the snippet was added - remove it manually", path)
+		}
+		if err = fs.Remove(fullPath); err != nil {
+			return fmt.Errorf("error removing file %q: %s", fullPath, err)
+		}
+	}
+	return nil
+}
+
+// removeDevcontainerJSONValues removes the values and properties that the action added at JSONPath in Target
+// Values that have since been removed are skipped, and objects/arrays along JSONPath that are left empty are removed
+// so that other snippets' changes to the same array or object are kept
+func (a *InstalledSnippetAction) removeDevcontainerJSONValues(fs *ioutil2.OverlayFS, projectFolder string) error {
+	if len(a.AddedValues) == 0 && len(a.AddedProperties) == 0 {
+		return nil
+	}
+	targetPath := filepath.Join(projectFolder, filepath.FromSlash(a.Target))
+	buf, err := fs.ReadFile(targetPath)
+	if err != nil {
+		return fmt.Errorf("error reading file %q: %s", targetPath, err)
+	}
+	document, err := parseJSONC(buf)
+	if err != nil {
+		return fmt.Errorf("error parsing file %q: %s", targetPath, err)
+	}
+
+	for _, name := range a.AddedProperties {
+		if err = document.RemoveValue(append(append([]string{}, a.JSONPath...), name)); err != nil {
+			return err
+		}
+	}
+	for _, value := range a.AddedValues {
+		array := document.Find(a.JSONPath...)
+		if array == nil || array.Kind != jsoncArray {
+			break
+		}
+		for i := len(array.Items) - 1; i >= 0; i-- {
+			var item interface{}
+			if err = document.UnmarshalValue(array.Items[i], &item); err != nil {
+				return err
+			}
+			if reflect.DeepEqual(item, value) {
+				if err = document.RemoveItem(a.JSONPath, i); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	for i := len(a.JSONPath); i > 0; i-- {
+		node := document.Find(a.JSONPath[:i]...)
+		if node == nil || len(node.Properties) > 0 || len(node.Items) > 0 || (node.Kind != jsoncObject && node.Kind != jsoncArray) {
+			break
+		}
+		if err = document.RemoveValue(a.JSONPath[:i]); err != nil {
+			return err
+		}
+	}
+	return fs.WriteFile(targetPath, document.Bytes(), 0644)
+}
+
+// removeDockerfileBlock removes block from content. If there are multiple occurrences then the one
+// closest to line is removed. Returns false if block isn't found
+func removeDockerfileBlock(content string, block string, line int) (string, bool) {
+	bestIndex := -1
+	bestDistance := 0
+	offset := 0
+	for {
+		i := strings.Index(content[offset:], block)
+		if i < 0 {
+			break
+		}
+		i += offset
+		distance := strings.Count(content[:i], "\n") + 1 - line
+		if distance < 0 {
+			distance = -distance
+		}
+		if bestIndex < 0 || distance < bestDistance {
+			bestIndex, bestDistance = i, distance
+		}
+		offset = i + 1
+	}
+	if bestIndex < 0 {
+		return content, false
+	}
+	return content[:bestIndex] + content[bestIndex+len(block):], true
+}
@@ -0,0 +1,192 @@
+package devcontainers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ioutil2 "github.com/stuartleeks/devcontainer-cli/internal/pkg/ioutil"
+)
+
+func TestAddSnippet_RecordsInstalledSnippet(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	snippetFolder := filepath.Join(root, "snippets", "test1")
+	writeTestFile(t, filepath.Join(snippetFolder, "script.sh"), "echo hi\n")
+	writeTestFile(t, filepath.Join(snippetFolder, "snippet.json"), `{
+		"actions": [
+			{ "type": "copyAndRun", "source": "script.sh" },
+			{ "type": "dockerfileSnippet", "content": "ENV HOME_DIR=__DEVCONTAINER_HOME__" }
+		]
+	}`)
+	snippet := &DevcontainerSnippet{Name: "test1", Path: snippetFolder, Type: DevcontainerSnippetTypeFolder}
+	projectFolder := filepath.Join(root, "project")
+	writeTestProject(t, projectFolder, `{ "name": "test", "remoteUser": "vscode" }`, "FROM foo\n\n# __DEVCONTAINER_SNIPPET_INSERT__\n\nRUN echo done\n")
+
+	if !assert.NoError(t, addSnippetToDevcontainer(projectFolder, snippet, nil)) {
+		return
+	}
+	installedSnippets, err := GetInstalledSnippets(projectFolder)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []InstalledSnippet{
+		{
+			Name: "test1",
+			Type: DevcontainerSnippetTypeFolder,
+			Path: snippet.Path,
+			Actions: []InstalledSnippetAction{
+				{
+					Type:            FolderSnippetActionCopyAndRun,
+					Files:           map[string]string{".devcontainer/scripts/script.sh": hashContent([]byte("echo hi\n"))},
+					DockerfileBlock: "# test1\nCOPY scripts/script.sh /tmp/\nRUN /tmp/script.sh\n\n",
+					DockerfileLine:  3,
+				},
+				{
+					Type:            FolderSnippetActionDockerfileSnippet,
+					DockerfileBlock: "ENV HOME_DIR=/home/vscode\n\n",
+					DockerfileLine:  7,
+				},
+			},
+		},
+	}, installedSnippets)
+
+	// adding the snippet again doesn't change anything
+	if !assert.NoError(t, addSnippetToDevcontainer(projectFolder, snippet, nil)) {
+		return
+	}
+	assert.Equal(t, "FROM foo\n\n# test1\nCOPY scripts/script.sh /tmp/\nRUN /tmp/script.sh\n\nENV HOME_DIR=/home/vscode\n\n# __DEVCONTAINER_SNIPPET_INSERT__\n\nRUN echo done\n",
+		readTestFile(t, filepath.Join(projectFolder, ".devcontainer", "Dockerfile")))
+}
+
+func TestRemoveSnippet_ReversesActions(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	snippetFolder := filepath.Join(root, "snippets", "test1")
+	writeTestFile(t, filepath.Join(snippetFolder, "script.sh"), "echo hi\n")
+	writeTestFile(t, filepath.Join(snippetFolder, "snippet.json"), `{
+		"actions": [
+			{ "type": "copyAndRun", "source": "script.sh" },
+			{ "type": "dockerfileSnippet", "content": "ENV HOME_DIR=__DEVCONTAINER_HOME__" }
+		]
+	}`)
+	snippet := &DevcontainerSnippet{Name: "test1", Path: snippetFolder, Type: DevcontainerSnippetTypeFolder}
+	projectFolder := filepath.Join(root, "project")
+	writeTestProject(t, projectFolder, `{ "name": "test", "remoteUser": "vscode" }`, "FROM foo\n\n# __DEVCONTAINER_SNIPPET_INSERT__\n\nRUN echo done\n")
+
+	if !assert.NoError(t, addSnippetToDevcontainer(projectFolder, snippet, nil)) {
+		return
+	}
+	// changes made after adding the snippet are kept
+	dockerfilePath := filepath.Join(projectFolder, ".devcontainer", "Dockerfile")
+	writeTestFile(t, dockerfilePath, readTestFile(t, dockerfilePath)+"RUN echo local\n")
+
+	preview, err := PreviewSnippetRemove(projectFolder, "test1")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Contains(t, preview, "-ENV HOME_DIR=/home/vscode\n")
+	assert.FileExists(t, filepath.Join(projectFolder, ".devcontainer", "scripts", "script.sh"))
+
+	if !assert.NoError(t, RemoveSnippetFromDevcontainer(projectFolder, "test1")) {
+		return
+	}
+	assert.Equal(t, "FROM foo\n\n# __DEVCONTAINER_SNIPPET_INSERT__\n\nRUN echo done\nRUN echo local\n", readTestFile(t, dockerfilePath))
+	assert.NoFileExists(t, filepath.Join(projectFolder, ".devcontainer", "scripts", "script.sh"))
+	assert.NoFileExists(t, filepath.Join(projectFolder, ".devcontainer", snippetManifestFilename))
+
+	assert.Error(t, RemoveSnippetFromDevcontainer(projectFolder, "test1"))
+}
+
+func TestRemoveSnippet_ModifiedBlockLeavesProjectUnchanged(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	snippetFolder := filepath.Join(root, "snippets", "test1")
+	writeTestFile(t, filepath.Join(snippetFolder, "script.sh"), "echo hi\n")
+	writeTestFile(t, filepath.Join(snippetFolder, "snippet.json"), `{
+		"actions": [
+			{ "type": "copyAndRun", "source": "script.sh" },
+			{ "type": "dockerfileSnippet", "content": "ENV HOME_DIR=__DEVCONTAINER_HOME__" }
+		]
+	}`)
+	snippet := &DevcontainerSnippet{Name: "test1", Path: snippetFolder, Type: DevcontainerSnippetTypeFolder}
+	projectFolder := filepath.Join(root, "project")
+	writeTestProject(t, projectFolder, `{ "name": "test", "remoteUser": "vscode" }`, "FROM foo\n\n# __DEVCONTAINER_SNIPPET_INSERT__\n\nRUN echo done\n")
+
+	if !assert.NoError(t, addSnippetToDevcontainer(projectFolder, snippet, nil)) {
+		return
+	}
+	dockerfilePath := filepath.Join(projectFolder, ".devcontainer", "Dockerfile")
+	modifiedDockerfile := "FROM foo\n\n# test1\nCOPY scripts/script.sh /tmp/\nRUN /tmp/script.sh\n\nENV HOME_DIR=/home/other\n\n# __DEVCONTAINER_SNIPPET_INSERT__\n\nRUN echo done\n"
+	writeTestFile(t, dockerfilePath, modifiedDockerfile)
+
+	assert.Error(t, RemoveSnippetFromDevcontainer(projectFolder, "test1"))
+	assert.Equal(t, modifiedDockerfile, readTestFile(t, dockerfilePath))
+	assert.FileExists(t, filepath.Join(projectFolder, ".devcontainer", "scripts", "script.sh"))
+	assert.FileExists(t, filepath.Join(projectFolder, ".devcontainer", snippetManifestFilename))
+}
+
+func TestGetSnippetManifestPath_NextToDevcontainerJSON(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	fs := ioutil2.NewOverlayFS()
+
+	// .devcontainer/devcontainer.json (or no dev container yet)
+	assert.Equal(t, filepath.Join(root, ".devcontainer", snippetManifestFilename), getSnippetManifestPath(fs, root))
+
+	// .devcontainer.json in the project folder
+	assert.NoError(t, fs.WriteFile(filepath.Join(root, ".devcontainer.json"), []byte("{}"), 0644))
+	assert.Equal(t, filepath.Join(root, "."+snippetManifestFilename), getSnippetManifestPath(fs, root))
+
+	assert.NoError(t, fs.WriteFile(filepath.Join(root, ".devcontainer", "devcontainer.json"), []byte("{}"), 0644))
+	assert.Equal(t, filepath.Join(root, ".devcontainer", snippetManifestFilename), getSnippetManifestPath(fs, root))
+}
+
+func TestRemoveSnippetAction_ReversesJSONMerge(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+
+	action := InstalledSnippetAction{
+		Type:     FolderSnippetActionMergeJSON,
+		Target:   ".devcontainer/devcontainer.json",
+		Original: "{\n  \"name\": \"test\",\n  \"image\": \"foo\",\n  \"a\": 1\n}\n",
+		Merged:   "{\n  \"name\": \"test\",\n  \"image\": \"foo\",\n  \"a\": 1,\n  \"b\": 2\n}\n",
+	}
+	targetPath := filepath.Join(root, ".devcontainer", "devcontainer.json")
+	writeTestFile(t, targetPath, "{\n  \"name\": \"local\",\n  \"image\": \"foo\",\n  \"a\": 1,\n  \"b\": 2\n}\n")
+
+	fs := ioutil2.NewOverlayFS()
+	if !assert.NoError(t, action.remove(fs, root)) {
+		return
+	}
+	buf, err := fs.ReadFile(targetPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  \"name\": \"local\",\n  \"image\": \"foo\",\n  \"a\": 1\n}\n", string(buf))
+}
+
+func TestRemoveDockerfileBlock_RemovesClosestBlock(t *testing.T) {
+	content, removed := removeDockerfileBlock("A\nB\nA\nB\nC\n", "A\nB\n", 3)
+	assert.True(t, removed)
+	assert.Equal(t, "A\nB\nC\n", content)
+
+	content, removed = removeDockerfileBlock("A\nB\nC\n", "D\n", 1)
+	assert.False(t, removed)
+	assert.Equal(t, "A\nB\nC\n", content)
+}
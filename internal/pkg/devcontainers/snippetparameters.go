@@ -0,0 +1,177 @@
+package devcontainers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	ioutil2 "github.com/stuartleeks/devcontainer-cli/internal/pkg/ioutil"
+)
+
+// GetParameters returns the parameters declared by the snippet (single file snippets have no parameters)
+func (s *DevcontainerSnippet) GetParameters() (map[string]TemplateOption, error) {
+	if s.Type != DevcontainerSnippetTypeFolder {
+		return map[string]TemplateOption{}, nil
+	}
+	snippetJSON, err := loadFolderSnippet(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	if snippetJSON.Parameters == nil {
+		return map[string]TemplateOption{}, nil
+	}
+	return snippetJSON.Parameters, nil
+}
+
+// ResolveParameterValues returns the values for all of the snippet parameters. Values are taken from values
+// (e.g. from --set name=value), then from prompt (if not nil) and finally from the parameter defaults.
+// prompt can return an empty string to use the default value
+func (s *DevcontainerSnippet) ResolveParameterValues(values map[string]string, prompt func(name string, parameter TemplateOption) (string, error)) (map[string]string, error) {
+	parameters, err := s.GetParameters()
+	if err != nil {
+		return nil, err
+	}
+	for name := range values {
+		if _, ok := parameters[name]; !ok {
+			return nil, fmt.Errorf("parameter %q is not defined by snippet %q", name, s.Name)
+		}
+	}
+	return resolveOptionValues(parameters, values, prompt, "parameter", "--set")
+}
+
+// snippetTemplateData is the data for rendering snippet content with text/template
+// A nil *snippetTemplateData leaves content unchanged (used for snippets without renderTemplates set)
+type snippetTemplateData struct {
+	Name       string
+	UserName   string
+	HomeFolder string
+	// Parameters maps the parameter names to their values (as bool for boolean parameters)
+	Parameters map[string]interface{}
+}
+
+// newSnippetTemplateData returns the data for rendering snippet content with the parameter values
+// and the values from the devcontainer.json for projectFolder. Returns nil if renderTemplates isn't set
+func newSnippetTemplateData(fs *ioutil2.OverlayFS, projectFolder string, snippetJSON *FolderSnippet, values map[string]string) (*snippetTemplateData, error) {
+	if !snippetJSON.RenderTemplates {
+		return nil, nil
+	}
+	substitutionValues, err := getSubstitutionValuesFromFile(fs, getProjectDevcontainerJSONPath(fs, projectFolder))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dev container values: %s", err)
+	}
+	data := &snippetTemplateData{
+		Name:       substitutionValues.Name,
+		UserName:   substitutionValues.UserName,
+		HomeFolder: substitutionValues.HomeFolder,
+		Parameters: map[string]interface{}{},
+	}
+	for name, parameter := range snippetJSON.Parameters {
+		value := values[name]
+		switch parameter.Type {
+		case "boolean":
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for parameter %q: %s", value, name, err)
+			}
+			data.Parameters[name] = b
+		case "number":
+			data.Parameters[name] = json.Number(value)
+		default:
+			data.Parameters[name] = value
+		}
+	}
+	return data, nil
+}
+
+// render renders content as a text/template (name is used in error messages)
+func (d *snippetTemplateData) render(name string, content string) (string, error) {
+	if d == nil {
+		return content, nil
+	}
+	tmpl, err := texttemplate.New(name).Option("missingkey=error").Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("error parsing template %q: %s", name, err)
+	}
+	var builder strings.Builder
+	if err = tmpl.Execute(&builder, d); err != nil {
+		return "", fmt.Errorf("error rendering template %q: %s", name, err)
+	}
+	return builder.String(), nil
+}
+
+// renderValue renders the strings in value (e.g. from snippet.json) as templates
+func (d *snippetTemplateData) renderValue(name string, value interface{}) (interface{}, error) {
+	if d == nil {
+		return value, nil
+	}
+	switch v := value.(type) {
+	case string:
+		return d.render(name, v)
+	case []interface{}:
+		result := []interface{}{}
+		for _, item := range v {
+			rendered, err := d.renderValue(name, item)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, rendered)
+		}
+		return result, nil
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for key, item := range v {
+			rendered, err := d.renderValue(name, item)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = rendered
+		}
+		return result, nil
+	}
+	return value, nil
+}
+
+// renderAction returns a copy of action with the content and values rendered as templates
+func (d *snippetTemplateData) renderAction(action FolderSnippetAction) (FolderSnippetAction, error) {
+	if d == nil {
+		return action, nil
+	}
+	name := string(action.Type)
+	var err error
+	if action.Content, err = d.render(name, action.Content); err != nil {
+		return action, err
+	}
+	if action.Values != nil {
+		values, err := d.renderValue(name, action.Values)
+		if err != nil {
+			return action, err
+		}
+		action.Values = values.([]interface{})
+	}
+	if action.Env != nil {
+		env := map[string]string{}
+		for key, value := range action.Env {
+			if env[key], err = d.render(name, value); err != nil {
+				return action, err
+			}
+		}
+		action.Env = env
+	}
+	if action.Features != nil {
+		features, err := d.renderValue(name, action.Features)
+		if err != nil {
+			return action, err
+		}
+		action.Features = features.(map[string]interface{})
+	}
+	if action.Definition != nil {
+		definition, err := d.renderValue(name, action.Definition)
+		if err != nil {
+			return action, err
+		}
+		action.Definition = definition.(map[string]interface{})
+	}
+	return action, nil
+}
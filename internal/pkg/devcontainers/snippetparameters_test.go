@@ -0,0 +1,157 @@
+package devcontainers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveParameterValues(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	snippetFolder := filepath.Join(root, "snippets", "postgres")
+	writeTestFile(t, filepath.Join(snippetFolder, "snippet.json"), `{
+		"renderTemplates": true,
+		"parameters": {
+			"version": { "type": "string", "default": "15", "enum": [ "14", "15" ] },
+			"port": { "type": "number", "default": 5432 },
+			"withTools": { "type": "boolean", "default": false }
+		},
+		"actions": []
+	}`)
+	snippet := &DevcontainerSnippet{Name: "postgres", Path: snippetFolder, Type: DevcontainerSnippetTypeFolder}
+
+	values, err := snippet.ResolveParameterValues(map[string]string{"version": "14"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"version": "14", "port": "5432", "withTools": "false"}, values)
+
+	prompt := func(name string, parameter TemplateOption) (string, error) {
+		if name == "port" {
+			return "6543", nil
+		}
+		return "", nil
+	}
+	values, err = snippet.ResolveParameterValues(map[string]string{}, prompt)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"version": "15", "port": "6543", "withTools": "false"}, values)
+
+	_, err = snippet.ResolveParameterValues(map[string]string{"other": "x"}, nil)
+	assert.Error(t, err)
+	_, err = snippet.ResolveParameterValues(map[string]string{"version": "13"}, nil)
+	assert.Error(t, err)
+}
+
+func TestAddSnippet_RendersParameters(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	snippetFolder := filepath.Join(root, "snippets", "postgres")
+	writeTestFile(t, filepath.Join(snippetFolder, "install.sh"), "echo installing client {{ .Parameters.version }} for {{ .UserName }}\n")
+	writeTestFile(t, filepath.Join(snippetFolder, "Dockerfile.snippet"), "{{ if .Parameters.withTools }}RUN install-tools{{ end }}\n")
+	writeTestFile(t, filepath.Join(snippetFolder, "snippet.json"), `{
+		"renderTemplates": true,
+		"parameters": {
+			"version": { "type": "string", "default": "15", "enum": [ "14", "15" ] },
+			"port": { "type": "number", "default": 5432 },
+			"withTools": { "type": "boolean", "default": false }
+		},
+		"actions": [
+			{ "type": "copyAndRun", "source": "install.sh" },
+			{ "type": "dockerfileSnippet", "content": "ENV PGPORT={{ .Parameters.port }}" },
+			{ "type": "dockerfileSnippet", "contentPath": "Dockerfile.snippet" },
+			{ "type": "containerEnv", "env": { "PGDATA": "{{ .HomeFolder }}/pg{{ .Parameters.version }}" } }
+		]
+	}`)
+	snippet := &DevcontainerSnippet{Name: "postgres", Path: snippetFolder, Type: DevcontainerSnippetTypeFolder}
+	projectFolder := filepath.Join(root, "project")
+	writeTestProject(t, projectFolder, `{ "name": "test", "remoteUser": "vscode" }`, "FROM foo\n")
+
+	if !assert.NoError(t, addSnippetToDevcontainer(projectFolder, snippet, SnippetParameterValues{"postgres": {"version": "14", "withTools": "true"}})) {
+		return
+	}
+	assert.Equal(t, "echo installing client 14 for vscode\n", readTestFile(t, filepath.Join(projectFolder, ".devcontainer", "scripts", "install.sh")))
+	assert.Equal(t, `FROM foo
+
+# postgres
+COPY scripts/install.sh /tmp/
+RUN /tmp/install.sh
+
+ENV PGPORT=5432
+
+RUN install-tools
+`, readTestFile(t, filepath.Join(projectFolder, ".devcontainer", "Dockerfile")))
+	assert.Equal(t, `{ "name": "test", "remoteUser": "vscode", "containerEnv": {
+	"PGDATA": "/home/vscode/pg14"
+} }`, readTestFile(t, filepath.Join(projectFolder, ".devcontainer", "devcontainer.json")))
+
+	installedSnippets, err := GetInstalledSnippets(projectFolder)
+	if assert.NoError(t, err) && assert.Len(t, installedSnippets, 1) {
+		assert.Equal(t, map[string]string{"version": "14", "port": "5432", "withTools": "true"}, installedSnippets[0].Parameters)
+	}
+}
+
+func TestAddSnippet_WithoutParametersDoesNotRenderTemplates(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+
+	snippetFolder := filepath.Join(root, "snippets", "test1")
+	writeTestFile(t, filepath.Join(snippetFolder, "snippet.json"), `{
+		"actions": [ { "type": "dockerfileSnippet", "content": "RUN docker ps --format '{{ .Names }}'" } ]
+	}`)
+	projectFolder := filepath.Join(root, "project")
+	writeTestProject(t, projectFolder, `{ "name": "test" }`, "FROM foo\n")
+	snippet := &DevcontainerSnippet{Name: "test1", Path: snippetFolder, Type: DevcontainerSnippetTypeFolder}
+
+	if !assert.NoError(t, addSnippetToDevcontainer(projectFolder, snippet, nil)) {
+		return
+	}
+	assert.Equal(t, "FROM foo\n\nRUN docker ps --format '{{ .Names }}'\n", readTestFile(t, filepath.Join(projectFolder, ".devcontainer", "Dockerfile")))
+}
+
+func TestAddSnippet_RenderTemplatesWithoutParameters(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+
+	snippetFolder := filepath.Join(root, "snippets", "test1")
+	writeTestFile(t, filepath.Join(snippetFolder, "snippet.json"), `{
+		"renderTemplates": true,
+		"actions": [ { "type": "dockerfileSnippet", "content": "RUN mkdir {{ .HomeFolder }}/.cache" } ]
+	}`)
+	projectFolder := filepath.Join(root, "project")
+	writeTestProject(t, projectFolder, `{ "name": "test", "remoteUser": "vscode" }`, "FROM foo\n")
+	snippet := &DevcontainerSnippet{Name: "test1", Path: snippetFolder, Type: DevcontainerSnippetTypeFolder}
+
+	if !assert.NoError(t, addSnippetToDevcontainer(projectFolder, snippet, nil)) {
+		return
+	}
+	assert.Equal(t, "FROM foo\n\nRUN mkdir /home/vscode/.cache\n", readTestFile(t, filepath.Join(projectFolder, ".devcontainer", "Dockerfile")))
+}
+
+func TestLoadFolderSnippet_ParametersRequireRenderTemplates(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	writeTestFile(t, filepath.Join(root, "snippet.json"), `{
+		"parameters": { "version": { "type": "string", "default": "1" } },
+		"actions": [ { "type": "dockerfileSnippet", "content": "RUN docker ps --format '{{ .Names }}'" } ]
+	}`)
+
+	_, err = loadFolderSnippet(root)
+	assert.Error(t, err)
+}
@@ -0,0 +1,264 @@
+package devcontainers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// snippetVerifyFolder is the folder that the snippet folder is mounted at when running the verify script
+const snippetVerifyFolder = "/tmp/devcontainer-snippet"
+
+// labelSnippetTest is applied to the containers created by RunSnippetTest (in place of the local_folder label)
+// so that they aren't listed as dev containers and any left behind by a killed test run can be found
+const labelSnippetTest = "devcontainer.snippet_test"
+
+// SnippetTestStage is a step in testing a snippet
+type SnippetTestStage string
+
+const (
+	SnippetTestStageTemplate SnippetTestStage = "template" // copy the template to the test folder
+	SnippetTestStageAdd      SnippetTestStage = "add"      // add the snippet to the test dev container
+	SnippetTestStageBuild    SnippetTestStage = "build"    // build the dev container image
+	SnippetTestStageVerify   SnippetTestStage = "verify"   // run the verify script in the dev container
+)
+
+// SnippetTestOptions controls how RunSnippetTest tests a snippet
+type SnippetTestOptions struct {
+	// Parameters are the values for the parameters of the snippet and its dependencies
+	Parameters SnippetParameterValues
+	// Output receives progress output (build output, verify script output)
+	Output io.Writer
+}
+
+// SnippetTestResult is the result of testing a snippet with a template
+type SnippetTestResult struct {
+	Snippet  string `json:"snippet"`
+	Template string `json:"template"`
+	Passed   bool   `json:"passed"`
+	// Stage is the stage that failed (empty if the test passed)
+	Stage SnippetTestStage `json:"stage,omitempty"`
+	Error string           `json:"error,omitempty"`
+}
+
+func (r *SnippetTestResult) fail(stage SnippetTestStage, err error) *SnippetTestResult {
+	r.Stage = stage
+	r.Error = err.Error()
+	return r
+}
+
+// RunSnippetTest tests a snippet by adding the template to a temporary folder, adding the snippet, building
+// the dev container image and running the snippet's verify script (if it has one) in the dev container
+// Failures in the test stages are reported in the result. An error is returned if the test couldn't be run
+// (e.g. the snippet or template wasn't found)
+func RunSnippetTest(snippetName string, templateName string, options SnippetTestOptions) (*SnippetTestResult, error) {
+	if options.Output == nil {
+		options.Output = ioutil.Discard
+	}
+	snippet, err := GetSnippetByName(snippetName)
+	if err != nil {
+		return nil, err
+	}
+	if snippet == nil {
+		return nil, fmt.Errorf("Snippet '%s' not found\n", snippetName)
+	}
+
+	testFolder, err := ioutil.TempDir("", "devcontainer-snippet-test*")
+	if err != nil {
+		return nil, fmt.Errorf("error creating test folder: %s", err)
+	}
+	defer os.RemoveAll(testFolder)
+	projectFolder := filepath.Join(testFolder, "snippet-test")
+	if err = os.Mkdir(projectFolder, 0755); err != nil {
+		return nil, fmt.Errorf("error creating test folder: %s", err)
+	}
+
+	template, err := GetTemplateByName(templateName, projectFolder)
+	if err != nil {
+		return nil, err
+	}
+	if template == nil {
+		return nil, fmt.Errorf("Template '%s' not found\n", templateName)
+	}
+
+	result := &SnippetTestResult{Snippet: snippetName, Template: templateName}
+	templateOptions, err := template.ResolveOptionValues(map[string]string{}, nil)
+	if err != nil {
+		return result.fail(SnippetTestStageTemplate, err), nil
+	}
+	fmt.Fprintf(options.Output, "Adding template %s\n", templateName)
+	if err = CopyTemplateToFolder(template, projectFolder, "", templateOptions, nil); err != nil {
+		return result.fail(SnippetTestStageTemplate, err), nil
+	}
+
+	fmt.Fprintf(options.Output, "Adding snippet %s\n", snippetName)
+	if err = addSnippetToDevcontainer(projectFolder, snippet, options.Parameters); err != nil {
+		return result.fail(SnippetTestStageAdd, err), nil
+	}
+
+	if stage, err := buildAndVerifySnippet(projectFolder, snippet, options.Output); err != nil {
+		return result.fail(stage, err), nil
+	}
+	result.Passed = true
+	return result, nil
+}
+
+// buildAndVerifySnippet builds the dev container for projectFolder and runs the snippet's verify script in it
+// If a stage fails then the stage is returned along with the error
+func buildAndVerifySnippet(projectFolder string, snippet *DevcontainerSnippet, output io.Writer) (SnippetTestStage, error) {
+	devcontainerJSONPath, err := getDevContainerJsonPath(projectFolder)
+	if err != nil {
+		return SnippetTestStageBuild, err
+	}
+	config, err := LoadDevcontainerConfig(devcontainerJSONPath)
+	if err != nil {
+		return SnippetTestStageBuild, fmt.Errorf("Error loading devcontainer.json: %s", err)
+	}
+	if len(config.DockerComposeFile) > 0 {
+		return SnippetTestStageBuild, fmt.Errorf("compose-based dev containers are not supported")
+	}
+
+	runtime, err := GetContainerRuntime()
+	if err != nil {
+		return SnippetTestStageBuild, err
+	}
+	image := config.Image
+	if dockerfilePath, contextPath := config.getDockerfilePath(devcontainerJSONPath); dockerfilePath != "" {
+		image = getImageNameForFolder(projectFolder)
+		buildOptions := BuildOptions{
+			ContextPath: contextPath,
+			Dockerfile:  dockerfilePath,
+			Tag:         image,
+			Output:      output,
+		}
+		if config.Build != nil {
+			buildOptions.Args = config.Build.Args
+			buildOptions.Target = config.Build.Target
+		}
+		fmt.Fprintf(output, "Building image %s\n", image)
+		if err = runtime.BuildImage(buildOptions); err != nil {
+			return SnippetTestStageBuild, err
+		}
+		defer func() {
+			if err := runtime.RemoveImage(image); err != nil {
+				fmt.Fprintf(output, "Error removing image %s: %s\n", image, err)
+			}
+		}()
+	}
+	if image == "" {
+		return SnippetTestStageBuild, fmt.Errorf("devcontainer.json must specify an image or a Dockerfile")
+	}
+
+	verifyScript, err := getSnippetVerifyScript(snippet)
+	if err != nil {
+		return SnippetTestStageVerify, err
+	}
+	if verifyScript == "" {
+		return "", nil
+	}
+	verifyCommand, err := getVerifyCommand(filepath.Join(snippet.Path, verifyScript), path.Join(snippetVerifyFolder, filepath.ToSlash(verifyScript)))
+	if err != nil {
+		return SnippetTestStageVerify, err
+	}
+
+	runOptions, err := getRunOptions(projectFolder, devcontainerJSONPath, config, image)
+	if err != nil {
+		return SnippetTestStageVerify, err
+	}
+	// the test container isn't a dev container for the (temporary) project folder, and publishing ports
+	// could collide with ports in use on the host (e.g. by running dev containers)
+	delete(runOptions.Labels, labelLocalFolder)
+	delete(runOptions.Labels, labelConfigFile)
+	runOptions.Labels[labelSnippetTest] = snippet.Name
+	runOptions.Ports = nil
+	runOptions.Mounts = append(runOptions.Mounts, fmt.Sprintf("type=bind,source=%s,target=%s,readonly", snippet.Path, snippetVerifyFolder))
+	fmt.Fprintf(output, "Starting container\n")
+	containerID, err := runtime.RunContainer(runOptions)
+	if err != nil {
+		return SnippetTestStageVerify, err
+	}
+	defer func() {
+		if err := runtime.RemoveContainer(containerID, true); err != nil {
+			fmt.Fprintf(output, "Error removing container %s: %s\n", containerID, err)
+		}
+	}()
+
+	_, workspaceFolder, err := getWorkspaceMount(projectFolder, config)
+	if err != nil {
+		return SnippetTestStageVerify, err
+	}
+	userName := config.RemoteUser
+	if userName == "" {
+		userName = config.ContainerUser
+	}
+	fmt.Fprintf(output, "Running %s\n", verifyScript)
+	err = runtime.Exec(containerID, ExecOptions{
+		Cmd:     verifyCommand,
+		User:    userName,
+		WorkDir: workspaceFolder,
+		Stdout:  output,
+		Stderr:  output,
+	})
+	if err != nil {
+		return SnippetTestStageVerify, err
+	}
+	return "", nil
+}
+
+// getSnippetVerifyScript returns the snippet-relative path to the verify script for the snippet (or empty string if not set)
+func getSnippetVerifyScript(snippet *DevcontainerSnippet) (string, error) {
+	if snippet.Type != DevcontainerSnippetTypeFolder {
+		return "", nil
+	}
+	snippetJSON, err := loadFolderSnippet(snippet.Path)
+	if err != nil {
+		return "", err
+	}
+	return snippetJSON.Verify, nil
+}
+
+// getVerifyCommand returns the command to run the verify script at containerPath in the container
+// The script is run with the interpreter from its #! line (or /bin/sh if it doesn't have one) so
+// that it doesn't need to be executable
+func getVerifyCommand(scriptPath string, containerPath string) ([]string, error) {
+	file, err := os.Open(scriptPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading verify script: %s", err)
+	}
+	defer file.Close()
+	firstLine, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("error reading verify script: %s", err)
+	}
+	if strings.HasPrefix(firstLine, "#!") {
+		if interpreter := strings.Fields(strings.TrimPrefix(firstLine, "#!")); len(interpreter) > 0 {
+			return append(interpreter, containerPath), nil
+		}
+	}
+	return []string{"/bin/sh", containerPath}, nil
+}
+
+// RunSnippetTests tests each of the snippets with each of the templates (see RunSnippetTest)
+// Errors running a test are reported as failures in the results so that the remaining tests are run
+func RunSnippetTests(snippetNames []string, templateNames []string, options SnippetTestOptions) []SnippetTestResult {
+	if options.Output == nil {
+		options.Output = ioutil.Discard
+	}
+	results := []SnippetTestResult{}
+	for _, snippetName := range snippetNames {
+		for _, templateName := range templateNames {
+			fmt.Fprintf(options.Output, "Testing snippet %s with template %s\n", snippetName, templateName)
+			result, err := RunSnippetTest(snippetName, templateName, options)
+			if err != nil {
+				result = &SnippetTestResult{Snippet: snippetName, Template: templateName, Error: err.Error()}
+			}
+			results = append(results, *result)
+		}
+	}
+	return results
+}
@@ -0,0 +1,111 @@
+package devcontainers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunSnippetTest_BuildsAndVerifies(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	writeTestProject(t, filepath.Join(root, "templates", "base"), `{
+	"name": "base",
+	"build": { "dockerfile": "Dockerfile" },
+	"forwardPorts": [ 8080 ],
+	"remoteUser": "vscode"
+}`, "FROM ubuntu\n")
+	useTemplateFolders(t, filepath.Join(root, "templates"))
+	writeTestFile(t, filepath.Join(root, "snippets", "go", "snippet.json"), `{
+		"verify": "verify.sh",
+		"actions": [ { "type": "dockerfileSnippet", "content": "RUN install-go" } ]
+	}`)
+	writeTestFile(t, filepath.Join(root, "snippets", "go", "verify.sh"), "#!/bin/bash -e\ngo version\n")
+	useSnippetFolders(t, filepath.Join(root, "snippets"))
+	runtime := &fakeRuntime{}
+	useFakeRuntime(t, runtime)
+
+	result, err := RunSnippetTest("go", "base", SnippetTestOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, &SnippetTestResult{Snippet: "go", Template: "base", Passed: true}, result)
+
+	if assert.Len(t, runtime.builds, 1) && assert.Len(t, runtime.runs, 1) {
+		assert.Equal(t, "Dockerfile", filepath.Base(runtime.builds[0].Dockerfile))
+		assert.Equal(t, runtime.builds[0].Tag, runtime.runs[0].Image)
+		assert.Contains(t, runtime.runs[0].Mounts, "type=bind,source="+filepath.Join(root, "snippets", "go")+",target=/tmp/devcontainer-snippet,readonly")
+		// the test container isn't published on host ports or listed as a dev container
+		assert.Empty(t, runtime.runs[0].Ports)
+		assert.Equal(t, "go", runtime.runs[0].Labels[labelSnippetTest])
+		assert.NotContains(t, runtime.runs[0].Labels, labelLocalFolder)
+		assert.NotContains(t, runtime.runs[0].Labels, labelConfigFile)
+		assert.Equal(t, []string{"container1"}, runtime.removes)
+		assert.Equal(t, []string{runtime.builds[0].Tag}, runtime.imageRemoves)
+	}
+	if assert.Len(t, runtime.execCalls, 1) {
+		assert.Equal(t, []string{"/bin/bash", "-e", "/tmp/devcontainer-snippet/verify.sh"}, runtime.execCalls[0].Cmd)
+		assert.Equal(t, "vscode", runtime.execCalls[0].User)
+	}
+}
+
+func TestRunSnippetTest_ReportsFailedStage(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	writeTestProject(t, filepath.Join(root, "templates", "base"), `{
+	"name": "base",
+	"build": { "dockerfile": "Dockerfile" },
+	"remoteUser": "vscode"
+}`, "FROM ubuntu\n")
+	useTemplateFolders(t, filepath.Join(root, "templates"))
+	writeTestFile(t, filepath.Join(root, "snippets", "go", "snippet.json"), `{
+		"verify": "verify.sh",
+		"actions": [ { "type": "dockerfileSnippet", "content": "RUN install-go" } ]
+	}`)
+	writeTestFile(t, filepath.Join(root, "snippets", "go", "verify.sh"), "#!/bin/bash -e\ngo version\n")
+	writeTestFile(t, filepath.Join(root, "snippets", "db", "snippet.json"), `{
+		"actions": [ { "type": "composeService", "service": "db", "definition": { "image": "postgres" } } ]
+	}`)
+	useSnippetFolders(t, filepath.Join(root, "snippets"))
+	runtime := &fakeRuntime{
+		execHandler: func(containerID string, options ExecOptions) error {
+			return &ExecError{ContainerID: containerID, Cmd: options.Cmd, ExitCode: 127}
+		},
+	}
+	useFakeRuntime(t, runtime)
+
+	results := RunSnippetTests([]string{"db", "go", "missing"}, []string{"base"}, SnippetTestOptions{})
+	if !assert.Len(t, results, 3) {
+		return
+	}
+	assert.False(t, results[0].Passed)
+	assert.Equal(t, SnippetTestStageAdd, results[0].Stage)
+	assert.False(t, results[1].Passed)
+	assert.Equal(t, SnippetTestStageVerify, results[1].Stage)
+	assert.False(t, results[2].Passed)
+	assert.NotEmpty(t, results[2].Error)
+	// the container is removed even if the verify script fails
+	assert.Equal(t, []string{"container1"}, runtime.removes)
+}
+
+func TestGetVerifyCommand_DefaultsToSh(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	writeTestFile(t, filepath.Join(root, "verify.sh"), "which go")
+
+	command, err := getVerifyCommand(filepath.Join(root, "verify.sh"), "/tmp/verify.sh")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/bin/sh", "/tmp/verify.sh"}, command)
+}
@@ -5,7 +5,6 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"regexp"
 	"sort"
 	"strings"
 
@@ -14,17 +13,45 @@ import (
 	ioutil2 "github.com/stuartleeks/devcontainer-cli/internal/pkg/ioutil"
 )
 
+// configTemplateFolders returns the configured templatePaths (replaced in tests)
+var configTemplateFolders = config.GetTemplateFolders
+
+// DevcontainerTemplateFormat identifies the layout of a template
+type DevcontainerTemplateFormat string
+
+const (
+	// TemplateFormatDevcontainerFolder is used for templates with a .devcontainer folder that is copied to the project
+	TemplateFormatDevcontainerFolder DevcontainerTemplateFormat = "devcontainerFolder"
+	// TemplateFormatSpec is used for templates that follow the Dev Container Template spec
+	// (https://containers.dev/implementors/templates/) where the template folder content is copied to the project
+	TemplateFormatSpec DevcontainerTemplateFormat = "spec"
+)
+
+// specTemplateExcludedFiles are the files in the root of spec templates that aren't copied to the project
+var specTemplateExcludedFiles = []string{specTemplateMetadataFilename, "README.md", "NOTES.md"}
+
 // DevcontainerTemplate holds info on templates for list/add etc
 type DevcontainerTemplate struct {
-	Name string
-	// Path is the path including the .devcontainer folder
-	Path string
+	Name   string                     `json:"name"`
+	Format DevcontainerTemplateFormat `json:"format"`
+	// Path is the path including the .devcontainer folder for TemplateFormatDevcontainerFolder templates
+	// and the template folder for TemplateFormatSpec templates
+	Path string `json:"path"`
+	// Source is the templatePaths entry for templates from git repos (empty for local folders)
+	Source string `json:"source,omitempty"`
+	// Commit is the git commit that the template was loaded from (for templates from git repos)
+	Commit string `json:"commit,omitempty"`
+	// Options are the options declared in the template manifest (template.json or devcontainer-template.json)
+	Options map[string]TemplateOption `json:"options,omitempty"`
+	// OptionalPaths are the files and folders that can be omitted when adding the template (TemplateFormatSpec only)
+	OptionalPaths []string `json:"optionalPaths,omitempty"`
 }
 
 // GetTemplateByName returns the template with the specified name or nil if not found
-func GetTemplateByName(name string) (*DevcontainerTemplate, error) {
+// Templates from git repos use the commits pinned for projectFolder (if any)
+func GetTemplateByName(name string, projectFolder string) (*DevcontainerTemplate, error) {
 	// TODO - could possibly make this quicker by searching using the name rather than listing all and filtering
-	templates, err := GetTemplates()
+	templates, err := GetTemplatesForProject(projectFolder)
 	if err != nil {
 		return nil, err
 	}
@@ -38,24 +65,48 @@ func GetTemplateByName(name string) (*DevcontainerTemplate, error) {
 
 // GetTemplates returns a list of discovered templates
 func GetTemplates() ([]DevcontainerTemplate, error) {
+	return GetTemplatesForProject("")
+}
+
+// GetTemplatesForProject returns a list of discovered templates using the commits pinned for
+// projectFolder for templates from git repos. If projectFolder is empty the latest cached commits are used
+func GetTemplatesForProject(projectFolder string) ([]DevcontainerTemplate, error) {
 
-	folders := config.GetTemplateFolders()
+	folders := configTemplateFolders()
 	if len(folders) == 0 {
 		return []DevcontainerTemplate{}, &errors.StatusError{Message: "No template folders configured - see https://github.com/stuartleeks/devcontainer-cli/#working-with-devcontainer-templates"}
 	}
-	templates, err := getTemplatesFromFolders(folders)
+	var pins *templatePins
+	if projectFolder != "" {
+		var err error
+		if pins, err = loadTemplatePins(projectFolder); err != nil {
+			return []DevcontainerTemplate{}, err
+		}
+	}
+	templates, err := getTemplatesFromFolders(folders, pins)
 	if err != nil {
 		return []DevcontainerTemplate{}, err
 	}
 	return templates, nil
 }
 
-func getTemplatesFromFolders(folders []string) ([]DevcontainerTemplate, error) {
+func getTemplatesFromFolders(folders []string, pins *templatePins) ([]DevcontainerTemplate, error) {
 	templates := []DevcontainerTemplate{}
 	templateNames := map[string]bool{}
 
 	for _, folder := range folders {
-		folder := os.ExpandEnv(folder)
+		source := ""
+		commit := ""
+		if gitSource, ok := parseGitTemplateSource(folder); ok {
+			var err error
+			folder, commit, err = gitSource.getFolder(pins.getCommit(gitSource.Entry))
+			if err != nil {
+				return []DevcontainerTemplate{}, err
+			}
+			source = gitSource.Entry
+		} else {
+			folder = os.ExpandEnv(folder)
+		}
 		newTemplates, err := getTemplatesFromFolder(folder)
 		if err != nil {
 			return []DevcontainerTemplate{}, err
@@ -63,6 +114,8 @@ func getTemplatesFromFolders(folders []string) ([]DevcontainerTemplate, error) {
 		for _, template := range newTemplates {
 			if !templateNames[template.Name] {
 				templateNames[template.Name] = true
+				template.Source = source
+				template.Commit = commit
 				templates = append(templates, template)
 			}
 		}
@@ -72,15 +125,6 @@ func getTemplatesFromFolders(folders []string) ([]DevcontainerTemplate, error) {
 }
 
 func getTemplatesFromFolder(folder string) ([]DevcontainerTemplate, error) {
-	isDevcontainerFolder := func(parentPath string, fi os.FileInfo) bool {
-		if !fi.IsDir() {
-			return false
-		}
-		// TOODO - add support for templates with .devcontainer.json rather than .devcontainer folder
-		devcontainerJsonPath := filepath.Join(parentPath, fi.Name(), ".devcontainer/devcontainer.json")
-		devContainerJsonInfo, err := os.Stat(devcontainerJsonPath)
-		return err == nil && !devContainerJsonInfo.IsDir()
-	}
 	c, err := ioutil.ReadDir(folder)
 
 	if err != nil {
@@ -89,17 +133,65 @@ func getTemplatesFromFolder(folder string) ([]DevcontainerTemplate, error) {
 
 	templates := []DevcontainerTemplate{}
 	for _, entry := range c {
-		if isDevcontainerFolder(folder, entry) {
-			template := DevcontainerTemplate{
-				Name: entry.Name(),
-				Path: filepath.Join(folder, entry.Name(), ".devcontainer"),
-			}
-			templates = append(templates, template)
+		if !entry.IsDir() {
+			continue
+		}
+		template, err := loadTemplateFromFolder(filepath.Join(folder, entry.Name()))
+		if err != nil {
+			return []DevcontainerTemplate{}, err
+		}
+		if template != nil {
+			templates = append(templates, *template)
 		}
 	}
 	return templates, nil
 }
 
+// loadTemplateFromFolder loads the template in templateFolder. Returns nil if the folder doesn't contain a template
+// Folders with a devcontainer-template.json or a .devcontainer.json file are loaded as TemplateFormatSpec templates
+// and folders with a .devcontainer/devcontainer.json file (but no devcontainer-template.json) as TemplateFormatDevcontainerFolder
+func loadTemplateFromFolder(templateFolder string) (*DevcontainerTemplate, error) {
+	isFile := func(path string) bool {
+		info, err := os.Stat(path)
+		return err == nil && !info.IsDir()
+	}
+	hasDevcontainerFolder := isFile(filepath.Join(templateFolder, ".devcontainer", "devcontainer.json"))
+	hasDevcontainerJSON := isFile(filepath.Join(templateFolder, ".devcontainer.json"))
+
+	template := DevcontainerTemplate{Name: filepath.Base(templateFolder)}
+	var manifest *TemplateManifest
+	var err error
+	switch {
+	case isFile(filepath.Join(templateFolder, specTemplateMetadataFilename)):
+		if !hasDevcontainerFolder && !hasDevcontainerJSON {
+			return nil, nil
+		}
+		template.Format = TemplateFormatSpec
+		template.Path = templateFolder
+		manifest, err = loadTemplateManifest(filepath.Join(templateFolder, specTemplateMetadataFilename))
+		if manifest != nil && manifest.ID != "" {
+			template.Name = manifest.ID
+		}
+	case hasDevcontainerFolder:
+		template.Format = TemplateFormatDevcontainerFolder
+		template.Path = filepath.Join(templateFolder, ".devcontainer")
+		manifest, err = loadTemplateManifest(filepath.Join(templateFolder, templateManifestFilename))
+	case hasDevcontainerJSON:
+		template.Format = TemplateFormatSpec
+		template.Path = templateFolder
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if manifest != nil {
+		template.Options = manifest.Options
+		template.OptionalPaths = manifest.OptionalPaths
+	}
+	return &template, nil
+}
+
 func GetDefaultDevcontainerNameForFolder(folderPath string) (string, error) {
 
 	absPath, err := filepath.Abs(folderPath)
@@ -111,12 +203,31 @@ func GetDefaultDevcontainerNameForFolder(folderPath string) (string, error) {
 	return folderName, nil
 }
 
-func CopyTemplateToFolder(templatePath string, targetFolder string, devcontainerName string) error {
-	var err error
+// CopyTemplateToFolder copies the template files to targetFolder and substitutes the placeholder values
+// options are the values for the template options (see ResolveOptionValues) and omitPaths are the
+// optional paths to leave out (see TemplateFormatSpec). The template and generated files are recorded
+// in the template lock file (see SyncTemplate). The files are only written if all steps succeed
+func CopyTemplateToFolder(template *DevcontainerTemplate, targetFolder string, devcontainerName string, options map[string]string, omitPaths []string) error {
+	fs := ioutil2.NewOverlayFS()
+	if err := applyTemplate(fs, template, targetFolder, devcontainerName, options, omitPaths); err != nil {
+		return err
+	}
+	return fs.Commit()
+}
 
-	if err = ioutil2.CopyFolder(templatePath, filepath.Join(targetFolder, ".devcontainer")); err != nil {
-		return fmt.Errorf("Error copying folder: %s\n", err)
+// PreviewTemplateCopy returns a unified diff of the changes that CopyTemplateToFolder would make
+// (without changing any files)
+func PreviewTemplateCopy(template *DevcontainerTemplate, targetFolder string, devcontainerName string, options map[string]string, omitPaths []string) (string, error) {
+	fs := ioutil2.NewOverlayFS()
+	if err := applyTemplate(fs, template, targetFolder, devcontainerName, options, omitPaths); err != nil {
+		return "", err
 	}
+	return formatOverlayChanges(fs, targetFolder), nil
+}
+
+// applyTemplate generates the template files and template lock for targetFolder in fs
+func applyTemplate(fs *ioutil2.OverlayFS, template *DevcontainerTemplate, targetFolder string, devcontainerName string, options map[string]string, omitPaths []string) error {
+	var err error
 
 	// by default the "name" in devcontainer.json is set to the name of the template
 	// override it here with the value passed in as --devcontainer-name (or the containing folder if not set)
@@ -126,89 +237,206 @@ func CopyTemplateToFolder(templatePath string, targetFolder string, devcontainer
 			return fmt.Errorf("Error getting default devcontainer name: %s", err)
 		}
 	}
+
+	files, err := generateTemplateFiles(fs, template, targetFolder, devcontainerName, options, omitPaths)
+	if err != nil {
+		return err
+	}
+	lock, err := newTemplateLock(fs, template, targetFolder, devcontainerName, options, omitPaths, files)
+	if err != nil {
+		return err
+	}
+	return lock.save(fs, targetFolder)
+}
+
+// generateTemplateFiles copies the template files to targetFolder in fs and substitutes the placeholder values
+// Returns the paths of the generated files
+func generateTemplateFiles(fs *ioutil2.OverlayFS, template *DevcontainerTemplate, targetFolder string, devcontainerName string, options map[string]string, omitPaths []string) ([]string, error) {
+	files, err := copyTemplateFiles(fs, template, targetFolder, omitPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	// substitute options first as they can be used in devcontainer.json in places that
+	// aren't valid JSON until the values are substituted (e.g. "appPort": __TEMPLATE_OPTION_port__)
+	if len(options) > 0 {
+		err = substituteFiles(fs, files, func(content string) string {
+			return substituteTemplateOptions(options, content)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Error substituting template options: %s", err)
+		}
+	}
+
 	devcontainerJsonPath := filepath.Join(targetFolder, ".devcontainer", "devcontainer.json")
-	err = SetDevcontainerName(devcontainerJsonPath, devcontainerName)
+	if !fs.Exists(devcontainerJsonPath) && template.Format == TemplateFormatSpec {
+		devcontainerJsonPath = filepath.Join(targetFolder, ".devcontainer.json")
+	}
+	err = setDevcontainerName(fs, devcontainerJsonPath, devcontainerName)
 	if err != nil {
-		return fmt.Errorf("Error setting devcontainer name: %s", err)
+		return nil, fmt.Errorf("Error setting devcontainer name: %s", err)
 	}
 
-	values, err := getSubstitutionValuesFromFile(devcontainerJsonPath)
+	values, err := getSubstitutionValuesFromFile(fs, devcontainerJsonPath)
 	if err != nil {
-		return fmt.Errorf("Error getting substituion values: %s", err)
+		return nil, fmt.Errorf("Error getting substituion values: %s", err)
 	}
-	err = recursiveSubstituteValues(values, filepath.Join(targetFolder, ".devcontainer"))
+	err = substituteFiles(fs, files, func(content string) string {
+		return performSubstitutionString(values, content)
+	})
 	if err != nil {
-		return fmt.Errorf("Error performing substitution: %s", err)
+		return nil, fmt.Errorf("Error performing substitution: %s", err)
 	}
 
-	return nil
+	return files, nil
 }
 
-func recursiveSubstituteValues(values *SubstitutionValues, path string) error {
-	_, err := os.Stat(path)
+// copyTemplateFiles copies the template files to targetFolder in fs and returns the paths of the copied files
+func copyTemplateFiles(fs *ioutil2.OverlayFS, template *DevcontainerTemplate, targetFolder string, omitPaths []string) ([]string, error) {
+	if template.Format != TemplateFormatSpec {
+		if len(omitPaths) > 0 {
+			return nil, fmt.Errorf("template %q doesn't have optional paths", template.Name)
+		}
+		devcontainerFolder := filepath.Join(targetFolder, ".devcontainer")
+		if fs.Exists(devcontainerFolder) {
+			return nil, fmt.Errorf("Target folder %q already exists - exiting", devcontainerFolder)
+		}
+		relativePaths, err := getFolderFiles(template.Path)
+		if err != nil {
+			return nil, err
+		}
+		files := []string{}
+		for _, relativePath := range relativePaths {
+			sourcePath := filepath.Join(template.Path, filepath.FromSlash(relativePath))
+			targetPath := filepath.Join(devcontainerFolder, filepath.FromSlash(relativePath))
+			if err = fs.CopyFile(sourcePath, targetPath); err != nil {
+				return nil, fmt.Errorf("Error copying file %q: %s", sourcePath, err)
+			}
+			files = append(files, targetPath)
+		}
+		return files, nil
+	}
+
+	for _, omitPath := range omitPaths {
+		if !containsString(template.OptionalPaths, omitPath) {
+			return nil, fmt.Errorf("%q is not an optional path for template %q (optional paths: %s)", omitPath, template.Name, strings.Join(template.OptionalPaths, ", "))
+		}
+	}
+	relativePaths, err := getFolderFiles(template.Path)
 	if err != nil {
-		return fmt.Errorf("Error reading folder: %s\n", err)
+		return nil, err
+	}
+	toCopy := []string{}
+	for _, relativePath := range relativePaths {
+		if containsString(specTemplateExcludedFiles, relativePath) || isOmittedPath(relativePath, omitPaths) {
+			continue
+		}
+		targetPath := filepath.Join(targetFolder, filepath.FromSlash(relativePath))
+		if fs.Exists(targetPath) {
+			return nil, fmt.Errorf("Target file %q already exists - exiting", targetPath)
+		}
+		toCopy = append(toCopy, relativePath)
+	}
+
+	files := []string{}
+	for _, relativePath := range toCopy {
+		sourcePath := filepath.Join(template.Path, filepath.FromSlash(relativePath))
+		targetPath := filepath.Join(targetFolder, filepath.FromSlash(relativePath))
+		if err = fs.CopyFile(sourcePath, targetPath); err != nil {
+			return nil, fmt.Errorf("Error copying file %q: %s", sourcePath, err)
+		}
+		files = append(files, targetPath)
 	}
+	return files, nil
+}
 
-	subItems, err := ioutil.ReadDir(path)
+// getFolderFiles returns the paths of the files under folder (relative to folder and using forward slashes)
+func getFolderFiles(folder string) ([]string, error) {
+	files := []string{}
+	err := filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		relativePath, err := filepath.Rel(folder, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(relativePath))
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("Error reading source folder contents: %s\n", err)
+		return nil, fmt.Errorf("Error reading folder %q: %s", folder, err)
 	}
+	return files, nil
+}
 
-	for _, subItem := range subItems {
-		if subItem.IsDir() {
-			if err = recursiveSubstituteValues(values, filepath.Join(path, subItem.Name())); err != nil {
-				return err
-			}
-		} else {
-			if err = performSubstitutionFile(values, filepath.Join(path, subItem.Name())); err != nil {
-				return err
+// isOmittedPath returns true if relativePath matches one of omitPaths
+// Paths ending in /* match all content of the folder (as in the optionalPaths of devcontainer-template.json)
+func isOmittedPath(relativePath string, omitPaths []string) bool {
+	for _, omitPath := range omitPaths {
+		if strings.HasSuffix(omitPath, "/*") {
+			if strings.HasPrefix(relativePath, strings.TrimSuffix(omitPath, "*")) {
+				return true
 			}
+		} else if relativePath == omitPath {
+			return true
+		}
+	}
+	return false
+}
+
+// substituteFiles applies substitute to the content of files
+func substituteFiles(fs *ioutil2.OverlayFS, files []string, substitute func(content string) string) error {
+	for _, file := range files {
+		if err := performSubstitutionFile(fs, file, substitute); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// SetDevcontainerName sets the top-level "name" property in devcontainer.json and
+// replaces __DEVCONTAINER_NAME__ placeholders with the name
 func SetDevcontainerName(devContainerJsonPath string, name string) error {
-	// This doesn't use `json` as devcontainer.json permits comments (and the default templates include them!)
-
-	// TODO - update this to use dora to query
-	// TODO - update this to replace __DEVCONTAINER_USER_NAME__ and __DEVCONTAINER_HOME__
+	fs := ioutil2.NewOverlayFS()
+	if err := setDevcontainerName(fs, devContainerJsonPath, name); err != nil {
+		return err
+	}
+	return fs.Commit()
+}
 
-	buf, err := ioutil.ReadFile(devContainerJsonPath)
+func setDevcontainerName(fs *ioutil2.OverlayFS, devContainerJsonPath string, name string) error {
+	buf, err := fs.ReadFile(devContainerJsonPath)
 	if err != nil {
 		return fmt.Errorf("error reading file %q: %s", devContainerJsonPath, err)
 	}
-	content := string(buf)
-
-	// replace `name` property in JSON
-	r := regexp.MustCompile("(\"name\"\\s*:\\s*\")[^\"]*(\")")
-	replacement := "${1}" + name + "${2}"
-	content = r.ReplaceAllString(content, replacement)
+	document, err := parseJSONC(buf)
+	if err != nil {
+		return fmt.Errorf("error parsing file %q: %s", devContainerJsonPath, err)
+	}
+	if err = document.SetValue([]string{"name"}, name); err != nil {
+		return fmt.Errorf("error setting name in %q: %s", devContainerJsonPath, err)
+	}
 
 	// replace __DEVCONTAINER_NAME__ with name
-	content = strings.ReplaceAll(content, "__DEVCONTAINER_NAME__", name)
+	content := strings.ReplaceAll(string(document.Bytes()), "__DEVCONTAINER_NAME__", name)
 
 	buf = []byte(content)
-	if err = ioutil.WriteFile(devContainerJsonPath, buf, 0777); err != nil {
+	if err = fs.WriteFile(devContainerJsonPath, buf, 0644); err != nil {
 		return fmt.Errorf("error writing file %q: %s", devContainerJsonPath, err)
 	}
 
 	return nil
 }
 
-// "remoteUser": "vscode"
+// GetDevContainerUserName returns the remoteUser from devcontainer.json (or empty string if not set)
 func GetDevContainerUserName(devContainerJsonPath string) (string, error) {
-	buf, err := ioutil.ReadFile(devContainerJsonPath)
+	config, err := LoadDevcontainerConfig(devContainerJsonPath)
 	if err != nil {
-		return "", fmt.Errorf("error reading file %q: %s", devContainerJsonPath, err)
-	}
-
-	r := regexp.MustCompile("\n[^/]*\"remoteUser\"\\s*:\\s*\"([^\"]*)\"")
-	match := r.FindStringSubmatch(string(buf))
-
-	if len(match) <= 0 {
-		return "", nil
+		return "", err
 	}
-	return match[1], nil
+	return config.RemoteUser, nil
 }
@@ -46,6 +46,43 @@ func TestSetDevcontainerName(t *testing.T) {
 }`, string(buf))
 }
 
+func TestSetDevcontainerName_OnlyChangesTopLevelName(t *testing.T) {
+
+	f, err := ioutil.TempFile("", "test.json")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.Remove(f.Name())
+
+	_, _ = f.WriteString(`{
+	/* "name": "in a comment" */
+	"name":
+		"initial",
+	"build": {
+		"args": { "name": "nested" }
+	}
+}`)
+
+	err = SetDevcontainerName(f.Name(), "newName")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	buf, err := ioutil.ReadFile(f.Name())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, `{
+	/* "name": "in a comment" */
+	"name":
+		"newName",
+	"build": {
+		"args": { "name": "nested" }
+	}
+}`, string(buf))
+}
+
 func TestGetDevContainerUserName_Uncommented(t *testing.T) {
 
 	f, err := ioutil.TempFile("", "test.json")
@@ -173,19 +210,21 @@ func TestGetTemplateFolders_ListsFoldersWithDevcontainers(t *testing.T) {
 	_ = os.MkdirAll(filepath.Join(root, "test2", ".devcontainer"), 0755)
 	_ = ioutil.WriteFile(filepath.Join(root, "test2", ".devcontainer", "devcontainer.json"), []byte{}, 0755)
 
-	templates, err := getTemplatesFromFolders(folders)
+	templates, err := getTemplatesFromFolders(folders, nil)
 	if !assert.NoError(t, err) {
 		return
 	}
 
 	expectedTemplates := []DevcontainerTemplate{
 		{
-			Name: "test1",
-			Path: filepath.Join(root, "test1", ".devcontainer"),
+			Name:   "test1",
+			Format: TemplateFormatDevcontainerFolder,
+			Path:   filepath.Join(root, "test1", ".devcontainer"),
 		},
 		{
-			Name: "test2",
-			Path: filepath.Join(root, "test2", ".devcontainer"),
+			Name:   "test2",
+			Format: TemplateFormatDevcontainerFolder,
+			Path:   filepath.Join(root, "test2", ".devcontainer"),
 		},
 	}
 
@@ -213,15 +252,16 @@ func TestGetTemplateFolders_TakesFolderInPrioirtyOrder(t *testing.T) {
 	_ = os.MkdirAll(filepath.Join(root2, "test1", ".devcontainer"), 0755)
 	_ = ioutil.WriteFile(filepath.Join(root2, "test1", ".devcontainer", "devcontainer.json"), []byte{}, 0755)
 
-	templates, err := getTemplatesFromFolders(folders)
+	templates, err := getTemplatesFromFolders(folders, nil)
 	if !assert.NoError(t, err) {
 		return
 	}
 
 	expectedTemplates := []DevcontainerTemplate{
 		{
-			Name: "test1",
-			Path: filepath.Join(root1, "test1", ".devcontainer"),
+			Name:   "test1",
+			Format: TemplateFormatDevcontainerFolder,
+			Path:   filepath.Join(root1, "test1", ".devcontainer"),
 		},
 	}
 
@@ -249,15 +289,16 @@ func TestGetTemplateFolders_IgnoresFolderWithoutDevcontainer(t *testing.T) {
 	_ = os.MkdirAll(filepath.Join(root2, "test1", ".devcontainer"), 0755)
 	_ = ioutil.WriteFile(filepath.Join(root2, "test1", ".devcontainer", "devcontainer.json"), []byte{}, 0755)
 
-	templates, err := getTemplatesFromFolders(folders)
+	templates, err := getTemplatesFromFolders(folders, nil)
 	if !assert.NoError(t, err) {
 		return
 	}
 
 	expectedTemplates := []DevcontainerTemplate{
 		{
-			Name: "test1",
-			Path: filepath.Join(root2, "test1", ".devcontainer"), // Takes root2 because root1 doesn't have devcontainer.json
+			Name:   "test1",
+			Format: TemplateFormatDevcontainerFolder,
+			Path:   filepath.Join(root2, "test1", ".devcontainer"), // Takes root2 because root1 doesn't have devcontainer.json
 		},
 	}
 
@@ -299,7 +340,7 @@ RUN echo hi2
 	_ = os.MkdirAll(targetFolder, 0755)
 
 	// Add template
-	err = CopyTemplateToFolder(filepath.Join(root, "test1", ".devcontainer"), targetFolder, "NewName")
+	err = CopyTemplateToFolder(&DevcontainerTemplate{Name: "test1", Format: TemplateFormatDevcontainerFolder, Path: filepath.Join(root, "test1", ".devcontainer")}, targetFolder, "NewName", nil, nil)
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -371,7 +412,7 @@ RUN echo hi2
 	_ = os.MkdirAll(targetFolder, 0755)
 
 	// Add template
-	err = CopyTemplateToFolder(filepath.Join(root, "test1", ".devcontainer"), targetFolder, "NewName")
+	err = CopyTemplateToFolder(&DevcontainerTemplate{Name: "test1", Format: TemplateFormatDevcontainerFolder, Path: filepath.Join(root, "test1", ".devcontainer")}, targetFolder, "NewName", nil, nil)
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -408,3 +449,192 @@ RUN echo hi2
 }`, stringContent)
 
 }
+
+func TestGetTemplateFolders_LoadsSpecTemplates(t *testing.T) {
+
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+
+	// template following the Dev Container Template spec
+	_ = os.MkdirAll(filepath.Join(root, "go-folder", ".devcontainer"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(root, "go-folder", ".devcontainer", "devcontainer.json"), []byte{}, 0755)
+	_ = ioutil.WriteFile(filepath.Join(root, "go-folder", "devcontainer-template.json"), []byte(`{
+	"id": "go",
+	"version": "1.0.0",
+	"name": "Go",
+	"options": {
+		"imageVariant": { "type": "string", "proposals": ["1.21", "1.20"], "default": "1.21" }
+	},
+	"optionalPaths": [".github/*"]
+}`), 0755)
+
+	// template using .devcontainer.json
+	_ = os.MkdirAll(filepath.Join(root, "test2"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(root, "test2", ".devcontainer.json"), []byte{}, 0755)
+
+	templates, err := getTemplatesFromFolders([]string{root}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	expectedTemplates := []DevcontainerTemplate{
+		{
+			Name:   "go",
+			Format: TemplateFormatSpec,
+			Path:   filepath.Join(root, "go-folder"),
+			Options: map[string]TemplateOption{
+				"imageVariant": {Type: "string", Proposals: []string{"1.21", "1.20"}, Default: "1.21"},
+			},
+			OptionalPaths: []string{".github/*"},
+		},
+		{
+			Name:   "test2",
+			Format: TemplateFormatSpec,
+			Path:   filepath.Join(root, "test2"),
+		},
+	}
+
+	assert.ElementsMatch(t, expectedTemplates, templates)
+}
+
+func createSpecTemplate(t *testing.T, templateFolder string) *DevcontainerTemplate {
+	files := map[string]string{
+		"devcontainer-template.json":  `{ "id": "test", "optionalPaths": [".github/*", "notes.txt"] }`,
+		"README.md":                   "# Test template",
+		".devcontainer.json":          `{ "name": "Test", "image": "golang:${templateOption:imageVariant}" }`,
+		".github/workflows/build.yml": "name: __DEVCONTAINER_NAME__",
+		"notes.txt":                   "notes",
+		"scripts/setup.sh":            "echo ${templateOption:imageVariant}",
+	}
+	for name, content := range files {
+		path := filepath.Join(templateFolder, filepath.FromSlash(name))
+		_ = os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	template, err := loadTemplateFromFolder(templateFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return template
+}
+
+func TestAddTemplate_CopiesSpecTemplate(t *testing.T) {
+
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+
+	template := createSpecTemplate(t, filepath.Join(root, "test"))
+	targetFolder := filepath.Join(root, "target")
+	_ = os.MkdirAll(targetFolder, 0755)
+	_ = ioutil.WriteFile(filepath.Join(targetFolder, "main.go"), []byte("package main"), 0644)
+
+	err = CopyTemplateToFolder(template, targetFolder, "NewName", map[string]string{"imageVariant": "1.20"}, []string{"notes.txt"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	files, err := getFolderFiles(targetFolder)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// the lock file is written next to .devcontainer.json so that no .devcontainer folder is created
+	assert.ElementsMatch(t, []string{".devcontainer.json", ".devcontainer-template-lock.json", ".github/workflows/build.yml", "main.go", "scripts/setup.sh"}, files)
+	lock, err := loadTemplateLock(targetFolder)
+	if assert.NoError(t, err) {
+		assert.Contains(t, lock.Files, ".devcontainer.json")
+	}
+
+	buf, err := ioutil.ReadFile(filepath.Join(targetFolder, ".devcontainer.json"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, `{ "name": "NewName", "image": "golang:1.20" }`, string(buf))
+
+	buf, err = ioutil.ReadFile(filepath.Join(targetFolder, ".github", "workflows", "build.yml"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "name: NewName", string(buf))
+}
+
+func TestAddTemplate_OmitsOptionalFolder(t *testing.T) {
+
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+
+	template := createSpecTemplate(t, filepath.Join(root, "test"))
+	targetFolder := filepath.Join(root, "target")
+	_ = os.MkdirAll(targetFolder, 0755)
+
+	err = CopyTemplateToFolder(template, targetFolder, "NewName", nil, []string{".github/*"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	files, err := getFolderFiles(targetFolder)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.ElementsMatch(t, []string{".devcontainer.json", ".devcontainer-template-lock.json", "notes.txt", "scripts/setup.sh"}, files)
+}
+
+func TestAddTemplate_SpecTemplateErrors(t *testing.T) {
+
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+
+	template := createSpecTemplate(t, filepath.Join(root, "test"))
+	targetFolder := filepath.Join(root, "target")
+	_ = os.MkdirAll(targetFolder, 0755)
+
+	err = CopyTemplateToFolder(template, targetFolder, "NewName", nil, []string{"README.md"})
+	assert.Error(t, err, "README.md isn't an optional path")
+
+	_ = ioutil.WriteFile(filepath.Join(targetFolder, "notes.txt"), []byte("existing"), 0644)
+	err = CopyTemplateToFolder(template, targetFolder, "NewName", nil, nil)
+	assert.Error(t, err, "notes.txt already exists")
+	_, err = os.Stat(filepath.Join(targetFolder, ".devcontainer.json"))
+	assert.True(t, os.IsNotExist(err), "no files should be copied if the target files exist")
+}
+
+func TestAddTemplate_PreviewDoesNotWriteFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+
+	templateFolder := filepath.Join(root, "templates", "test1", ".devcontainer")
+	writeTestFile(t, filepath.Join(templateFolder, "devcontainer.json"), "{\n\t\"name\": \"test1\"\n}\n")
+	targetFolder := filepath.Join(root, "target")
+	_ = os.MkdirAll(targetFolder, 0755)
+
+	template := &DevcontainerTemplate{Name: "test1", Format: TemplateFormatDevcontainerFolder, Path: templateFolder}
+	preview, err := PreviewTemplateCopy(template, targetFolder, "NewName", nil, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Contains(t, preview, `--- /dev/null
++++ b/.devcontainer/devcontainer.json
+@@ -0,0 +1,3 @@
++{
++	"name": "NewName"
++}
+`)
+	assert.Contains(t, preview, "+++ b/.devcontainer/"+templateLockFilename)
+	assert.NoDirExists(t, filepath.Join(targetFolder, ".devcontainer"))
+}
@@ -0,0 +1,193 @@
+package devcontainers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ioutil2 "github.com/stuartleeks/devcontainer-cli/internal/pkg/ioutil"
+)
+
+// CreateTemplateFromFolder creates a template called name from the .devcontainer folder in projectFolder
+// The template is created in the first template folder that isn't a git repo. The name in devcontainer.json and
+// the remoteUser and home folder values are replaced with the placeholders used when adding templates
+// (e.g. __DEVCONTAINER_NAME__)
+// If force is true then an existing template with the same name is replaced
+// Returns the path of the created template
+func CreateTemplateFromFolder(projectFolder string, name string, force bool) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid template name %q", name)
+	}
+	sourceFolder := filepath.Join(projectFolder, ".devcontainer")
+	devcontainerJsonPath := filepath.Join(sourceFolder, "devcontainer.json")
+	if _, err := os.Stat(devcontainerJsonPath); err != nil {
+		return "", fmt.Errorf("%q not found - templates can only be created from a .devcontainer folder", devcontainerJsonPath)
+	}
+	values, err := getTemplateCreateValues(devcontainerJsonPath)
+	if err != nil {
+		return "", err
+	}
+
+	templatesFolder := ""
+	for _, folder := range configTemplateFolders() {
+		if _, isGitSource := parseGitTemplateSource(folder); !isGitSource {
+			templatesFolder = os.ExpandEnv(folder)
+			break
+		}
+	}
+	if templatesFolder == "" {
+		return "", fmt.Errorf("no template folder configured - add a folder that isn't a git repo to templatePaths")
+	}
+
+	templateFolder := filepath.Join(templatesFolder, name)
+	templateExists := false
+	if _, err := os.Stat(templateFolder); err == nil {
+		if !force {
+			return "", fmt.Errorf("template folder %q already exists (use --force to replace it)", templateFolder)
+		}
+		templateExists = true
+	}
+
+	relativePaths, err := getFolderFiles(sourceFolder)
+	if err != nil {
+		return "", err
+	}
+	// the template is written to a staging folder alongside templateFolder and then moved into place so that
+	// an existing template is only replaced once the new one has been written
+	if err = os.MkdirAll(templatesFolder, 0755); err != nil {
+		return "", fmt.Errorf("error creating folder %q: %s", templatesFolder, err)
+	}
+	stagingFolder, err := ioutil.TempDir(templatesFolder, "."+name+".tmp*")
+	if err != nil {
+		return "", fmt.Errorf("error creating folder in %q: %s", templatesFolder, err)
+	}
+	defer os.RemoveAll(stagingFolder)
+	newTemplateFolder := filepath.Join(stagingFolder, "new")
+	fs := ioutil2.NewOverlayFS()
+	targetFolder := filepath.Join(newTemplateFolder, ".devcontainer")
+	for _, relativePath := range relativePaths {
+		// the lock file and snippet manifest record how this project was generated from its template and snippets
+		// so aren't part of the new template
+		if relativePath == templateLockFilename || relativePath == snippetManifestFilename {
+			continue
+		}
+		sourcePath := filepath.Join(sourceFolder, filepath.FromSlash(relativePath))
+		buf, err := ioutil.ReadFile(sourcePath)
+		if err != nil {
+			return "", fmt.Errorf("error reading file %q: %s", sourcePath, err)
+		}
+		info, err := os.Stat(sourcePath)
+		if err != nil {
+			return "", fmt.Errorf("error reading file %q: %s", sourcePath, err)
+		}
+		content := reverseSubstitutionString(values, string(buf))
+		if relativePath == "devcontainer.json" {
+			if content, err = setTemplateDevcontainerJSONValues(values, content); err != nil {
+				return "", fmt.Errorf("error updating %q: %s", sourcePath, err)
+			}
+		}
+		if err = fs.WriteFile(filepath.Join(targetFolder, filepath.FromSlash(relativePath)), []byte(content), info.Mode().Perm()); err != nil {
+			return "", err
+		}
+	}
+
+	if err = fs.Commit(); err != nil {
+		return "", err
+	}
+	oldTemplateFolder := filepath.Join(stagingFolder, "old")
+	if templateExists {
+		if err = os.Rename(templateFolder, oldTemplateFolder); err != nil {
+			return "", fmt.Errorf("error replacing folder %q: %s", templateFolder, err)
+		}
+	}
+	if err = os.Rename(newTemplateFolder, templateFolder); err != nil {
+		if templateExists {
+			_ = os.Rename(oldTemplateFolder, templateFolder)
+		}
+		return "", fmt.Errorf("error creating folder %q: %s", templateFolder, err)
+	}
+	return templateFolder, nil
+}
+
+// setTemplateDevcontainerJSONValues sets the name in devcontainer.json to the name placeholder and restores
+// remoteUser (which reverseSubstitutionString replaces) as it is used to determine the user name when the
+// template is added
+func setTemplateDevcontainerJSONValues(values *SubstitutionValues, content string) (string, error) {
+	document, err := parseJSONC([]byte(content))
+	if err != nil {
+		return "", err
+	}
+	if values.Name != "" {
+		// the name is only replaced here as short project names (e.g. api) often appear in other content
+		if err = document.SetValue([]string{"name"}, "__DEVCONTAINER_NAME__"); err != nil {
+			return "", err
+		}
+	}
+	if values.UserName != "" {
+		if err = document.SetValue([]string{"remoteUser"}, values.UserName); err != nil {
+			return "", err
+		}
+	}
+	return string(document.Bytes()), nil
+}
+
+// getTemplateCreateValues returns the project-specific values to replace with placeholders when creating a template
+// UserName and HomeFolder are only set if remoteUser is set in devcontainer.json
+func getTemplateCreateValues(devcontainerJsonPath string) (*SubstitutionValues, error) {
+	config, err := LoadDevcontainerConfig(devcontainerJsonPath)
+	if err != nil {
+		return nil, err
+	}
+	values := &SubstitutionValues{Name: config.Name}
+	if config.RemoteUser != "" {
+		substitutionValues, err := getSubstitutionValuesFromFile(ioutil2.NewOverlayFS(), devcontainerJsonPath)
+		if err != nil {
+			return nil, err
+		}
+		values.UserName = substitutionValues.UserName
+		values.HomeFolder = substitutionValues.HomeFolder
+	}
+	return values, nil
+}
+
+// reverseSubstitutionString replaces the user name and home folder values in substitutionValues with the
+// __DEVCONTAINER_*__ placeholders (the reverse of performSubstitutionString). Only whole-word occurrences are
+// replaced. The name is handled separately (see setTemplateDevcontainerJSONValues)
+func reverseSubstitutionString(substitutionValues *SubstitutionValues, content string) string {
+	// replace the home folder first as it usually contains the user name
+	content = replaceWord(content, substitutionValues.HomeFolder, "__DEVCONTAINER_HOME__")
+	content = replaceWord(content, substitutionValues.UserName, "__DEVCONTAINER_USER_NAME__")
+	return content
+}
+
+// replaceWord replaces occurrences of value in content that aren't part of a longer word
+func replaceWord(content string, value string, replacement string) string {
+	if value == "" {
+		return content
+	}
+	var builder strings.Builder
+	start, offset := 0, 0
+	for {
+		i := strings.Index(content[offset:], value)
+		if i < 0 {
+			break
+		}
+		i += offset
+		end := i + len(value)
+		offset = end
+		if (i > 0 && isWordByte(content[i-1])) || (end < len(content) && isWordByte(content[end])) {
+			continue
+		}
+		builder.WriteString(content[start:i])
+		builder.WriteString(replacement)
+		start = end
+	}
+	builder.WriteString(content[start:])
+	return builder.String()
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '-' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
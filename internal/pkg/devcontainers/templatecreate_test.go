@@ -0,0 +1,142 @@
+package devcontainers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplaceWord(t *testing.T) {
+	assert.Equal(t, "USER __X__\nRUN echo vscodes-vscode\n", replaceWord("USER vscode\nRUN echo vscodes-vscode\n", "vscode", "__X__"))
+	assert.Equal(t, "__X__/.cache __X__", replaceWord("/home/vscode/.cache /home/vscode", "/home/vscode", "__X__"))
+	assert.Equal(t, "unchanged", replaceWord("unchanged", "", "__X__"))
+}
+
+func TestCreateTemplateFromFolder(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	useTemplateFolders(t, "https://example.com/templates.git", filepath.Join(root, "templates"))
+
+	projectFolder := filepath.Join(root, "project")
+	writeTestFile(t, filepath.Join(projectFolder, ".devcontainer", "devcontainer.json"), `{
+	"name": "myproject",
+	"remoteUser": "vscode",
+	"mounts": [ "source=myproject-cache,target=/home/vscode/.cache,type=volume" ]
+}`)
+	writeTestFile(t, filepath.Join(projectFolder, ".devcontainer", "Dockerfile"), "FROM golang\nUSER vscode\nRUN echo myproject > /home/vscode/name\n")
+	writeTestFile(t, filepath.Join(projectFolder, ".devcontainer", templateLockFilename), "{}")
+	writeTestFile(t, filepath.Join(projectFolder, ".devcontainer", snippetManifestFilename), `{ "snippets": [] }`)
+
+	templateFolder, err := CreateTemplateFromFolder(projectFolder, "go-project", false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, filepath.Join(root, "templates", "go-project"), templateFolder)
+	assert.Equal(t, `{
+	"name": "__DEVCONTAINER_NAME__",
+	"remoteUser": "vscode",
+	"mounts": [ "source=myproject-cache,target=__DEVCONTAINER_HOME__/.cache,type=volume" ]
+}`, readTestFile(t, filepath.Join(templateFolder, ".devcontainer", "devcontainer.json")))
+	assert.Equal(t, "FROM golang\nUSER __DEVCONTAINER_USER_NAME__\nRUN echo myproject > __DEVCONTAINER_HOME__/name\n", readTestFile(t, filepath.Join(templateFolder, ".devcontainer", "Dockerfile")))
+	assert.NoFileExists(t, filepath.Join(templateFolder, ".devcontainer", templateLockFilename))
+	assert.NoFileExists(t, filepath.Join(templateFolder, ".devcontainer", snippetManifestFilename))
+
+	// adding the new template re-applies the values
+	useTemplateFolders(t, filepath.Join(root, "templates"))
+	newProjectFolder := filepath.Join(root, "newproject")
+	_ = os.MkdirAll(newProjectFolder, 0755)
+	template, err := GetTemplateByName("go-project", newProjectFolder)
+	if !assert.NoError(t, err) || !assert.NotNil(t, template) {
+		return
+	}
+	if !assert.NoError(t, CopyTemplateToFolder(template, newProjectFolder, "other", nil, nil)) {
+		return
+	}
+	assert.Equal(t, "FROM golang\nUSER vscode\nRUN echo myproject > /home/vscode/name\n", readTestFile(t, filepath.Join(newProjectFolder, ".devcontainer", "Dockerfile")))
+}
+
+func TestCreateTemplateFromFolder_ExistingTemplate(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	useTemplateFolders(t, filepath.Join(root, "templates"))
+
+	projectFolder := filepath.Join(root, "project")
+	writeTestFile(t, filepath.Join(projectFolder, ".devcontainer", "devcontainer.json"), `{ "name": "myproject" }`)
+	writeTestFile(t, filepath.Join(root, "templates", "existing", ".devcontainer", "old.txt"), "old")
+
+	_, err = CreateTemplateFromFolder(projectFolder, "existing", false)
+	assert.Error(t, err)
+	assert.FileExists(t, filepath.Join(root, "templates", "existing", ".devcontainer", "old.txt"))
+
+	_, err = CreateTemplateFromFolder(projectFolder, "existing", true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoFileExists(t, filepath.Join(root, "templates", "existing", ".devcontainer", "old.txt"))
+	assert.Equal(t, `{ "name": "__DEVCONTAINER_NAME__" }`, readTestFile(t, filepath.Join(root, "templates", "existing", ".devcontainer", "devcontainer.json")))
+	// the staging folder used to replace the template is removed
+	entries, err := ioutil.ReadDir(filepath.Join(root, "templates"))
+	if assert.NoError(t, err) && assert.Len(t, entries, 1) {
+		assert.Equal(t, "existing", entries[0].Name())
+	}
+}
+
+func TestCreateTemplateFromFolder_ExpandsTemplateFolderEnvVars(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	previous, hadPrevious := os.LookupEnv("DEVCONTAINERX_TEST_ROOT")
+	os.Setenv("DEVCONTAINERX_TEST_ROOT", root)
+	t.Cleanup(func() {
+		if hadPrevious {
+			os.Setenv("DEVCONTAINERX_TEST_ROOT", previous)
+		} else {
+			os.Unsetenv("DEVCONTAINERX_TEST_ROOT")
+		}
+	})
+	useTemplateFolders(t, "$DEVCONTAINERX_TEST_ROOT/templates")
+
+	projectFolder := filepath.Join(root, "project")
+	writeTestFile(t, filepath.Join(projectFolder, ".devcontainer", "devcontainer.json"), `{ "name": "myproject" }`)
+
+	templateFolder, err := CreateTemplateFromFolder(projectFolder, "new-template", false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, filepath.Join(root, "templates", "new-template"), templateFolder)
+	template, err := GetTemplateByName("new-template", projectFolder)
+	if assert.NoError(t, err) {
+		assert.NotNil(t, template)
+	}
+}
+
+func TestCreateTemplateFromFolder_OnlyReplacesNameInDevcontainerJSON(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	useTemplateFolders(t, filepath.Join(root, "templates"))
+
+	projectFolder := filepath.Join(root, "project")
+	writeTestFile(t, filepath.Join(projectFolder, ".devcontainer", "devcontainer.json"), `{ "name": "api", "postStartCommand": "api serve" }`)
+	writeTestFile(t, filepath.Join(projectFolder, ".devcontainer", "Dockerfile"), "FROM golang\nRUN curl http://localhost/api/health\nCMD [\"api\"]\n")
+
+	templateFolder, err := CreateTemplateFromFolder(projectFolder, "api-template", false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, `{ "name": "__DEVCONTAINER_NAME__", "postStartCommand": "api serve" }`, readTestFile(t, filepath.Join(templateFolder, ".devcontainer", "devcontainer.json")))
+	assert.Equal(t, "FROM golang\nRUN curl http://localhost/api/health\nCMD [\"api\"]\n", readTestFile(t, filepath.Join(templateFolder, ".devcontainer", "Dockerfile")))
+}
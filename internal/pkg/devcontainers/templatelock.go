@@ -0,0 +1,97 @@
+package devcontainers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	ioutil2 "github.com/stuartleeks/devcontainer-cli/internal/pkg/ioutil"
+)
+
+// templateLockFilename is the name of the file that records the template used for a project
+// (see getDevcontainerRecordPath for where it is written)
+const templateLockFilename = "devcontainer-template-lock.json"
+
+// templateLock records the template (and values) used to generate the dev container files for a project
+type templateLock struct {
+	Template         string            `json:"template"`
+	Source           string            `json:"source,omitempty"`
+	Commit           string            `json:"commit,omitempty"`
+	DevcontainerName string            `json:"devcontainerName"`
+	Options          map[string]string `json:"options,omitempty"`
+	OmitPaths        []string          `json:"omitPaths,omitempty"`
+	// Files maps the paths of the generated files (relative to the project folder, using forward slashes)
+	// to the hash of the content generated from the template
+	Files map[string]string `json:"files"`
+}
+
+func getTemplateLockPath(fs *ioutil2.OverlayFS, projectFolder string) string {
+	return getDevcontainerRecordPath(fs, projectFolder, templateLockFilename)
+}
+
+// newTemplateLock creates a lock for the files generated from template in projectFolder
+func newTemplateLock(fs *ioutil2.OverlayFS, template *DevcontainerTemplate, projectFolder string, devcontainerName string, options map[string]string, omitPaths []string, files []string) (*templateLock, error) {
+	lock := &templateLock{
+		Template:         template.Name,
+		Source:           template.Source,
+		Commit:           template.Commit,
+		DevcontainerName: devcontainerName,
+		Options:          options,
+		OmitPaths:        omitPaths,
+		Files:            map[string]string{},
+	}
+	for _, file := range files {
+		relativePath, err := filepath.Rel(projectFolder, file)
+		if err != nil {
+			return nil, err
+		}
+		buf, err := fs.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading file %q: %s", file, err)
+		}
+		lock.Files[filepath.ToSlash(relativePath)] = hashContent(buf)
+	}
+	return lock, nil
+}
+
+// loadTemplateLock loads the template lock file for projectFolder
+func loadTemplateLock(projectFolder string) (*templateLock, error) {
+	lockPath := getTemplateLockPath(ioutil2.NewOverlayFS(), projectFolder)
+	buf, err := ioutil.ReadFile(lockPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("template lock file %q not found (only dev containers created with `template add` can be compared with their template)", lockPath)
+		}
+		return nil, fmt.Errorf("error reading file %q: %s", lockPath, err)
+	}
+	var lock templateLock
+	if err = json.Unmarshal(buf, &lock); err != nil {
+		return nil, fmt.Errorf("error parsing file %q: %s", lockPath, err)
+	}
+	if lock.Files == nil {
+		lock.Files = map[string]string{}
+	}
+	return &lock, nil
+}
+
+func (l *templateLock) save(fs *ioutil2.OverlayFS, projectFolder string) error {
+	lockPath := getTemplateLockPath(fs, projectFolder)
+	buf, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = fs.WriteFile(lockPath, append(buf, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing file %q: %s", lockPath, err)
+	}
+	return nil
+}
+
+// hashContent returns the hash used to identify file content in the template lock
+func hashContent(buf []byte) string {
+	hash := sha256.Sum256(buf)
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,159 @@
+package devcontainers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// templateManifestFilename is the name of the (optional) manifest file next to a template's .devcontainer folder
+const templateManifestFilename = "template.json"
+
+// specTemplateMetadataFilename is the name of the metadata file for Dev Container Template spec templates
+const specTemplateMetadataFilename = "devcontainer-template.json"
+
+// TemplateManifest maps to the content of the template.json file for a template, or the devcontainer-template.json
+// file for Dev Container Template spec templates (https://containers.dev/implementors/templates/)
+type TemplateManifest struct {
+	ID            string                    `json:"id"`
+	Version       string                    `json:"version"`
+	Name          string                    `json:"name"`
+	Description   string                    `json:"description"`
+	Options       map[string]TemplateOption `json:"options"`
+	OptionalPaths []string                  `json:"optionalPaths"`
+}
+
+// TemplateOption is an option declared in a template manifest
+// Values are substituted for __TEMPLATE_OPTION_<name>__ and ${templateOption:<name>} placeholders in the template files
+type TemplateOption struct {
+	// Type is string (the default), boolean or number
+	Type        string      `json:"type"`
+	Default     interface{} `json:"default"`
+	Description string      `json:"description"`
+	Enum        []string    `json:"enum"`
+	// Proposals are suggested values (unlike Enum, other values are allowed)
+	Proposals []string `json:"proposals"`
+}
+
+// loadTemplateManifest loads the template manifest at manifestPath. Returns nil if the manifest doesn't exist
+func loadTemplateManifest(manifestPath string) (*TemplateManifest, error) {
+	buf, err := ioutil.ReadFile(manifestPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading file %q: %s", manifestPath, err)
+	}
+	var manifest TemplateManifest
+	if err = json.Unmarshal(buf, &manifest); err != nil {
+		return nil, fmt.Errorf("error parsing file %q: %s", manifestPath, err)
+	}
+	if err = validateOptionTypes(manifest.Options, "option"); err != nil {
+		return nil, fmt.Errorf("error parsing file %q: %s", manifestPath, err)
+	}
+	return &manifest, nil
+}
+
+// DefaultValue returns the default value for the option as a string (or empty string if there is no default)
+func (o TemplateOption) DefaultValue() string {
+	if o.Default == nil {
+		return ""
+	}
+	return fmt.Sprint(o.Default)
+}
+
+// ValidateValue checks that value is valid for the option type and allowed values
+func (o TemplateOption) ValidateValue(value string) error {
+	switch o.Type {
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("must be a boolean (true or false)")
+		}
+	case "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("must be a number")
+		}
+	}
+	if len(o.Enum) > 0 && !containsString(o.Enum, value) {
+		return fmt.Errorf("must be one of %s", strings.Join(o.Enum, ", "))
+	}
+	return nil
+}
+
+// GetOptionNames returns the names of the template options in sorted order
+func (t *DevcontainerTemplate) GetOptionNames() []string {
+	return getOptionNames(t.Options)
+}
+
+// ResolveOptionValues returns the values for all of the template options. Values are taken from values
+// (e.g. from --option name=value), then from prompt (if not nil) and finally from the option defaults.
+// prompt can return an empty string to use the default value
+func (t *DevcontainerTemplate) ResolveOptionValues(values map[string]string, prompt func(name string, option TemplateOption) (string, error)) (map[string]string, error) {
+	for name := range values {
+		if _, ok := t.Options[name]; !ok {
+			return nil, fmt.Errorf("option %q is not defined by template %q", name, t.Name)
+		}
+	}
+	return resolveOptionValues(t.Options, values, prompt, "option", "--option")
+}
+
+func getOptionNames(options map[string]TemplateOption) []string {
+	names := []string{}
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveOptionValues returns the values for all of options (see ResolveOptionValues)
+// kind and flag are used in error messages (e.g. "option" and "--option")
+func resolveOptionValues(options map[string]TemplateOption, values map[string]string, prompt func(name string, option TemplateOption) (string, error), kind string, flag string) (map[string]string, error) {
+	result := map[string]string{}
+	for _, name := range getOptionNames(options) {
+		option := options[name]
+		value, ok := values[name]
+		if !ok && prompt != nil {
+			var err error
+			if value, err = prompt(name, option); err != nil {
+				return nil, err
+			}
+		}
+		if !ok && value == "" {
+			if option.Default == nil {
+				return nil, fmt.Errorf("no value specified for %s %q (use %s %s=<value>)", kind, name, flag, name)
+			}
+			value = option.DefaultValue()
+		}
+		if err := option.ValidateValue(value); err != nil {
+			return nil, fmt.Errorf("invalid value %q for %s %q: %s", value, kind, name, err)
+		}
+		result[name] = value
+	}
+	return result, nil
+}
+
+// validateOptionTypes checks that the options have supported types
+func validateOptionTypes(options map[string]TemplateOption, kind string) error {
+	for name, option := range options {
+		switch option.Type {
+		case "", "string", "boolean", "number":
+		default:
+			return fmt.Errorf("%s %q has unsupported type %q (expected string, boolean or number)", kind, name, option.Type)
+		}
+	}
+	return nil
+}
+
+// substituteTemplateOptions replaces __TEMPLATE_OPTION_<name>__ and ${templateOption:<name>} placeholders with the option values
+func substituteTemplateOptions(options map[string]string, content string) string {
+	for name, value := range options {
+		content = strings.ReplaceAll(content, "__TEMPLATE_OPTION_"+name+"__", value)
+		content = strings.ReplaceAll(content, "${templateOption:"+name+"}", value)
+	}
+	return content
+}
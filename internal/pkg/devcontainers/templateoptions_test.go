@@ -0,0 +1,161 @@
+package devcontainers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestOptionsTemplate() *DevcontainerTemplate {
+	return &DevcontainerTemplate{
+		Name: "go",
+		Options: map[string]TemplateOption{
+			"goVersion":   {Type: "string", Default: "1.20", Enum: []string{"1.19", "1.20"}},
+			"installNode": {Type: "boolean", Default: false},
+			"port":        {Type: "number"},
+		},
+	}
+}
+
+func TestGetTemplatesFromFolders_LoadsTemplateManifest(t *testing.T) {
+
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+
+	_ = os.MkdirAll(filepath.Join(root, "test1", ".devcontainer"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(root, "test1", ".devcontainer", "devcontainer.json"), []byte{}, 0755)
+	_ = ioutil.WriteFile(filepath.Join(root, "test1", "template.json"), []byte(`{
+	"description": "Test template",
+	"options": {
+		"imageTag": { "type": "string", "default": "latest", "description": "Base image tag" },
+		"port": { "type": "number", "default": 8080 }
+	}
+}`), 0755)
+
+	templates, err := getTemplatesFromFolders([]string{root}, nil)
+	if !assert.NoError(t, err) || !assert.Len(t, templates, 1) {
+		return
+	}
+	assert.Equal(t, map[string]TemplateOption{
+		"imageTag": {Type: "string", Default: "latest", Description: "Base image tag"},
+		"port":     {Type: "number", Default: float64(8080)},
+	}, templates[0].Options)
+	assert.Equal(t, []string{"imageTag", "port"}, templates[0].GetOptionNames())
+}
+
+func TestGetTemplatesFromFolders_ErrorsOnInvalidOptionType(t *testing.T) {
+
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+
+	_ = os.MkdirAll(filepath.Join(root, "test1", ".devcontainer"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(root, "test1", ".devcontainer", "devcontainer.json"), []byte{}, 0755)
+	_ = ioutil.WriteFile(filepath.Join(root, "test1", "template.json"), []byte(`{"options": {"ports": {"type": "array"}}}`), 0755)
+
+	_, err = getTemplatesFromFolders([]string{root}, nil)
+	assert.Error(t, err)
+}
+
+func TestResolveOptionValues_UsesValuesThenDefaults(t *testing.T) {
+	template := createTestOptionsTemplate()
+
+	options, err := template.ResolveOptionValues(map[string]string{"port": "3000", "installNode": "true"}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]string{"goVersion": "1.20", "installNode": "true", "port": "3000"}, options)
+}
+
+func TestResolveOptionValues_UsesPrompt(t *testing.T) {
+	template := createTestOptionsTemplate()
+
+	prompted := []string{}
+	prompt := func(name string, option TemplateOption) (string, error) {
+		prompted = append(prompted, name)
+		if name == "port" {
+			return "8080", nil
+		}
+		return "", nil // use default
+	}
+	options, err := template.ResolveOptionValues(map[string]string{"goVersion": "1.19"}, prompt)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"installNode", "port"}, prompted)
+	assert.Equal(t, map[string]string{"goVersion": "1.19", "installNode": "false", "port": "8080"}, options)
+}
+
+func TestResolveOptionValues_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]string
+	}{
+		{name: "missing value without default", values: map[string]string{}},
+		{name: "unknown option", values: map[string]string{"port": "1", "other": "x"}},
+		{name: "value not in enum", values: map[string]string{"port": "1", "goVersion": "1.18"}},
+		{name: "invalid boolean", values: map[string]string{"port": "1", "installNode": "yes please"}},
+		{name: "invalid number", values: map[string]string{"port": "eighty"}},
+	}
+	for _, test := range tests {
+		_, err := createTestOptionsTemplate().ResolveOptionValues(test.values, nil)
+		assert.Error(t, err, test.name)
+	}
+}
+
+func TestAddTemplate_SubstitutesOptions(t *testing.T) {
+
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+
+	_ = os.MkdirAll(filepath.Join(root, "test1", ".devcontainer"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(root, "test1", ".devcontainer", "devcontainer.json"), []byte(`{
+	"name": "__DEVCONTAINER_NAME__",
+	"appPort": __TEMPLATE_OPTION_port__,
+	"build": {
+		"args": { "VARIANT": "__TEMPLATE_OPTION_goVersion__" }
+	}
+}`), 0755)
+	_ = ioutil.WriteFile(filepath.Join(root, "test1", ".devcontainer", "Dockerfile"), []byte(`ARG VARIANT=__TEMPLATE_OPTION_goVersion__
+FROM golang:${VARIANT}
+`), 0755)
+
+	targetFolder := filepath.Join(root, "target")
+	_ = os.MkdirAll(targetFolder, 0755)
+
+	err = CopyTemplateToFolder(&DevcontainerTemplate{Name: "test1", Format: TemplateFormatDevcontainerFolder, Path: filepath.Join(root, "test1", ".devcontainer")}, targetFolder, "NewName", map[string]string{"goVersion": "1.19", "port": "3000"}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	buf, err := ioutil.ReadFile(filepath.Join(targetFolder, ".devcontainer", "devcontainer.json"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, `{
+	"name": "NewName",
+	"appPort": 3000,
+	"build": {
+		"args": { "VARIANT": "1.19" }
+	}
+}`, string(buf))
+
+	buf, err = ioutil.ReadFile(filepath.Join(targetFolder, ".devcontainer", "Dockerfile"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, `ARG VARIANT=1.19
+FROM golang:${VARIANT}
+`, string(buf))
+}
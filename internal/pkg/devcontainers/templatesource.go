@@ -0,0 +1,275 @@
+package devcontainers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/config"
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/git"
+)
+
+// templateCacheFolder returns the folder that git template sources are cloned into (replaced in tests)
+var templateCacheFolder = config.GetTemplateCacheFolder
+
+// templatePinsFilename is the name of the file (in the project root) that records the commit used for each git template source
+const templatePinsFilename = ".devcontainer-templates.json"
+
+// gitTemplateSource is a templatePaths entry that refers to a git repo
+// Entries use the form <url>[#<ref>[:<subfolder>]], e.g. https://github.com/org/repo.git#main:containers
+type gitTemplateSource struct {
+	// Entry is the templatePaths entry (used as the key when pinning)
+	Entry     string
+	URL       string
+	Ref       string
+	Subfolder string
+}
+
+var gitURLPrefixes = []string{"https://", "http://", "ssh://", "git://", "file://", "git@"}
+
+// parseGitTemplateSource parses entry as a git template source. Returns false if entry isn't a git URL
+func parseGitTemplateSource(entry string) (*gitTemplateSource, bool) {
+	isGitURL := false
+	for _, prefix := range gitURLPrefixes {
+		isGitURL = isGitURL || strings.HasPrefix(entry, prefix)
+	}
+	if !isGitURL {
+		return nil, false
+	}
+
+	source := &gitTemplateSource{Entry: entry, URL: entry}
+	if i := strings.LastIndex(entry, "#"); i >= 0 {
+		source.URL = entry[:i]
+		parts := strings.SplitN(entry[i+1:], ":", 2)
+		source.Ref = parts[0]
+		if len(parts) > 1 {
+			source.Subfolder = strings.Trim(parts[1], "/")
+		}
+	}
+	// allow credentials etc to be passed via env vars without them being recorded in the pins file
+	source.URL = os.ExpandEnv(source.URL)
+	return source, true
+}
+
+var invalidCacheNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// getCacheFolder returns the folder that the repo is cloned into
+// The same URL with different refs is cloned into separate folders as the checked out commit differs
+func (s *gitTemplateSource) getCacheFolder() string {
+	hash := sha256.Sum256([]byte(s.URL + "#" + s.Ref))
+	name := path.Base(strings.TrimSuffix(strings.TrimSuffix(s.URL, "/"), ".git"))
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		name = name[i+1:] // handle git@host:repo
+	}
+	name = invalidCacheNameChars.ReplaceAllString(name, "_")
+	return filepath.Join(templateCacheFolder(), fmt.Sprintf("%s-%s", name, hex.EncodeToString(hash[:])[:12]))
+}
+
+// ensureCloned clones the repo into the cache folder if it hasn't already been cloned
+func (s *gitTemplateSource) ensureCloned() error {
+	cacheFolder := s.getCacheFolder()
+	if _, err := os.Stat(filepath.Join(cacheFolder, ".git")); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(cacheFolder), 0755); err != nil {
+		return fmt.Errorf("Error creating template cache folder: %s", err)
+	}
+	if err := git.Clone(s.URL, cacheFolder); err != nil {
+		_ = os.RemoveAll(cacheFolder)
+		return fmt.Errorf("Error cloning template source %q: %s", s.Entry, err)
+	}
+	return s.checkoutLatest()
+}
+
+// checkoutLatest checks out the latest fetched commit for Ref (or the default branch if Ref isn't set)
+func (s *gitTemplateSource) checkoutLatest() error {
+	cacheFolder := s.getCacheFolder()
+	target := "origin/HEAD"
+	if s.Ref != "" {
+		target = s.Ref
+		// prefer the remote branch so that updates are picked up for branch refs
+		if _, err := git.GetCommit(cacheFolder, "refs/remotes/origin/"+s.Ref); err == nil {
+			target = "origin/" + s.Ref
+		}
+	}
+	if err := git.Checkout(cacheFolder, target); err != nil {
+		return fmt.Errorf("Error checking out %q for template source %q: %s", target, s.Entry, err)
+	}
+	return nil
+}
+
+// getFolder returns the local template folder for the source at commit (or at the currently cached
+// commit if commit is empty), cloning the repo if needed. Also returns the commit for the folder
+func (s *gitTemplateSource) getFolder(commit string) (string, string, error) {
+	if err := s.ensureCloned(); err != nil {
+		return "", "", err
+	}
+	cacheFolder := s.getCacheFolder()
+	currentCommit, err := git.GetCommit(cacheFolder, "HEAD")
+	if err != nil {
+		return "", "", err
+	}
+
+	folder := cacheFolder
+	if commit == "" || commit == currentCommit {
+		commit = currentCommit
+	} else {
+		// pinned to a different commit - use a separate worktree per commit
+		folder, err = s.ensureCommitWorktree(commit)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return filepath.Join(folder, filepath.FromSlash(s.Subfolder)), commit, nil
+}
+
+func (s *gitTemplateSource) ensureCommitWorktree(commit string) (string, error) {
+	cacheFolder := s.getCacheFolder()
+	worktreeFolder := fmt.Sprintf("%s@%.12s", cacheFolder, commit)
+	if _, err := os.Stat(worktreeFolder); err == nil {
+		return worktreeFolder, nil
+	}
+	if _, err := git.GetCommit(cacheFolder, commit); err != nil {
+		// the pinned commit may be newer than the cache
+		if err = git.Fetch(cacheFolder); err != nil {
+			return "", fmt.Errorf("Error fetching template source %q: %s", s.Entry, err)
+		}
+	}
+	if err := git.AddWorktree(cacheFolder, worktreeFolder, commit); err != nil {
+		return "", fmt.Errorf("Error checking out commit %q for template source %q: %s", commit, s.Entry, err)
+	}
+	return worktreeFolder, nil
+}
+
+// TemplateSourceUpdate describes the result of updating a git template source
+type TemplateSourceUpdate struct {
+	Source string `json:"source"`
+	// OldCommit is empty if the source hadn't previously been cloned
+	OldCommit string `json:"oldCommit"`
+	NewCommit string `json:"newCommit"`
+}
+
+// UpdateTemplateSources fetches the latest commits for the git repos in templatePaths
+func UpdateTemplateSources() ([]TemplateSourceUpdate, error) {
+	return updateTemplateSources(configTemplateFolders())
+}
+
+func updateTemplateSources(folders []string) ([]TemplateSourceUpdate, error) {
+	updates := []TemplateSourceUpdate{}
+	for _, folder := range folders {
+		source, ok := parseGitTemplateSource(folder)
+		if !ok {
+			continue
+		}
+		cacheFolder := source.getCacheFolder()
+		oldCommit, _ := git.GetCommit(cacheFolder, "HEAD")
+		if oldCommit == "" {
+			if err := source.ensureCloned(); err != nil {
+				return nil, err
+			}
+		} else {
+			if err := git.Fetch(cacheFolder); err != nil {
+				return nil, fmt.Errorf("Error fetching template source %q: %s", source.Entry, err)
+			}
+			if err := source.checkoutLatest(); err != nil {
+				return nil, err
+			}
+		}
+		newCommit, err := git.GetCommit(cacheFolder, "HEAD")
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, TemplateSourceUpdate{Source: source.Entry, OldCommit: oldCommit, NewCommit: newCommit})
+	}
+	return updates, nil
+}
+
+// templatePins holds the commits that git template sources are pinned to for a project
+type templatePins struct {
+	path    string
+	Sources map[string]string `json:"sources"`
+}
+
+// getTemplatePinsPath returns the path to the pins file for projectFolder
+// The file is kept at the root of the git repo (if projectFolder is in one) so that it applies to the whole repo
+func getTemplatePinsPath(projectFolder string) (string, error) {
+	rootFolder, err := git.GetTopLevelPath(projectFolder)
+	if err != nil {
+		return "", err
+	}
+	if rootFolder == "" {
+		rootFolder = projectFolder
+	}
+	return filepath.Join(rootFolder, templatePinsFilename), nil
+}
+
+// loadTemplatePins loads the pins for projectFolder. Returns empty pins if there is no pins file
+func loadTemplatePins(projectFolder string) (*templatePins, error) {
+	pinsPath, err := getTemplatePinsPath(projectFolder)
+	if err != nil {
+		return nil, err
+	}
+	pins := &templatePins{path: pinsPath, Sources: map[string]string{}}
+	buf, err := ioutil.ReadFile(pinsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return pins, nil
+		}
+		return nil, fmt.Errorf("error reading file %q: %s", pinsPath, err)
+	}
+	if err = json.Unmarshal(buf, pins); err != nil {
+		return nil, fmt.Errorf("error parsing file %q: %s", pinsPath, err)
+	}
+	if pins.Sources == nil {
+		pins.Sources = map[string]string{}
+	}
+	return pins, nil
+}
+
+// getCommit returns the pinned commit for the source entry (or empty string if not pinned)
+func (p *templatePins) getCommit(entry string) string {
+	if p == nil {
+		return ""
+	}
+	return p.Sources[entry]
+}
+
+func (p *templatePins) save() error {
+	buf, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(p.path, append(buf, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing file %q: %s", p.path, err)
+	}
+	return nil
+}
+
+// PinTemplateSource records commit as the commit to use for the git template source in the project
+func PinTemplateSource(projectFolder string, source string, commit string) error {
+	pins, err := loadTemplatePins(projectFolder)
+	if err != nil {
+		return err
+	}
+	if pins.Sources[source] == commit {
+		return nil
+	}
+	pins.Sources[source] = commit
+	return pins.save()
+}
+
+// GetTemplatePins returns the pinned commits (keyed by template source) for the project
+func GetTemplatePins(projectFolder string) (map[string]string, error) {
+	pins, err := loadTemplatePins(projectFolder)
+	if err != nil {
+		return nil, err
+	}
+	return pins.Sources, nil
+}
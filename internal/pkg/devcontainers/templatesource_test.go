@@ -0,0 +1,190 @@
+package devcontainers
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// useTemplateCacheFolder sets the template cache folder for the test
+func useTemplateCacheFolder(t *testing.T, folder string) {
+	previous := templateCacheFolder
+	templateCacheFolder = func() string { return folder }
+	t.Cleanup(func() { templateCacheFolder = previous })
+}
+
+func runGitCommand(t *testing.T, path string, args ...string) string {
+	args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = path
+	buf, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %s\n%s", strings.Join(args, " "), err, buf)
+	}
+	return strings.TrimSpace(string(buf))
+}
+
+// createTemplateRepo creates a git repo with a template in the `containers` folder and returns the repo commit
+func createTemplateRepo(t *testing.T, repoFolder string, templateName string) string {
+	_ = os.MkdirAll(filepath.Join(repoFolder, "containers", templateName, ".devcontainer"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(repoFolder, "containers", templateName, ".devcontainer", "devcontainer.json"), []byte(`{"name": "`+templateName+`"}`), 0644)
+	if _, err := os.Stat(filepath.Join(repoFolder, ".git")); err != nil {
+		runGitCommand(t, repoFolder, "init", "--quiet")
+	}
+	runGitCommand(t, repoFolder, "add", "-A")
+	runGitCommand(t, repoFolder, "commit", "--quiet", "-m", "add "+templateName)
+	return runGitCommand(t, repoFolder, "rev-parse", "HEAD")
+}
+
+func TestParseGitTemplateSource(t *testing.T) {
+	tests := []struct {
+		entry     string
+		isGit     bool
+		url       string
+		ref       string
+		subfolder string
+	}{
+		{entry: "$HOME/source/templates", isGit: false},
+		{entry: "https://github.com/org/repo.git", isGit: true, url: "https://github.com/org/repo.git"},
+		{entry: "https://github.com/org/repo.git#v1.0", isGit: true, url: "https://github.com/org/repo.git", ref: "v1.0"},
+		{entry: "https://github.com/org/repo.git#main:containers/", isGit: true, url: "https://github.com/org/repo.git", ref: "main", subfolder: "containers"},
+		{entry: "git@github.com:org/repo.git#:containers", isGit: true, url: "git@github.com:org/repo.git", subfolder: "containers"},
+	}
+	for _, test := range tests {
+		source, isGit := parseGitTemplateSource(test.entry)
+		if !assert.Equal(t, test.isGit, isGit, test.entry) || !isGit {
+			continue
+		}
+		assert.Equal(t, test.entry, source.Entry)
+		assert.Equal(t, test.url, source.URL, test.entry)
+		assert.Equal(t, test.ref, source.Ref, test.entry)
+		assert.Equal(t, test.subfolder, source.Subfolder, test.entry)
+	}
+}
+
+func TestGetTemplatesFromFolders_ClonesGitSource(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	useTemplateCacheFolder(t, filepath.Join(root, "cache"))
+
+	repoFolder := filepath.Join(root, "repo")
+	commit := createTemplateRepo(t, repoFolder, "test1")
+	entry := "file://" + filepath.ToSlash(repoFolder) + "#:containers"
+
+	templates, err := getTemplatesFromFolders([]string{entry}, nil)
+	if !assert.NoError(t, err) || !assert.Len(t, templates, 1) {
+		return
+	}
+	assert.Equal(t, "test1", templates[0].Name)
+	assert.Equal(t, entry, templates[0].Source)
+	assert.Equal(t, commit, templates[0].Commit)
+	assert.True(t, strings.HasPrefix(templates[0].Path, filepath.Join(root, "cache")))
+	assert.FileExists(t, filepath.Join(templates[0].Path, "devcontainer.json"))
+}
+
+func TestGetTemplatesFromFolders_UsesPinnedCommit(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	useTemplateCacheFolder(t, filepath.Join(root, "cache"))
+
+	repoFolder := filepath.Join(root, "repo")
+	firstCommit := createTemplateRepo(t, repoFolder, "test1")
+	entry := "file://" + filepath.ToSlash(repoFolder) + "#:containers"
+
+	projectFolder := filepath.Join(root, "project")
+	_ = os.MkdirAll(projectFolder, 0755)
+	if !assert.NoError(t, PinTemplateSource(projectFolder, entry, firstCommit)) {
+		return
+	}
+
+	secondCommit := createTemplateRepo(t, repoFolder, "test2")
+	updates, err := updateTemplateSources([]string{entry})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []TemplateSourceUpdate{{Source: entry, OldCommit: "", NewCommit: secondCommit}}, updates)
+
+	// without pins the latest commit is used
+	templates, err := getTemplatesFromFolders([]string{entry}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, templates, 2)
+
+	// with pins the pinned commit is used
+	pins, err := loadTemplatePins(projectFolder)
+	if !assert.NoError(t, err) {
+		return
+	}
+	templates, err = getTemplatesFromFolders([]string{entry}, pins)
+	if !assert.NoError(t, err) || !assert.Len(t, templates, 1) {
+		return
+	}
+	assert.Equal(t, "test1", templates[0].Name)
+	assert.Equal(t, firstCommit, templates[0].Commit)
+	assert.FileExists(t, filepath.Join(templates[0].Path, "devcontainer.json"))
+}
+
+func TestUpdateTemplateSources_FetchesNewCommits(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	useTemplateCacheFolder(t, filepath.Join(root, "cache"))
+
+	repoFolder := filepath.Join(root, "repo")
+	firstCommit := createTemplateRepo(t, repoFolder, "test1")
+	entry := "file://" + filepath.ToSlash(repoFolder) + "#:containers"
+
+	_, err = updateTemplateSources([]string{entry, root})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	secondCommit := createTemplateRepo(t, repoFolder, "test2")
+	updates, err := updateTemplateSources([]string{entry, root})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []TemplateSourceUpdate{{Source: entry, OldCommit: firstCommit, NewCommit: secondCommit}}, updates)
+}
+
+func TestPinTemplateSource_WritesPinsToRepoRoot(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+
+	projectFolder := filepath.Join(root, "src", "service")
+	_ = os.MkdirAll(projectFolder, 0755)
+	runGitCommand(t, root, "init", "--quiet")
+
+	err = PinTemplateSource(projectFolder, "https://example.com/templates.git", "abc123")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	buf, err := ioutil.ReadFile(filepath.Join(root, templatePinsFilename))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, `{
+  "sources": {
+    "https://example.com/templates.git": "abc123"
+  }
+}
+`, string(buf))
+}
@@ -0,0 +1,340 @@
+package devcontainers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/diff"
+	ioutil2 "github.com/stuartleeks/devcontainer-cli/internal/pkg/ioutil"
+)
+
+// TemplateFileStatus describes how a file generated from a template differs from the current template
+type TemplateFileStatus string
+
+const (
+	// TemplateFileUnchanged is used for files that haven't changed locally or in the template
+	TemplateFileUnchanged TemplateFileStatus = "unchanged"
+	// TemplateFileModifiedLocally is used for files that have only been changed in the project
+	TemplateFileModifiedLocally TemplateFileStatus = "modified locally"
+	// TemplateFileModifiedUpstream is used for files that have only been changed in the template
+	TemplateFileModifiedUpstream TemplateFileStatus = "modified upstream"
+	// TemplateFileModifiedBoth is used for files that have been changed in the project and in the template
+	TemplateFileModifiedBoth TemplateFileStatus = "modified locally and upstream"
+	// TemplateFileAddedUpstream is used for files that have been added to the template
+	TemplateFileAddedUpstream TemplateFileStatus = "added upstream"
+	// TemplateFileRemovedUpstream is used for files that have been removed from the template
+	TemplateFileRemovedUpstream TemplateFileStatus = "removed upstream"
+	// TemplateFileDeletedLocally is used for files that have been deleted from the project
+	TemplateFileDeletedLocally TemplateFileStatus = "deleted locally"
+)
+
+// TemplateFileChange describes the differences between a project file and the template it was generated from
+type TemplateFileChange struct {
+	// Path is relative to the project folder
+	Path   string             `json:"path"`
+	Status TemplateFileStatus `json:"status"`
+	// Diff is a unified diff of the template changes for the file (empty if the template hasn't changed)
+	Diff string `json:"diff,omitempty"`
+	// Action is the change made by SyncTemplate (e.g. updated, merged)
+	Action string `json:"action,omitempty"`
+	// Conflict is set by SyncTemplate if the project and template changes couldn't be merged
+	Conflict bool `json:"conflict"`
+}
+
+// renderedFile is the content of a file generated from a template
+type renderedFile struct {
+	Content string
+	Mode    os.FileMode
+}
+
+// templateFileVersions holds the versions of a file generated from a template
+type templateFileVersions struct {
+	Path string
+	// LockHash is the hash of the file when it was generated (empty if it isn't in the lock file)
+	LockHash string
+	// Local, Base and Upstream are nil if the file doesn't exist (or the base content isn't available)
+	Local    *string
+	Base     *string
+	Upstream *renderedFile
+}
+
+// DiffTemplate compares the files generated from a template with the current template content
+// Unchanged files are not included
+func DiffTemplate(projectFolder string) ([]TemplateFileChange, error) {
+	_, _, versions, err := compareTemplateFiles(projectFolder)
+	if err != nil {
+		return nil, err
+	}
+	changes := []TemplateFileChange{}
+	for _, fileVersions := range versions {
+		change := fileVersions.getChange()
+		if change.Status != TemplateFileUnchanged {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// SyncTemplate updates the files generated from a template with the changes in the current template
+// Changes to files that have also been modified in the project are merged, and conflicting changes are
+// written with conflict markers. The files are only written if all changes can be applied.
+// Unchanged files are not included in the result
+func SyncTemplate(projectFolder string) ([]TemplateFileChange, error) {
+	absProjectFolder, newLock, versions, err := compareTemplateFiles(projectFolder)
+	if err != nil {
+		return nil, err
+	}
+	fs := ioutil2.NewOverlayFS()
+	changes := []TemplateFileChange{}
+	for _, fileVersions := range versions {
+		change := fileVersions.getChange()
+		if change.Status == TemplateFileUnchanged {
+			continue
+		}
+		if err = fileVersions.sync(fs, absProjectFolder, &change); err != nil {
+			return nil, err
+		}
+		if change.Status == TemplateFileRemovedUpstream && change.Conflict {
+			// keep the file in the lock so that the conflict is reported until it is resolved (e.g. by deleting the file)
+			newLock.Files[fileVersions.Path] = fileVersions.LockHash
+		}
+		changes = append(changes, change)
+	}
+	if err = newLock.save(fs, absProjectFolder); err != nil {
+		return nil, err
+	}
+	if err = fs.Commit(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// compareTemplateFiles loads the versions of the files generated from the template for projectFolder
+// Also returns the absolute project folder and the lock for the current template content
+func compareTemplateFiles(projectFolder string) (string, *templateLock, []templateFileVersions, error) {
+	projectFolder, err := filepath.Abs(projectFolder)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("Error handling path %q: %s", projectFolder, err)
+	}
+	lock, err := loadTemplateLock(projectFolder)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	template, err := GetTemplateByName(lock.Template, projectFolder)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if template == nil {
+		return "", nil, nil, fmt.Errorf("template %q not found", lock.Template)
+	}
+
+	// options may have been added to or removed from the template
+	values := map[string]string{}
+	for name, value := range lock.Options {
+		if _, ok := template.Options[name]; ok {
+			values[name] = value
+		}
+	}
+	options, err := template.ResolveOptionValues(values, nil)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	omitPaths := []string{}
+	for _, omitPath := range lock.OmitPaths {
+		if containsString(template.OptionalPaths, omitPath) {
+			omitPaths = append(omitPaths, omitPath)
+		}
+	}
+
+	upstreamFiles, err := renderTemplate(template, lock.DevcontainerName, options, omitPaths)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	newLock := &templateLock{
+		Template:         template.Name,
+		Source:           template.Source,
+		Commit:           template.Commit,
+		DevcontainerName: lock.DevcontainerName,
+		Options:          options,
+		OmitPaths:        omitPaths,
+		Files:            map[string]string{},
+	}
+	for path, file := range upstreamFiles {
+		newLock.Files[path] = hashContent([]byte(file.Content))
+	}
+
+	// The base content is only available if the template originally used can be generated again
+	// (the content is checked against the hashes in the lock file before it is used)
+	baseFiles := getTemplateBaseFiles(lock, template)
+
+	paths := []string{}
+	for path := range lock.Files {
+		paths = append(paths, path)
+	}
+	for path := range upstreamFiles {
+		if _, ok := lock.Files[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	versions := []templateFileVersions{}
+	for _, path := range paths {
+		fileVersions := templateFileVersions{Path: path, LockHash: lock.Files[path]}
+		if buf, err := ioutil.ReadFile(filepath.Join(projectFolder, filepath.FromSlash(path))); err == nil {
+			local := string(buf)
+			fileVersions.Local = &local
+		} else if !os.IsNotExist(err) {
+			return "", nil, nil, fmt.Errorf("error reading file %q: %s", path, err)
+		}
+		if base, ok := baseFiles[path]; ok && fileVersions.LockHash == hashContent([]byte(base.Content)) {
+			fileVersions.Base = &base.Content
+		}
+		if upstream, ok := upstreamFiles[path]; ok {
+			fileVersions.Upstream = &upstream
+		}
+		versions = append(versions, fileVersions)
+	}
+	return projectFolder, newLock, versions, nil
+}
+
+// getTemplateBaseFiles generates the files for the template at the commit in the lock file
+// Returns an empty map if the files can't be generated
+func getTemplateBaseFiles(lock *templateLock, template *DevcontainerTemplate) map[string]renderedFile {
+	baseTemplate := template
+	if lock.Source != "" && lock.Commit != template.Commit {
+		pins := &templatePins{Sources: map[string]string{lock.Source: lock.Commit}}
+		templates, err := getTemplatesFromFolders(configTemplateFolders(), pins)
+		if err != nil {
+			return map[string]renderedFile{}
+		}
+		baseTemplate = nil
+		for i := range templates {
+			if templates[i].Name == lock.Template && templates[i].Source == lock.Source {
+				baseTemplate = &templates[i]
+			}
+		}
+		if baseTemplate == nil {
+			return map[string]renderedFile{}
+		}
+	}
+	baseFiles, err := renderTemplate(baseTemplate, lock.DevcontainerName, lock.Options, lock.OmitPaths)
+	if err != nil {
+		return map[string]renderedFile{}
+	}
+	return baseFiles
+}
+
+// renderTemplate generates the template files in memory and returns the content keyed by relative path
+func renderTemplate(template *DevcontainerTemplate, devcontainerName string, options map[string]string, omitPaths []string) (map[string]renderedFile, error) {
+	// the files are generated for an empty folder that is never written to
+	tempFolder, err := ioutil.TempDir("", "devcontainer-template-*")
+	if err != nil {
+		return nil, fmt.Errorf("Error creating temp folder: %s", err)
+	}
+	defer os.RemoveAll(tempFolder)
+
+	fs := ioutil2.NewOverlayFS()
+	if _, err = generateTemplateFiles(fs, template, tempFolder, devcontainerName, options, omitPaths); err != nil {
+		return nil, err
+	}
+	result := map[string]renderedFile{}
+	for _, change := range fs.Changes() {
+		relativePath, err := filepath.Rel(tempFolder, change.Path)
+		if err != nil {
+			return nil, err
+		}
+		result[filepath.ToSlash(relativePath)] = renderedFile{Content: string(change.Content), Mode: change.Mode}
+	}
+	return result, nil
+}
+
+func (v *templateFileVersions) getChange() TemplateFileChange {
+	change := TemplateFileChange{Path: v.Path}
+	localPath := "a/" + v.Path
+	upstreamPath := "b/" + v.Path
+	switch {
+	case v.LockHash == "":
+		change.Status = TemplateFileAddedUpstream
+		change.Diff = diff.Unified("/dev/null", upstreamPath, "", v.Upstream.Content)
+	case v.Upstream == nil:
+		change.Status = TemplateFileRemovedUpstream
+		if v.Base != nil {
+			change.Diff = diff.Unified(localPath, "/dev/null", *v.Base, "")
+		}
+	default:
+		localModified := v.Local == nil || hashContent([]byte(*v.Local)) != v.LockHash
+		upstreamModified := hashContent([]byte(v.Upstream.Content)) != v.LockHash
+		switch {
+		case v.Local == nil:
+			change.Status = TemplateFileDeletedLocally
+		case localModified && upstreamModified:
+			change.Status = TemplateFileModifiedBoth
+		case localModified:
+			change.Status = TemplateFileModifiedLocally
+		case upstreamModified:
+			change.Status = TemplateFileModifiedUpstream
+		default:
+			change.Status = TemplateFileUnchanged
+		}
+		if upstreamModified {
+			// show the template changes if the original content is available, otherwise compare with the local file
+			from := v.Base
+			if from == nil {
+				from = v.Local
+			}
+			if from != nil {
+				change.Diff = diff.Unified(localPath, upstreamPath, *from, v.Upstream.Content)
+			}
+		}
+	}
+	return change
+}
+
+// sync applies the template changes for the file to the project and sets the Action and Conflict for change
+func (v *templateFileVersions) sync(fs *ioutil2.OverlayFS, projectFolder string, change *TemplateFileChange) error {
+	path := filepath.Join(projectFolder, filepath.FromSlash(v.Path))
+	switch change.Status {
+	case TemplateFileModifiedUpstream:
+		change.Action = "updated"
+		return fs.WriteFile(path, []byte(v.Upstream.Content), v.Upstream.Mode)
+	case TemplateFileModifiedBoth, TemplateFileAddedUpstream:
+		if v.Local == nil {
+			change.Action = "added"
+			return fs.WriteFile(path, []byte(v.Upstream.Content), v.Upstream.Mode)
+		}
+		if *v.Local == v.Upstream.Content {
+			return nil
+		}
+		// without the original content all differences are treated as conflicts
+		base := ""
+		if v.Base != nil {
+			base = *v.Base
+		}
+		merged, hasConflicts := diff.Merge(base, *v.Local, v.Upstream.Content, "local", "template")
+		change.Action = "merged"
+		change.Conflict = hasConflicts
+		return fs.WriteFile(path, []byte(merged), v.Upstream.Mode)
+	case TemplateFileRemovedUpstream:
+		if v.Local == nil {
+			return nil
+		}
+		if hashContent([]byte(*v.Local)) != v.LockHash {
+			// keep local changes
+			change.Conflict = true
+			return nil
+		}
+		change.Action = "removed"
+		if err := fs.Remove(path); err != nil {
+			return fmt.Errorf("error removing file %q: %s", path, err)
+		}
+	case TemplateFileDeletedLocally:
+		// leave deleted, but flag if there are template changes that haven't been applied
+		change.Conflict = change.Diff != ""
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package devcontainers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// useTemplateFolders sets the template folders used for the test
+func useTemplateFolders(t *testing.T, folders ...string) {
+	previous := configTemplateFolders
+	configTemplateFolders = func() []string { return folders }
+	t.Cleanup(func() { configTemplateFolders = previous })
+}
+
+func writeTestFile(t *testing.T, path string, content string) {
+	_ = os.MkdirAll(filepath.Dir(path), 0755)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeTestProject writes a dev container definition (.devcontainer/devcontainer.json and Dockerfile) to folder
+func writeTestProject(t *testing.T, folder string, devcontainerJSON string, dockerfile string) {
+	writeTestFile(t, filepath.Join(folder, ".devcontainer", "devcontainer.json"), devcontainerJSON)
+	writeTestFile(t, filepath.Join(folder, ".devcontainer", "Dockerfile"), dockerfile)
+}
+
+func readTestFile(t *testing.T, path string) string {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf)
+}
+
+const testSyncDockerfile = `FROM golang:1.20
+RUN echo one
+RUN echo two
+RUN echo three
+RUN echo four
+RUN echo five
+`
+
+// addTestTemplate adds the "test1" template from templateFolders to projectFolder
+func addTestTemplate(t *testing.T, projectFolder string) {
+	_ = os.MkdirAll(projectFolder, 0755)
+	template, err := GetTemplateByName("test1", projectFolder)
+	if err != nil || template == nil {
+		t.Fatalf("template not found: %v", err)
+	}
+	if err = CopyTemplateToFolder(template, projectFolder, "project", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAddTemplate_WritesLockFile(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	useTemplateFolders(t, filepath.Join(root, "templates"))
+
+	writeTestFile(t, filepath.Join(root, "templates", "test1", ".devcontainer", "devcontainer.json"), `{ "name": "test" }`)
+	writeTestFile(t, filepath.Join(root, "templates", "test1", ".devcontainer", "Dockerfile"), testSyncDockerfile)
+
+	projectFolder := filepath.Join(root, "project")
+	addTestTemplate(t, projectFolder)
+
+	lock, err := loadTemplateLock(projectFolder)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "test1", lock.Template)
+	assert.Equal(t, "project", lock.DevcontainerName)
+	assert.Equal(t, map[string]string{
+		".devcontainer/devcontainer.json": hashContent([]byte(`{ "name": "project" }`)),
+		".devcontainer/Dockerfile":        hashContent([]byte(testSyncDockerfile)),
+	}, lock.Files)
+}
+
+func TestDiffTemplate_NoLockFile(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+
+	_, err = DiffTemplate(root)
+	assert.Error(t, err)
+}
+
+func TestSyncTemplate_AppliesTemplateChanges(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	useTemplateFolders(t, filepath.Join(root, "templates"))
+
+	templateFolder := filepath.Join(root, "templates", "test1", ".devcontainer")
+	writeTestFile(t, filepath.Join(templateFolder, "devcontainer.json"), `{ "name": "test" }`)
+	writeTestFile(t, filepath.Join(templateFolder, "Dockerfile"), testSyncDockerfile)
+	writeTestFile(t, filepath.Join(templateFolder, "old.sh"), "echo old")
+
+	projectFolder := filepath.Join(root, "project")
+	addTestTemplate(t, projectFolder)
+
+	// local change to devcontainer.json, template changes to the Dockerfile and scripts
+	writeTestFile(t, filepath.Join(projectFolder, ".devcontainer", "devcontainer.json"), `{ "name": "local" }`)
+	writeTestFile(t, filepath.Join(templateFolder, "Dockerfile"), "FROM golang:1.21\n")
+	writeTestFile(t, filepath.Join(templateFolder, "new.sh"), "echo new")
+	_ = os.Remove(filepath.Join(templateFolder, "old.sh"))
+
+	changes, err := DiffTemplate(projectFolder)
+	if !assert.NoError(t, err) {
+		return
+	}
+	statuses := map[string]TemplateFileStatus{}
+	for _, change := range changes {
+		statuses[change.Path] = change.Status
+	}
+	assert.Equal(t, map[string]TemplateFileStatus{
+		".devcontainer/devcontainer.json": TemplateFileModifiedLocally,
+		".devcontainer/Dockerfile":        TemplateFileModifiedUpstream,
+		".devcontainer/new.sh":            TemplateFileAddedUpstream,
+		".devcontainer/old.sh":            TemplateFileRemovedUpstream,
+	}, statuses)
+
+	changes, err = SyncTemplate(projectFolder)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, change := range changes {
+		assert.False(t, change.Conflict, change.Path)
+	}
+	assert.Equal(t, `{ "name": "local" }`, readTestFile(t, filepath.Join(projectFolder, ".devcontainer", "devcontainer.json")))
+	assert.Equal(t, "FROM golang:1.21\n", readTestFile(t, filepath.Join(projectFolder, ".devcontainer", "Dockerfile")))
+	assert.Equal(t, "echo new", readTestFile(t, filepath.Join(projectFolder, ".devcontainer", "new.sh")))
+	assert.NoFileExists(t, filepath.Join(projectFolder, ".devcontainer", "old.sh"))
+
+	// after syncing only the local change remains
+	changes, err = DiffTemplate(projectFolder)
+	if !assert.NoError(t, err) || !assert.Len(t, changes, 1) {
+		return
+	}
+	assert.Equal(t, TemplateFileModifiedLocally, changes[0].Status)
+}
+
+func TestSyncTemplate_KeepsRemovedUpstreamConflictInLock(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	useTemplateFolders(t, filepath.Join(root, "templates"))
+
+	templateFolder := filepath.Join(root, "templates", "test1", ".devcontainer")
+	writeTestFile(t, filepath.Join(templateFolder, "devcontainer.json"), `{ "name": "test" }`)
+	writeTestFile(t, filepath.Join(templateFolder, "old.sh"), "echo old")
+
+	projectFolder := filepath.Join(root, "project")
+	addTestTemplate(t, projectFolder)
+
+	// old.sh is modified locally and removed from the template
+	writeTestFile(t, filepath.Join(projectFolder, ".devcontainer", "old.sh"), "echo local")
+	_ = os.Remove(filepath.Join(templateFolder, "old.sh"))
+
+	for i := 0; i < 2; i++ {
+		changes, err := SyncTemplate(projectFolder)
+		if !assert.NoError(t, err) || !assert.Len(t, changes, 1) {
+			return
+		}
+		assert.Equal(t, TemplateFileRemovedUpstream, changes[0].Status)
+		assert.True(t, changes[0].Conflict)
+		assert.Equal(t, "echo local", readTestFile(t, filepath.Join(projectFolder, ".devcontainer", "old.sh")))
+	}
+
+	// deleting the file resolves the conflict
+	_ = os.Remove(filepath.Join(projectFolder, ".devcontainer", "old.sh"))
+	if _, err = SyncTemplate(projectFolder); !assert.NoError(t, err) {
+		return
+	}
+	changes, err := DiffTemplate(projectFolder)
+	if assert.NoError(t, err) {
+		assert.Empty(t, changes)
+	}
+}
+
+func TestSyncTemplate_MergesChangesFromGitTemplate(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	useTemplateCacheFolder(t, filepath.Join(root, "cache"))
+
+	repoFolder := filepath.Join(root, "repo")
+	templateFolder := filepath.Join(repoFolder, "containers", "test1", ".devcontainer")
+	writeTestFile(t, filepath.Join(templateFolder, "Dockerfile"), testSyncDockerfile)
+	writeTestFile(t, filepath.Join(templateFolder, "install.sh"), "echo install\n")
+	createTemplateRepo(t, repoFolder, "test1")
+	entry := "file://" + filepath.ToSlash(repoFolder) + "#:containers"
+	useTemplateFolders(t, entry)
+
+	projectFolder := filepath.Join(root, "project")
+	addTestTemplate(t, projectFolder)
+
+	// non-conflicting changes to the Dockerfile and conflicting changes to install.sh
+	writeTestFile(t, filepath.Join(projectFolder, ".devcontainer", "Dockerfile"), "FROM golang:1.20\nRUN echo ONE\nRUN echo two\nRUN echo three\nRUN echo four\nRUN echo five\n")
+	writeTestFile(t, filepath.Join(projectFolder, ".devcontainer", "install.sh"), "echo local\n")
+	writeTestFile(t, filepath.Join(templateFolder, "Dockerfile"), "FROM golang:1.20\nRUN echo one\nRUN echo two\nRUN echo three\nRUN echo four\nRUN echo FIVE\n")
+	writeTestFile(t, filepath.Join(templateFolder, "install.sh"), "echo template\n")
+	createTemplateRepo(t, repoFolder, "test1")
+	if _, err = updateTemplateSources([]string{entry}); !assert.NoError(t, err) {
+		return
+	}
+
+	changes, err := DiffTemplate(projectFolder)
+	if !assert.NoError(t, err) || !assert.Len(t, changes, 2) {
+		return
+	}
+	assert.Equal(t, TemplateFileModifiedBoth, changes[0].Status)
+	assert.Equal(t, `--- a/.devcontainer/Dockerfile
++++ b/.devcontainer/Dockerfile
+@@ -3,4 +3,4 @@
+ RUN echo two
+ RUN echo three
+ RUN echo four
+-RUN echo five
++RUN echo FIVE
+`, changes[0].Diff)
+
+	changes, err = SyncTemplate(projectFolder)
+	if !assert.NoError(t, err) || !assert.Len(t, changes, 2) {
+		return
+	}
+	assert.Equal(t, ".devcontainer/Dockerfile", changes[0].Path)
+	assert.False(t, changes[0].Conflict)
+	assert.Equal(t, ".devcontainer/install.sh", changes[1].Path)
+	assert.True(t, changes[1].Conflict)
+
+	assert.Equal(t, "FROM golang:1.20\nRUN echo ONE\nRUN echo two\nRUN echo three\nRUN echo four\nRUN echo FIVE\n", readTestFile(t, filepath.Join(projectFolder, ".devcontainer", "Dockerfile")))
+	assert.Equal(t, "<<<<<<< local\necho local\n=======\necho template\n>>>>>>> template\n", readTestFile(t, filepath.Join(projectFolder, ".devcontainer", "install.sh")))
+
+	lock, err := loadTemplateLock(projectFolder)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, hashContent([]byte("echo template\n")), lock.Files[".devcontainer/install.sh"])
+}
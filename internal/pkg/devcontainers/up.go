@@ -0,0 +1,290 @@
+package devcontainers
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/stuartleeks/devcontainer-cli/internal/pkg/git"
+)
+
+const labelConfigFile = "devcontainer.config_file"
+
+// UpOptions controls how UpDevcontainer creates the dev container
+type UpOptions struct {
+	// Labels are additional labels to apply to the container
+	Labels map[string]string
+	// Output receives progress output (build output, lifecycle command output)
+	Output io.Writer
+}
+
+// UpDevcontainer builds (if needed) and starts the dev container for the specified folder.
+// The container is labelled in the same way as VS Code so that it can be found by ListDevcontainers.
+// Returns the ID of the running container
+func UpDevcontainer(folderPath string, options UpOptions) (string, error) {
+	if options.Output == nil {
+		options.Output = ioutil.Discard
+	}
+	absPath, err := filepath.Abs(folderPath)
+	if err != nil {
+		return "", fmt.Errorf("Error handling path %q: %s", folderPath, err)
+	}
+	devcontainerJSONPath, err := getDevContainerJsonPath(absPath)
+	if err != nil {
+		return "", err
+	}
+	config, err := LoadDevcontainerConfig(devcontainerJSONPath)
+	if err != nil {
+		return "", fmt.Errorf("Error loading devcontainer.json: %s", err)
+	}
+	if len(config.DockerComposeFile) > 0 {
+		return upComposeDevcontainer(absPath, devcontainerJSONPath, config, options)
+	}
+
+	runtime, err := GetContainerRuntime()
+	if err != nil {
+		return "", err
+	}
+	_, workspaceFolder, err := getWorkspaceMount(absPath, config)
+	if err != nil {
+		return "", err
+	}
+
+	// Reuse an existing container for the folder if there is one
+	containerID, running, err := findContainerForFolder(runtime, absPath)
+	if err != nil {
+		return "", err
+	}
+	if containerID != "" {
+		if running {
+			return containerID, nil
+		}
+		fmt.Fprintf(options.Output, "Starting existing container %s\n", containerID)
+		if err = runtime.StartContainer(containerID); err != nil {
+			return "", err
+		}
+		return containerID, runLifecycleCommands(runtime, containerID, config, workspaceFolder, options.Output, config.PostStartCommand)
+	}
+
+	image := config.Image
+	if dockerfilePath, contextPath := config.getDockerfilePath(devcontainerJSONPath); dockerfilePath != "" {
+		image = getImageNameForFolder(absPath)
+		buildOptions := BuildOptions{
+			ContextPath: contextPath,
+			Dockerfile:  dockerfilePath,
+			Tag:         image,
+			Output:      options.Output,
+		}
+		if config.Build != nil {
+			buildOptions.Args = config.Build.Args
+			buildOptions.Target = config.Build.Target
+		}
+		fmt.Fprintf(options.Output, "Building image %s\n", image)
+		if err = runtime.BuildImage(buildOptions); err != nil {
+			return "", err
+		}
+	}
+	if image == "" {
+		return "", fmt.Errorf("devcontainer.json must specify an image or a Dockerfile")
+	}
+
+	runOptions, err := getRunOptions(absPath, devcontainerJSONPath, config, image)
+	if err != nil {
+		return "", err
+	}
+	for name, value := range options.Labels {
+		if _, ok := runOptions.Labels[name]; !ok {
+			runOptions.Labels[name] = value
+		}
+	}
+	fmt.Fprintf(options.Output, "Starting container\n")
+	containerID, err = runtime.RunContainer(runOptions)
+	if err != nil {
+		return "", err
+	}
+
+	if err = runLifecycleCommands(runtime, containerID, config, workspaceFolder, options.Output, config.OnCreateCommand, config.PostCreateCommand, config.PostStartCommand); err != nil {
+		return containerID, err
+	}
+	return containerID, nil
+}
+
+// findContainerForFolder returns the ID of an existing dev container for the folder and whether it is running
+func findContainerForFolder(runtime ContainerRuntime, absPath string) (string, bool, error) {
+	for _, all := range []bool{false, true} {
+		containers, err := runtime.ListContainers(all)
+		if err != nil {
+			return "", false, err
+		}
+		for _, container := range containers {
+			if container.Labels[labelLocalFolder] == absPath {
+				return container.ID, !all, nil
+			}
+		}
+	}
+	return "", false, nil
+}
+
+var imageNameInvalidChars = regexp.MustCompile("[^a-z0-9._-]+")
+
+// getImageNameForFolder returns the image name to use when building the dev container for a folder
+// This follows the VS Code naming, e.g. vsc-<folder name>-<hash of folder path>
+func getImageNameForFolder(absPath string) string {
+	hash := md5.Sum([]byte(absPath))
+	name := imageNameInvalidChars.ReplaceAllString(strings.ToLower(filepath.Base(absPath)), "-")
+	return fmt.Sprintf("vsc-%s-%s", name, hex.EncodeToString(hash[:]))
+}
+
+// getWorkspaceMount returns the mount for the workspace and the path it is mounted at in the container
+func getWorkspaceMount(absPath string, config *DevcontainerConfig) (string, string, error) {
+	workspaceFolder := config.WorkspaceFolder
+	if config.WorkspaceMount != "" {
+		if workspaceFolder == "" {
+			return "", "", fmt.Errorf("workspaceFolder must be set when workspaceMount is set")
+		}
+		return config.WorkspaceMount, workspaceFolder, nil
+	}
+
+	// Mount the git repo root (if any) as VS Code does
+	mountSource, err := git.GetTopLevelPath(absPath)
+	if err != nil || mountSource == "" {
+		mountSource = absPath
+	}
+	mountTarget := "/workspaces/" + filepath.Base(mountSource)
+	if workspaceFolder == "" {
+		relativePath, err := filepath.Rel(mountSource, absPath)
+		if err != nil {
+			return "", "", err
+		}
+		workspaceFolder = filepath.ToSlash(filepath.Join(mountTarget, relativePath))
+	}
+	return fmt.Sprintf("type=bind,source=%s,target=%s,consistency=cached", mountSource, mountTarget), workspaceFolder, nil
+}
+
+func getRunOptions(absPath string, devcontainerJSONPath string, config *DevcontainerConfig, image string) (RunOptions, error) {
+	workspaceMount, _, err := getWorkspaceMount(absPath, config)
+	if err != nil {
+		return RunOptions{}, err
+	}
+
+	labels := map[string]string{
+		labelLocalFolder: absPath,
+		labelConfigFile:  devcontainerJSONPath,
+	}
+	if config.RemoteUser != "" {
+		metadata, err := json.Marshal([]map[string]string{{"remoteUser": config.RemoteUser}})
+		if err != nil {
+			return RunOptions{}, err
+		}
+		labels[labelMetadata] = string(metadata)
+	}
+
+	mounts := []string{workspaceMount}
+	for _, mount := range config.Mounts {
+		mounts = append(mounts, string(mount))
+	}
+
+	env := []string{}
+	for _, name := range sortedKeys(config.ContainerEnv) {
+		env = append(env, name+"="+config.ContainerEnv[name])
+	}
+
+	ports, err := getPublishedPorts(config)
+	if err != nil {
+		return RunOptions{}, err
+	}
+
+	runOptions := RunOptions{
+		Image:     image,
+		Labels:    labels,
+		Mounts:    mounts,
+		Env:       env,
+		Ports:     ports,
+		User:      config.ContainerUser,
+		ExtraArgs: config.RunArgs,
+	}
+	if config.OverrideCommand == nil || *config.OverrideCommand {
+		// Keep the container running regardless of the image command
+		runOptions.Entrypoint = "/bin/sh"
+		runOptions.Cmd = []string{"-c", "echo Container started; trap \"exit 0\" 15; while sleep 1 & wait $!; do :; done"}
+	}
+	return runOptions, nil
+}
+
+// getPublishedPorts converts forwardPorts and appPort to `--publish` values
+// forwardPorts entries for other hosts (e.g. "db:5432") are skipped
+func getPublishedPorts(config *DevcontainerConfig) ([]string, error) {
+	ports := []string{}
+	for _, port := range config.ForwardPorts {
+		switch value := port.(type) {
+		case float64:
+			ports = append(ports, fmt.Sprintf("127.0.0.1:%[1]d:%[1]d", int(value)))
+		case string:
+			if strings.Contains(value, ":") {
+				continue
+			}
+			ports = append(ports, fmt.Sprintf("127.0.0.1:%[1]s:%[1]s", value))
+		default:
+			return nil, fmt.Errorf("unsupported forwardPorts value: %v", port)
+		}
+	}
+
+	appPorts := []interface{}{}
+	switch value := config.AppPort.(type) {
+	case nil:
+	case []interface{}:
+		appPorts = value
+	default:
+		appPorts = append(appPorts, value)
+	}
+	for _, port := range appPorts {
+		switch value := port.(type) {
+		case float64:
+			ports = append(ports, fmt.Sprintf("%[1]d:%[1]d", int(value)))
+		case string:
+			ports = append(ports, value)
+		default:
+			return nil, fmt.Errorf("unsupported appPort value: %v", port)
+		}
+	}
+	return ports, nil
+}
+
+func runLifecycleCommands(runtime ContainerRuntime, containerID string, config *DevcontainerConfig, workspaceFolder string, output io.Writer, lifecycleCommands ...LifecycleCommand) error {
+	userName := config.RemoteUser
+	if userName == "" {
+		userName = config.ContainerUser
+	}
+	for _, lifecycleCommand := range lifecycleCommands {
+		for _, command := range lifecycleCommand {
+			fmt.Fprintf(output, "Running %s\n", strings.Join(command, " "))
+			err := runtime.Exec(containerID, ExecOptions{
+				Cmd:     command,
+				User:    userName,
+				WorkDir: workspaceFolder,
+				Stdout:  output,
+				Stderr:  output,
+			})
+			if err != nil {
+				return fmt.Errorf("lifecycle command failed: %s", err)
+			}
+		}
+	}
+	return nil
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
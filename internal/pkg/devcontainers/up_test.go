@@ -0,0 +1,93 @@
+package devcontainers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPublishedPorts(t *testing.T) {
+	config, err := parseDevcontainerConfig([]byte(`{
+		"forwardPorts": [3000, "8080", "db:5432"],
+		"appPort": [ 5000, "9000:9001" ]
+	}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	ports, err := getPublishedPorts(config)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"127.0.0.1:3000:3000", "127.0.0.1:8080:8080", "5000:5000", "9000:9001"}, ports)
+	}
+}
+
+func TestUpDevcontainer_BuildsAndRunsWithLabels(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+
+	projectFolder := filepath.Join(root, "project1")
+	_ = os.MkdirAll(filepath.Join(projectFolder, ".devcontainer"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(projectFolder, ".devcontainer", "devcontainer.json"), []byte(`{
+		"name": "project1",
+		"build": { "dockerfile": "Dockerfile", "args": { "VARIANT": "3" } },
+		"containerEnv": { "FOO": "bar" },
+		"remoteUser": "vscode",
+		"postCreateCommand": "make setup",
+	}`), 0644)
+
+	runtime := &fakeRuntime{}
+	useFakeRuntime(t, runtime)
+
+	containerID, err := UpDevcontainer(projectFolder, UpOptions{Labels: map[string]string{"extra": "label"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "container1", containerID)
+
+	if assert.Len(t, runtime.builds, 1) {
+		assert.Equal(t, filepath.Join(projectFolder, ".devcontainer", "Dockerfile"), runtime.builds[0].Dockerfile)
+		assert.Equal(t, map[string]string{"VARIANT": "3"}, runtime.builds[0].Args)
+	}
+	if assert.Len(t, runtime.runs, 1) {
+		run := runtime.runs[0]
+		assert.Equal(t, runtime.builds[0].Tag, run.Image)
+		assert.Equal(t, projectFolder, run.Labels[labelLocalFolder])
+		assert.Equal(t, "label", run.Labels["extra"])
+		assert.Equal(t, `[{"remoteUser":"vscode"}]`, run.Labels[labelMetadata])
+		assert.Equal(t, []string{"FOO=bar"}, run.Env)
+		assert.Equal(t, "type=bind,source="+projectFolder+",target=/workspaces/project1,consistency=cached", run.Mounts[0])
+	}
+	if assert.Len(t, runtime.execCalls, 1) {
+		assert.Equal(t, []string{"/bin/sh", "-c", "make setup"}, runtime.execCalls[0].Cmd)
+		assert.Equal(t, "vscode", runtime.execCalls[0].User)
+		assert.Equal(t, "/workspaces/project1", runtime.execCalls[0].WorkDir)
+	}
+}
+
+func TestUpDevcontainer_ReusesRunningContainer(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	_ = os.MkdirAll(filepath.Join(root, ".devcontainer"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(root, ".devcontainer", "devcontainer.json"), []byte(`{ "image": "ubuntu" }`), 0644)
+
+	runtime := &fakeRuntime{
+		containers: []ContainerDetails{
+			{Container: Container{ID: "existing", Labels: map[string]string{labelLocalFolder: root}}},
+		},
+	}
+	useFakeRuntime(t, runtime)
+
+	containerID, err := UpDevcontainer(root, UpOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "existing", containerID)
+		assert.Len(t, runtime.runs, 0)
+	}
+}
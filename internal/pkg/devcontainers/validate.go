@@ -0,0 +1,285 @@
+package devcontainers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ValidationSeverity indicates whether a ValidationIssue is an error or a warning
+type ValidationSeverity string
+
+const (
+	// ValidationError is used for issues that will stop the dev container from working
+	ValidationError ValidationSeverity = "error"
+	// ValidationWarning is used for issues that may cause unexpected behaviour
+	ValidationWarning ValidationSeverity = "warning"
+)
+
+// ValidationIssue is a problem found when validating a dev container definition
+// Line and Column are 1-based and are zero if the issue applies to the whole file
+type ValidationIssue struct {
+	File     string             `json:"file"`
+	Line     int                `json:"line"`
+	Column   int                `json:"column"`
+	Severity ValidationSeverity `json:"severity"`
+	Message  string             `json:"message"`
+}
+
+func (i ValidationIssue) String() string {
+	location := i.File
+	if i.Line > 0 {
+		location = fmt.Sprintf("%s:%d:%d", i.File, i.Line, i.Column)
+	}
+	return fmt.Sprintf("%s: %s: %s", location, i.Severity, i.Message)
+}
+
+// ValidateDevcontainer validates the devcontainer.json (and referenced files) for the dev container in folderPath
+func ValidateDevcontainer(folderPath string) ([]ValidationIssue, error) {
+	absPath, err := filepath.Abs(folderPath)
+	if err != nil {
+		return nil, fmt.Errorf("Error handling path %q: %s", folderPath, err)
+	}
+	devcontainerJSONPath, err := getDevContainerJsonPath(absPath)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := ioutil.ReadFile(devcontainerJSONPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %q: %s", devcontainerJSONPath, err)
+	}
+	return validateDevcontainerJSON(devcontainerJSONPath, buf)
+}
+
+func validateDevcontainerJSON(devcontainerJSONPath string, buf []byte) ([]ValidationIssue, error) {
+	v := &devcontainerValidator{path: devcontainerJSONPath, issues: []ValidationIssue{}}
+
+	document, err := parseJSONC(buf)
+	if err != nil {
+		if syntaxErr, ok := err.(*jsoncSyntaxError); ok {
+			v.issues = append(v.issues, ValidationIssue{File: devcontainerJSONPath, Line: syntaxErr.Line, Column: syntaxErr.Column, Severity: ValidationError, Message: syntaxErr.Message})
+			return v.issues, nil
+		}
+		return nil, err
+	}
+	v.document = document
+
+	schema, err := parseJSONSchema(devcontainerSchemaJSON)
+	if err != nil {
+		return nil, err
+	}
+	for _, violation := range schema.Validate(document) {
+		v.addIssue(violation.Offset, ValidationError, violation.Message)
+	}
+
+	v.variables, err = getDevcontainerVariables(devcontainerJSONPath, v.getString("workspaceFolder"))
+	if err != nil {
+		return nil, err
+	}
+	v.validateMounts()
+	v.validateFiles()
+	v.validateFeatures()
+	return v.issues, nil
+}
+
+type devcontainerValidator struct {
+	path      string
+	document  *jsoncDocument
+	variables *devcontainerVariables
+	issues    []ValidationIssue
+}
+
+func (v *devcontainerValidator) addIssue(offset int, severity ValidationSeverity, message string) {
+	line, column := getLineColumn(v.document.source, offset)
+	v.issues = append(v.issues, ValidationIssue{File: v.path, Line: line, Column: column, Severity: severity, Message: message})
+}
+
+// getStringNode returns the node at path if it is a string, along with its (substituted) value
+func (v *devcontainerValidator) getStringNode(path ...string) (*jsoncNode, string) {
+	node := v.document.Find(path...)
+	if node == nil || node.Kind != jsoncString {
+		return nil, ""
+	}
+	return node, v.getNodeString(node)
+}
+
+func (v *devcontainerValidator) getString(path ...string) string {
+	_, value := v.getStringNode(path...)
+	return value
+}
+
+func (v *devcontainerValidator) getNodeString(node *jsoncNode) string {
+	var value string
+	_ = json.Unmarshal(v.document.standardized[node.Start:node.End], &value)
+	if v.variables != nil {
+		value = v.variables.Substitute(value)
+	}
+	return value
+}
+
+func (v *devcontainerValidator) validateMounts() {
+	if node, value := v.getStringNode("workspaceMount"); node != nil && value != "" {
+		if err := validateMountString(value); err != nil {
+			v.addIssue(node.Start, ValidationError, fmt.Sprintf("workspaceMount: %s", err))
+		}
+	}
+	mounts := v.document.Find("mounts")
+	if mounts == nil || mounts.Kind != jsoncArray {
+		return
+	}
+	for i, item := range mounts.Items {
+		if item.Kind != jsoncString {
+			continue
+		}
+		if err := validateMountString(v.getNodeString(item)); err != nil {
+			v.addIssue(item.Start, ValidationError, fmt.Sprintf("mounts[%d]: %s", i, err))
+		}
+	}
+}
+
+// mountOptions are the options supported in `docker run --mount` strings (true if a value is required)
+var mountOptions = map[string]bool{
+	"type": true, "source": true, "src": true, "target": true, "destination": true, "dst": true,
+	"readonly": false, "ro": false, "consistency": true, "bind-propagation": true, "bind-nonrecursive": false,
+	"volume-driver": true, "volume-opt": true, "volume-nocopy": false, "tmpfs-size": true, "tmpfs-mode": true,
+	"external": false,
+}
+
+// validateMountString checks that mount is a valid `docker run --mount` value
+func validateMountString(mount string) error {
+	hasTarget := false
+	for _, option := range strings.Split(mount, ",") {
+		parts := strings.SplitN(option, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		requiresValue, ok := mountOptions[name]
+		if !ok {
+			return fmt.Errorf("invalid mount option %q in %q", name, mount)
+		}
+		if requiresValue && (len(parts) < 2 || parts[1] == "") {
+			return fmt.Errorf("mount option %q requires a value in %q", name, mount)
+		}
+		switch name {
+		case "type":
+			if parts[1] != "bind" && parts[1] != "volume" && parts[1] != "tmpfs" {
+				return fmt.Errorf("invalid mount type %q in %q (expected bind, volume or tmpfs)", parts[1], mount)
+			}
+		case "target", "destination", "dst":
+			hasTarget = true
+		}
+	}
+	if !hasTarget {
+		return fmt.Errorf("mount %q must specify a target", mount)
+	}
+	return nil
+}
+
+func (v *devcontainerValidator) validateFiles() {
+	configFolder := filepath.Dir(v.path)
+
+	dockerfileNode, dockerfile := v.getStringNode("build", "dockerfile")
+	if dockerfileNode == nil {
+		dockerfileNode, dockerfile = v.getStringNode("dockerFile")
+	}
+	if dockerfileNode != nil {
+		dockerfilePath := filepath.Join(configFolder, dockerfile)
+		if v.checkPathExists(dockerfileNode, dockerfilePath, false) {
+			v.validateDockerfile(dockerfilePath)
+		}
+	}
+
+	contextNode, context := v.getStringNode("build", "context")
+	if contextNode == nil {
+		contextNode, context = v.getStringNode("context")
+	}
+	if contextNode != nil {
+		v.checkPathExists(contextNode, filepath.Join(configFolder, context), true)
+	}
+
+	if composeNode := v.document.Find("dockerComposeFile"); composeNode != nil {
+		composeNodes := []*jsoncNode{composeNode}
+		if composeNode.Kind == jsoncArray {
+			composeNodes = composeNode.Items
+		}
+		for _, node := range composeNodes {
+			if node.Kind == jsoncString {
+				v.checkPathExists(node, filepath.Join(configFolder, v.getNodeString(node)), false)
+			}
+		}
+	}
+}
+
+// checkPathExists adds an issue if path doesn't exist (or isn't the expected type). Returns true if path is valid
+func (v *devcontainerValidator) checkPathExists(node *jsoncNode, path string, isDir bool) bool {
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		v.addIssue(node.Start, ValidationError, fmt.Sprintf("%q not found", path))
+		return false
+	case isDir && !info.IsDir():
+		v.addIssue(node.Start, ValidationError, fmt.Sprintf("%q is not a folder", path))
+		return false
+	case !isDir && info.IsDir():
+		v.addIssue(node.Start, ValidationError, fmt.Sprintf("%q is a folder", path))
+		return false
+	}
+	return true
+}
+
+func (v *devcontainerValidator) validateDockerfile(dockerfilePath string) {
+	buf, err := ioutil.ReadFile(dockerfilePath)
+	if err != nil {
+		v.issues = append(v.issues, ValidationIssue{File: dockerfilePath, Severity: ValidationError, Message: fmt.Sprintf("error reading file: %s", err)})
+		return
+	}
+	if !strings.Contains(string(buf), dockerfileSnippetInsertMarker) {
+		v.issues = append(v.issues, ValidationIssue{
+			File:     dockerfilePath,
+			Severity: ValidationWarning,
+			Message:  fmt.Sprintf("snippet insertion marker %s not found (snippets will be added to the end of the Dockerfile)", dockerfileSnippetInsertMarker),
+		})
+	}
+}
+
+// validateFeatures checks that local features exist and that feature options are valid
+// Features that can't be found in the feature folders are skipped
+func (v *devcontainerValidator) validateFeatures() {
+	featuresNode := v.document.Find("features")
+	if featuresNode == nil || featuresNode.Kind != jsoncObject {
+		return
+	}
+	configFolder := filepath.Dir(v.path)
+	refs := []string{}
+	for _, property := range featuresNode.Properties {
+		refs = append(refs, property.Name)
+	}
+	availableFeatures, err := getFeaturesForRefs(refs, configFeatureFolders())
+	if err != nil {
+		v.addIssue(featuresNode.Start, ValidationError, fmt.Sprintf("features: %s", err))
+		return
+	}
+	for _, property := range featuresNode.Properties {
+		feature, err := resolveFeature(property.Name, configFolder, availableFeatures)
+		if err != nil {
+			v.addIssue(property.Start, ValidationError, fmt.Sprintf("features: %s", err))
+			continue
+		}
+		if feature == nil {
+			if isLocalFeatureRef(property.Name) {
+				v.addIssue(property.Start, ValidationError, fmt.Sprintf("features: local feature %q not found", property.Name))
+			}
+			continue
+		}
+		var value interface{}
+		_ = json.Unmarshal(v.document.standardized[property.Value.Start:property.Value.End], &value)
+		options, err := getFeatureOptions(value, feature)
+		if err != nil {
+			continue // reported by the schema validation
+		}
+		for _, problem := range feature.ValidateOptions(options) {
+			v.addIssue(property.Value.Start, ValidationError, fmt.Sprintf("features.%s: %s", property.Name, problem))
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package devcontainers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createValidateTestFolder creates a project folder with the specified files (relative paths to content)
+func createValidateTestFolder(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(root) })
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		_ = os.MkdirAll(filepath.Dir(path), 0755)
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func getIssueMessages(issues []ValidationIssue) []string {
+	messages := []string{}
+	for _, issue := range issues {
+		messages = append(messages, issue.String())
+	}
+	return messages
+}
+
+func TestValidateDevcontainer_ValidDefinition(t *testing.T) {
+	root := createValidateTestFolder(t, map[string]string{
+		".devcontainer/devcontainer.json": `{
+	// comment
+	"name": "test",
+	"build": { "dockerfile": "Dockerfile", "context": ".." },
+	"forwardPorts": [8080, "db:5432"],
+	"mounts": [
+		"source=${localEnv:HOME}/.cache,target=/cache,type=bind,consistency=cached",
+		{ "type": "volume", "source": "data", "target": "/data" }
+	],
+	"remoteEnv": { "REMOVED": null },
+	"postCreateCommand": { "one": "echo hi", "two": ["echo", "hi"] },
+	"remoteUser": "vscode",
+}`,
+		".devcontainer/Dockerfile": "FROM ubuntu\n# __DEVCONTAINER_SNIPPET_INSERT__\n",
+	})
+
+	issues, err := ValidateDevcontainer(root)
+	if assert.NoError(t, err) {
+		assert.Empty(t, getIssueMessages(issues))
+	}
+}
+
+func TestValidateDevcontainer_ReportsSchemaErrorsWithLocation(t *testing.T) {
+	root := createValidateTestFolder(t, map[string]string{
+		".devcontainer/devcontainer.json": `{
+	"name": "test",
+	"image": "ubuntu",
+	"remoteuser": "vscode",
+	"forwardports": [8080],
+	"overrideCommand": "yes",
+	"userEnvProbe": "login",
+	"build": { "dockerFile": "Dockerfile" }
+}`,
+	})
+	jsonPath := filepath.Join(root, ".devcontainer", "devcontainer.json")
+
+	issues, err := ValidateDevcontainer(root)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			jsonPath + `:4:2: error: property "remoteuser" is not allowed (did you mean "remoteUser"?)`,
+			jsonPath + `:5:2: error: property "forwardports" is not allowed (did you mean "forwardPorts"?)`,
+			jsonPath + `:6:21: error: overrideCommand: expected boolean, got string`,
+			jsonPath + `:7:18: error: userEnvProbe: value must be one of "none", "loginShell", "interactiveShell", "loginInteractiveShell"`,
+			jsonPath + `:8:13: error: build: property "dockerFile" is not allowed (did you mean "dockerfile"?)`,
+		}, getIssueMessages(issues))
+	}
+}
+
+func TestValidateDevcontainer_ReportsSyntaxErrors(t *testing.T) {
+	root := createValidateTestFolder(t, map[string]string{
+		".devcontainer/devcontainer.json": "{\n\t\"name\": \"test\"\n\t\"image\": \"ubuntu\"\n}",
+	})
+
+	issues, err := ValidateDevcontainer(root)
+	if assert.NoError(t, err) && assert.Len(t, issues, 1) {
+		assert.Equal(t, 3, issues[0].Line)
+		assert.Equal(t, 2, issues[0].Column)
+		assert.Equal(t, ValidationError, issues[0].Severity)
+	}
+}
+
+func TestValidateDevcontainer_ReportsInvalidMounts(t *testing.T) {
+	root := createValidateTestFolder(t, map[string]string{
+		".devcontainer/devcontainer.json": `{
+	"image": "ubuntu",
+	"workspaceMount": "source=${localWorkspaceFolder},type=bind",
+	"workspaceFolder": "/workspace",
+	"mounts": [
+		"source=data,target=/data,type=volume",
+		"source=data,taget=/data,type=volume",
+		"source=data,target=/data,type=nfs",
+		{ "type": "bind", "source": "/tmp" }
+	]
+}`,
+	})
+	jsonPath := filepath.Join(root, ".devcontainer", "devcontainer.json")
+
+	issues, err := ValidateDevcontainer(root)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			jsonPath + `:9:3: error: mounts[3]: missing required property "target"`,
+			jsonPath + `:3:20: error: workspaceMount: mount "source=` + root + `,type=bind" must specify a target`,
+			jsonPath + `:7:3: error: mounts[1]: invalid mount option "taget" in "source=data,taget=/data,type=volume"`,
+			jsonPath + `:8:3: error: mounts[2]: invalid mount type "nfs" in "source=data,target=/data,type=nfs" (expected bind, volume or tmpfs)`,
+		}, getIssueMessages(issues))
+	}
+}
+
+func TestValidateDevcontainer_ReportsMissingFiles(t *testing.T) {
+	root := createValidateTestFolder(t, map[string]string{
+		".devcontainer/devcontainer.json": `{
+	"dockerFile": "Dockerfile",
+	"context": "missing-folder",
+	"dockerComposeFile": ["docker-compose.yml", "missing.yml"],
+	"service": "app"
+}`,
+		".devcontainer/docker-compose.yml": "version: '3'\n",
+	})
+	jsonPath := filepath.Join(root, ".devcontainer", "devcontainer.json")
+	configFolder := filepath.Dir(jsonPath)
+
+	issues, err := ValidateDevcontainer(root)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			jsonPath + `:2:16: error: "` + filepath.Join(configFolder, "Dockerfile") + `" not found`,
+			jsonPath + `:3:13: error: "` + filepath.Join(configFolder, "missing-folder") + `" not found`,
+			jsonPath + `:4:46: error: "` + filepath.Join(configFolder, "missing.yml") + `" not found`,
+		}, getIssueMessages(issues))
+	}
+}
+
+func TestValidateDevcontainer_WarnsForMissingSnippetMarker(t *testing.T) {
+	root := createValidateTestFolder(t, map[string]string{
+		".devcontainer/devcontainer.json": `{ "dockerFile": "Dockerfile" }`,
+		".devcontainer/Dockerfile":        "FROM ubuntu\n",
+	})
+
+	issues, err := ValidateDevcontainer(root)
+	if assert.NoError(t, err) && assert.Len(t, issues, 1) {
+		assert.Equal(t, ValidationWarning, issues[0].Severity)
+		assert.Equal(t, filepath.Join(root, ".devcontainer", "Dockerfile"), issues[0].File)
+		assert.Equal(t, 0, issues[0].Line)
+	}
+}
+
+func TestGetPropertySuggestion(t *testing.T) {
+	properties := map[string]*jsonSchema{"remoteUser": {}, "remoteEnv": {}, "image": {}}
+	assert.Equal(t, "remoteUser", getPropertySuggestion("remoteuser", properties))
+	assert.Equal(t, "remoteUser", getPropertySuggestion("remoteUsr", properties))
+	assert.Equal(t, "image", getPropertySuggestion("imag", properties))
+	assert.Equal(t, "", getPropertySuggestion("somethingElse", properties))
+}
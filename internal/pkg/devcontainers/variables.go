@@ -0,0 +1,156 @@
+package devcontainers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// devcontainerVariables holds the values used to resolve ${...} variables in devcontainer.json
+// See https://containers.dev/implementors/json_reference/#variables-in-devcontainerjson
+type devcontainerVariables struct {
+	LocalWorkspaceFolder     string
+	ContainerWorkspaceFolder string
+	DevcontainerID           string
+	LocalEnv                 func(string) (string, bool)
+	// ContainerEnv is nil when the container environment isn't available (e.g. when loading devcontainer.json
+	// before the container is created). In that case ${containerEnv:VAR} is left in place to be resolved later
+	ContainerEnv map[string]string
+
+	// getDefaultContainerWorkspaceFolder is used to resolve ContainerWorkspaceFolder when it is first needed
+	// (if not set) as the default workspace folder depends on the git repo for the local folder
+	getDefaultContainerWorkspaceFolder func() (string, error)
+}
+
+var variableRegex = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// getDevcontainerVariables returns the variables for the devcontainer.json at devcontainerJSONPath
+// ${containerWorkspaceFolder} is resolved using workspaceFolder (or the default folder if that is empty)
+func getDevcontainerVariables(devcontainerJSONPath string, workspaceFolder string) (*devcontainerVariables, error) {
+	absPath, err := filepath.Abs(devcontainerJSONPath)
+	if err != nil {
+		return nil, err
+	}
+	localFolder := getLocalFolderForConfigFile(absPath)
+	variables := &devcontainerVariables{
+		LocalWorkspaceFolder: localFolder,
+		DevcontainerID:       getDevcontainerID(localFolder, absPath),
+		LocalEnv:             os.LookupEnv,
+	}
+
+	// workspaceFolder can itself use the local variables (e.g. /workspaces/${localWorkspaceFolderBasename})
+	variables.ContainerWorkspaceFolder = variables.Substitute(workspaceFolder)
+	variables.getDefaultContainerWorkspaceFolder = func() (string, error) {
+		defaultFolder, err := getDefaultWorkspaceFolderForPath(localFolder)
+		if err != nil {
+			return "", err
+		}
+		return "/workspaces/" + filepath.ToSlash(defaultFolder), nil
+	}
+	return variables, nil
+}
+
+// getContainerWorkspaceFolder returns ContainerWorkspaceFolder, resolving the default if not set
+func (v *devcontainerVariables) getContainerWorkspaceFolder() string {
+	if v.ContainerWorkspaceFolder == "" && v.getDefaultContainerWorkspaceFolder != nil {
+		if folder, err := v.getDefaultContainerWorkspaceFolder(); err == nil {
+			v.ContainerWorkspaceFolder = folder
+		}
+	}
+	return v.ContainerWorkspaceFolder
+}
+
+// getLocalFolderForConfigFile returns the project folder for a devcontainer.json path
+// i.e. the parent of .devcontainer/devcontainer.json or the folder containing .devcontainer.json
+func getLocalFolderForConfigFile(devcontainerJSONPath string) string {
+	folder := filepath.Dir(devcontainerJSONPath)
+	if filepath.Base(folder) == ".devcontainer" {
+		return filepath.Dir(folder)
+	}
+	return folder
+}
+
+// getDevcontainerID returns the ${devcontainerId} value for the labels that identify the dev container
+// This follows the reference implementation: the sha256 of the JSON labels (with sorted keys)
+// formatted in base 32 and padded to 52 characters
+func getDevcontainerID(localFolder string, configFile string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	// maps are encoded with sorted keys
+	_ = encoder.Encode(map[string]string{
+		labelLocalFolder: localFolder,
+		labelConfigFile:  configFile,
+	})
+	hash := sha256.Sum256(bytes.TrimRight(buf.Bytes(), "\n"))
+	id := new(big.Int).SetBytes(hash[:]).Text(32)
+	return strings.Repeat("0", 52-len(id)) + id
+}
+
+// Substitute replaces the variables in value. Unknown variables are left unchanged
+func (v *devcontainerVariables) Substitute(value string) string {
+	return variableRegex.ReplaceAllStringFunc(value, func(match string) string {
+		parts := strings.SplitN(match[2:len(match)-1], ":", 3)
+		switch parts[0] {
+		case "localWorkspaceFolder":
+			return v.LocalWorkspaceFolder
+		case "localWorkspaceFolderBasename":
+			return filepath.Base(v.LocalWorkspaceFolder)
+		case "containerWorkspaceFolder":
+			if folder := v.getContainerWorkspaceFolder(); folder != "" {
+				return folder
+			}
+		case "containerWorkspaceFolderBasename":
+			if folder := v.getContainerWorkspaceFolder(); folder != "" {
+				return path.Base(folder)
+			}
+		case "devcontainerId":
+			if v.DevcontainerID == "" {
+				return match
+			}
+			return v.DevcontainerID
+		case "localEnv", "env":
+			if len(parts) < 2 || v.LocalEnv == nil {
+				return match
+			}
+			result, _ := v.LocalEnv(parts[1])
+			return getVariableValueOrDefault(result, parts)
+		case "containerEnv":
+			if len(parts) < 2 || v.ContainerEnv == nil {
+				return match
+			}
+			return getVariableValueOrDefault(v.ContainerEnv[parts[1]], parts)
+		}
+		return match
+	})
+}
+
+func getVariableValueOrDefault(value string, parts []string) string {
+	if value == "" && len(parts) > 2 {
+		return parts[2]
+	}
+	return value
+}
+
+// substituteJSONValue applies Substitute to all string values in a decoded JSON value
+func (v *devcontainerVariables) substituteJSONValue(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case string:
+		return v.Substitute(typedValue)
+	case []interface{}:
+		for i, item := range typedValue {
+			typedValue[i] = v.substituteJSONValue(item)
+		}
+	case map[string]interface{}:
+		for name, item := range typedValue {
+			typedValue[name] = v.substituteJSONValue(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,96 @@
+package devcontainers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDevcontainerVariablesSubstitute_Env(t *testing.T) {
+	variables := &devcontainerVariables{
+		ContainerEnv: map[string]string{"PATH": "/usr/bin", "EMPTY": ""},
+		LocalEnv: func(name string) (string, bool) {
+			if name == "HOME" {
+				return "/home/me", true
+			}
+			return "", false
+		},
+	}
+
+	assert.Equal(t, "/usr/bin:/extra", variables.Substitute("${containerEnv:PATH}:/extra"))
+	assert.Equal(t, "/home/me/.config", variables.Substitute("${localEnv:HOME}/.config"))
+	assert.Equal(t, "/home/me", variables.Substitute("${env:HOME}"))
+	assert.Equal(t, "fallback", variables.Substitute("${localEnv:MISSING:fallback}"))
+	assert.Equal(t, "with:colon", variables.Substitute("${localEnv:MISSING:with:colon}"))
+	assert.Equal(t, "fallback", variables.Substitute("${containerEnv:EMPTY:fallback}"))
+	assert.Equal(t, "", variables.Substitute("${containerEnv:MISSING}"))
+	assert.Equal(t, "${unknown:VAR}", variables.Substitute("${unknown:VAR}"))
+}
+
+func TestDevcontainerVariablesSubstitute_LeavesContainerEnvWhenNotAvailable(t *testing.T) {
+	variables := &devcontainerVariables{}
+	assert.Equal(t, "${containerEnv:PATH}:/extra", variables.Substitute("${containerEnv:PATH}:/extra"))
+}
+
+func TestDevcontainerVariablesSubstitute_WorkspaceFolders(t *testing.T) {
+	variables, err := getDevcontainerVariables("/src/project1/.devcontainer/devcontainer.json", "/workspaces/${localWorkspaceFolderBasename}/src")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "/src/project1", variables.Substitute("${localWorkspaceFolder}"))
+	assert.Equal(t, "project1", variables.Substitute("${localWorkspaceFolderBasename}"))
+	assert.Equal(t, "/workspaces/project1/src", variables.Substitute("${containerWorkspaceFolder}"))
+	assert.Equal(t, "src", variables.Substitute("${containerWorkspaceFolderBasename}"))
+	assert.Len(t, variables.Substitute("${devcontainerId}"), 52)
+
+	variables, err = getDevcontainerVariables("/src/project2/.devcontainer.json", "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "/src/project2", variables.LocalWorkspaceFolder)
+	}
+}
+
+func TestGetDevcontainerID_IsStableAndUnique(t *testing.T) {
+	id1 := getDevcontainerID("/src/project1", "/src/project1/.devcontainer/devcontainer.json")
+	assert.Equal(t, id1, getDevcontainerID("/src/project1", "/src/project1/.devcontainer/devcontainer.json"))
+	assert.NotEqual(t, id1, getDevcontainerID("/src/project2", "/src/project2/.devcontainer/devcontainer.json"))
+	assert.Regexp(t, "^[0-9a-v]{52}$", id1)
+}
+
+func TestLoadDevcontainerConfig_ResolvesVariables(t *testing.T) {
+	root, err := ioutil.TempDir("", "devcontainer*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	projectFolder := filepath.Join(root, "project1")
+	_ = os.MkdirAll(filepath.Join(projectFolder, ".devcontainer"), 0755)
+	os.Setenv("DEVCONTAINERX_TEST_VAR", "test-value")
+	defer os.Unsetenv("DEVCONTAINERX_TEST_VAR")
+
+	_ = ioutil.WriteFile(filepath.Join(projectFolder, ".devcontainer", "devcontainer.json"), []byte(`{
+	"name": "${localWorkspaceFolderBasename}",
+	"workspaceFolder": "/workspaces/${localWorkspaceFolderBasename}",
+	"workspaceMount": "source=${localWorkspaceFolder},target=${containerWorkspaceFolder},type=bind",
+	"containerEnv": { "TEST_VAR": "${localEnv:DEVCONTAINERX_TEST_VAR}" },
+	"remoteEnv": { "PATH": "${containerEnv:PATH}:/extra" },
+	"mounts": [ "source=cache-${devcontainerId},target=/cache,type=volume" ]
+}`), 0644)
+
+	config, err := LoadDevcontainerConfig(filepath.Join(projectFolder, ".devcontainer", "devcontainer.json"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "project1", config.Name)
+	assert.Equal(t, "/workspaces/project1", config.WorkspaceFolder)
+	assert.Equal(t, "source="+projectFolder+",target=/workspaces/project1,type=bind", config.WorkspaceMount)
+	assert.Equal(t, "test-value", config.ContainerEnv["TEST_VAR"])
+	if assert.NotNil(t, config.RemoteEnv["PATH"]) {
+		assert.Equal(t, "${containerEnv:PATH}:/extra", *config.RemoteEnv["PATH"])
+	}
+	if assert.Len(t, config.Mounts, 1) {
+		assert.NotContains(t, string(config.Mounts[0]), "${devcontainerId}")
+	}
+}
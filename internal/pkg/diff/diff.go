@@ -0,0 +1,196 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around changes in unified diffs
+const contextLines = 3
+
+// OpKind is the type of an Op in an edit script
+type OpKind rune
+
+const (
+	// OpEqual is used for lines that are in both inputs
+	OpEqual OpKind = ' '
+	// OpDelete is used for lines that are only in the first input
+	OpDelete OpKind = '-'
+	// OpInsert is used for lines that are only in the second input
+	OpInsert OpKind = '+'
+)
+
+// Op is a line in an edit script
+type Op struct {
+	Kind OpKind
+	Line string
+}
+
+// SplitLines splits content into lines. Lines include the trailing newline (except for a final line without one)
+func SplitLines(content string) []string {
+	lines := []string{}
+	for content != "" {
+		i := strings.IndexByte(content, '\n')
+		if i < 0 {
+			lines = append(lines, content)
+			break
+		}
+		lines = append(lines, content[:i+1])
+		content = content[i+1:]
+	}
+	return lines
+}
+
+// getMatches returns the longest common subsequence of a and b as a map from indexes in a to indexes in b
+func getMatches(a []string, b []string) map[int]int {
+	// lengths[i][j] is the LCS length of a[i:] and b[j:]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			case lengths[i+1][j] >= lengths[i][j+1]:
+				lengths[i][j] = lengths[i+1][j]
+			default:
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	matches := map[int]int{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			matches[i] = j
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return matches
+}
+
+// Lines returns the edit script to convert a into b
+func Lines(a []string, b []string) []Op {
+	matches := getMatches(a, b)
+	ops := []Op{}
+	j := 0
+	for i, line := range a {
+		matchIndex, matched := matches[i]
+		if !matched {
+			ops = append(ops, Op{Kind: OpDelete, Line: line})
+			continue
+		}
+		for ; j < matchIndex; j++ {
+			ops = append(ops, Op{Kind: OpInsert, Line: b[j]})
+		}
+		ops = append(ops, Op{Kind: OpEqual, Line: line})
+		j++
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, Op{Kind: OpInsert, Line: b[j]})
+	}
+	return ops
+}
+
+// Unified returns a unified diff between from and to (or empty string if they are the same)
+func Unified(fromName string, toName string, from string, to string) string {
+	if from == to {
+		return ""
+	}
+	ops := Lines(SplitLines(from), SplitLines(to))
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "--- %s\n+++ %s\n", fromName, toName)
+
+	// fromLine and toLine are the (0-based) line numbers at ops[i] in from and to
+	fromLine, toLine := 0, 0
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == OpEqual {
+			i++
+			fromLine++
+			toLine++
+			continue
+		}
+
+		// start the hunk with up to contextLines of leading context
+		start := i
+		for start > 0 && i-start < contextLines && ops[start-1].Kind == OpEqual {
+			start--
+		}
+		// extend the hunk until there are more than 2*contextLines equal lines before the next change
+		end := i
+		for end < len(ops) {
+			if ops[end].Kind != OpEqual {
+				end++
+				continue
+			}
+			next := end
+			for next < len(ops) && ops[next].Kind == OpEqual {
+				next++
+			}
+			if next == len(ops) || next-end > 2*contextLines {
+				end += minInt(next-end, contextLines)
+				break
+			}
+			end = next
+		}
+
+		hunkFromStart, hunkToStart := fromLine-(i-start), toLine-(i-start)
+		fromCount, toCount := 0, 0
+		for _, op := range ops[start:end] {
+			if op.Kind != OpInsert {
+				fromCount++
+			}
+			if op.Kind != OpDelete {
+				toCount++
+			}
+		}
+		fmt.Fprintf(&builder, "@@ -%s +%s @@\n", formatRange(hunkFromStart, fromCount), formatRange(hunkToStart, toCount))
+		for _, op := range ops[start:end] {
+			builder.WriteRune(rune(op.Kind))
+			builder.WriteString(op.Line)
+			if !strings.HasSuffix(op.Line, "\n") {
+				builder.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		for _, op := range ops[i:end] {
+			if op.Kind != OpInsert {
+				fromLine++
+			}
+			if op.Kind != OpDelete {
+				toLine++
+			}
+		}
+		i = end
+	}
+	return builder.String()
+}
+
+// formatRange formats the line range for a hunk header (start is 0-based)
+func formatRange(start int, count int) string {
+	if count == 0 {
+		// empty ranges use the line before the hunk
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
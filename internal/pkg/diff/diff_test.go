@@ -0,0 +1,63 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitLines(t *testing.T) {
+	assert.Equal(t, []string{}, SplitLines(""))
+	assert.Equal(t, []string{"a\n", "b\n"}, SplitLines("a\nb\n"))
+	assert.Equal(t, []string{"a\n", "b"}, SplitLines("a\nb"))
+}
+
+func TestUnified_NoChanges(t *testing.T) {
+	assert.Equal(t, "", Unified("a", "b", "line1\nline2\n", "line1\nline2\n"))
+}
+
+func TestUnified_SingleHunk(t *testing.T) {
+	from := "1\n2\n3\n4\n5\n6\n7\n8\n"
+	to := "1\n2\n3\n4\nfive\n6\n7\n8\n"
+	assert.Equal(t, `--- a/file
++++ b/file
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+`, Unified("a/file", "b/file", from, to))
+}
+
+func TestUnified_MultipleHunks(t *testing.T) {
+	from := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	to := "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+	assert.Equal(t, `--- a
++++ b
+@@ -1,4 +1,4 @@
+-1
++one
+ 2
+ 3
+ 4
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`, Unified("a", "b", from, to))
+}
+
+func TestUnified_AddedFile(t *testing.T) {
+	assert.Equal(t, `--- /dev/null
++++ b
+@@ -0,0 +1,2 @@
++1
++2
+\ No newline at end of file
+`, Unified("/dev/null", "b", "", "1\n2"))
+}
@@ -0,0 +1,78 @@
+package diff
+
+import (
+	"strings"
+)
+
+// Merge performs a three-way merge of the changes from base to local and from base to other
+// Conflicting changes are written with conflict markers labelled with localName and otherName
+// Returns the merged content and true if there were conflicts
+func Merge(base string, local string, other string, localName string, otherName string) (string, bool) {
+	baseLines := SplitLines(base)
+	localLines := SplitLines(local)
+	otherLines := SplitLines(other)
+	localMatches := getMatches(baseLines, localLines)
+	otherMatches := getMatches(baseLines, otherLines)
+
+	var builder strings.Builder
+	hasConflicts := false
+	writeChunk := func(baseChunk []string, localChunk []string, otherChunk []string) {
+		switch {
+		case linesEqual(localChunk, baseChunk):
+			writeLines(&builder, otherChunk)
+		case linesEqual(otherChunk, baseChunk), linesEqual(localChunk, otherChunk):
+			writeLines(&builder, localChunk)
+		default:
+			hasConflicts = true
+			builder.WriteString("<<<<<<< " + localName + "\n")
+			writeLines(&builder, terminateLastLine(localChunk))
+			builder.WriteString("=======\n")
+			writeLines(&builder, terminateLastLine(otherChunk))
+			builder.WriteString(">>>>>>> " + otherName + "\n")
+		}
+	}
+
+	// walk through the base lines that are unchanged in both local and other, merging the chunks between them
+	i, j, k := 0, 0, 0
+	for b := range baseLines {
+		localIndex, inLocal := localMatches[b]
+		otherIndex, inOther := otherMatches[b]
+		if !inLocal || !inOther {
+			continue
+		}
+		writeChunk(baseLines[i:b], localLines[j:localIndex], otherLines[k:otherIndex])
+		builder.WriteString(baseLines[b])
+		i, j, k = b+1, localIndex+1, otherIndex+1
+	}
+	writeChunk(baseLines[i:], localLines[j:], otherLines[k:])
+
+	return builder.String(), hasConflicts
+}
+
+func linesEqual(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func writeLines(builder *strings.Builder, lines []string) {
+	for _, line := range lines {
+		builder.WriteString(line)
+	}
+}
+
+// terminateLastLine ensures that the last line ends with a newline (so that conflict markers start on a new line)
+func terminateLastLine(lines []string) []string {
+	if len(lines) == 0 || strings.HasSuffix(lines[len(lines)-1], "\n") {
+		return lines
+	}
+	result := append([]string{}, lines...)
+	result[len(result)-1] += "\n"
+	return result
+}
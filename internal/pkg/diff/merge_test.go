@@ -0,0 +1,49 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge_CombinesNonConflictingChanges(t *testing.T) {
+	base := "1\n2\n3\n4\n5\n"
+	local := "one\n2\n3\n4\n5\n"
+	other := "1\n2\n3\n4\nfive\n6\n"
+
+	merged, hasConflicts := Merge(base, local, other, "local", "template")
+	assert.False(t, hasConflicts)
+	assert.Equal(t, "one\n2\n3\n4\nfive\n6\n", merged)
+}
+
+func TestMerge_SameChangeInBoth(t *testing.T) {
+	base := "1\n2\n3\n"
+	local := "1\ntwo\n3\n"
+
+	merged, hasConflicts := Merge(base, local, local, "local", "template")
+	assert.False(t, hasConflicts)
+	assert.Equal(t, local, merged)
+}
+
+func TestMerge_MarksConflicts(t *testing.T) {
+	base := "1\n2\n3\n"
+	local := "1\nlocal\n3\n"
+	other := "1\nother\n3\n"
+
+	merged, hasConflicts := Merge(base, local, other, "local", "template")
+	assert.True(t, hasConflicts)
+	assert.Equal(t, `1
+<<<<<<< local
+local
+=======
+other
+>>>>>>> template
+3
+`, merged)
+}
+
+func TestMerge_ConflictWithoutTrailingNewline(t *testing.T) {
+	merged, hasConflicts := Merge("", "local", "other", "local", "template")
+	assert.True(t, hasConflicts)
+	assert.Equal(t, "<<<<<<< local\nlocal\n=======\nother\n>>>>>>> template\n", merged)
+}
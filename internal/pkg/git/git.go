@@ -1,6 +1,7 @@
 package git
 
 import (
+	"bytes"
 	"fmt"
 	"os/exec"
 	"strings"
@@ -28,3 +29,49 @@ func GetTopLevelPath(path string) (string, error) {
 	}
 	return strings.TrimSpace(string(buf)), nil
 }
+
+// Clone clones the repo at url into path
+func Clone(url string, path string) error {
+	_, err := runGit("", "clone", "--quiet", url, path)
+	return err
+}
+
+// Fetch fetches branches and tags from origin for the repo at path
+func Fetch(path string) error {
+	_, err := runGit(path, "fetch", "--quiet", "--force", "--tags", "origin")
+	return err
+}
+
+// Checkout checks out ref (as a detached HEAD) in the repo at path
+func Checkout(path string, ref string) error {
+	_, err := runGit(path, "checkout", "--quiet", "--detach", ref)
+	return err
+}
+
+// GetCommit returns the commit hash that ref resolves to in the repo at path
+func GetCommit(path string, ref string) (string, error) {
+	return runGit(path, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+}
+
+// AddWorktree checks out commit from the repo at path into worktreePath
+func AddWorktree(path string, worktreePath string, commit string) error {
+	_, err := runGit(path, "worktree", "add", "--quiet", "--detach", worktreePath, commit)
+	return err
+}
+
+func runGit(path string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = path
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	buf, err := cmd.Output()
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return "", fmt.Errorf("Error git %s: %s", args[0], message)
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
@@ -0,0 +1,267 @@
+package ioutil
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// OverlayFS records file changes in memory on top of the files on disk
+// Reads return the pending content for files that have been written, and Commit applies the changes to disk
+type OverlayFS struct {
+	files map[string]*overlayFile
+}
+
+type overlayFile struct {
+	content []byte
+	mode    os.FileMode
+	deleted bool
+}
+
+// OverlayChange is a pending change to a file in an OverlayFS
+type OverlayChange struct {
+	Path string
+	// Original is the content on disk (nil if the file doesn't exist)
+	Original []byte
+	// Content is the new content (nil if the file is removed)
+	Content []byte
+	Mode    os.FileMode
+}
+
+// NewOverlayFS creates an OverlayFS with no pending changes
+func NewOverlayFS() *OverlayFS {
+	return &OverlayFS{files: map[string]*overlayFile{}}
+}
+
+// ReadFile returns the content of the file at path
+func (o *OverlayFS) ReadFile(path string) ([]byte, error) {
+	if file, ok := o.files[filepath.Clean(path)]; ok {
+		if file.deleted {
+			return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+		}
+		return append([]byte{}, file.content...), nil
+	}
+	return ioutil.ReadFile(path)
+}
+
+// Exists returns true if there is a file or folder at path
+func (o *OverlayFS) Exists(path string) bool {
+	path = filepath.Clean(path)
+	if file, ok := o.files[path]; ok {
+		return !file.deleted
+	}
+	folderPrefix := path + string(filepath.Separator)
+	for filePath, file := range o.files {
+		if !file.deleted && strings.HasPrefix(filePath, folderPrefix) {
+			return true
+		}
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// WriteFile sets the content of the file at path
+// As with ioutil.WriteFile, perm is only used if the file doesn't already exist
+func (o *OverlayFS) WriteFile(path string, data []byte, perm os.FileMode) error {
+	path = filepath.Clean(path)
+	mode := perm
+	if file, ok := o.files[path]; ok && !file.deleted {
+		mode = file.mode
+	} else if info, err := os.Stat(path); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("cannot write file %q: path is a directory", path)
+		}
+		mode = info.Mode().Perm()
+	}
+	o.files[path] = &overlayFile{content: append([]byte{}, data...), mode: mode}
+	return nil
+}
+
+// CopyFile copies source to target (using the mode of source)
+func (o *OverlayFS) CopyFile(source string, target string) error {
+	buf, err := o.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if file, ok := o.files[filepath.Clean(source)]; ok {
+		mode = file.mode
+	} else if info, err := os.Stat(source); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if o.Exists(target) {
+		return fmt.Errorf("Target file %q already exists", target)
+	}
+	return o.WriteFile(target, buf, mode)
+}
+
+// Remove removes the file at path
+func (o *OverlayFS) Remove(path string) error {
+	if !o.Exists(path) {
+		return &os.PathError{Op: "remove", Path: path, Err: os.ErrNotExist}
+	}
+	o.files[filepath.Clean(path)] = &overlayFile{deleted: true}
+	return nil
+}
+
+// Changes returns the pending changes that differ from the files on disk (ordered by path)
+func (o *OverlayFS) Changes() []OverlayChange {
+	changes := []OverlayChange{}
+	for path, file := range o.files {
+		original, err := ioutil.ReadFile(path)
+		if err != nil {
+			original = nil
+		}
+		if file.deleted {
+			if original != nil {
+				changes = append(changes, OverlayChange{Path: path, Original: original})
+			}
+			continue
+		}
+		if original != nil && bytes.Equal(original, file.content) {
+			continue
+		}
+		changes = append(changes, OverlayChange{Path: path, Original: original, Content: file.content, Mode: file.mode})
+	}
+	sort.Slice(changes, func(i int, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// Commit writes the pending changes to disk
+// New content is written to temporary files first, and the files being replaced or removed are moved to backup
+// files until every change has been applied, so that a failure restores the existing files
+func (o *OverlayFS) Commit() error {
+	type pendingChange struct {
+		targetPath string
+		// tempPath is the file with the new content (empty if the file is removed)
+		tempPath string
+		// backupPath is the file the original is moved to (empty if there is no original)
+		backupPath string
+		applied    bool
+	}
+	pendingChanges := []*pendingChange{}
+	createdFolders := []string{}
+	rollback := func() {
+		for i := len(pendingChanges) - 1; i >= 0; i-- {
+			change := pendingChanges[i]
+			if change.applied && change.tempPath != "" {
+				_ = os.Remove(change.targetPath)
+			}
+			if change.backupPath != "" {
+				_ = os.Rename(change.backupPath, change.targetPath)
+			}
+			if change.tempPath != "" {
+				_ = os.Remove(change.tempPath)
+			}
+		}
+		for i := len(createdFolders) - 1; i >= 0; i-- {
+			_ = os.RemoveAll(createdFolders[i])
+		}
+	}
+
+	for _, change := range o.Changes() {
+		if change.Content == nil {
+			pendingChanges = append(pendingChanges, &pendingChange{targetPath: change.Path})
+			continue
+		}
+		targetPath := change.Path
+		if resolvedPath, err := filepath.EvalSymlinks(targetPath); err == nil {
+			// write through symlinks (e.g. for linked templates)
+			targetPath = resolvedPath
+		}
+		folder := filepath.Dir(targetPath)
+		if createdFolder := getMissingFolder(folder); createdFolder != "" {
+			if err := os.MkdirAll(folder, 0755); err != nil {
+				rollback()
+				return fmt.Errorf("Error creating directory '%s': %s", folder, err)
+			}
+			createdFolders = append(createdFolders, createdFolder)
+		}
+		tempPath, err := writeTempFile(folder, filepath.Base(targetPath), change.Content, change.Mode)
+		if err != nil {
+			rollback()
+			return fmt.Errorf("error writing file %q: %s", change.Path, err)
+		}
+		pendingChanges = append(pendingChanges, &pendingChange{targetPath: targetPath, tempPath: tempPath})
+	}
+
+	for _, change := range pendingChanges {
+		if _, err := os.Lstat(change.targetPath); err == nil {
+			backupPath, err := moveToBackupFile(change.targetPath)
+			if err != nil {
+				rollback()
+				return fmt.Errorf("error replacing file %q: %s", change.targetPath, err)
+			}
+			change.backupPath = backupPath
+		}
+		if change.tempPath != "" {
+			if err := os.Rename(change.tempPath, change.targetPath); err != nil {
+				rollback()
+				return fmt.Errorf("error writing file %q: %s", change.targetPath, err)
+			}
+		}
+		change.applied = true
+	}
+	for _, change := range pendingChanges {
+		if change.backupPath != "" {
+			// the changes have been applied so failing to remove a backup file isn't treated as an error
+			_ = os.Remove(change.backupPath)
+		}
+	}
+	o.files = map[string]*overlayFile{}
+	return nil
+}
+
+// moveToBackupFile moves the file at path to a backup file in the same folder and returns the backup path
+func moveToBackupFile(path string) (string, error) {
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".orig*")
+	if err != nil {
+		return "", err
+	}
+	backupPath := file.Name()
+	_ = file.Close()
+	if err = os.Rename(path, backupPath); err != nil {
+		_ = os.Remove(backupPath)
+		return "", err
+	}
+	return backupPath, nil
+}
+
+// getMissingFolder returns the top-most folder in path that doesn't exist (or empty string if path exists)
+func getMissingFolder(path string) string {
+	missingFolder := ""
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return missingFolder
+		}
+		missingFolder = path
+		parent := filepath.Dir(path)
+		if parent == path {
+			return missingFolder
+		}
+		path = parent
+	}
+}
+
+func writeTempFile(folder string, name string, content []byte, mode os.FileMode) (string, error) {
+	file, err := ioutil.TempFile(folder, "."+name+".tmp*")
+	if err != nil {
+		return "", err
+	}
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), mode)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
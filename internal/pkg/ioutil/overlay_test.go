@@ -0,0 +1,130 @@
+package ioutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverlayFS_ReadsPendingChanges(t *testing.T) {
+	root, err := ioutil.TempDir("", "overlay*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	existingPath := filepath.Join(root, "existing.txt")
+	_ = ioutil.WriteFile(existingPath, []byte("existing"), 0644)
+
+	fs := NewOverlayFS()
+	buf, err := fs.ReadFile(existingPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "existing", string(buf))
+
+	newPath := filepath.Join(root, "folder", "new.txt")
+	assert.False(t, fs.Exists(newPath))
+	assert.NoError(t, fs.WriteFile(newPath, []byte("new"), 0755))
+	assert.NoError(t, fs.WriteFile(existingPath, []byte("updated"), 0755))
+	assert.True(t, fs.Exists(newPath))
+	assert.True(t, fs.Exists(filepath.Join(root, "folder")))
+
+	buf, err = fs.ReadFile(existingPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "updated", string(buf))
+
+	// nothing is written until Commit
+	buf, _ = ioutil.ReadFile(existingPath)
+	assert.Equal(t, "existing", string(buf))
+	assert.NoFileExists(t, newPath)
+
+	assert.Equal(t, []OverlayChange{
+		{Path: existingPath, Original: []byte("existing"), Content: []byte("updated"), Mode: 0644},
+		{Path: newPath, Content: []byte("new"), Mode: 0755},
+	}, fs.Changes())
+}
+
+func TestOverlayFS_Commit(t *testing.T) {
+	root, err := ioutil.TempDir("", "overlay*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	existingPath := filepath.Join(root, "existing.txt")
+	removedPath := filepath.Join(root, "removed.txt")
+	_ = ioutil.WriteFile(existingPath, []byte("existing"), 0644)
+	_ = ioutil.WriteFile(removedPath, []byte("removed"), 0644)
+
+	fs := NewOverlayFS()
+	newPath := filepath.Join(root, "folder", "new.sh")
+	assert.NoError(t, fs.WriteFile(newPath, []byte("new"), 0755))
+	assert.NoError(t, fs.WriteFile(existingPath, []byte("updated"), 0755))
+	assert.NoError(t, fs.Remove(removedPath))
+	assert.False(t, fs.Exists(removedPath))
+	assert.Error(t, fs.Remove(filepath.Join(root, "missing.txt")))
+
+	if !assert.NoError(t, fs.Commit()) {
+		return
+	}
+	buf, _ := ioutil.ReadFile(existingPath)
+	assert.Equal(t, "updated", string(buf))
+	buf, _ = ioutil.ReadFile(newPath)
+	assert.Equal(t, "new", string(buf))
+	info, err := os.Stat(newPath)
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	}
+	assert.NoFileExists(t, removedPath)
+	assert.Empty(t, fs.Changes())
+}
+
+func TestOverlayFS_CommitFailureRestoresFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "overlay*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	firstPath := filepath.Join(root, "a.txt")
+	removedPath := filepath.Join(root, "b.txt")
+	failingPath := filepath.Join(root, "c.txt")
+	_ = ioutil.WriteFile(firstPath, []byte("original"), 0644)
+	_ = ioutil.WriteFile(removedPath, []byte("removed"), 0644)
+
+	fs := NewOverlayFS()
+	assert.NoError(t, fs.WriteFile(firstPath, []byte("updated"), 0644))
+	assert.NoError(t, fs.Remove(removedPath))
+	assert.NoError(t, fs.WriteFile(failingPath, []byte("new"), 0644))
+	// c.txt is committed last (changes are ordered by path) and can't be replaced once it is a folder
+	_ = os.MkdirAll(filepath.Join(failingPath, "child"), 0755)
+
+	assert.Error(t, fs.Commit())
+	buf, _ := ioutil.ReadFile(firstPath)
+	assert.Equal(t, "original", string(buf))
+	buf, _ = ioutil.ReadFile(removedPath)
+	assert.Equal(t, "removed", string(buf))
+	entries, err := ioutil.ReadDir(root)
+	if assert.NoError(t, err) {
+		names := []string{}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		// the temporary and backup files are removed
+		assert.Equal(t, []string{"a.txt", "b.txt", "c.txt"}, names)
+	}
+}
+
+func TestOverlayFS_CopyFileToExistingFile(t *testing.T) {
+	root, err := ioutil.TempDir("", "overlay*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(root)
+	sourcePath := filepath.Join(root, "source.txt")
+	_ = ioutil.WriteFile(sourcePath, []byte("source"), 0644)
+
+	fs := NewOverlayFS()
+	targetPath := filepath.Join(root, "target.txt")
+	assert.NoError(t, fs.CopyFile(sourcePath, targetPath))
+	assert.Error(t, fs.CopyFile(sourcePath, targetPath))
+}
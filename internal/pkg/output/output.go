@@ -0,0 +1,188 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Format is the output format for a command
+type Format string
+
+const (
+	// FormatDefault leaves the output to the command's default (e.g. a list of names)
+	FormatDefault Format = ""
+	// FormatTable outputs a table with the main columns
+	FormatTable Format = "table"
+	// FormatWide outputs a table including the wide columns
+	FormatWide Format = "wide"
+	// FormatJSON outputs indented JSON
+	FormatJSON Format = "json"
+	// FormatYAML outputs YAML (with the same field names as JSON)
+	FormatYAML Format = "yaml"
+	// FormatTemplate executes the Go text/template from Options.Template for each item
+	FormatTemplate Format = "template"
+)
+
+// Formats lists the supported values for --output
+var Formats = []Format{FormatTable, FormatWide, FormatJSON, FormatYAML, FormatTemplate}
+
+// Options holds the output settings (typically from the --output and --format flags)
+type Options struct {
+	Format   Format
+	Template string
+}
+
+// Column defines a column for table output
+type Column struct {
+	Header string
+	// Wide columns are only included for wide output
+	Wide  bool
+	Value func(item interface{}) string
+}
+
+// Resolve validates the options and returns the format to use, with a template implying FormatTemplate
+func (o Options) Resolve() (Format, error) {
+	format := Format(strings.ToLower(string(o.Format)))
+	if o.Template != "" {
+		if format != FormatDefault && format != FormatTemplate {
+			return "", fmt.Errorf("--format can only be used with --output template")
+		}
+		return FormatTemplate, nil
+	}
+	if format == FormatTemplate {
+		return "", fmt.Errorf("--output template requires --format")
+	}
+	if format == FormatDefault {
+		return FormatDefault, nil
+	}
+	for _, f := range Formats {
+		if format == f {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported output %q (supported values: %s)", o.Format, formatList())
+}
+
+// IsDefault returns true if no output format has been specified
+func (o Options) IsDefault() bool {
+	return o.Format == FormatDefault && o.Template == ""
+}
+
+func formatList() string {
+	values := []string{}
+	for _, f := range Formats {
+		values = append(values, string(f))
+	}
+	return strings.Join(values, ", ")
+}
+
+// Write writes data in the format specified by options
+// data is typically a slice, in which case table and template output are applied per item
+func Write(w io.Writer, options Options, data interface{}, columns []Column) error {
+	format, err := options.Resolve()
+	if err != nil {
+		return err
+	}
+	switch format {
+	case FormatJSON:
+		buf, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return fmt.Errorf("Error converting to JSON: %s", err)
+		}
+		_, err = fmt.Fprintf(w, "%s\n", buf)
+		return err
+	case FormatYAML:
+		return writeYAML(w, data)
+	case FormatTemplate:
+		return writeTemplate(w, options.Template, data)
+	case FormatWide:
+		return writeTable(w, data, columns, true)
+	default:
+		return writeTable(w, data, columns, false)
+	}
+}
+
+// writeYAML converts via JSON so that the field names match the JSON output
+func writeYAML(w io.Writer, data interface{}) error {
+	buf, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("Error converting to YAML: %s", err)
+	}
+	var value interface{}
+	if err = yaml.Unmarshal(buf, &value); err != nil {
+		return fmt.Errorf("Error converting to YAML: %s", err)
+	}
+	buf, err = yaml.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("Error converting to YAML: %s", err)
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+func writeTemplate(w io.Writer, text string, data interface{}) error {
+	tmpl, err := template.New("format").Parse(text)
+	if err != nil {
+		return fmt.Errorf("Error parsing format template: %s", err)
+	}
+	for _, item := range getItems(data) {
+		if err = tmpl.Execute(w, item); err != nil {
+			return fmt.Errorf("Error executing format template: %s", err)
+		}
+		if _, err = fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeTable(w io.Writer, data interface{}, columns []Column, wide bool) error {
+	selectedColumns := []Column{}
+	for _, column := range columns {
+		if wide || !column.Wide {
+			selectedColumns = append(selectedColumns, column)
+		}
+	}
+
+	tw := new(tabwriter.Writer)
+	// minwidth, tabwidth, padding, padchar, flags
+	tw.Init(w, 8, 8, 0, '\t', 0)
+
+	headers := []string{}
+	underlines := []string{}
+	for _, column := range selectedColumns {
+		headers = append(headers, column.Header)
+		underlines = append(underlines, strings.Repeat("-", len(column.Header)))
+	}
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	fmt.Fprintln(tw, strings.Join(underlines, "\t"))
+
+	for _, item := range getItems(data) {
+		values := []string{}
+		for _, column := range selectedColumns {
+			values = append(values, column.Value(item))
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	return tw.Flush()
+}
+
+// getItems returns the items for per-item output (the elements of a slice, otherwise data itself)
+func getItems(data interface{}) []interface{} {
+	value := reflect.ValueOf(data)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return []interface{}{data}
+	}
+	items := []interface{}{}
+	for i := 0; i < value.Len(); i++ {
+		items = append(items, value.Index(i).Interface())
+	}
+	return items
+}
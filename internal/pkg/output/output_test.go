@@ -0,0 +1,61 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testItem struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+var testItems = []testItem{{Name: "one", Path: "/a"}, {Name: "two", Path: "/b"}}
+
+var testColumns = []Column{
+	{Header: "NAME", Value: func(item interface{}) string { return item.(testItem).Name }},
+	{Header: "PATH", Wide: true, Value: func(item interface{}) string { return item.(testItem).Path }},
+}
+
+func TestWrite_Table(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, Options{Format: FormatTable}, testItems, testColumns)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "NAME\n----\none\ntwo\n", buf.String())
+	}
+}
+
+func TestWrite_Wide(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, Options{Format: FormatWide}, testItems, testColumns)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "NAME\tPATH\n----\t----\none\t/a\ntwo\t/b\n", buf.String())
+	}
+}
+
+func TestWrite_YAMLUsesJSONFieldNames(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, Options{Format: FormatYAML}, testItems, testColumns)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "- name: one\n  path: /a\n- name: two\n  path: /b\n", buf.String())
+	}
+}
+
+func TestWrite_TemplateIsAppliedPerItem(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, Options{Template: "{{.Name}}={{.Path}}"}, testItems, testColumns)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "one=/a\ntwo=/b\n", buf.String())
+	}
+}
+
+func TestOptionsResolve_Errors(t *testing.T) {
+	_, err := Options{Format: "xml"}.Resolve()
+	assert.Error(t, err)
+	_, err = Options{Format: FormatTemplate}.Resolve()
+	assert.Error(t, err)
+	_, err = Options{Format: FormatJSON, Template: "{{.Name}}"}.Resolve()
+	assert.Error(t, err)
+}
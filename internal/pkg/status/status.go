@@ -46,6 +46,11 @@ func getConfigPath() string {
 	return os.ExpandEnv(path)
 }
 
+// GetStatusFolder returns the folder containing the status file (other cached state is stored alongside it)
+func GetStatusFolder() string {
+	return getConfigPath()
+}
+
 func GetLastUpdateCheck() time.Time {
 	EnsureInitialised()
 	return viper.GetTime("lastUpdateCheck")
@@ -2,25 +2,43 @@ package terminal
 
 import (
 	"fmt"
+	"io"
 	"os"
 )
 
 // UpdatingStatusWriter overwrites messages on successive writes
 type UpdatingStatusWriter struct {
+	// Writer is where status messages are written (defaults to stdout)
+	Writer            io.Writer
 	lastMessageLength int
 }
 
 func (w *UpdatingStatusWriter) Printf(format string, a ...interface{}) {
+	writer := w.Writer
+	if writer == nil {
+		writer = os.Stdout
+	}
 	// format current message
 	currentMessage := fmt.Sprintf(format, a...)
 	// right-pad current message and prefix with carriage return to put at start of line
-	fmt.Printf("\r%-*s", w.lastMessageLength, currentMessage)
+	fmt.Fprintf(writer, "\r%-*s", w.lastMessageLength, currentMessage)
 
 	w.lastMessageLength = len(currentMessage)
 }
 
+// IsTTY returns true if stdout is a terminal
 func IsTTY() bool {
-	fi, err := os.Stdout.Stat()
+	return IsFileTTY(os.Stdout)
+}
+
+// IsStdinTTY returns true if stdin is a terminal
+func IsStdinTTY() bool {
+	return IsFileTTY(os.Stdin)
+}
+
+// IsFileTTY returns true if the file is a terminal (character device)
+func IsFileTTY(f *os.File) bool {
+	fi, err := f.Stat()
 	if err != nil {
 		return false
 	}